@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Defaults for the advisories in device details. The mold humidity is
+// fixed; the rest can be changed in the config.
+const (
+	moldHumidity            = 60.0 // % RH
+	defaultMoldMinutes      = 30
+	defaultVentilateCO2     = 1000 // ppm
+	defaultVentilateMinutes = 10
+)
+
+// Kinds of advisory.
+const (
+	advisoryMold      = "mold"
+	advisoryVentilate = "ventilate"
+)
+
+// AdvisoryRules are how long a condition has to last before it is
+// advised on.
+type AdvisoryRules struct {
+	MoldAfter      time.Duration // humidity above moldHumidity
+	VentilateCO2   float64       // ppm
+	VentilateAfter time.Duration // CO₂ above VentilateCO2
+}
+
+// Advisory is a condition that has lasted long enough to act on.
+type Advisory struct {
+	Kind  string
+	Limit float64       // the value the sensor stayed above
+	For   time.Duration // how long it has, as far as the history goes
+}
+
+// Advisories returns the conditions in h that have lasted at least as long
+// as r requires, up to its newest sample. They clear as soon as a sample
+// is back under the limit. Samples further apart than maxStep (missed
+// polls) end a condition, since nothing is known about the gap, and a
+// condition can't be seen to last longer than the history does.
+func Advisories(h History, r AdvisoryRules, maxStep time.Duration) []Advisory {
+	var out []Advisory
+	check := func(kind, key string, limit float64, after time.Duration) {
+		if d, ok := sustainedAbove(historySeries(h, key, maxStep), limit); ok && d >= after {
+			out = append(out, Advisory{Kind: kind, Limit: limit, For: d})
+		}
+	}
+	check(advisoryMold, "humid", moldHumidity, r.MoldAfter)
+	check(advisoryVentilate, "co2", r.VentilateCO2, r.VentilateAfter)
+	return out
+}
+
+// sustainedAbove returns how long the points have been above limit, from
+// the first point of the unbroken run ending at the newest point. ok is
+// false if the newest point isn't above limit.
+func sustainedAbove(points []chartPoint, limit float64) (d time.Duration, ok bool) {
+	if len(points) == 0 {
+		return 0, false
+	}
+	last := points[len(points)-1]
+	for i := len(points) - 1; i >= 0 && points[i].V > limit; i-- {
+		d, ok = last.T.Sub(points[i].T), true
+		if points[i].Gap {
+			break
+		}
+	}
+	return d, ok
+}
+
+// renderAdvisory renders a as a short colored line for device details.
+func renderAdvisory(a Advisory) string {
+	d := shortDuration(a.For)
+	switch a.Kind {
+	case advisoryMold:
+		return lipgloss.NewStyle().Foreground(theme.Poor).Render(
+			fmt.Sprintf("Mold risk: humidity above %g%% for %s", a.Limit, d))
+	default:
+		return lipgloss.NewStyle().Foreground(theme.Fair).Render(
+			fmt.Sprintf("Ventilate: CO₂ above %g ppm for %s", a.Limit, d))
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+var advisoryEpoch = time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+
+// advisoryStep is a sample taken minute minutes after advisoryEpoch.
+type advisoryStep struct {
+	minute     int
+	humid, co2 float64
+}
+
+// advisoryHistory is a history of steps, received at their times.
+func advisoryHistory(steps ...advisoryStep) History {
+	var h History
+	for _, st := range steps {
+		h.Samples = append(h.Samples, Sample{
+			Received: advisoryEpoch.Add(time.Duration(st.minute) * time.Minute),
+			Data:     &awair.SensorData{Temp: 22, Humid: st.humid, CO2: st.co2, VOC: 100, PM25: 3},
+		})
+	}
+	return h
+}
+
+// everyMinute is a step for each minute from first to last.
+func everyMinute(first, last int, humid, co2 float64) []advisoryStep {
+	var steps []advisoryStep
+	for m := first; m <= last; m++ {
+		steps = append(steps, advisoryStep{m, humid, co2})
+	}
+	return steps
+}
+
+func TestSustainedAbove(t *testing.T) {
+	point := func(minute int, v float64, gap bool) chartPoint {
+		return chartPoint{T: advisoryEpoch.Add(time.Duration(minute) * time.Minute), V: v, Gap: gap}
+	}
+	tests := []struct {
+		name   string
+		points []chartPoint
+		want   time.Duration
+		ok     bool
+	}{
+		{"no points", nil, 0, false},
+		{"one point above", []chartPoint{point(0, 1200, false)}, 0, true},
+		{"all above", []chartPoint{point(0, 1200, false), point(5, 1100, false), point(10, 1300, false)}, 10 * time.Minute, true},
+		{"newest back under", []chartPoint{point(0, 1200, false), point(5, 1200, false), point(10, 800, false)}, 0, false},
+		// At the limit isn't above it
+		{"newest at the limit", []chartPoint{point(0, 1200, false), point(5, 1000, false)}, 0, false},
+		{"dipped under", []chartPoint{point(0, 1200, false), point(5, 800, false), point(10, 1200, false), point(15, 1200, false)}, 5 * time.Minute, true},
+		// The run starts again at the point after the gap
+		{"gap", []chartPoint{point(0, 1200, false), point(5, 1200, false), point(30, 1200, true), point(35, 1200, false)}, 5 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		if d, ok := sustainedAbove(tt.points, 1000); d != tt.want || ok != tt.ok {
+			t.Errorf("%s: %v, %v; want %v, %v", tt.name, d, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestAdvisories(t *testing.T) {
+	rules := AdvisoryRules{MoldAfter: 30 * time.Minute, VentilateCO2: 1000, VentilateAfter: 10 * time.Minute}
+	tests := []struct {
+		name  string
+		steps []advisoryStep
+		want  []Advisory
+	}{
+		{"nothing", nil, nil},
+		{
+			name:  "CO₂ high for long enough",
+			steps: everyMinute(0, 10, 50, 1200),
+			want:  []Advisory{{advisoryVentilate, 1000, 10 * time.Minute}},
+		},
+		{
+			// It may have been high for longer, but the history can't say
+			name:  "too little history",
+			steps: everyMinute(0, 9, 50, 1200),
+		},
+		{
+			name:  "cleared",
+			steps: append(everyMinute(0, 20, 50, 1200), advisoryStep{21, 50, 800}),
+		},
+		{
+			name:  "high again after clearing",
+			steps: append(append(everyMinute(0, 20, 50, 1200), advisoryStep{21, 50, 800}), everyMinute(22, 32, 50, 1200)...),
+			want:  []Advisory{{advisoryVentilate, 1000, 10 * time.Minute}},
+		},
+		{
+			// Ten minutes of polls missed: the run starts over after them
+			name:  "gap",
+			steps: append(everyMinute(0, 10, 50, 1200), everyMinute(21, 25, 50, 1200)...),
+		},
+		{
+			name:  "both",
+			steps: everyMinute(0, 45, 65, 1200),
+			want: []Advisory{
+				{advisoryMold, moldHumidity, 45 * time.Minute},
+				{advisoryVentilate, 1000, 45 * time.Minute},
+			},
+		},
+		{
+			name:  "humidity at the mold limit",
+			steps: everyMinute(0, 45, moldHumidity, 800),
+		},
+	}
+	for _, tt := range tests {
+		got := Advisories(advisoryHistory(tt.steps...), rules, time.Minute)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// alertExecTimeout is how long an --alert-exec command may run before it
+// is killed.
+const alertExecTimeout = 30 * time.Second
+
+// alertExecOutputLines is how much of a failed command's output is logged.
+const alertExecOutputLines = 5
+
+// alertExecResultMsg reports a finished --alert-exec command.
+type alertExecResultMsg struct {
+	Event  alertEvent
+	Err    error
+	Output string // combined stdout and stderr
+}
+
+// shellCommand runs command with the platform's shell. args are the
+// command's $1, $2... and need no quoting; cmd on Windows has no such
+// thing, so there they are appended to the command line, quoted.
+func shellCommand(ctx context.Context, command string, args ...string) *exec.Cmd {
+	if goos == "windows" {
+		return exec.CommandContext(ctx, "cmd", append([]string{"/C", command}, args...)...)
+	}
+	return exec.CommandContext(ctx, "sh", append([]string{"-c", command, "sh"}, args...)...)
+}
+
+// alertEnv returns the AWAIR_* variables describing ev.
+func alertEnv(ev alertEvent) []string {
+	value := ""
+	if ev.Value != nil {
+		value = strconv.FormatFloat(*ev.Value, 'f', -1, 64)
+	}
+	return []string{
+		"AWAIR_EVENT=" + ev.Event,
+		"AWAIR_DEVICE=" + ev.Name,
+		"AWAIR_IP=" + ev.IP,
+		"AWAIR_UUID=" + ev.UUID,
+		"AWAIR_SENSOR=" + ev.Sensor,
+		"AWAIR_VALUE=" + value,
+		"AWAIR_FORMATTED=" + ev.Formatted,
+		"AWAIR_SEVERITY=" + ev.Severity,
+		"AWAIR_RATING=" + ev.Rating,
+		"AWAIR_PREVIOUS_RATING=" + ev.PreviousRating,
+	}
+}
+
+// alertExecCmd runs command in the background; see runAlertExec.
+func alertExecCmd(command string, t alertTemplates, ev alertEvent) tea.Cmd {
+	return trackCmd("alert command "+ev.Sensor+" "+ev.IP, func() tea.Msg {
+		out, err := runAlertExec(command, t, ev)
+		return alertExecResultMsg{Event: ev, Err: err, Output: out}
+	})
+}
+
+// runAlertExec runs command with ev in its environment and t's argument
+// templates executed with ev as its arguments, killing it after
+// alertExecTimeout. It returns the command's combined output.
+func runAlertExec(command string, t alertTemplates, ev alertEvent) (string, error) {
+	args, err := t.execArgs(ev)
+	if err != nil {
+		return "", fmt.Errorf("alert_exec_args: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertExecTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command, args...)
+	cmd.Env = append(os.Environ(), alertEnv(ev)...)
+	// Don't wait forever on children that keep the output open
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("killed after %s", alertExecTimeout)
+	}
+	return string(out), err
+}
+
+// handleAlertExecResult logs a failed command with the end of its output.
+// Successful runs only go to the log file.
+func (m *model) handleAlertExecResult(msg alertExecResultMsg) {
+	what := fmt.Sprintf("%s %s %s", msg.Event.Name, msg.Event.Label, msg.Event.Rating)
+	if msg.Err == nil {
+		logf(levelDebug, "alert command for %s succeeded", what)
+		return
+	}
+	m.logAt(levelWarn, fmt.Sprintf("Alert command for %s failed: %v", what, msg.Err))
+	lines := strings.Split(strings.TrimRight(msg.Output, "\n"), "\n")
+	if len(lines) > alertExecOutputLines {
+		lines = lines[len(lines)-alertExecOutputLines:]
+	}
+	for _, l := range lines {
+		if l = strings.TrimRight(l, "\r"); strings.TrimSpace(l) != "" {
+			m.logAt(levelWarn, "  "+l)
+		}
+	}
+}
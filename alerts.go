@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// alertSeverity orders alerts; higher is worse.
+type alertSeverity int
+
+const (
+	alertNone alertSeverity = iota
+	alertWarning
+	alertCritical
+)
+
+func (s alertSeverity) String() string {
+	switch s {
+	case alertWarning:
+		return "warning"
+	case alertCritical:
+		return "critical"
+	default:
+		return "none"
+	}
+}
+
+// scoreAlertKey is the rule key for the overall Awair score.
+const scoreAlertKey = "score"
+
+// AlertRule fires at Severity when Fires reports true for the display
+// value (°F for temperatures) of the sensor Key, or for the score.
+type AlertRule struct {
+	Key      string
+	Severity alertSeverity
+	Fires    func(value float64) bool
+}
+
+// defaultAlertRules returns a warning and a critical rule per sensor,
+// following the fair/poor ratings, plus the same pair for the score.
+func defaultAlertRules() []AlertRule {
+	var rules []AlertRule
+	for key := range awair.OptimalRanges {
+		key := key
+		rules = append(rules,
+			AlertRule{Key: key, Severity: alertWarning, Fires: func(v float64) bool {
+				return awair.RateSensorValue(key, v) != "good"
+			}},
+			AlertRule{Key: key, Severity: alertCritical, Fires: func(v float64) bool {
+				return awair.RateSensorValue(key, v) == "poor"
+			}})
+	}
+	rules = append(rules,
+		AlertRule{Key: scoreAlertKey, Severity: alertWarning, Fires: func(v float64) bool { return v < 80 }},
+		AlertRule{Key: scoreAlertKey, Severity: alertCritical, Fires: func(v float64) bool { return v < 60 }})
+	sortAlertRules(rules)
+	return rules
+}
+
+// sortAlertRules puts rules in evaluation order: most severe first, then
+// by sensor key.
+func sortAlertRules(rules []AlertRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Severity != rules[j].Severity {
+			return rules[i].Severity > rules[j].Severity
+		}
+		return rules[i].Key < rules[j].Key
+	})
+}
+
+// Alert is a rule that fired for a reading.
+type Alert struct {
+	Key      string
+	Severity alertSeverity
+	Value    float64 // display value the rule fired on
+}
+
+// Label returns the human-readable name of the alert's sensor.
+func (a Alert) Label() string {
+	if a.Key == scoreAlertKey {
+		return "Score"
+	}
+	return awair.OptimalRanges[a.Key].Label
+}
+
+// AlertSnapshot is the outcome of evaluating one reading: the most severe
+// firing alert per sensor key.
+type AlertSnapshot map[string]Alert
+
+// evaluateAlerts runs rules against d in evaluation order (see
+// sortAlertRules). Only the most severe alert per sensor is kept, so a
+// reading that trips both the warning and the critical CO₂ rule yields a
+// single critical CO₂ alert.
+func evaluateAlerts(rules []AlertRule, d *awair.SensorData) AlertSnapshot {
+	values := map[string]float64{scoreAlertKey: float64(d.Score)}
+	for _, s := range d.Readings() {
+		values[s.Key] = awair.DisplayValue(s.Key, s.Value)
+	}
+
+	snap := make(AlertSnapshot)
+	for _, r := range rules {
+		v, ok := values[r.Key]
+		if !ok || !r.Fires(v) {
+			continue
+		}
+		if prev, ok := snap[r.Key]; ok && prev.Severity >= r.Severity {
+			continue
+		}
+		snap[r.Key] = Alert{Key: r.Key, Severity: r.Severity, Value: v}
+	}
+	return snap
+}
+
+// Sorted returns the snapshot's alerts, most severe first, then by key.
+func (s AlertSnapshot) Sorted() []Alert {
+	alerts := make([]Alert, 0, len(s))
+	for _, a := range s {
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Severity != alerts[j].Severity {
+			return alerts[i].Severity > alerts[j].Severity
+		}
+		return alerts[i].Key < alerts[j].Key
+	})
+	return alerts
+}
+
+// Worst returns the alert that drives the device badge: the most severe
+// one, ties broken by key. ok is false when nothing fired.
+func (s AlertSnapshot) Worst() (a Alert, ok bool) {
+	sorted := s.Sorted()
+	if len(sorted) == 0 {
+		return Alert{}, false
+	}
+	return sorted[0], true
+}
+
+// Rating returns the rating the snapshot implies for sensor key: "poor"
+// for a critical alert, "fair" for a warning and "good" for none.
+func (s AlertSnapshot) Rating(key string) string {
+	switch s[key].Severity {
+	case alertCritical:
+		return "poor"
+	case alertWarning:
+		return "fair"
+	default:
+		return "good"
+	}
+}
+
+// Kinds of AlertTransition.
+const (
+	alertFired   = "fired"
+	alertChanged = "changed" // same sensor, different severity
+	alertCleared = "cleared"
+)
+
+// AlertTransition is a change between two consecutive snapshots.
+type AlertTransition struct {
+	Kind     string
+	Alert    Alert // current alert; the cleared one for alertCleared
+	Previous alertSeverity
+}
+
+// diffAlerts compares a snapshot with the previous one. Each sensor yields
+// at most one transition, so a single poll can't both fire and clear the
+// same alert. Transitions are ordered like alerts: most severe first (the
+// cleared severity for cleared alerts), then by key.
+func diffAlerts(prev, cur AlertSnapshot) []AlertTransition {
+	var out []AlertTransition
+	for key, a := range cur {
+		p, had := prev[key]
+		switch {
+		case !had:
+			out = append(out, AlertTransition{Kind: alertFired, Alert: a})
+		case p.Severity != a.Severity:
+			out = append(out, AlertTransition{Kind: alertChanged, Alert: a, Previous: p.Severity})
+		}
+	}
+	for key, p := range prev {
+		if _, still := cur[key]; !still {
+			out = append(out, AlertTransition{Kind: alertCleared, Alert: p, Previous: p.Severity})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Alert.Severity != out[j].Alert.Severity {
+			return out[i].Alert.Severity > out[j].Alert.Severity
+		}
+		return out[i].Alert.Key < out[j].Alert.Key
+	})
+	return out
+}
+
+// Describe formats the transition for the log panel.
+func (t AlertTransition) Describe(device string) string {
+	a := t.Alert
+	switch t.Kind {
+	case alertCleared:
+		return fmt.Sprintf("%s: %s back to normal", device, a.Label())
+	case alertChanged:
+		return fmt.Sprintf("%s: %s %s → %s", device, a.Label(), t.Previous, a.Severity)
+	default:
+		return fmt.Sprintf("%s: %s %s", device, a.Label(), a.Severity)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+func alertKeys(alerts []Alert) string {
+	var keys []string
+	for _, a := range alerts {
+		keys = append(keys, a.Key+":"+a.Severity.String())
+	}
+	return strings.Join(keys, " ")
+}
+
+func transitionKeys(ts []AlertTransition) string {
+	var keys []string
+	for _, t := range ts {
+		keys = append(keys, t.Kind+":"+t.Alert.Key+":"+t.Alert.Severity.String())
+	}
+	return strings.Join(keys, " ")
+}
+
+func TestSortAlertRules(t *testing.T) {
+	rules := []AlertRule{
+		{Key: "pm25", Severity: alertWarning},
+		{Key: "co2", Severity: alertWarning},
+		{Key: "voc", Severity: alertCritical},
+		{Key: "co2", Severity: alertCritical},
+	}
+	sortAlertRules(rules)
+	var got []string
+	for _, r := range rules {
+		got = append(got, r.Key+":"+r.Severity.String())
+	}
+	if want := "co2:critical voc:critical co2:warning pm25:warning"; strings.Join(got, " ") != want {
+		t.Errorf("order %v, want %s", got, want)
+	}
+}
+
+func TestEvaluateAlertsKeepsMostSevere(t *testing.T) {
+	above := func(limit float64) func(float64) bool { return func(v float64) bool { return v > limit } }
+	rules := []AlertRule{
+		{Key: "co2", Severity: alertWarning, Fires: above(800)},
+		{Key: "co2", Severity: alertCritical, Fires: above(1000)},
+		// A second warning rule on the same sensor changes nothing
+		{Key: "co2", Severity: alertWarning, Fires: above(500)},
+	}
+	d := &awair.SensorData{Score: 90, Temp: 21, Humid: 45, CO2: 1200, PM25: 2}
+
+	// Whatever order the rules come in
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2}} {
+		var ordered []AlertRule
+		for _, i := range order {
+			ordered = append(ordered, rules[i])
+		}
+		snap := evaluateAlerts(ordered, d)
+		if len(snap) != 1 || snap["co2"].Severity != alertCritical || snap["co2"].Value != 1200 {
+			t.Errorf("order %v: %+v", order, snap)
+		}
+	}
+
+	d.CO2 = 900
+	if snap := evaluateAlerts(rules, d); snap["co2"].Severity != alertWarning {
+		t.Errorf("900 ppm: %+v", snap)
+	}
+}
+
+func TestDefaultAlerts(t *testing.T) {
+	dew, abs := 12.0, 8.0
+	d := &awair.SensorData{
+		Score: 55, Temp: 21, Humid: 35, CO2: 1500, VOC: 100, PM25: 20,
+		DewPoint: &dew, AbsHumid: &abs,
+	}
+	snap := evaluateAlerts(defaultAlertRules(), d)
+	if got, want := alertKeys(snap.Sorted()), "co2:critical score:critical humid:warning pm25:warning"; got != want {
+		t.Errorf("alerts %s, want %s", got, want)
+	}
+	if worst, ok := snap.Worst(); !ok || worst.Key != "co2" {
+		t.Errorf("worst %+v", worst)
+	}
+	// Rules see display values: 21 °C is 69.8 °F, good
+	if _, ok := snap["temp"]; ok {
+		t.Error("temperature alert at 21 °C")
+	}
+	if snap.Rating("co2") != "poor" || snap.Rating("humid") != "fair" || snap.Rating("voc") != "good" {
+		t.Errorf("ratings %s %s %s", snap.Rating("co2"), snap.Rating("humid"), snap.Rating("voc"))
+	}
+}
+
+func TestWorstTieBreak(t *testing.T) {
+	snap := AlertSnapshot{
+		"voc":   {Key: "voc", Severity: alertWarning},
+		"pm25":  {Key: "pm25", Severity: alertCritical},
+		"co2":   {Key: "co2", Severity: alertCritical},
+		"score": {Key: "score", Severity: alertCritical},
+	}
+	// Map order varies between runs; the answer mustn't
+	for range 20 {
+		if worst, _ := snap.Worst(); worst.Key != "co2" {
+			t.Fatalf("worst %s, want co2", worst.Key)
+		}
+	}
+	if got := alertKeys(snap.Sorted()); got != "co2:critical pm25:critical score:critical voc:warning" {
+		t.Errorf("sorted %s", got)
+	}
+	if _, ok := (AlertSnapshot{}).Worst(); ok {
+		t.Error("worst of nothing")
+	}
+}
+
+func TestDiffAlerts(t *testing.T) {
+	prev := AlertSnapshot{
+		"co2":  {Key: "co2", Severity: alertWarning},
+		"pm25": {Key: "pm25", Severity: alertCritical},
+		"voc":  {Key: "voc", Severity: alertWarning},
+		"temp": {Key: "temp", Severity: alertWarning},
+	}
+	cur := AlertSnapshot{
+		"co2":   {Key: "co2", Severity: alertCritical},
+		"humid": {Key: "humid", Severity: alertWarning},
+		"score": {Key: "score", Severity: alertWarning},
+		"temp":  {Key: "temp", Severity: alertWarning},
+	}
+	ts := diffAlerts(prev, cur)
+	// Most severe first, cleared ones by the severity they had, then by key
+	want := "changed:co2:critical cleared:pm25:critical fired:humid:warning fired:score:warning cleared:voc:warning"
+	for range 20 {
+		if got := transitionKeys(diffAlerts(prev, cur)); got != want {
+			t.Fatalf("transitions %s, want %s", got, want)
+		}
+	}
+	if ts[0].Previous != alertWarning || ts[1].Previous != alertCritical {
+		t.Errorf("previous %v, %v", ts[0].Previous, ts[1].Previous)
+	}
+
+	if ts := diffAlerts(cur, cur); len(ts) != 0 {
+		t.Errorf("no change gave %s", transitionKeys(ts))
+	}
+	if got := transitionKeys(diffAlerts(nil, cur)); got != "fired:co2:critical fired:humid:warning fired:score:warning fired:temp:warning" {
+		t.Errorf("from nothing: %s", got)
+	}
+}
+
+func TestDescribeTransition(t *testing.T) {
+	tests := []struct {
+		t    AlertTransition
+		want string
+	}{
+		{AlertTransition{Kind: alertFired, Alert: Alert{Key: "co2", Severity: alertCritical}}, "Office: CO₂ critical"},
+		{AlertTransition{Kind: alertChanged, Alert: Alert{Key: "pm25", Severity: alertWarning}, Previous: alertCritical}, "Office: PM2.5 critical → warning"},
+		{AlertTransition{Kind: alertCleared, Alert: Alert{Key: scoreAlertKey, Severity: alertWarning}}, "Office: Score back to normal"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.Describe("Office"); got != tt.want {
+			t.Errorf("Describe = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// alertTemplates shape what alert deliveries send, from the config: the
+// webhook body (alert_webhook_template) and the arguments passed to the
+// --alert-exec command (alert_exec_args). Templates are executed with
+// the alertEvent.
+type alertTemplates struct {
+	webhook *template.Template   // nil posts the event as JSON
+	args    []*template.Template // appended to the command as $1, $2...
+}
+
+// alertTemplateFuncs are the functions alert templates can call besides
+// the built-in ones.
+var alertTemplateFuncs = template.FuncMap{
+	"round": templateRound,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"json":  templateJSON,
+}
+
+// templateRound rounds a number to the given decimal places, none by
+// default: {{round .Value 1}}. A missing value is an error.
+func templateRound(v any, places ...int) (float64, error) {
+	var f float64
+	switch v := v.(type) {
+	case float64:
+		f = v
+	case *float64:
+		if v == nil {
+			return 0, fmt.Errorf("no value")
+		}
+		f = *v
+	case int:
+		f = float64(v)
+	default:
+		return 0, fmt.Errorf("%T is not a number", v)
+	}
+	scale := 1.0
+	if len(places) > 0 {
+		scale = math.Pow(10, float64(places[0]))
+	}
+	return math.Round(f*scale) / scale, nil
+}
+
+// templateJSON encodes v as JSON, so a value can go into a JSON body
+// quoted and escaped: {"text": {{json .Name}}}.
+func templateJSON(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// templateErrorRE picks the position out of text/template's errors, e.g.
+// `template: body:1:9: executing "body" at <.Foo>: ...`. Parse errors
+// have only a line.
+var templateErrorRE = regexp.MustCompile(`^template: [^:]*:(\d+)(?::(\d+))?: (?:executing "[^"]*" )?(.*)$`)
+
+// templateError restates a template error as "line L, column C: ...".
+func templateError(err error) error {
+	m := templateErrorRE.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	if m[2] == "" {
+		return fmt.Errorf("line %s: %s", m[1], m[3])
+	}
+	return fmt.Errorf("line %s, column %s: %s", m[1], m[2], m[3])
+}
+
+// parseAlertTemplate parses text and executes it once with a sample
+// event, so unknown fields and wrong arguments show up at startup rather
+// than when air turns bad.
+func parseAlertTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(alertTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, templateError(err))
+	}
+	if err := t.Execute(io.Discard, sampleAlertEvent(time.Now())); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, templateError(err))
+	}
+	return t, nil
+}
+
+// parseAlertTemplates parses the templates in s. Errors name the config
+// key and the position in the template.
+func parseAlertTemplates(s Settings) (alertTemplates, error) {
+	var t alertTemplates
+	if s.AlertWebhookTemplate != "" {
+		webhook, err := parseAlertTemplate("alert_webhook_template", s.AlertWebhookTemplate)
+		if err != nil {
+			return alertTemplates{}, err
+		}
+		t.webhook = webhook
+	}
+	for i, text := range s.AlertExecArgs {
+		arg, err := parseAlertTemplate(fmt.Sprintf("alert_exec_args[%d]", i), text)
+		if err != nil {
+			return alertTemplates{}, err
+		}
+		t.args = append(t.args, arg)
+	}
+	return t, nil
+}
+
+// webhookBody is what to post for ev: the template's output, or the
+// event as JSON.
+func (t alertTemplates) webhookBody(ev alertEvent) ([]byte, error) {
+	if t.webhook == nil {
+		return json.Marshal(ev)
+	}
+	var b strings.Builder
+	if err := t.webhook.Execute(&b, ev); err != nil {
+		return nil, templateError(err)
+	}
+	return []byte(b.String()), nil
+}
+
+// execArgs returns the arguments for the --alert-exec command for ev.
+func (t alertTemplates) execArgs(ev alertEvent) ([]string, error) {
+	var args []string
+	for _, tmpl := range t.args {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, ev); err != nil {
+			return nil, templateError(err)
+		}
+		args = append(args, b.String())
+	}
+	return args, nil
+}
+
+// sampleAlertEvent is a made-up CO₂ alert, for checking templates and
+// for --test-alert.
+func sampleAlertEvent(now time.Time) alertEvent {
+	value := 1850.0
+	return alertEvent{
+		Event:          alertFired,
+		IP:             "192.0.2.10",
+		Name:           "Test device",
+		UUID:           "awair-element_0",
+		Sensor:         "co2",
+		Label:          awair.OptimalRanges["co2"].Label,
+		Value:          &value,
+		Formatted:      awair.FormatValue("co2", value, false),
+		Rating:         "poor",
+		PreviousRating: "fair",
+		Severity:       alertCritical.String(),
+		Time:           now.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertTemplates(t *testing.T) {
+	tmpl, err := parseAlertTemplates(Settings{
+		AlertWebhookTemplate: `{"text": {{json (printf "%s: %s is %s" .Name .Label (upper .Rating))}}, "value": {{round .Value 1}}}`,
+		AlertExecArgs:        []string{"{{.Name}}", "{{.Sensor}}={{round .Value}}", "{{.Event}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := sampleAlertEvent(time.Now())
+	v := 1234.56
+	ev.Value = &v
+	ev.Name = `Kid's "room"`
+
+	body, err := tmpl.webhookBody(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"text": "Kid's \"room\": CO₂ is POOR", "value": 1234.6}`
+	if string(body) != want {
+		t.Errorf("body = %s, want %s", body, want)
+	}
+	if !json.Valid(body) {
+		t.Error("body isn't JSON")
+	}
+
+	args, err := tmpl.execArgs(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(args, "|") != `Kid's "room"|co2=1235|fired` {
+		t.Errorf("args = %q", args)
+	}
+}
+
+func TestAlertTemplatesDefaultBody(t *testing.T) {
+	ev := sampleAlertEvent(time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC))
+	body, err := alertTemplates{}.webhookBody(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["event"] != "fired" || got["sensor"] != "co2" || got["value"] != 1850.0 || got["timestamp"] != "2026-01-02T15:04:05Z" {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestAlertTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Settings
+		want string
+	}{
+		{"unknown field", Settings{AlertWebhookTemplate: `{"text": {{.Nmae}}}`},
+			"alert_webhook_template: line 1, column 11: at <.Nmae>: can't evaluate field Nmae"},
+		{"unclosed action", Settings{AlertWebhookTemplate: "ok\n{{if .Name}}"},
+			"alert_webhook_template: line 2: unexpected EOF"},
+		{"unknown function", Settings{AlertWebhookTemplate: "{{shout .Name}}"},
+			`alert_webhook_template: line 1: function "shout" not defined`},
+		{"bad argument", Settings{AlertExecArgs: []string{"{{.Name}}", "{{round .Label}}"}},
+			"alert_exec_args[1]: line 1, column 2: at <round .Label>: error calling round: string is not a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseAlertTemplates(tt.s)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q...", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateRound(t *testing.T) {
+	v := 21.456
+	tests := []struct {
+		v      any
+		places []int
+		want   float64
+	}{
+		{21.5, nil, 22},
+		{&v, []int{1}, 21.5},
+		{&v, []int{2}, 21.46},
+		{7, nil, 7},
+	}
+	for _, tt := range tests {
+		got, err := templateRound(tt.v, tt.places...)
+		if err != nil || got != tt.want {
+			t.Errorf("round(%v, %v) = %v, %v, want %v", tt.v, tt.places, got, err, tt.want)
+		}
+	}
+	if _, err := templateRound((*float64)(nil)); err == nil {
+		t.Error("round of a missing value succeeded")
+	}
+}
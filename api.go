@@ -1,212 +1,361 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"math"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"strconv"
 	"strings"
 	"time"
-)
 
-const maxResponseSize = 1 << 20 // 1 MB
-
-// SensorData represents the JSON response from /air-data/latest.
-type SensorData struct {
-	Timestamp      string   `json:"timestamp"`
-	Score          int      `json:"score"`
-	DewPoint       *float64 `json:"dew_point"`
-	Temp           float64  `json:"temp"`
-	Humid          float64  `json:"humid"`
-	AbsHumid       *float64 `json:"abs_humid"`
-	CO2            float64  `json:"co2"`
-	CO2Est         *float64 `json:"co2_est"`
-	CO2EstBaseline *float64 `json:"co2_est_baseline"`
-	VOC            float64  `json:"voc"`
-	VOCBaseline    *float64 `json:"voc_baseline"`
-	VOCH2Raw       *float64 `json:"voc_h2_raw"`
-	VOCEthanolRaw  *float64 `json:"voc_ethanol_raw"`
-	PM25           float64  `json:"pm25"`
-	PM10Est        *float64 `json:"pm10_est"`
-}
-
-// DeviceConfig represents the JSON response from /settings/config/data.
-type DeviceConfig struct {
-	DeviceUUID string `json:"device_uuid"`
-	WifiMAC    string `json:"wifi_mac"`
-	SSID       string `json:"ssid"`
-	IP         string `json:"ip"`
-	Netmask    string `json:"netmask"`
-	Gateway    string `json:"gateway"`
-	FWVersion  string `json:"fw_version"`
-	Timezone   string `json:"timezone"`
-	Display    string `json:"display"`
-}
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
 
 // Device holds the state for a single Awair device.
 type Device struct {
-	IP         string
-	Name       string
-	Data       *SensorData
-	Config     *DeviceConfig
-	LastError  error
-	LastUpdate time.Time
+	ID             deviceID // stable handle, assigned when added
+	IP             string   // IP address or host name; the device's key
+	Addrs          []string // what IP resolved to, if it is a host name
+	Name           string
+	DiscoveredName string // mDNS instance name (or name given when added)
+	UUID           string // device UUID, from its saved entry until the config arrives
+	Data           *awair.SensorData
+	Prev           *awair.SensorData // the unique reading before Data, for trends
+	Config         *awair.DeviceConfig
+	LastError      error
+	Failures       int // consecutive failed polls; see Offline
+	Backoff        int // ticks skipped after the latest failure, see backOff
+	SkipTicks      int // ticks left to skip before the next poll
+
+	// ctx carries the device's requests and is cancelled when it is
+	// removed; see model.addDevice.
+	ctx        context.Context
+	cancel     context.CancelFunc
+	LastUpdate time.Time     // when the device last answered a poll
+	SampleTime time.Time     // device timestamp of Data; zero if it has none
+	ClockSkew  time.Duration // device sample time minus ours at the latest poll
+	skewLogged bool          // the skew warning was logged this session
+	History    History       // unique samples, deduplicated on device timestamp
+	Summary    Summary       // aggregates of History's samples and more, for the summary view
+	Alerts     AlertSnapshot // alerts from the latest reading
+	Latency    Latency       // how long successful polls took
+
+	// The display mode last asked for from the detail view, which the
+	// next one follows, and whether a config fetch is to confirm it; see
+	// confirmDisplay.
+	displayMode    string
+	displayPending bool
+
+	LED            *LEDSettings // nil until fetched
+	ledUnsupported bool         // the firmware has no /settings/led
+
+	cloudPolled    time.Time // when a cloud device was last polled; see cloudPollDue
+	cloudExhausted bool      // out of today's cloud quota, and logged as such
+	endpoint       string    // air data endpoint polled; see endpointFallback
+}
+
+// Title is the device's name as cards and views show it, tagged if it
+// is polled through the Awair cloud or simulated by --demo.
+func (d *Device) Title() string {
+	if isCloud(d.IP) {
+		return glyphs.Cloud + " " + d.Name
+	}
+	if isDemo(d.IP) {
+		return d.Name + " (demo)"
+	}
+	return d.Name
+}
+
+// Label is Title with the device's address, which cloud and demo devices
+// don't have.
+func (d *Device) Label() string {
+	if isCloud(d.IP) || isDemo(d.IP) {
+		return d.Title()
+	}
+	return fmt.Sprintf("%s (%s)", d.Name, d.IP)
 }
 
-// SensorRange defines the optimal range for a sensor reading.
-type SensorRange struct {
-	Min   float64
-	Max   float64
-	Unit  string
-	Label string
+// Offline reports whether the device has stopped answering: its last
+// offlineAfterFailures polls failed.
+func (d *Device) Offline() bool {
+	return d.Failures >= offlineAfterFailures
 }
 
-// OptimalRanges defines per-sensor optimal ranges (temps in °F for rating).
-var OptimalRanges = map[string]SensorRange{
-	"temp":      {Min: 68, Max: 77, Unit: "°F", Label: "Temperature"},
-	"dew_point": {Min: 50, Max: 65, Unit: "°F", Label: "Dew Point"},
-	"humid":     {Min: 40, Max: 50, Unit: "%", Label: "Humidity"},
-	"abs_humid": {Min: 4, Max: 12, Unit: "g/m³", Label: "Abs Humidity"},
-	"co2":       {Min: 0, Max: 600, Unit: "ppm", Label: "CO₂"},
-	"co2_est":   {Min: 0, Max: 600, Unit: "ppm", Label: "CO₂ (est)"},
-	"voc":       {Min: 0, Max: 300, Unit: "ppb", Label: "VOC"},
-	"pm25":      {Min: 0, Max: 12, Unit: "µg/m³", Label: "PM2.5"},
-	"pm10_est":  {Min: 0, Max: 50, Unit: "µg/m³", Label: "PM10 (est)"},
+// TrendThresholds is the smallest change between polls that counts as a
+// trend rather than sensor noise, in API units (temps in °C).
+var TrendThresholds = map[string]float64{
+	"temp":      0.3,
+	"dew_point": 0.3,
+	"humid":     1,
+	"abs_humid": 0.3,
+	"co2":       25,
+	"co2_est":   25,
+	"voc":       25,
+	"pm25":      2,
+	"pm10_est":  3,
+	"lux":       20,
+	"spl_a":     3,
+}
+
+// defaultHTTPTimeout is how long one request to a device may take.
+const defaultHTTPTimeout = awair.DefaultTimeout
+
+// Per-attempt timeout and number of retries for device requests, set by
+// configureHTTP from --http-timeout and --http-retries.
+var (
+	httpTimeout = defaultHTTPTimeout
+	httpRetries = 0
+)
+
+// httpClient has no overall timeout; deviceClient applies httpTimeout to
+// each attempt.
+var httpClient = &http.Client{
+	Transport: loggingTransport{newDeviceTransport(defaultInterval * time.Second)},
 }
 
-var httpClient = &http.Client{Timeout: 5 * time.Second}
+// configureHTTP applies the request settings in s to httpClient and
+// routes the awair package's warnings to the log. Call it before the
+// first request.
+func configureHTTP(s Settings) {
+	awair.Logf = func(format string, args ...any) { logf(levelWarn, format, args...) }
+	httpTimeout, httpRetries = s.HTTPTimeout, s.HTTPRetries
+	cloudToken = s.CloudToken
+	httpClient.Transport = loggingTransport{newDeviceTransport(time.Duration(s.Interval) * time.Second)}
+}
 
-// formatHost wraps IPv6 addresses in brackets for use in URLs.
-func formatHost(ip string) string {
-	if strings.Contains(ip, ":") && !strings.HasPrefix(ip, "[") {
-		return "[" + ip + "]"
+// newDeviceTransport returns a transport that keeps each device's
+// connection open from one poll to the next, since some firmware copes
+// poorly with a new TCP connection every few seconds. Idle connections
+// outlive the poll interval by a margin; if the device closes one first,
+// the transport retries the GET on a new connection.
+func newDeviceTransport(pollInterval time.Duration) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 2, // a poll and a config fetch
+		IdleConnTimeout:     pollInterval + 5*time.Second,
+		// Devices don't compress their responses
+		DisableCompression: true,
 	}
-	return ip
 }
 
-// FetchAirData retrieves the latest sensor data from an Awair device.
-func FetchAirData(ip string) (*SensorData, error) {
-	url := fmt.Sprintf("http://%s/air-data/latest", formatHost(ip))
-	resp, err := httpClient.Get(url)
+// loggingTransport records every device request in the application log
+// at debug level, with whether it reused an idle connection.
+type loggingTransport struct {
+	next http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	conn := "new connection"
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				conn = fmt.Sprintf("reused connection, idle %s", info.IdleTime.Round(time.Millisecond))
+			}
+		},
+	}))
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
 	if err != nil {
+		logf(levelDebug, "%s %s: %v", req.Method, req.URL, err)
 		return nil, err
 	}
-	defer resp.Body.Close()
+	logf(levelDebug, "%s %s: %s (%s, %s)", req.Method, req.URL, resp.Status, time.Since(start).Round(time.Millisecond), conn)
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, resp.Status)
+// normalizeIP returns the canonical text form of an IP address: IPv6 in
+// lowercase compressed form with its zone (fe80::1%eth0) kept, IPv4-mapped
+// IPv6 as plain IPv4. Surrounding brackets are accepted. Devices, config
+// entries and records are keyed by this form, so every spelling of an
+// address maps to one device. ok is false if s isn't an IP address.
+func normalizeIP(s string) (ip string, ok bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
 	}
-
-	var data SensorData
-	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&data); err != nil {
-		return nil, err
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false
 	}
-	return &data, nil
+	return addr.Unmap().String(), true
 }
 
-// FetchDeviceConfig retrieves the device configuration.
-func FetchDeviceConfig(ip string) (*DeviceConfig, error) {
-	url := fmt.Sprintf("http://%s/settings/config/data", formatHost(ip))
-	resp, err := httpClient.Get(url)
-	if err != nil {
-		return nil, err
+// defaultPort is the port of the device's local API.
+const defaultPort = 80
+
+// normalizeAddress is normalizeIP that also accepts host names, such as
+// awair-bedroom.local, returned lowercase without a trailing dot, and a
+// port after either: 192.168.1.5:8080, [fe80::1]:8080. The port is dropped
+// if it is defaultPort, so it only shows where it matters. A bare IPv6
+// address is always taken as a whole, never as address:port. Base URLs
+// of proxied devices go through normalizeURL. ok is false if s is none
+// of these.
+func normalizeAddress(s string) (addr string, ok bool) {
+	s = strings.TrimSpace(s)
+	if isURL(s) {
+		return normalizeURL(s)
+	}
+	if ip, ok := normalizeIP(s); ok {
+		return ip, true
 	}
-	defer resp.Body.Close()
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, ok := normalizeHost(h)
+		port, err := strconv.Atoi(p)
+		if !ok || err != nil || port < 1 || port > 65535 {
+			return "", false
+		}
+		return joinAddress(host, port), true
+	}
+	return normalizeHost(s)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d %s", resp.StatusCode, resp.Status)
+// normalizeHost is normalizeIP for an IP address or a host name, without
+// a port.
+func normalizeHost(s string) (host string, ok bool) {
+	if ip, ok := normalizeIP(s); ok {
+		return ip, true
+	}
+	host = strings.ToLower(strings.TrimSuffix(s, "."))
+	if host == "" || len(host) > 253 {
+		return "", false
 	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", false
+			}
+		}
+	}
+	return host, true
+}
 
-	var cfg DeviceConfig
-	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&cfg); err != nil {
-		return nil, err
+// joinAddress is the device address for host and port, in normalizeAddress
+// form.
+func joinAddress(host string, port int) string {
+	if port == defaultPort {
+		return host
 	}
-	return &cfg, nil
+	return net.JoinHostPort(host, strconv.Itoa(port))
 }
 
-// CToF converts Celsius to Fahrenheit.
-func CToF(c float64) float64 {
-	return c*9.0/5.0 + 32.0
+// splitAddress splits a device address into its host, without brackets,
+// and port.
+func splitAddress(addr string) (host string, port int) {
+	if ip, ok := normalizeIP(addr); ok {
+		return ip, defaultPort
+	}
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		if port, err := strconv.Atoi(p); err == nil {
+			return h, port
+		}
+	}
+	return addr, defaultPort
 }
 
-// RateSensorValue returns "good", "fair", or "poor" for a sensor value.
-// For temp/dew_point, value should be in °F.
-func RateSensorValue(key string, value float64) string {
-	r, ok := OptimalRanges[key]
-	if !ok {
-		return "fair"
+// isHostname reports whether a device address is a host name rather than
+// an IP address. Base URLs are neither; they are never resolved.
+func isHostname(addr string) bool {
+	if isURL(addr) || isCloud(addr) || isDemo(addr) {
+		return false
 	}
+	host, _ := splitAddress(addr)
+	_, ok := normalizeIP(host)
+	return !ok
+}
 
-	switch key {
-	case "temp", "dew_point":
-		if value >= r.Min && value <= r.Max {
-			return "good"
-		}
-		dist := r.Min - value
-		if value > r.Max {
-			dist = value - r.Max
-		}
-		if dist <= 5 {
-			return "fair"
-		}
-		return "poor"
+// isIPAddress reports whether a device address is an IP address, with or
+// without a port, rather than a host name or URL.
+func isIPAddress(addr string) bool {
+	return !isURL(addr) && !isCloud(addr) && !isDemo(addr) && !isHostname(addr)
+}
 
-	case "humid":
-		if value >= r.Min && value <= r.Max {
-			return "good"
-		}
-		dist := r.Min - value
-		if value > r.Max {
-			dist = value - r.Max
-		}
-		if dist <= 10 {
-			return "fair"
-		}
-		return "poor"
+// canonicalIP is normalizeAddress for stored or user-supplied addresses
+// that are used as given when they don't parse.
+func canonicalIP(s string) string {
+	if addr, ok := normalizeAddress(s); ok {
+		return addr
+	}
+	return s
+}
 
-	case "abs_humid":
-		if value >= r.Min && value <= r.Max {
-			return "good"
-		}
-		return "fair"
+// hostResolveTimeout bounds a host name lookup, which for .local names
+// waits on mDNS.
+const hostResolveTimeout = 3 * time.Second
 
-	default:
-		// co2, co2_est, voc, pm25, pm10_est — lower is better
-		if value <= r.Max {
-			return "good"
-		}
-		if value <= r.Max*2 {
-			return "fair"
-		}
-		return "poor"
+// resolveHost looks up the host name in a device address. The addresses
+// it resolved to are returned as device addresses with the same port.
+func resolveHost(ctx context.Context, addr string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, hostResolveTimeout)
+	defer cancel()
+	host, port := splitAddress(addr)
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
 	}
+	for i, a := range addrs {
+		addrs[i] = joinAddress(canonicalIP(a), port)
+	}
+	return addrs, nil
 }
 
-// FormatValue formats a sensor value for display.
-func FormatValue(key string, value float64, fahrenheit bool) string {
-	r := OptimalRanges[key]
+// formatHost turns a device address into the host part of a URL: IPv6
+// addresses in brackets, with the zone separator escaped as URLs require
+// (fe80::1%eth0 → [fe80::1%25eth0]), and the port if it isn't
+// defaultPort.
+func formatHost(addr string) string {
+	host, port := splitAddress(addr)
+	if strings.Contains(host, ":") {
+		host = "[" + strings.Replace(host, "%", "%25", 1) + "]"
+	}
+	if port != defaultPort {
+		host += ":" + strconv.Itoa(port)
+	}
+	return host
+}
 
-	switch key {
-	case "temp", "dew_point":
-		if fahrenheit {
-			return fmt.Sprintf("%.1f°F", CToF(value))
-		}
-		return fmt.Sprintf("%.1f°C", value)
-	case "humid":
-		return fmt.Sprintf("%.1f%s", value, r.Unit)
-	case "abs_humid":
-		return fmt.Sprintf("%.1f %s", value, r.Unit)
-	default:
-		return fmt.Sprintf("%.0f %s", math.Round(value), r.Unit)
+// deviceClient returns the client for requests to the device at ip, with
+// the request settings from configureHTTP and its credentials if it was
+// given as a URL with them, or the cloud token for cloud devices.
+func deviceClient(ip string) *awair.Client {
+	c := &awair.Client{
+		BaseURL: deviceURL(ip, ""),
+		HTTP:    httpClient,
+		Timeout: httpTimeout,
+		Retries: httpRetries,
+		User:    deviceCredentials(ip),
+	}
+	if isCloud(ip) {
+		c.Token = cloudToken
+	}
+	return c
+}
+
+// FetchAirData retrieves the latest sensor data from an Awair device.
+func FetchAirData(ctx context.Context, ip string) (*awair.SensorData, error) {
+	if isCloud(ip) {
+		return fetchCloudAirData(ctx, ip)
 	}
+	if isDemo(ip) {
+		return demoAirData(ip)
+	}
+	return deviceClient(ip).AirData(ctx)
 }
 
-// DisplayValue returns the value used for rating and bar display.
-// For temp/dew_point this is always °F (since ratings are defined in °F).
-func DisplayValue(key string, rawCelsius float64) float64 {
-	if key == "temp" || key == "dew_point" {
-		return CToF(rawCelsius)
+// FetchDeviceConfig retrieves the device configuration.
+func FetchDeviceConfig(ctx context.Context, ip string) (*awair.DeviceConfig, error) {
+	if isDemo(ip) {
+		return demoConfig(ip), nil
 	}
-	return rawCelsius
+	return deviceClient(ip).Config(ctx)
 }
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"192.168.1.5", "192.168.1.5", true},
+		{" 192.168.1.5 ", "192.168.1.5", true},
+		{"192.168.1.5:80", "192.168.1.5", true},
+		{"192.168.1.5:8080", "192.168.1.5:8080", true},
+		// Every spelling of one IPv6 address is the same key
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1", true},
+		{"2001:DB8::1", "2001:db8::1", true},
+		{"2001:db8:0:0::1", "2001:db8::1", true},
+		{"[2001:db8::1]", "2001:db8::1", true},
+		{"[2001:db8::1]:80", "2001:db8::1", true},
+		{"[2001:0DB8::0001]:8080", "[2001:db8::1]:8080", true},
+		// A bare IPv6 address is never address:port
+		{"2001:db8::80", "2001:db8::80", true},
+		// Zones are kept, in any spelling of the address
+		{"fe80::1%eth0", "fe80::1%eth0", true},
+		{"FE80:0:0:0:0:0:0:1%eth0", "fe80::1%eth0", true},
+		{"[fe80::1%eth0]", "fe80::1%eth0", true},
+		{"[fe80::1%eth0]:8080", "[fe80::1%eth0]:8080", true},
+		// IPv4-mapped IPv6 is the IPv4 address
+		{"::ffff:192.168.1.5", "192.168.1.5", true},
+		{"[::ffff:c0a8:105]:8080", "192.168.1.5:8080", true},
+		// Host names
+		{"Awair-Bedroom.local.", "awair-bedroom.local", true},
+		{"awair-bedroom.local:8080", "awair-bedroom.local:8080", true},
+		// Not addresses
+		{"", "", false},
+		{"2001:db8::1::2", "", false},
+		{"[2001:db8::1", "", false},
+		{"fe80::1%", "", false},
+		{"192.168.1.5:0", "", false},
+		{"192.168.1.5:70000", "", false},
+		{"awair bedroom", "", false},
+		{"-awair.local", "", false},
+		{"awair..local", "", false},
+		{"awair_1.local", "", false},
+		{"ftp://192.168.1.5", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeAddress(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("normalizeAddress(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCanonicalIP(t *testing.T) {
+	if got := canonicalIP("2001:DB8:0::1"); got != "2001:db8::1" {
+		t.Errorf("canonicalIP = %q", got)
+	}
+	// What doesn't parse is kept as given
+	if got := canonicalIP("not an address"); got != "not an address" {
+		t.Errorf("canonicalIP = %q", got)
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		host string
+		port int
+	}{
+		{"192.168.1.5", "192.168.1.5", 80},
+		{"192.168.1.5:8080", "192.168.1.5", 8080},
+		{"2001:db8::1", "2001:db8::1", 80},
+		{"[2001:db8::1]:8080", "2001:db8::1", 8080},
+		{"fe80::1%eth0", "fe80::1%eth0", 80},
+		{"[fe80::1%eth0]:8080", "fe80::1%eth0", 8080},
+		{"awair.local:81", "awair.local", 81},
+	}
+	for _, tt := range tests {
+		host, port := splitAddress(tt.in)
+		if host != tt.host || port != tt.port {
+			t.Errorf("splitAddress(%q) = %q, %d, want %q, %d", tt.in, host, port, tt.host, tt.port)
+		}
+	}
+}
+
+func TestFormatHost(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"192.168.1.5", "192.168.1.5"},
+		{"192.168.1.5:8080", "192.168.1.5:8080"},
+		{"2001:db8::1", "[2001:db8::1]"},
+		{"[2001:db8::1]:8080", "[2001:db8::1]:8080"},
+		{"fe80::1%eth0", "[fe80::1%25eth0]"},
+		{"[fe80::1%eth0]:8080", "[fe80::1%25eth0]:8080"},
+		{"awair.local", "awair.local"},
+	}
+	for _, tt := range tests {
+		if got := formatHost(tt.in); got != tt.want {
+			t.Errorf("formatHost(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := deviceURL("fe80::1%eth0", "/air-data/latest"); got != "http://[fe80::1%25eth0]/air-data/latest" {
+		t.Errorf("deviceURL = %q", got)
+	}
+}
+
+func TestIsHostname(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"192.168.1.5", false},
+		{"192.168.1.5:8080", false},
+		{"2001:db8::1", false},
+		{"[fe80::1%eth0]:8080", false},
+		{"awair.local", true},
+		{"awair.local:8080", true},
+		{"https://awair.example.com/awair", false},
+	}
+	for _, tt := range tests {
+		if got := isHostname(tt.in); got != tt.want {
+			t.Errorf("isHostname(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIPv6SpellingsAreOneDevice(t *testing.T) {
+	m := newTestModel(t, "2001:0db8:0000:0000:0000:0000:0000:0001", "[2001:DB8::1]", "2001:db8::1", "fe80::1%eth0", "[FE80::1%eth0]:80")
+	if len(m.devices) != 2 {
+		t.Fatalf("%d devices: %v", len(m.devices), m.deviceOrder)
+	}
+	for _, key := range []string{"2001:db8::1", "fe80::1%eth0"} {
+		if m.devices[key] == nil {
+			t.Errorf("no device keyed %q", key)
+		}
+	}
+}
+
+func TestConfigCanonicalize(t *testing.T) {
+	cfg := &Config{
+		Devices: DeviceList{
+			{IP: "2001:0DB8::1", Name: "Kitchen"},
+			{IP: "[2001:db8:0:0::1]", Name: "Kitchen again"},
+			{IP: "[fe80::1%eth0]:80", Name: "Link-local"},
+			{IP: "192.168.1.9", UUID: "awair-element_1", Name: "Office"},
+			{IP: "192.168.1.10", UUID: "awair-element_1", Name: "Office, moved"},
+		},
+		Order: []string{"2001:db8::0001", "[2001:db8::1]", "fe80:0::1%eth0"},
+	}
+	cfg.canonicalize()
+	var got []string
+	for _, e := range cfg.Devices {
+		got = append(got, e.IP+"="+e.Name)
+	}
+	want := []string{"2001:db8::1=Kitchen", "fe80::1%eth0=Link-local", "192.168.1.9=Office"}
+	if len(got) != len(want) {
+		t.Fatalf("devices %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("device %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if len(cfg.Order) != 2 || cfg.Order[0] != "2001:db8::1" || cfg.Order[1] != "fe80::1%eth0" {
+		t.Errorf("order %q", cfg.Order)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// logLevel is the severity of an application log entry.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+// parseLogLevel parses a --log-level value.
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return levelInfo, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// logQueueSize bounds the entries waiting to be written. When the file
+// can't keep up, new entries are dropped rather than blocking the UI.
+const logQueueSize = 256
+
+// fileLogger writes timestamped entries to a file from a background
+// goroutine.
+type fileLogger struct {
+	level   logLevel
+	entries chan string
+	done    chan struct{}
+}
+
+// appLog is the application log, set up by main when --log-file is given.
+// A nil appLog discards everything.
+var appLog *fileLogger
+
+// newFileLogger starts writing entries at or above level to w.
+func newFileLogger(w io.Writer, level logLevel) *fileLogger {
+	l := &fileLogger{
+		level:   level,
+		entries: make(chan string, logQueueSize),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(l.done)
+		for e := range l.entries {
+			_, _ = io.WriteString(w, e)
+		}
+	}()
+	return l
+}
+
+// Log queues an entry without blocking. Safe on a nil logger.
+func (l *fileLogger) Log(level logLevel, msg string) {
+	if l == nil || level < l.level {
+		return
+	}
+	entry := fmt.Sprintf("%s %-5s %s\n", time.Now().Format("2006-01-02 15:04:05.000"), level, msg)
+	select {
+	case l.entries <- entry:
+	default:
+	}
+}
+
+// Close writes out the queued entries and stops the logger.
+func (l *fileLogger) Close() {
+	if l == nil {
+		return
+	}
+	close(l.entries)
+	<-l.done
+}
+
+// logf logs a formatted message to the application log.
+func logf(level logLevel, format string, args ...any) {
+	appLog.Log(level, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// aqiColor returns the EPA's standard color for an AQI value.
+func aqiColor(aqi int) lipgloss.Color {
+	switch {
+	case aqi <= 50:
+		return lipgloss.Color("#00E400")
+	case aqi <= 100:
+		return lipgloss.Color("#FFFF00")
+	case aqi <= 150:
+		return lipgloss.Color("#FF7E00")
+	case aqi <= 200:
+		return lipgloss.Color("#FF0000")
+	case aqi <= 300:
+		return lipgloss.Color("#8F3F97")
+	default:
+		return lipgloss.Color("#7E0023")
+	}
+}
+
+// aqiShortCategory is AQICategory abbreviated for device cards.
+func aqiShortCategory(aqi int) string {
+	if c := awair.AQICategory(aqi); c != "Unhealthy for Sensitive Groups" {
+		return c
+	}
+	return "USG"
+}
+
+// aqiText renders "AQI 38 Good" for a card's PM2.5 row in the category's
+// color, or "" for other sensors.
+func aqiText(key string, value float64) string {
+	if key != "pm25" {
+		return ""
+	}
+	aqi := awair.PM25AQI(value)
+	return lipgloss.NewStyle().Foreground(aqiColor(aqi)).Render(fmt.Sprintf("AQI %d %s", aqi, aqiShortCategory(aqi)))
+}
+
+// headlineAQI returns the worse of the PM2.5 and PM10 AQI for d, of
+// those reported, and the label of the sensor it comes from. ok is false
+// if d has neither.
+func headlineAQI(d *awair.SensorData) (aqi int, source string, ok bool) {
+	if d.Reported("pm25") {
+		aqi, source, ok = awair.PM25AQI(d.PM25), awair.OptimalRanges["pm25"].Label, true
+	}
+	if d.PM10Est != nil {
+		if pm10 := awair.PM10AQI(*d.PM10Est); !ok || pm10 > aqi {
+			aqi, source, ok = pm10, awair.OptimalRanges["pm10_est"].Label, true
+		}
+	}
+	return aqi, source, ok
+}
+
+// sensorRowTail fits a sensor row's bar and its note (the AQI, or
+// "(calc)" for derived values) into barWidth columns: the bar shrinks to
+// make room for the note, and the note is dropped if the bar would get
+// too short.
+func sensorRowTail(s awair.SensorReading, ratingVal float64, barWidth int, barColor lipgloss.Color) string {
+	note := aqiText(s.Key, s.Value)
+	if s.Derived {
+		note = lipgloss.NewStyle().Foreground(theme.Muted).Render("(calc)")
+	}
+	if note != "" && barWidth-lipgloss.Width(note)-1 >= 4 {
+		return renderSensorBar(s.Key, ratingVal, barWidth-lipgloss.Width(note)-1, barColor) + " " + note
+	}
+	return renderSensorBar(s.Key, ratingVal, barWidth, barColor)
+}
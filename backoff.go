@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxPollBackoff caps how long an unreachable device goes unpolled.
+const maxPollBackoff = 5 * time.Minute
+
+// backOff records a failed poll of dev. From the second failure in a row
+// it is skipped for 2, 4, 8... ticks, up to maxPollBackoff, so a device
+// that is switched off doesn't cost a timeout on every tick.
+func (m *model) backOff(dev *Device) {
+	if dev.Failures < 2 {
+		return
+	}
+	limit := max(int(maxPollBackoff/m.pollInterval), 1)
+	dev.Backoff = min(max(2*dev.Backoff, 2), limit)
+	dev.SkipTicks = dev.Backoff
+}
+
+// pollDue returns a poll command for every device due on this tick,
+// counting down the ticks of those backing off. Each device is polled in
+// its own slot of the interval, see pollOffset.
+func (m *model) pollDue() []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, ip := range m.deviceOrder {
+		dev := m.devices[ip]
+		if dev.SkipTicks > 0 {
+			dev.SkipTicks--
+			continue
+		}
+		if !m.cloudPollDue(dev) {
+			continue
+		}
+		cmds = append(cmds, staggeredPollCmd(dev, m.pollOffset(ip)))
+	}
+	return cmds
+}
+
+// pollOffset is how long after each tick ip is polled. Devices are spread
+// evenly across the interval, so a tick doesn't send every request at
+// once and their timeouts don't all land together.
+func (m model) pollOffset(ip string) time.Duration {
+	step := m.pollInterval / time.Duration(max(len(m.deviceOrder), 1))
+	for i, o := range m.deviceOrder {
+		if o == ip {
+			return time.Duration(i) * step
+		}
+	}
+	return 0
+}
+
+// retryIn returns roughly how long until dev is polled again: the ticks
+// it still skips plus the next one, and its slot within that tick.
+func (m model) retryIn(dev *Device) time.Duration {
+	next := time.Duration(dev.SkipTicks+1)*m.pollInterval + m.pollOffset(dev.IP)
+	if !m.lastTick.IsZero() {
+		next -= age(m.lastTick)
+	}
+	return max(next, 0)
+}
+
+// lowerFirst lowercases the first letter of a retryText, to follow on
+// from other text.
+func lowerFirst(s string) string {
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// retryText says when a failing device is polled next, for its card.
+func (m model) retryText(dev *Device) string {
+	if m.paused {
+		return "Paused"
+	}
+	if m.cloudQuotaLeft(dev, time.Now()) == 0 {
+		return cloudExhaustedText
+	}
+	return "Retrying in " + shortDuration(m.retryIn(dev))
+}
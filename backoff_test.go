@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// failPoll delivers a failed poll for the device at ip.
+func failPoll(m model, ip string) model {
+	next, _ := m.Update(pollResultMsg{IP: ip, Err: errors.New("i/o timeout")})
+	return next.(model)
+}
+
+func TestBackoffSequence(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	m.pollInterval = 10 * time.Second
+	dev := m.devices["192.0.2.1"]
+	// One failure is retried on the next tick, then 2, 4, 8... ticks up
+	// to five minutes' worth
+	for i, want := range []int{0, 2, 4, 8, 16, 30, 30} {
+		m = failPoll(m, dev.IP)
+		if dev.Failures != i+1 || dev.Backoff != want || dev.SkipTicks != want {
+			t.Errorf("failure %d: backoff %d, skip %d, want %d", dev.Failures, dev.Backoff, dev.SkipTicks, want)
+		}
+	}
+
+	// The cap is in time, so it follows the interval
+	m.pollInterval = time.Minute
+	m = failPoll(m, dev.IP)
+	if dev.Backoff != 5 {
+		t.Errorf("backoff %d at one-minute ticks, want 5", dev.Backoff)
+	}
+	m.pollInterval = 10 * time.Minute
+	m = failPoll(m, dev.IP)
+	if dev.Backoff != 1 {
+		t.Errorf("backoff %d at ten-minute ticks, want 1", dev.Backoff)
+	}
+
+	// A successful poll starts over
+	m = pollWith(m, dev.IP, 500)
+	if dev.Failures != 0 || dev.Backoff != 0 || dev.SkipTicks != 0 {
+		t.Errorf("after a success: failures %d, backoff %d, skip %d", dev.Failures, dev.Backoff, dev.SkipTicks)
+	}
+	m.pollInterval = 10 * time.Second
+	m = failPoll(m, dev.IP)
+	m = failPoll(m, dev.IP)
+	if dev.Backoff != 2 {
+		t.Errorf("backoff %d after two new failures, want 2", dev.Backoff)
+	}
+}
+
+func TestTickSkipsBackingOff(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	a, b := m.devices["192.0.2.1"], m.devices["192.0.2.2"]
+	for range 3 {
+		m = failPoll(m, a.IP)
+	}
+	if a.SkipTicks != 4 {
+		t.Fatalf("skip %d, want 4", a.SkipTicks)
+	}
+
+	// Four ticks poll only b, the fifth both
+	for i := 1; i <= 5; i++ {
+		cmds := m.pollDue()
+		want := 1
+		if i == 5 {
+			want = 2
+		}
+		if len(cmds) != want {
+			t.Errorf("tick %d polled %d devices, want %d", i, len(cmds), want)
+		}
+		if a.SkipTicks != max(4-i, 0) || b.SkipTicks != 0 {
+			t.Errorf("tick %d: skip %d and %d", i, a.SkipTicks, b.SkipTicks)
+		}
+	}
+}
+
+func TestPausedTickKeepsBackoff(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	dev := m.devices["192.0.2.1"]
+	m = failPoll(m, dev.IP)
+	m = failPoll(m, dev.IP)
+	m.paused = true
+	next, _ := m.Update(tickMsg{Gen: m.tickGen})
+	m = next.(model)
+	if dev.SkipTicks != 2 {
+		t.Errorf("skip %d after a paused tick, want 2", dev.SkipTicks)
+	}
+	if got := m.retryText(dev); got != "Paused" {
+		t.Errorf("retry text %q", got)
+	}
+
+	m.paused = false
+	next, _ = m.Update(tickMsg{Gen: m.tickGen})
+	m = next.(model)
+	if dev.SkipTicks != 1 {
+		t.Errorf("skip %d after a tick, want 1", dev.SkipTicks)
+	}
+}
+
+func TestRefreshForcesPoll(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	a := m.devices["192.0.2.1"]
+	for range 4 {
+		m = failPoll(m, a.IP)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatal("r polled nothing")
+	}
+	if a.Backoff != 0 || a.SkipTicks != 0 {
+		t.Errorf("backoff %d, skip %d after r", a.Backoff, a.SkipTicks)
+	}
+	// Failures aren't forgotten: the device is still offline until it
+	// answers, and the next failure backs off again
+	m = failPoll(m, a.IP)
+	if a.Failures != 5 || a.Backoff != 2 {
+		t.Errorf("failures %d, backoff %d after failing again", a.Failures, a.Backoff)
+	}
+	if got := len(m.pollAll()); got != 2 {
+		t.Errorf("pollAll polled %d devices, want 2", got)
+	}
+}
+
+func TestRetryText(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	m.pollInterval = 10 * time.Second
+	a, b := m.devices["192.0.2.1"], m.devices["192.0.2.2"]
+	m.lastTick = time.Now().Add(-500 * time.Millisecond)
+	a.SkipTicks, b.SkipTicks = 3, 3
+	// Four ticks away, less the half second since the last one; b goes
+	// in the second half of each tick
+	if got := m.retryText(a); got != "Retrying in 39s" {
+		t.Errorf("a: %q", got)
+	}
+	if got := m.retryText(b); got != "Retrying in 44s" {
+		t.Errorf("b: %q", got)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// chartSensors are the sensors the detail chart cycles through with
+// left/right.
+var chartSensors = []string{"temp", "humid", "co2", "voc", "pm25"}
+
+// chartPoint is one sample on a chart. Gap marks a break in the line
+// before the point, where polls failed.
+type chartPoint struct {
+	T   time.Time
+	V   float64
+	Gap bool
+}
+
+// sampleTime is when s was taken: the device time, or when it was
+// received if the device didn't say.
+func sampleTime(s Sample) time.Time {
+	if s.DeviceTime.IsZero() {
+		return s.Received
+	}
+	return s.DeviceTime
+}
+
+// historySeries returns the stored values of one sensor as chart points.
+// A point further than maxStep from the one before starts a new line;
+// averages seeded at startup are allowed their period on top.
+// Consecutive samples further apart than maxStep are not joined.
+func historySeries(h History, key string, maxStep time.Duration) []chartPoint {
+	var out []chartPoint
+	var prevPeriod time.Duration
+	for _, s := range h.Samples {
+		for _, r := range s.Data.Readings() {
+			if r.Key != key {
+				continue
+			}
+			p := chartPoint{T: sampleTime(s), V: r.Value}
+			if n := len(out); n > 0 && p.T.Sub(out[n-1].T) > maxStep+max(s.Period, prevPeriod) {
+				p.Gap = true
+			}
+			out = append(out, p)
+			prevPeriod = s.Period
+			break
+		}
+	}
+	return out
+}
+
+// brailleBits[y%4][x%2] is the dot for a pixel within a braille cell,
+// with y counted from the top.
+var brailleBits = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// brailleChart draws points as a line in width×height braille cells,
+// two dots across and four down per cell, scaled to [lo, hi] vertically
+// and to the time span of points horizontally. With ASCII glyphs, each
+// cell with any dot set is drawn as glyphs.ChartDot instead.
+func brailleChart(points []chartPoint, lo, hi float64, width, height int) []string {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	cells := make([][]rune, height)
+	for i := range cells {
+		cells[i] = make([]rune, width)
+	}
+	dotsX, dotsY := width*2, height*4
+
+	set := func(x, y int) {
+		if x < 0 || x >= dotsX || y < 0 || y >= dotsY {
+			return
+		}
+		// y counts up from the bottom; cells are stored top down
+		y = dotsY - 1 - y
+		cells[y/4][x/2] |= brailleBits[y%4][x%2]
+	}
+	pos := func(p chartPoint) (int, int) {
+		x := 0
+		if span := points[len(points)-1].T.Sub(points[0].T); span > 0 {
+			x = int(math.Round(float64(p.T.Sub(points[0].T)) / float64(span) * float64(dotsX-1)))
+		}
+		y := dotsY / 2
+		if hi > lo {
+			y = int(math.Round((p.V - lo) / (hi - lo) * float64(dotsY-1)))
+		}
+		return x, y
+	}
+
+	for i, p := range points {
+		x1, y1 := pos(p)
+		if i == 0 || p.Gap {
+			set(x1, y1)
+			continue
+		}
+		// Join to the previous point
+		x0, y0 := pos(points[i-1])
+		steps := max(abs(x1-x0), abs(y1-y0))
+		for s := 0; s <= steps; s++ {
+			t := 0.0
+			if steps > 0 {
+				t = float64(s) / float64(steps)
+			}
+			set(x0+int(math.Round(t*float64(x1-x0))), y0+int(math.Round(t*float64(y1-y0))))
+		}
+	}
+
+	lines := make([]string, height)
+	for i, row := range cells {
+		var b strings.Builder
+		for _, c := range row {
+			switch {
+			case glyphs.ChartDot == 0:
+				b.WriteRune(0x2800 + c)
+			case c != 0:
+				b.WriteRune(glyphs.ChartDot)
+			default:
+				b.WriteRune(' ')
+			}
+		}
+		lines[i] = b.String()
+	}
+	return lines
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// renderSensorChart renders the history of the selected chart sensor in
+// width columns and rows chart lines, with the y-axis labeled at
+// min/mid/max and time ticks below.
+func (m model) renderSensorChart(dev *Device, width, rows int) string {
+	key := chartSensors[m.chartSensor]
+	title := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(awair.OptimalRanges[key].Label) +
+		lipgloss.NewStyle().Foreground(theme.Muted).Render("  ←/→ sensor")
+
+	// Gaps are anything longer than a few missed polls
+	points := historySeries(dev.History, key, 3*m.pollInterval)
+	if len(points) < 2 {
+		return title + "\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render("  not enough history yet")
+	}
+
+	lo, hi := points[0].V, points[0].V
+	for _, p := range points {
+		lo, hi = math.Min(lo, p.V), math.Max(hi, p.V)
+	}
+
+	labels := []string{awair.FormatValue(key, hi, m.fahrenheit), awair.FormatValue(key, (lo+hi)/2, m.fahrenheit), awair.FormatValue(key, lo, m.fahrenheit)}
+	axisWidth := 0
+	for _, l := range labels {
+		axisWidth = max(axisWidth, lipgloss.Width(l))
+	}
+	plotWidth := width - axisWidth - 2
+	if plotWidth < 10 {
+		return title + "\n" + lipgloss.NewStyle().Foreground(theme.Muted).Render("  too narrow for a chart")
+	}
+
+	gray := lipgloss.NewStyle().Foreground(theme.Muted)
+	plot := lipgloss.NewStyle().Foreground(ratingColor(awair.RateSensorValue(key, awair.DisplayValue(key, points[len(points)-1].V))))
+	lines := []string{title}
+	for i, row := range brailleChart(points, lo, hi, plotWidth, rows) {
+		label := ""
+		switch i {
+		case 0:
+			label = labels[0]
+		case rows / 2:
+			if rows > 2 {
+				label = labels[1]
+			}
+		case rows - 1:
+			label = labels[2]
+		}
+		lines = append(lines, gray.Render(visPadLeft(label, axisWidth)+" ┤")+plot.Render(row))
+	}
+
+	// Time axis: a tick roughly every 16 columns, labeled below
+	first, last := points[0].T, points[len(points)-1].T
+	layout := "15:04"
+	if last.Sub(first) < 10*time.Minute {
+		layout = "15:04:05"
+	}
+	ticks := max(2, plotWidth/16+1)
+	axis := []rune(strings.Repeat("─", plotWidth))
+	stamps := []rune(strings.Repeat(" ", plotWidth+8))
+	for i := 0; i < ticks; i++ {
+		col := i * (plotWidth - 1) / (ticks - 1)
+		axis[col] = '┬'
+		t := first.Add(time.Duration(float64(last.Sub(first)) * float64(col) / float64(plotWidth-1)))
+		stamp := t.Local().Format(layout)
+		start := min(max(col-len(stamp)/2, 0), plotWidth-len(stamp))
+		copy(stamps[start:], []rune(stamp))
+	}
+	pad := strings.Repeat(" ", axisWidth)
+	lines = append(lines,
+		gray.Render(pad+" └"+string(axis)),
+		gray.Render(pad+"  "+strings.TrimRight(string(stamps), " ")))
+	return strings.Join(lines, "\n")
+}
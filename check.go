@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Exit codes for --check, following the Nagios plugin convention.
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+)
+
+// ratingSeverity orders ratings from best to worst.
+func ratingSeverity(rating string) int {
+	switch rating {
+	case "good":
+		return 0
+	case "fair":
+		return 1
+	default:
+		return 2
+	}
+}
+
+// rangeOverride is a flag.Value that overrides the Min, Max or FairMargin
+// of a single OptimalRanges entry, e.g. --check-co2-max 1000.
+type rangeOverride struct {
+	key   string
+	field string // "min", "max" or "margin"
+}
+
+func (o rangeOverride) String() string { return "" }
+
+func (o rangeOverride) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	checkOverrides = append(checkOverrides, func() {
+		r := awair.OptimalRanges[o.key]
+		switch o.field {
+		case "min":
+			r.Min = v
+		case "max":
+			r.Max = v
+		case "margin":
+			r.FairMargin = v
+		}
+		awair.OptimalRanges[o.key] = r
+	})
+	return nil
+}
+
+// checkOverrides are the parsed --check-<sensor>-* flags, in command-line
+// order. With --check they are applied after the config is loaded, so
+// they win over its thresholds; other modes ignore them.
+var checkOverrides []func()
+
+// applyCheckOverrides applies the --check-<sensor>-* flags to OptimalRanges.
+func applyCheckOverrides() {
+	for _, apply := range checkOverrides {
+		apply()
+	}
+}
+
+// registerCheckFlags adds --check-<sensor>-min/max/margin flags for every sensor
+// in OptimalRanges. With --check, overrides apply to the shared ranges (see
+// applyCheckOverrides), so --check rates readings with exactly the same
+// code as the dashboard, which never sees them.
+func registerCheckFlags(fs *flag.FlagSet) {
+	keys := make([]string, 0, len(awair.OptimalRanges))
+	for k := range awair.OptimalRanges {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		r := awair.OptimalRanges[k]
+		name := strings.ReplaceAll(k, "_", "-")
+		if !r.LowerIsBetter {
+			fs.Var(rangeOverride{key: k, field: "min"}, "check-"+name+"-min",
+				fmt.Sprintf("With --check, lower bound of the good %s range (default %g %s)", r.Label, r.Min, r.Unit))
+		}
+		fs.Var(rangeOverride{key: k, field: "max"}, "check-"+name+"-max",
+			fmt.Sprintf("With --check, upper bound of the good %s range (default %g %s)", r.Label, r.Max, r.Unit))
+		fs.Var(rangeOverride{key: k, field: "margin"}, "check-"+name+"-margin",
+			fmt.Sprintf("With --check, how far outside the good %s range is still fair (default %g %s)", r.Label, r.FairMargin, r.Unit))
+	}
+}
+
+// runCheck polls the given devices once and prints a single Nagios-style
+// summary line. It returns 0 if every reading is good, 1 if any is fair,
+// and 2 if any is poor or a device is unreachable.
+func runCheck(cfg *Config, s Settings) int {
+	targets := oneShotTargets(s)
+	if len(targets) == 0 {
+		fmt.Println("CRITICAL - no devices found")
+		return checkCritical
+	}
+
+	results := pollOnce(cfg, targets, s.FetchDeviceConfig)
+
+	worstSeverity := -1
+	var worst string
+	for _, r := range results {
+		if r.Data == nil {
+			if worstSeverity < checkCritical {
+				worstSeverity = checkCritical
+				worst = fmt.Sprintf("%s unreachable (%s)", r.Name, r.Error)
+			}
+			continue
+		}
+		for _, s := range r.Data.Readings() {
+			rating := awair.RateSensorValue(s.Key, awair.DisplayValue(s.Key, s.Value))
+			if sev := ratingSeverity(rating); sev > worstSeverity {
+				worstSeverity = sev
+				worst = fmt.Sprintf("%s %s %s (%s)",
+					r.Name, s.Key, strings.ReplaceAll(awair.FormatValue(s.Key, s.Value, false), " ", ""), rating)
+			}
+		}
+	}
+
+	status := "OK"
+	code := checkOK
+	switch worstSeverity {
+	case 1:
+		status, code = "WARNING", checkWarning
+	case 2:
+		status, code = "CRITICAL", checkCritical
+	}
+
+	fmt.Printf("%s - %d devices, worst: %s\n", status, len(results), worst)
+	return code
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// clipboardTools are the commands tried, in order, when the terminal
+// can't take OSC 52. Those that aren't installed are skipped.
+var clipboardTools = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip"},
+}
+
+// errNoClipboard means neither the terminal nor a clipboard tool could
+// take the text.
+var errNoClipboard = errors.New("the terminal doesn't support OSC 52 and no clipboard tool (pbcopy, wl-copy, xclip, xsel, clip) works here")
+
+// osc52Supported reports whether the terminal is likely to honour OSC 52.
+// There is no reliable way to ask, so terminals known not to are ruled
+// out: the Linux console, Apple's Terminal and the Windows console host.
+func osc52Supported() bool {
+	if goos == "windows" {
+		return os.Getenv("WT_SESSION") != ""
+	}
+	if os.Getenv("TERM_PROGRAM") == "Apple_Terminal" {
+		return false
+	}
+	term := os.Getenv("TERM")
+	return term != "" && term != "dumb" && term != "linux"
+}
+
+// copyToClipboard puts text on the system clipboard and returns how:
+// with OSC 52 through the terminal, which works over SSH too, or else
+// with a local clipboard tool.
+func copyToClipboard(text string) (string, error) {
+	if osc52Supported() {
+		seq := ansi.SetSystemClipboard(text)
+		// tmux takes the sequence itself with set-clipboard on, and
+		// passes it through to the outer terminal with allow-passthrough
+		if os.Getenv("TMUX") != "" {
+			seq += ansi.TmuxPassthrough(seq)
+		}
+		if _, err := os.Stdout.WriteString(seq); err != nil {
+			return "", err
+		}
+		return "OSC 52", nil
+	}
+	for _, tool := range clipboardTools {
+		path, err := exec.LookPath(tool[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			logf(levelDebug, "clipboard: %s: %v", tool[0], err)
+			continue
+		}
+		return tool[0], nil
+	}
+	return "", errNoClipboard
+}
+
+// clipboardMsg reports a copy; What describes what was copied.
+type clipboardMsg struct {
+	What string
+	Via  string
+	Err  error
+}
+
+func clipboardCmd(what, text string) tea.Cmd {
+	return trackCmd("clipboard", func() tea.Msg {
+		via, err := copyToClipboard(text)
+		return clipboardMsg{What: what, Via: via, Err: err}
+	})
+}
+
+// copyAddress copies dev's address (y), or its UUID for a cloud device,
+// which has none.
+func (m *model) copyAddress(dev *Device) tea.Cmd {
+	if isCloud(dev.IP) {
+		if dev.UUID == "" {
+			m.logAt(levelWarn, fmt.Sprintf("%s: no UUID to copy yet", dev.Name))
+			return nil
+		}
+		return clipboardCmd(fmt.Sprintf("%s's UUID %s", dev.Name, dev.UUID), dev.UUID)
+	}
+	return clipboardCmd(fmt.Sprintf("%s's address %s", dev.Name, dev.IP), dev.IP)
+}
+
+// deviceDump is what Y copies: the device's latest reading and config as
+// the device sent them.
+type deviceDump struct {
+	Name   string              `json:"name"`
+	IP     string              `json:"ip"`
+	UUID   string              `json:"uuid,omitempty"`
+	Data   *awair.SensorData   `json:"data"`
+	Config *awair.DeviceConfig `json:"config"`
+}
+
+// copyDeviceJSON copies dev's latest reading and config as JSON (Y in the
+// detail view).
+func (m *model) copyDeviceJSON(dev *Device) tea.Cmd {
+	if dev.Data == nil && dev.Config == nil {
+		m.logAt(levelWarn, fmt.Sprintf("%s: nothing to copy yet", dev.Name))
+		return nil
+	}
+	raw, err := json.MarshalIndent(deviceDump{Name: dev.Name, IP: dev.IP, UUID: dev.UUID, Data: dev.Data, Config: dev.Config}, "", "  ")
+	if err != nil {
+		m.logAt(levelError, fmt.Sprintf("%s: can't encode the device info: %v", dev.Name, err))
+		return nil
+	}
+	return clipboardCmd(fmt.Sprintf("%s's reading and config (%d bytes of JSON)", dev.Name, len(raw)), string(raw))
+}
+
+// handleClipboard logs what was copied, or why it couldn't be.
+func (m *model) handleClipboard(msg clipboardMsg) {
+	if msg.Err != nil {
+		m.logAt(levelError, fmt.Sprintf("Can't copy %s: %v", msg.What, msg.Err))
+		return
+	}
+	m.addLog(fmt.Sprintf("Copied %s to the clipboard (%s)", msg.What, msg.Via))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// defaultMaxClockSkew is how far a device's clock may be off before its
+// card warns. Samples are up to ~10s old when fetched, well inside it.
+const defaultMaxClockSkew = 2 * time.Minute
+
+// Which time --once --json and --events report for a reading, see
+// readingTime.
+const (
+	exportTimeReceived = "received"
+	exportTimeDevice   = "device"
+)
+
+// validExportTime reports whether mode is a known export time.
+func validExportTime(mode string) bool {
+	return mode == exportTimeReceived || mode == exportTimeDevice
+}
+
+// readingTime returns the time to report for a reading fetched at
+// received: the device's own timestamp in device mode, if it has one.
+func readingTime(data *awair.SensorData, received time.Time, mode string) time.Time {
+	if mode == exportTimeDevice {
+		if t := parseDeviceTime(data.Timestamp); !t.IsZero() {
+			return t
+		}
+	}
+	return received
+}
+
+// clockSkewed reports whether dev's clock is further off than
+// maxClockSkew. Devices that send no timestamp never are.
+func (m model) clockSkewed(dev *Device) bool {
+	skew := dev.ClockSkew
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew > m.maxClockSkew
+}
+
+// checkClockSkew measures how far the time of the sample dev just
+// returned is from the time of the poll, and logs the first time in the
+// session that it is too far.
+func (m *model) checkClockSkew(dev *Device, sample time.Time) {
+	dev.ClockSkew = 0
+	if sample.IsZero() || dev.endpoint != awair.Latest {
+		// Averages are stamped up to their period before now
+		return
+	}
+	dev.ClockSkew = sample.Sub(dev.LastUpdate)
+	if !m.clockSkewed(dev) || dev.skewLogged {
+		return
+	}
+	dev.skewLogged = true
+	m.logAt(levelWarn, fmt.Sprintf("%s: device clock is %s (sample time %s); its timestamps are wrong",
+		dev.Name, describeSkew(dev.ClockSkew), formatSampleTime(sample)))
+}
+
+// describeSkew formats a clock offset as "3m ahead" or "2d behind".
+func describeSkew(skew time.Duration) string {
+	dir := "ahead"
+	if skew < 0 {
+		skew, dir = -skew, "behind"
+	}
+	if skew >= 48*time.Hour {
+		return fmt.Sprintf("%dd %s", int(skew.Hours()/24), dir)
+	}
+	return shortDuration(skew) + " " + dir
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// cloudBaseURL is the Awair developer API
+// (https://developer.getawair.com), for devices whose local API can't be
+// reached.
+const cloudBaseURL = "https://developer-apis.awair.is/v1"
+
+// cloudMinInterval is how often a cloud device is polled at most.
+const cloudMinInterval = 5 * time.Minute
+
+// cloudDailyQuota is how many air-data calls the developer API allows per
+// device per day on its Hobbyist tier. Days start at midnight UTC.
+const cloudDailyQuota = 300
+
+// cloudToken is the bearer token for the developer API, set by
+// configureHTTP from --cloud-token. It must never be logged.
+var cloudToken string
+
+// Cloud devices are keyed "cloud:<device type>/<device id>", which
+// stands in for an address everywhere a device is keyed by one. The
+// key cloudAccount is the account itself, for listing its devices.
+const (
+	cloudPrefix  = "cloud:"
+	cloudAccount = cloudPrefix
+)
+
+// isCloud reports whether a device address is a cloud device key.
+func isCloud(addr string) bool {
+	return strings.HasPrefix(addr, cloudPrefix)
+}
+
+// cloudURL is deviceURL for cloud device keys.
+func cloudURL(addr, path string) string {
+	u := cloudBaseURL + "/users/self/devices"
+	if id := strings.TrimPrefix(addr, cloudPrefix); id != "" {
+		u += "/" + id
+	}
+	return u + path
+}
+
+// CloudDevice is a device listed by the developer API.
+type CloudDevice struct {
+	Name       string `json:"name"`
+	DeviceID   int    `json:"deviceId"`
+	DeviceType string `json:"deviceType"`
+	DeviceUUID string `json:"deviceUUID"`
+}
+
+// Key returns the device's key, see isCloud.
+func (d CloudDevice) Key() string {
+	return cloudPrefix + d.DeviceType + "/" + strconv.Itoa(d.DeviceID)
+}
+
+// FetchCloudDevices lists the devices of the account cloudToken is for.
+func FetchCloudDevices(ctx context.Context) ([]CloudDevice, error) {
+	var list struct {
+		Devices []CloudDevice `json:"devices"`
+	}
+	if err := deviceClient(cloudAccount).Get(ctx, "", &list); err != nil {
+		return nil, err
+	}
+	return list.Devices, nil
+}
+
+// cloudAirData is the developer API's air-data/latest answer: the
+// sensors come as a list of components rather than fields.
+type cloudAirData struct {
+	Data []struct {
+		Timestamp string  `json:"timestamp"`
+		Score     float64 `json:"score"`
+		Sensors   []struct {
+			Comp  string  `json:"comp"`
+			Value float64 `json:"value"`
+		} `json:"sensors"`
+	} `json:"data"`
+}
+
+// errNoCloudData is a cloud device without a recent reading, such as
+// one that has been offline for a while.
+var errNoCloudData = errors.New("no recent data")
+
+// fetchCloudAirData is FetchAirData for cloud devices. The reading is
+// rebuilt as the local API would send it, so sensors the device lacks
+// are reported missing the same way.
+func fetchCloudAirData(ctx context.Context, key string) (*awair.SensorData, error) {
+	var latest cloudAirData
+	if err := deviceClient(key).Get(ctx, awair.PathAirData, &latest); err != nil {
+		return nil, err
+	}
+	if len(latest.Data) == 0 {
+		return nil, &awair.FetchError{URL: cloudURL(key, awair.PathAirData), Path: awair.PathAirData, Err: errNoCloudData}
+	}
+	d := latest.Data[0]
+	fields := map[string]any{"timestamp": d.Timestamp, "score": d.Score}
+	for _, s := range d.Sensors {
+		fields[s.Comp] = s.Value
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var data awair.SensorData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, &awair.FetchError{URL: cloudURL(key, awair.PathAirData), Path: awair.PathAirData, Err: err}
+	}
+	return &data, nil
+}
+
+// cloudDevicesMsg carries the devices listed by the developer API.
+type cloudDevicesMsg struct {
+	Devices []CloudDevice
+	Err     error
+}
+
+func cloudDevicesCmd(ctx context.Context) tea.Cmd {
+	return trackCmd("cloud devices", func() tea.Msg {
+		devices, err := FetchCloudDevices(ctx)
+		return cloudDevicesMsg{Devices: devices, Err: err}
+	})
+}
+
+// addCloudDevices adds the listed cloud devices next to the local ones
+// and returns the commands that load them.
+func (m *model) addCloudDevices(msg cloudDevicesMsg) []tea.Cmd {
+	if msg.Err != nil {
+		logf(levelDebug, "cloud devices: %v", msg.Err)
+		m.logAt(levelError, "Awair Cloud: can't list devices: "+errorSummary(msg.Err))
+		return nil
+	}
+	var cmds []tea.Cmd
+	for _, d := range msg.Devices {
+		key := d.Key()
+		if _, ok := m.devices[key]; ok {
+			continue
+		}
+		dev := m.addDevice(key, d.Name)
+		dev.UUID = d.DeviceUUID
+		dev.Config = &awair.DeviceConfig{DeviceUUID: d.DeviceUUID}
+		dev.endpoint = awair.Latest
+		cmds = append(cmds, m.fetchCmds(key)...)
+	}
+	m.addLog(fmt.Sprintf("Awair Cloud: %d device(s), polled every %s at most and %d times a day", len(msg.Devices), cloudMinInterval, cloudDailyQuota))
+	return cmds
+}
+
+// CloudUsage counts a cloud device's air-data calls on one quota day.
+type CloudUsage struct {
+	Day   string `json:"day"` // 2006-01-02, UTC
+	Calls int    `json:"calls"`
+}
+
+// cloudDay returns the quota day t falls in, and when the next one
+// starts.
+func cloudDay(t time.Time) (day string, next time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return start.Format(time.DateOnly), start.AddDate(0, 0, 1)
+}
+
+// CloudCalls returns how many calls the cloud device key has made on
+// now's quota day.
+func (s *RecordStore) CloudCalls(key string, now time.Time) int {
+	day, _ := cloudDay(now)
+	if u := s.Cloud[key]; u != nil && u.Day == day {
+		return u.Calls
+	}
+	return 0
+}
+
+// CountCloudCall counts a call the cloud device key makes at now.
+func (s *RecordStore) CountCloudCall(key string, now time.Time) {
+	if s.Cloud == nil {
+		s.Cloud = make(map[string]*CloudUsage)
+	}
+	day, _ := cloudDay(now)
+	u := s.Cloud[key]
+	if u == nil || u.Day != day {
+		u = &CloudUsage{Day: day}
+		s.Cloud[key] = u
+	}
+	u.Calls++
+	s.dirty = true
+}
+
+// cloudQuotaLeft returns how many calls dev has left today; local
+// devices are never short.
+func (m model) cloudQuotaLeft(dev *Device, now time.Time) int {
+	if !isCloud(dev.IP) {
+		return math.MaxInt
+	}
+	return max(cloudDailyQuota-m.records.CloudCalls(dev.IP, now), 0)
+}
+
+// cloudInterval is how long a cloud device with left calls waits between
+// polls at now: cloudMinInterval, or longer to spread them until midnight
+// UTC, so a day that started with a lot of restarts doesn't run out.
+func cloudInterval(left int, now time.Time) time.Duration {
+	_, next := cloudDay(now)
+	if left <= 0 {
+		return next.Sub(now)
+	}
+	return max(cloudMinInterval, next.Sub(now)/time.Duration(left))
+}
+
+// cloudPollDue reports whether dev may be polled now: local devices
+// always; cloud devices once per cloudInterval, which this starts, until
+// today's quota is used up. The poll it allows is counted against it.
+func (m *model) cloudPollDue(dev *Device) bool {
+	if !isCloud(dev.IP) {
+		return true
+	}
+	now := time.Now()
+	left := m.cloudQuotaLeft(dev, now)
+	if left == 0 {
+		if !dev.cloudExhausted {
+			dev.cloudExhausted = true
+			m.logAt(levelWarn, fmt.Sprintf("%s: Awair Cloud quota of %d calls used up; polling again after midnight UTC", dev.Name, cloudDailyQuota))
+		}
+		return false
+	}
+	dev.cloudExhausted = false
+	// A second's slack, so ticks that land just short still count
+	if !dev.cloudPolled.IsZero() && age(dev.cloudPolled) < cloudInterval(left, now)-time.Second {
+		return false
+	}
+	dev.cloudPolled = now
+	m.records.CountCloudCall(dev.IP, now)
+	return true
+}
+
+// cloudExhaustedText is a cloud device's card once its quota is used up.
+const cloudExhaustedText = "Cloud quota exhausted until midnight UTC"
+
+// cloudQuotaText describes dev's quota for the detail view.
+func (m model) cloudQuotaText(dev *Device) string {
+	now := time.Now()
+	left := m.cloudQuotaLeft(dev, now)
+	if left == 0 {
+		_, next := cloudDay(now)
+		return lipgloss.NewStyle().Foreground(theme.Fair).Render(
+			fmt.Sprintf("exhausted until midnight UTC (in %s)", shortDuration(next.Sub(now))))
+	}
+	return fmt.Sprintf("%d of %d calls left today, polling every %s", left, cloudDailyQuota, shortDuration(cloudInterval(left, now)))
+}
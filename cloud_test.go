@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCloudInterval(t *testing.T) {
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		at   time.Duration // after midnight UTC
+		left int
+		want time.Duration
+	}{
+		{0, cloudDailyQuota, cloudMinInterval}, // 24h over 300 is under 5m
+		{12 * time.Hour, 72, 10 * time.Minute},
+		{23*time.Hour + 50*time.Minute, cloudDailyQuota, cloudMinInterval},
+		{23 * time.Hour, 0, time.Hour}, // until midnight
+	}
+	for _, tt := range tests {
+		if got := cloudInterval(tt.left, day.Add(tt.at)); got != tt.want {
+			t.Errorf("%v after midnight with %d left: %v, want %v", tt.at, tt.left, got, tt.want)
+		}
+	}
+	// Days are UTC whatever the local zone
+	east := time.FixedZone("UTC+10", 10*3600)
+	if d, next := cloudDay(time.Date(2026, 6, 2, 8, 0, 0, 0, east)); d != "2026-06-01" || !next.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("day %s, next %v", d, next)
+	}
+}
+
+func TestCloudQuota(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	local := m.devices["192.0.2.1"]
+	dev := m.addDevice("cloud:awair-element/7", "Office")
+	now := time.Now()
+	today, _ := cloudDay(now)
+
+	// Yesterday's calls don't count
+	m.records.Cloud = map[string]*CloudUsage{dev.IP: {Day: "2000-01-01", Calls: cloudDailyQuota}}
+	if left := m.cloudQuotaLeft(dev, now); left != cloudDailyQuota {
+		t.Errorf("%d calls left on a new day", left)
+	}
+
+	m.records.Cloud[dev.IP] = &CloudUsage{Day: today, Calls: cloudDailyQuota - 1}
+	if !m.cloudPollDue(dev) {
+		t.Fatal("the last call of the day wasn't allowed")
+	}
+	if u := m.records.Cloud[dev.IP]; u.Calls != cloudDailyQuota || !m.records.dirty {
+		t.Errorf("calls %d, dirty %v", u.Calls, m.records.dirty)
+	}
+	// Within the interval
+	if m.cloudPollDue(dev) {
+		t.Error("polled again right away")
+	}
+
+	// Out of quota: no polls however long it has been, said once
+	dev.cloudPolled = time.Time{}
+	for range 2 {
+		if m.cloudPollDue(dev) {
+			t.Error("polled past the quota")
+		}
+	}
+	if got := lastLog(m); !strings.Contains(got, "quota of 300 calls used up") {
+		t.Errorf("log %q", got)
+	}
+	n := 0
+	for _, l := range m.logs {
+		if strings.Contains(l.Message, "used up") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("logged %d times", n)
+	}
+	if got := m.retryText(dev); got != cloudExhaustedText {
+		t.Errorf("retry text %q", got)
+	}
+	if got := m.cloudQuotaText(dev); !strings.Contains(got, "exhausted until midnight UTC") {
+		t.Errorf("detail %q", got)
+	}
+
+	// Local devices have no quota
+	for range 3 {
+		if !m.cloudPollDue(local) {
+			t.Error("a local device wasn't due")
+		}
+	}
+	if _, ok := m.records.Cloud[local.IP]; ok {
+		t.Error("counted a local device's polls")
+	}
+
+	// The count survives a restart
+	SaveRecords(m.records)
+	if got := loadTestRecords(t).CloudCalls(dev.IP, now); got != cloudDailyQuota {
+		t.Errorf("loaded %d calls, want %d", got, cloudDailyQuota)
+	}
+}
@@ -1,52 +1,626 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Sources of a saved device entry.
+const (
+	entryManual     = "manual"     // added via the prompt; re-added on startup
+	entryDiscovered = "discovered" // found via discovery; re-added on startup with --remember-discovered
+	entryNameOnly   = ""           // only a friendly name is saved
 )
 
+// DeviceEntry is a device saved in the config. Entries are matched to
+// devices by UUID, which is filled in once the device config has been
+// fetched, and by IP until then.
+type DeviceEntry struct {
+	IP       string `json:"ip"`
+	UUID     string `json:"uuid,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Instance string `json:"instance,omitempty"` // mDNS instance name, for --remember-discovered
+	Source   string `json:"source,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"` // air data endpoint to poll, overriding poll_endpoint
+}
+
+// key identifies the entry: its UUID if known, otherwise its address.
+func (e DeviceEntry) key() string {
+	if e.UUID != "" {
+		return "uuid:" + e.UUID
+	}
+	return canonicalIP(e.IP)
+}
+
+// deviceEntryAlias has DeviceEntry's fields without its JSON method, so it
+// marshals without credentials.
+type deviceEntryAlias DeviceEntry
+
+// MarshalJSON saves the entry with the credentials given for its address,
+// which are kept out of IP everywhere else; see normalizeURL.
+func (e DeviceEntry) MarshalJSON() ([]byte, error) {
+	e.IP = withCredentials(e.IP)
+	return json.Marshal(deviceEntryAlias(e))
+}
+
+// UnmarshalJSON also accepts a bare address, so a hand-written default
+// device list can be just ["192.168.1.10", "bedroom.local"]. Such entries
+// are manual ones and are saved back as objects.
+func (e *DeviceEntry) UnmarshalJSON(data []byte) error {
+	var addr string
+	if err := json.Unmarshal(data, &addr); err == nil {
+		*e = DeviceEntry{IP: addr, Source: entryManual}
+		return nil
+	}
+	return json.Unmarshal(data, (*deviceEntryAlias)(e))
+}
+
+// DeviceList is the saved device list.
+type DeviceList []DeviceEntry
+
 // Config holds persistent application configuration.
 type Config struct {
-	Devices map[string]string `json:"devices"` // IP → friendly name
+	Version int `json:"version"` // schema version, see migrate.go
+
+	Devices       DeviceList `json:"devices"`
+	Order         []string   `json:"order,omitempty"`          // device IPs in dashboard order
+	Interval      int        `json:"interval,omitempty"`       // polling interval in seconds
+	Fahrenheit    *bool      `json:"fahrenheit,omitempty"`     // nil = Celsius
+	NoDiscovery   *bool      `json:"no_discovery,omitempty"`   // true: only saved and command-line devices
+	Sort          string     `json:"sort,omitempty"`           // device sort mode, see sort.go
+	MaxDiscovered int        `json:"max_discovered,omitempty"` // auto-add limit for discovered devices
+	SlowTerminal  *bool      `json:"slow_terminal,omitempty"`  // nil = auto-detect over SSH
+	ASCII         *bool      `json:"ascii,omitempty"`          // nil = auto-detect legacy Windows consoles
+	Bell          *bool      `json:"bell,omitempty"`           // false: no bell when a sensor turns poor
+	Flash         *bool      `json:"flash,omitempty"`          // false: no card highlight when a sensor turns poor
+	Mouse         *bool      `json:"mouse,omitempty"`          // false: leave the mouse to the terminal
+
+	// RememberDiscovered saves discovered devices so they are added at
+	// startup, before discovery finds them again.
+	RememberDiscovered *bool `json:"remember_discovered,omitempty"`
+
+	// SmoothScore shows the median (or, with SmoothMode "mean", the mean)
+	// of the last SmoothScore scores on cards instead of the latest one.
+	SmoothScore int    `json:"smooth_score,omitempty"`
+	SmoothMode  string `json:"smooth_mode,omitempty"`
+
+	// Advisories in device details: mold risk after humidity has been
+	// above 60% for MoldMinutes, ventilate after CO₂ has been above
+	// VentilateCO2 ppm for VentilateMinutes. 0 keeps the default.
+	MoldMinutes      int `json:"mold_minutes,omitempty"`
+	VentilateCO2     int `json:"ventilate_co2,omitempty"`
+	VentilateMinutes int `json:"ventilate_minutes,omitempty"`
+
+	// HTTPTimeout is the per-attempt timeout for device requests in
+	// seconds, HTTPRetries how often transient failures are retried.
+	HTTPTimeout float64 `json:"http_timeout,omitempty"`
+	HTTPRetries int     `json:"http_retries,omitempty"`
+
+	// SlowLatency is the poll response time in seconds above which a
+	// card shows the device's latency as slow.
+	SlowLatency float64 `json:"slow_latency,omitempty"`
+
+	// MaxClockSkew is how far in seconds a device's clock may be off
+	// before its card warns; ExportTime is which time --once --json and
+	// --events report readings at ("received" or "device").
+	MaxClockSkew float64 `json:"max_clock_skew,omitempty"`
+	ExportTime   string  `json:"export_time,omitempty"`
+
+	// CloudToken is an Awair developer API token; its account's devices
+	// are polled through the cloud next to the local ones.
+	CloudToken string `json:"cloud_token,omitempty"`
+
+	// PollEndpoint is the /air-data/ endpoint devices are polled at:
+	// "latest", "10-sec-avg" or "5-min-avg". Saved devices can override it.
+	PollEndpoint string `json:"poll_endpoint,omitempty"`
+
+	// DB is the path of a SQLite database every sample is saved to;
+	// DBRetain how long they are kept there, e.g. "30d" or "72h", and
+	// DBRaw how long before they are compacted.
+	DB       string `json:"db,omitempty"`
+	DBRetain string `json:"db_retain,omitempty"`
+	DBRaw    string `json:"db_raw,omitempty"`
+
+	// Serve is the address the HTTP API listens on, e.g. ":8080".
+	Serve string `json:"serve,omitempty"`
+
+	// AlertWebhookTemplate is a text/template for the body --alert-webhook
+	// posts, AlertExecArgs templates for the arguments --alert-exec gets.
+	// Both are executed with the alert; see alertEvent.
+	AlertWebhookTemplate string   `json:"alert_webhook_template,omitempty"`
+	AlertExecArgs        []string `json:"alert_exec_args,omitempty"`
+
+	// HistoryEndpoint is which averages seed a device's history when it
+	// is added: "5-min-avg", "15-min-avg" or "off".
+	HistoryEndpoint string `json:"history_endpoint,omitempty"`
+
+	// mDNS discovery: service types to query (default ["_http._tcp"]),
+	// a regular expression instance names or TXT records must match
+	// (default "awair", case-insensitive) and the re-query interval in
+	// seconds (default 30).
+	DiscoveryServices []string `json:"discovery_services,omitempty"`
+	DiscoveryMatch    string   `json:"discovery_match,omitempty"`
+	DiscoveryInterval int      `json:"discovery_interval,omitempty"`
+
+	// FetchDeviceConfig set to false skips /settings/config/data entirely;
+	// devices are then named and keyed by mDNS name or IP only.
+	FetchDeviceConfig *bool `json:"fetch_device_config,omitempty"`
+	ShowFirmware      *bool `json:"show_firmware,omitempty"` // firmware version in card footers
+
+	LastSeenVersion string `json:"last_seen_version,omitempty"` // for the what's-new overlay
+	WhatsNew        *bool  `json:"whats_new,omitempty"`         // false disables the overlay
+
+	// Theme is "default", "colorblind" or "light"; ThemeColors overrides
+	// its colors with hex values, e.g. {"good": "#0072B2"}. Keys: good,
+	// fair, poor, accent, muted, dim, bar_fg, bar_bg.
+	Theme       string            `json:"theme,omitempty"`
+	ThemeColors map[string]string `json:"theme_colors,omitempty"`
+
+	// CardSensors lists the sensor keys shown on grid cards, in order,
+	// e.g. ["co2", "pm25", "temp"]. Empty shows them all.
+	CardSensors []string `json:"card_sensors,omitempty"`
+
+	// Thresholds overrides OptimalRanges per sensor key, e.g.
+	// {"co2": {"max": 800}}. Temperatures are in °F like the defaults.
+	Thresholds map[string]SensorThreshold `json:"thresholds,omitempty"`
+
+	// extra holds top-level fields this version doesn't know about (e.g.
+	// written by a newer release), so saving doesn't drop them.
+	extra map[string]json.RawMessage
+
+	// loadErr is why the config file couldn't be loaded, if it couldn't;
+	// SaveConfig refuses to overwrite it then.
+	loadErr error
+
+	// memory marks a config that is never saved, the blank one --demo
+	// runs with.
+	memory bool
+}
+
+// SensorThreshold overrides parts of one sensor's SensorRange. Unset
+// fields keep the default.
+type SensorThreshold struct {
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	Margin *float64 `json:"margin,omitempty"` // FairMargin
+}
+
+// configAlias has Config's fields without its JSON methods.
+type configAlias Config
+
+func (c *Config) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*configAlias)(c)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, name := range configFieldNames() {
+		delete(fields, name)
+	}
+	c.extra = fields
+	return nil
+}
+
+func (c *Config) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal((*configAlias)(c))
+	if err != nil || len(c.extra) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, v := range c.extra {
+		if _, known := fields[name]; !known {
+			fields[name] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// configFieldNames returns the JSON names of Config's own fields.
+func configFieldNames() []string {
+	t := reflect.TypeOf(configAlias{})
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// applyThresholds merges the configured threshold overrides into
+// OptimalRanges. Entries for unknown sensors, or that would leave min >= max
+// or a negative margin, are logged and ignored.
+func (c *Config) applyThresholds() {
+	keys := make([]string, 0, len(c.Thresholds))
+	for k := range c.Thresholds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		t := c.Thresholds[k]
+		r, ok := awair.OptimalRanges[k]
+		if !ok {
+			logf(levelWarn, "config thresholds: unknown sensor %q ignored", k)
+			continue
+		}
+		if t.Min != nil {
+			r.Min = *t.Min
+		}
+		if t.Max != nil {
+			r.Max = *t.Max
+		}
+		if t.Margin != nil {
+			r.FairMargin = *t.Margin
+		}
+		if r.Min >= r.Max {
+			logf(levelWarn, "config thresholds: %s min %g is not below max %g; ignored", k, r.Min, r.Max)
+			continue
+		}
+		if r.FairMargin < 0 {
+			logf(levelWarn, "config thresholds: %s margin %g is negative; ignored", k, r.FairMargin)
+			continue
+		}
+		awair.OptimalRanges[k] = r
+	}
+}
+
+// canonicalize rewrites saved addresses in normalizeIP form, merging
+// entries that were saved under different spellings of one address or
+// with the same UUID.
+func (c *Config) canonicalize() {
+	devices := c.Devices[:0]
+	seen := make(map[string]bool)
+	for _, e := range c.Devices {
+		e.IP = canonicalIP(e.IP)
+		if seen[e.key()] {
+			continue
+		}
+		seen[e.key()] = true
+		devices = append(devices, e)
+	}
+	c.Devices = devices
+
+	order := c.Order[:0]
+	seen = make(map[string]bool)
+	for _, ip := range c.Order {
+		ip = canonicalIP(ip)
+		if !seen[ip] {
+			seen[ip] = true
+			order = append(order, ip)
+		}
+	}
+	c.Order = order
+}
+
+// Entry returns the saved entry for the device with the given UUID, or
+// nil. Without a UUID, or if none is saved for it, the entry for ip
+// stands in, unless it belongs to another UUID.
+func (c *Config) Entry(uuid, ip string) *DeviceEntry {
+	if i := c.entryIndex(uuid, ip); i >= 0 {
+		return &c.Devices[i]
+	}
+	return nil
 }
 
+// entryIndex is the index of the entry Entry returns, or -1.
+func (c *Config) entryIndex(uuid, ip string) int {
+	if uuid != "" {
+		for i, e := range c.Devices {
+			if e.UUID == uuid {
+				return i
+			}
+		}
+	}
+	for i, e := range c.Devices {
+		if e.IP == ip && (uuid == "" || e.UUID == "") {
+			return i
+		}
+	}
+	return -1
+}
+
+// Name returns the saved friendly name for a device, or "".
+func (c *Config) Name(uuid, ip string) string {
+	if e := c.Entry(uuid, ip); e != nil {
+		return e.Name
+	}
+	return ""
+}
+
+// SetName saves a friendly name for a device. An empty name clears it,
+// dropping the entry entirely if nothing else was saved for the device.
+func (c *Config) SetName(uuid, ip, name string) {
+	e := c.Entry(uuid, ip)
+	switch {
+	case e != nil:
+		e.Name = name
+		if name == "" && e.Source == entryNameOnly {
+			c.Forget(uuid, ip)
+		}
+	case name != "":
+		c.Devices = append(c.Devices, DeviceEntry{IP: ip, UUID: uuid, Name: name})
+	}
+}
+
+// Remember saves ip as a device from the given source, keeping any name
+// already saved for it. A manual entry is never downgraded.
+func (c *Config) Remember(ip, name, source string) {
+	e := c.Entry("", ip)
+	if e == nil {
+		c.Devices = append(c.Devices, DeviceEntry{IP: ip, Name: name, Source: source})
+		return
+	}
+	if name != "" {
+		e.Name = name
+	}
+	if e.Source != entryManual {
+		e.Source = source
+	}
+}
+
+// Identify records that the device at ip has the given UUID, on the entry
+// saved for either, and reports whether anything changed. An entry saved
+// for the UUID at another IP address moves to ip, so the device keeps its
+// name across DHCP address changes; entries keyed by host name or URL
+// stay as they are.
+func (c *Config) Identify(uuid, ip string) bool {
+	i := c.entryIndex(uuid, ip)
+	if i < 0 {
+		return false
+	}
+	e := &c.Devices[i]
+	switch {
+	case e.UUID == "":
+		e.UUID = uuid
+		return true
+	case e.IP == ip || !isIPAddress(e.IP) || !isIPAddress(ip):
+		return false
+	}
+	e.IP = ip
+	// An entry saved at the new address before the device was known
+	// there, e.g. by --remember-discovered, is folded into this one
+	for j, other := range c.Devices {
+		if j != i && other.IP == ip && other.UUID == "" {
+			e.absorb(other)
+			c.Devices = slices.Delete(c.Devices, j, j+1)
+			break
+		}
+	}
+	return true
+}
+
+// absorb fills in what e lacks from other, an entry for the same device:
+// a name, an instance name, or a source that adds the device at startup.
+func (e *DeviceEntry) absorb(other DeviceEntry) {
+	if e.Name == "" {
+		e.Name = other.Name
+	}
+	if e.Instance == "" {
+		e.Instance = other.Instance
+	}
+	if e.Source != entryManual && other.Source != entryNameOnly {
+		e.Source = other.Source
+	}
+}
+
+// AddDiscovered saves a discovered device, with its mDNS instance
+// name, to be added at startup, and reports whether anything changed.
+// Entries added by hand stay manual.
+func (c *Config) AddDiscovered(uuid, ip, instance string) bool {
+	e := c.Entry(uuid, ip)
+	if e == nil {
+		c.Devices = append(c.Devices, DeviceEntry{IP: ip, UUID: uuid, Instance: instance, Source: entryDiscovered})
+		return true
+	}
+	changed := false
+	if e.Source == entryNameOnly {
+		e.Source = entryDiscovered
+		changed = true
+	}
+	if instance != "" && e.Instance != instance {
+		e.Instance = instance
+		changed = true
+	}
+	return changed
+}
+
+// ForgetDiscovered stops adding a remembered discovered device at startup,
+// keeping its name if it has one, and reports whether anything changed.
+func (c *Config) ForgetDiscovered(uuid, ip string) bool {
+	e := c.Entry(uuid, ip)
+	switch {
+	case e == nil || e.Source != entryDiscovered:
+		return false
+	case e.Name == "":
+		c.Forget(uuid, ip)
+	default:
+		e.Source, e.Instance = entryNameOnly, ""
+	}
+	return true
+}
+
+// Forget removes everything saved for a device.
+func (c *Config) Forget(uuid, ip string) {
+	if i := c.entryIndex(uuid, ip); i >= 0 {
+		c.Devices = append(c.Devices[:i], c.Devices[i+1:]...)
+	}
+}
+
+// DevicesFrom returns the entries from the given source, in saved order.
+func (c *Config) DevicesFrom(source string) []DeviceEntry {
+	var out []DeviceEntry
+	for _, e := range c.Devices {
+		if e.Source == source {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// configFile is the config file given with --config, if any.
+var configFile string
+
+// configPath is the --config file, or awair-tui/config.json in the user
+// config directory: $XDG_CONFIG_HOME (usually ~/.config) on Linux,
+// ~/Library/Application Support on macOS and %AppData% on Windows.
+// Configs from before live in ~/.awair-tui.json; see moveLegacyFiles.
 func configPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ".awair-tui.json"
+	if configFile != "" {
+		return configFile
 	}
-	return filepath.Join(home, ".awair-tui.json")
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "awair-tui", "config.json")
+	}
+	return legacyFilePath(".awair-tui.json")
 }
 
-// LoadConfig reads the config file from ~/.awair-tui.json.
-// Returns an empty config on any error.
-func LoadConfig() *Config {
-	cfg := &Config{Devices: make(map[string]string)}
+// LoadConfig reads the config file at configPath, upgrading it to the
+// current schema version first if it is older. A missing file gives an
+// empty config. So does a file that can't be read or parsed, along with
+// the error; that config refuses to be saved, so the file isn't replaced
+// before the user has fixed it.
+func LoadConfig() (*Config, error) {
+	cfg := &Config{Version: configVersion, Devices: DeviceList{}}
 
 	data, err := os.ReadFile(configPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
 	if err != nil {
-		return cfg
+		cfg.loadErr = err
+		return cfg, err
 	}
+	lastSaved = data
 
 	var parsed Config
-	if err := json.Unmarshal(data, &parsed); err != nil {
-		return cfg
+	migrated, err := parseConfig(data, &parsed)
+	if err != nil {
+		cfg.loadErr = fmt.Errorf("%s: %w", configPath(), err)
+		return cfg, cfg.loadErr
+	}
+
+	if parsed.Devices == nil {
+		parsed.Devices = cfg.Devices
+	}
+	parsed.canonicalize()
+	parsed.applyThresholds()
+	if migrated {
+		if err := SaveConfig(&parsed); err != nil {
+			logf(levelError, "saving upgraded config: %v", err)
+		}
 	}
+	return &parsed, nil
+}
 
-	if parsed.Devices != nil {
-		cfg.Devices = parsed.Devices
+// parseConfig upgrades data to the current schema and decodes it into cfg,
+// reporting whether it was upgraded. Errors in the JSON say where they
+// are.
+func parseConfig(data []byte, cfg *Config) (migrated bool, err error) {
+	upgraded, migrated, err := migrateConfig(data)
+	if err == nil {
+		err = json.Unmarshal(upgraded, cfg)
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr) && !migrated:
+		return false, fmt.Errorf("%s: %w", jsonPosition(data, syntaxErr.Offset), err)
+	case errors.As(err, &typeErr) && !migrated:
+		return false, fmt.Errorf("%s: %w", jsonPosition(data, typeErr.Offset), err)
 	}
-	return cfg
+	return migrated, err
 }
 
-// SaveConfig writes the config to ~/.awair-tui.json.
-// Errors are silently ignored.
-func SaveConfig(cfg *Config) {
+// jsonPosition turns an offset into data into "line L, column C".
+func jsonPosition(data []byte, offset int64) string {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d", line, col)
+}
+
+// SaveConfig writes the config to configPath. If the file was edited
+// since it was last loaded or saved, those edits are merged into cfg
+// first rather than overwritten (see mergeConfig). A config that failed to
+// load is never saved, and an in-memory one (--demo) silently isn't.
+func SaveConfig(cfg *Config) error {
+	if cfg.memory {
+		return nil
+	}
+	if cfg.loadErr != nil {
+		return fmt.Errorf("%s couldn't be loaded; fix it to save changes", configPath())
+	}
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
-		return
+		return err
+	}
+	if disk, err := os.ReadFile(configPath()); err == nil && !bytes.Equal(disk, lastSaved) {
+		if data, err = mergeFromDisk(cfg, data, disk); err != nil {
+			return err
+		}
 	}
 	data = append(data, '\n')
-	_ = os.WriteFile(configPath(), data, 0600)
+	if err := writeAppFile(configPath(), data); err != nil {
+		return err
+	}
+	lastSaved = data
+	return nil
+}
+
+// mergeFromDisk merges the edits in disk, the config file as changed
+// behind the app's back, into cfg and returns its new encoding. Whatever
+// can't be merged is kept in a copy of the file, so no edit is lost
+// silently; if even that fails, nothing is saved.
+func mergeFromDisk(cfg *Config, data, disk []byte) ([]byte, error) {
+	merged, conflicts, err := mergeConfig(lastSaved, data, disk)
+	if err != nil {
+		// E.g. a half-finished edit that isn't valid JSON
+		path, cerr := saveConflictCopy(disk)
+		if cerr != nil {
+			return nil, fmt.Errorf("config changed on disk and can't be merged (%v); not overwriting it: %v", err, cerr)
+		}
+		logf(levelWarn, "config changed on disk and can't be merged (%v); the file's version is saved in %s", err, path)
+		return data, nil
+	}
+
+	if len(conflicts) > 0 {
+		path, err := saveConflictCopy(disk)
+		if err != nil {
+			return nil, fmt.Errorf("%s changed both here and on disk; not overwriting the file: %v", strings.Join(conflicts, ", "), err)
+		}
+		logf(levelWarn, "config: %s changed both here and on disk; kept this session's values, the file's version is saved in %s",
+			strings.Join(conflicts, ", "), path)
+	} else {
+		logf(levelInfo, "config: merged changes made to the file on disk")
+	}
+
+	var next Config
+	if err := json.Unmarshal(merged, &next); err != nil {
+		return nil, err
+	}
+	if next.Devices == nil {
+		next.Devices = DeviceList{}
+	}
+	next.canonicalize()
+	*cfg = next
+	return json.MarshalIndent(cfg, "", "  ")
 }
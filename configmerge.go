@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// lastSaved is the config file as this process last read or wrote it, or
+// nil if there was none. SaveConfig compares it with the file on disk to
+// notice edits made behind the app's back, and uses it as the common base
+// when merging them.
+var lastSaved []byte
+
+// mergeConfig merges two sets of changes to the config file since base:
+// ours, made in the app, and theirs, found on disk. Each top-level field
+// takes whichever side changed it, and devices merge entry by entry,
+// keyed by UUID, or by address for entries without one (see
+// DeviceEntry.key). Where both sides changed the same field or device
+// differently, ours wins and the field is reported in conflicts.
+func mergeConfig(base, ours, theirs []byte) (merged []byte, conflicts []string, err error) {
+	b, err := configFields(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("last saved config: %w", err)
+	}
+	o, err := configFields(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, err := configFields(theirs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("config on disk: %w", err)
+	}
+
+	out := make(map[string]json.RawMessage)
+	for _, name := range unionKeys(b, o, t) {
+		if name == "devices" {
+			v, c, err := mergeDevices(b[name], o[name], t[name])
+			if err != nil {
+				return nil, nil, err
+			}
+			conflicts = append(conflicts, c...)
+			out[name] = v
+			continue
+		}
+		v, ok := merge3(b[name], o[name], t[name])
+		if !ok {
+			conflicts = append(conflicts, name)
+		}
+		if v != nil {
+			out[name] = v
+		}
+	}
+
+	merged, err = json.Marshal(out)
+	return merged, conflicts, err
+}
+
+// configFields splits config data into its top-level fields, migrated to
+// the current schema. Unlike loading, merging writes no backups. Empty
+// data has no fields.
+func configFields(data []byte) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if len(bytes.TrimSpace(data)) == 0 {
+		return fields, nil
+	}
+	data, _, err := upgradeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// mergeDevices merges three versions of the devices list by UUID, or by
+// IP for entries without one. Devices
+// keep our order, followed by the ones only added on disk in theirs.
+func mergeDevices(base, ours, theirs json.RawMessage) (merged json.RawMessage, conflicts []string, err error) {
+	b, _, err := deviceEntries(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	o, oOrder, err := deviceEntries(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	t, tOrder, err := deviceEntries(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := []json.RawMessage{}
+	seen := make(map[string]bool)
+	for _, key := range append(oOrder, tOrder...) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		v, ok := merge3(b[key], o[key], t[key])
+		if !ok {
+			conflicts = append(conflicts, "devices["+key+"]")
+		}
+		if v != nil {
+			out = append(out, v)
+		}
+	}
+
+	merged, err = json.Marshal(out)
+	return merged, conflicts, err
+}
+
+// deviceEntries indexes a raw devices list by DeviceEntry.key, also
+// returning the keys in list order.
+func deviceEntries(raw json.RawMessage) (map[string]json.RawMessage, []string, error) {
+	byKey := make(map[string]json.RawMessage)
+	if raw == nil {
+		return byKey, nil, nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, nil, fmt.Errorf("devices: %w", err)
+	}
+	var order []string
+	for _, item := range list {
+		var e DeviceEntry
+		if err := json.Unmarshal(item, &e); err != nil {
+			return nil, nil, fmt.Errorf("devices: %w", err)
+		}
+		key := e.key()
+		if _, dup := byKey[key]; !dup {
+			order = append(order, key)
+		}
+		byKey[key] = item
+	}
+	return byKey, order, nil
+}
+
+// merge3 picks the value of a field from base and the two changed
+// versions; nil means absent. ok is false if both sides changed it in
+// different ways, in which case ours is returned.
+func merge3(base, ours, theirs json.RawMessage) (v json.RawMessage, ok bool) {
+	switch {
+	case sameJSON(ours, base):
+		return theirs, true
+	case sameJSON(theirs, base), sameJSON(ours, theirs):
+		return ours, true
+	default:
+		return ours, false
+	}
+}
+
+// sameJSON reports whether a and b encode the same value, ignoring
+// formatting and object key order. nil only equals nil.
+func sameJSON(a, b json.RawMessage) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
+
+// unionKeys returns the keys of all the maps, sorted.
+func unionKeys(maps ...map[string]json.RawMessage) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// saveConflictCopy keeps the on-disk config that SaveConfig is about to
+// overwrite, for edits that couldn't be merged, and returns its path.
+func saveConflictCopy(data []byte) (string, error) {
+	path := configPath() + ".conflict"
+	return path, os.WriteFile(path, data, 0600)
+}
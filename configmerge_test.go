@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// deviceNames lists a config's devices as ip=name.
+func deviceNames(devices DeviceList) string {
+	var names []string
+	for _, e := range devices {
+		names = append(names, e.IP+"="+e.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := `{"version": 2, "interval": 30, "theme": "dark", "devices": [
+		{"ip": "192.0.2.1", "name": "Kitchen"},
+		{"ip": "192.0.2.2", "name": "Office"},
+		{"ip": "192.0.2.3", "name": "Hall", "uuid": "awair-element_3"}]}`
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string // the merged config's interval, theme and devices
+		conflicts    string
+	}{
+		{
+			name:   "only changed on disk",
+			ours:   base,
+			theirs: strings.Replace(base, `"interval": 30`, `"interval": 60`, 1),
+			want:   `60 dark 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+		},
+		{
+			name:   "only changed here",
+			ours:   strings.Replace(base, `"theme": "dark"`, `"theme": "light"`, 1),
+			theirs: base,
+			want:   `30 light 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+		},
+		{
+			name:   "different fields on each side",
+			ours:   strings.Replace(base, `"theme": "dark"`, `"theme": "light"`, 1),
+			theirs: strings.Replace(base, `"interval": 30`, `"interval": 60`, 1),
+			want:   `60 light 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+		},
+		{
+			name:   "the same change on both sides",
+			ours:   strings.Replace(base, `"interval": 30`, `"interval": 60`, 1),
+			theirs: strings.Replace(base, `"interval": 30`, `"interval": 60`, 1),
+			want:   `60 dark 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+		},
+		{
+			name:      "the same field changed differently",
+			ours:      strings.Replace(base, `"interval": 30`, `"interval": 60`, 1),
+			theirs:    strings.Replace(base, `"interval": 30`, `"interval": 15`, 1),
+			want:      `60 dark 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+			conflicts: "interval",
+		},
+		{
+			name:   "a field removed on disk",
+			ours:   base,
+			theirs: strings.Replace(base, `"theme": "dark", `, ``, 1),
+			want:   `30  192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall`,
+		},
+		{
+			name:   "different devices renamed on each side",
+			ours:   strings.Replace(base, `"Kitchen"`, `"Kitchen 2"`, 1),
+			theirs: strings.Replace(base, `"Office"`, `"Study"`, 1),
+			want:   `30 dark 192.0.2.1=Kitchen 2 192.0.2.2=Study 192.0.2.3=Hall`,
+		},
+		{
+			name:   "a device added here and another on disk",
+			ours:   strings.Replace(base, `]}`, `, {"ip": "192.0.2.4", "name": "Garage"}]}`, 1),
+			theirs: strings.Replace(base, `]}`, `, {"ip": "192.0.2.5", "name": "Attic"}]}`, 1),
+			want:   `30 dark 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.3=Hall 192.0.2.4=Garage 192.0.2.5=Attic`,
+		},
+		{
+			name:   "a device removed on disk",
+			ours:   base,
+			theirs: strings.Replace(base, `{"ip": "192.0.2.2", "name": "Office"},`, ``, 1),
+			want:   `30 dark 192.0.2.1=Kitchen 192.0.2.3=Hall`,
+		},
+		{
+			// Matched by UUID, not address
+			name:   "a device moved on disk",
+			ours:   base,
+			theirs: strings.Replace(base, `"192.0.2.3"`, `"192.0.2.33"`, 1),
+			want:   `30 dark 192.0.2.1=Kitchen 192.0.2.2=Office 192.0.2.33=Hall`,
+		},
+		{
+			name:      "the same device renamed differently",
+			ours:      strings.Replace(base, `"Office"`, `"Study"`, 1),
+			theirs:    strings.Replace(base, `"Office"`, `"Den"`, 1),
+			want:      `30 dark 192.0.2.1=Kitchen 192.0.2.2=Study 192.0.2.3=Hall`,
+			conflicts: "devices[192.0.2.2]",
+		},
+		{
+			name:      "a device renamed here and removed on disk",
+			ours:      strings.Replace(base, `"Office"`, `"Study"`, 1),
+			theirs:    strings.Replace(base, `{"ip": "192.0.2.2", "name": "Office"},`, ``, 1),
+			want:      `30 dark 192.0.2.1=Kitchen 192.0.2.2=Study 192.0.2.3=Hall`,
+			conflicts: "devices[192.0.2.2]",
+		},
+	}
+	for _, tt := range tests {
+		merged, conflicts, err := mergeConfig([]byte(base), []byte(tt.ours), []byte(tt.theirs))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var cfg Config
+		if err := json.Unmarshal(merged, &cfg); err != nil {
+			t.Fatalf("%s: %s: %v", tt.name, merged, err)
+		}
+		got := strings.Join([]string{strconv.Itoa(cfg.Interval), cfg.Theme, deviceNames(cfg.Devices)}, " ")
+		if got != tt.want {
+			t.Errorf("%s: merged %q, want %q", tt.name, got, tt.want)
+		}
+		if c := strings.Join(conflicts, ", "); c != tt.conflicts {
+			t.Errorf("%s: conflicts %q, want %q", tt.name, c, tt.conflicts)
+		}
+	}
+}
+
+func TestMergeConfigInvalid(t *testing.T) {
+	base := []byte(`{"version": 2, "devices": []}`)
+	if _, _, err := mergeConfig(base, base, []byte(`{"version": 2, "devices": [`)); err == nil {
+		t.Error("merged a half-written file")
+	}
+	// No base, e.g. the file was created after the app started: whatever
+	// is on disk is their change
+	merged, conflicts, err := mergeConfig(nil, base, []byte(`{"version": 2, "devices": [], "interval": 45}`))
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("no base: %v, conflicts %v", err, conflicts)
+	}
+	if fields := decodeFields(t, merged); string(fields["interval"]) != "45" {
+		t.Errorf("no base: merged %s", merged)
+	}
+}
+
+func decodeFields(t *testing.T, data []byte) map[string]json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("%s: %v", data, err)
+	}
+	return fields
+}
+
+// TestSaveConfigInterleaved edits the config file behind the app's back
+// between its own changes, as a hand edit racing a debounced save does.
+func TestSaveConfigInterleaved(t *testing.T) {
+	path := useConfigFile(t)
+	writeTestConfig(t, path, `{"version": 2, "devices": [{"ip": "192.0.2.1", "name": "Kitchen"}], "interval": 30}`)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changed here, then on disk before the save
+	cfg.Devices[0].Name = "Kitchen 2"
+	writeTestConfig(t, path, `{"version": 2, "devices": [{"ip": "192.0.2.1", "name": "Kitchen"}], "interval": 30, "theme": "light"}`)
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Theme != "light" || deviceNames(cfg.Devices) != "192.0.2.1=Kitchen 2" {
+		t.Errorf("after the first save: theme %q, devices %s", cfg.Theme, deviceNames(cfg.Devices))
+	}
+
+	// Edited on disk again; the last save is now the base, so only the
+	// new edit counts as theirs
+	fields := readTestConfig(t, path)
+	var devices []map[string]string
+	json.Unmarshal(fields["devices"], &devices)
+	devices = append(devices, map[string]string{"ip": "192.0.2.2", "name": "Office"})
+	fields["devices"], _ = json.Marshal(devices)
+	data, _ := json.Marshal(fields)
+	writeTestConfig(t, path, string(data))
+
+	cfg.Interval = 60
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Interval != 60 || loaded.Theme != "light" || deviceNames(loaded.Devices) != "192.0.2.1=Kitchen 2 192.0.2.2=Office" {
+		t.Errorf("saved interval %d, theme %q, devices %s", loaded.Interval, loaded.Theme, deviceNames(loaded.Devices))
+	}
+	if _, err := os.Stat(path + ".conflict"); err == nil {
+		t.Error("merged edits left a conflict copy")
+	}
+
+	// Nothing changed on disk: a plain save
+	cfg.Interval = 90
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if fields := readTestConfig(t, path); string(fields["interval"]) != "90" {
+		t.Errorf("interval %s", fields["interval"])
+	}
+}
+
+func TestSaveConfigConflict(t *testing.T) {
+	path := useConfigFile(t)
+	writeTestConfig(t, path, `{"version": 2, "devices": [], "interval": 30}`)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Interval = 60
+	disk := `{"version": 2, "devices": [], "interval": 15, "theme": "light"}`
+	writeTestConfig(t, path, disk)
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	// This session's value wins, the rest of the edit is merged, and the
+	// file as edited is kept
+	if fields := readTestConfig(t, path); string(fields["interval"]) != "60" || string(fields["theme"]) != `"light"` {
+		t.Errorf("saved interval %s, theme %s", fields["interval"], fields["theme"])
+	}
+	if data, err := os.ReadFile(path + ".conflict"); err != nil || string(data) != disk {
+		t.Errorf("conflict copy %q, %v", data, err)
+	}
+
+	// A half-written file can't be merged: it is kept, and overwritten
+	os.Remove(path + ".conflict")
+	cfg.Interval = 90
+	writeTestConfig(t, path, `{"version": 2, "devices": [`)
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if fields := readTestConfig(t, path); string(fields["interval"]) != "90" {
+		t.Errorf("saved interval %s", fields["interval"])
+	}
+	if data, err := os.ReadFile(path + ".conflict"); err != nil || string(data) != `{"version": 2, "devices": [` {
+		t.Errorf("conflict copy %q, %v", data, err)
+	}
+}
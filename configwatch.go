@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configWatchInterval is how often the config file is checked for edits.
+// A stat every few seconds is cheap and, unlike change notifications,
+// works on network and synced filesystems too.
+const configWatchInterval = 3 * time.Second
+
+// fileStamp tells whether a file may have changed since it was last seen.
+type fileStamp struct {
+	mod  time.Time
+	size int64
+}
+
+// statConfig returns the stamp of the config file, or the zero stamp if
+// it can't be read.
+func statConfig() fileStamp {
+	info, err := os.Stat(configPath())
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{mod: info.ModTime(), size: info.Size()}
+}
+
+// configCheckMsg reports a look at the config file. Data is nil if its
+// stamp is unchanged. Manual checks (ctrl+r) always read the file.
+type configCheckMsg struct {
+	Stamp  fileStamp
+	Data   []byte
+	Err    error
+	Manual bool
+}
+
+// configWatchCmd checks the config file after configWatchInterval.
+func configWatchCmd(last fileStamp) tea.Cmd {
+	return tea.Tick(configWatchInterval, func(time.Time) tea.Msg {
+		return checkConfig(last, false)
+	})
+}
+
+// reloadConfigCmd reads the config file right away.
+func reloadConfigCmd() tea.Cmd {
+	return func() tea.Msg { return checkConfig(fileStamp{}, true) }
+}
+
+func checkConfig(last fileStamp, manual bool) configCheckMsg {
+	msg := configCheckMsg{Stamp: statConfig(), Manual: manual}
+	if msg.Stamp == last && !manual {
+		return msg
+	}
+	msg.Data, msg.Err = os.ReadFile(configPath())
+	return msg
+}
+
+// handleConfigCheck reloads the config if the file was edited outside the
+// app. A file that doesn't parse is reported and the current config
+// kept, so a half-finished edit loses nothing.
+func (m *model) handleConfigCheck(msg configCheckMsg) tea.Cmd {
+	m.configStamp = msg.Stamp
+	switch {
+	case m.config.memory:
+		// --demo ignores the config file
+		if msg.Manual {
+			m.addLog("Demo mode doesn't use the config file")
+		}
+		return nil
+	case msg.Err != nil && msg.Manual:
+		m.logAt(levelWarn, "Can't reload config: "+msg.Err.Error())
+		return nil
+	case msg.Err != nil:
+		if !errors.Is(msg.Err, fs.ErrNotExist) {
+			logf(levelWarn, "config watch: %v", msg.Err)
+		}
+		return nil
+	case msg.Data == nil:
+		return nil
+	case !msg.Manual && bytes.Equal(msg.Data, lastSaved):
+		// Saved by the app itself
+		return nil
+	}
+
+	var cfg Config
+	if _, err := parseConfig(msg.Data, &cfg); err != nil {
+		m.logAt(levelWarn, fmt.Sprintf("Can't reload config, keeping the current one: %s: %v", configPath(), err))
+		return nil
+	}
+	if cfg.Devices == nil {
+		cfg.Devices = DeviceList{}
+	}
+	cfg.canonicalize()
+	lastSaved = msg.Data
+	return m.applyConfig(&cfg)
+}
+
+// applyConfig replaces m.config with cfg, renames devices whose saved
+// name changed and adds the devices it newly lists. Other settings take
+// effect on the next start.
+func (m *model) applyConfig(cfg *Config) tea.Cmd {
+	old := *m.config
+	*m.config = *cfg
+
+	renamed := 0
+	for _, dev := range m.devices {
+		name := m.config.Name(dev.UUID, dev.IP)
+		if name == old.Name(dev.UUID, dev.IP) {
+			continue
+		}
+		if name == "" {
+			name = fallbackName(dev)
+		}
+		if name != dev.Name {
+			dev.Name = name
+			renamed++
+		}
+	}
+
+	entries := m.config.DevicesFrom(entryManual)
+	if m.remember {
+		entries = append(entries, m.config.DevicesFrom(entryDiscovered)...)
+	}
+	var cmds []tea.Cmd
+	added := 0
+	for _, e := range entries {
+		if _, ok := m.devices[e.IP]; ok || m.deviceByUUID(e.UUID) != nil {
+			continue
+		}
+		dev := m.addDevice(e.IP, e.Instance)
+		m.addLog(fmt.Sprintf("Added device: %s", dev.Name))
+		cmds = append(cmds, m.fetchCmds(e.IP)...)
+		added++
+	}
+
+	m.addLog(fmt.Sprintf("Config reloaded (%d renamed, %d added)", renamed, added))
+	return tea.Batch(cmds...)
+}
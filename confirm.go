@@ -0,0 +1,43 @@
+package main
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmPrompt is a pending yes/no question. onYes runs when the user
+// answers y and onNo (if set) on any other answer.
+type confirmPrompt struct {
+	question string
+	onYes    func(m *model) tea.Cmd
+	onNo     func(m *model) tea.Cmd
+}
+
+func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.confirm
+	m.confirm = nil
+
+	switch msg.String() {
+	case "y", "Y":
+		return m, c.onYes(&m)
+	default:
+		if c.onNo != nil {
+			return m, c.onNo(&m)
+		}
+		return m, nil
+	}
+}
+
+func (m model) overlayConfirm(gridHeight int) string {
+	box := lipgloss.NewStyle().
+		Width(50).
+		Border(glyphs.Border).
+		BorderForeground(theme.Fair).
+		Padding(0, 1).
+		Render(m.confirm.question + "\n" +
+			lipgloss.NewStyle().Foreground(theme.Muted).Render("y yes  n no"))
+
+	return lipgloss.Place(m.width, gridHeight,
+		lipgloss.Center, lipgloss.Center,
+		box)
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// legacyConsole reports whether the console lacks the glyphs the
+// dashboard draws with. Only old Windows consoles do.
+func legacyConsole() bool {
+	return false
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// legacyConsole reports whether stdout is a console without virtual
+// terminal support, such as conhost before Windows 10, which can't be
+// relied on to draw block elements and braille.
+func legacyConsole() bool {
+	h := windows.Handle(os.Stdout.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		// Not a console: redirected, or a terminal like mintty
+		return false
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return false
+	}
+	// Bubble Tea enables this itself; if it can't be set, the console is
+	// the legacy one
+	if err := windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		return true
+	}
+	windows.SetConsoleMode(h, mode)
+	return false
+}
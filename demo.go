@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Demo devices (--demo) are keyed "demo:<n>", which stands in for an
+// address like cloud keys do. Their readings come from a demoGenerator
+// instead of the network, through the same poll, history and config
+// paths as real devices.
+const demoPrefix = "demo:"
+
+// demoMaxDevices is the most devices --demo creates.
+const demoMaxDevices = 12
+
+// demoSeed seeds every generator, so each run shows the same readings.
+const demoSeed = 0x5eed
+
+// A demo device's simulated clock starts at demoStart and advances
+// demoStep per reading: a day passes in 288 readings, 48 minutes at the
+// default interval.
+const (
+	demoStart = 7 * time.Hour
+	demoStep  = 5 * time.Minute
+)
+
+// demoHistory is how many 5-minute averages a demo device seeds its
+// history with, an hour's worth like the 5-min-avg endpoint.
+const demoHistory = 12
+
+// demoRooms names the demo devices, in order.
+var demoRooms = []string{
+	"Living Room", "Bedroom", "Office", "Kitchen", "Nursery", "Basement",
+	"Guest Room", "Studio", "Den", "Hallway", "Attic", "Garage",
+}
+
+// demoGenerators holds the generator of each demo device. It is filled
+// by setupDemo before the dashboard starts and only read afterwards.
+var demoGenerators = map[string]*demoGenerator{}
+
+// isDemo reports whether a device address is a demo device key.
+func isDemo(addr string) bool {
+	return strings.HasPrefix(addr, demoPrefix)
+}
+
+// setupDemo creates n demo devices and returns their keys.
+func setupDemo(n int) []string {
+	keys := make([]string, 0, n)
+	for i := range n {
+		key := demoPrefix + strconv.Itoa(i+1)
+		demoGenerators[key] = newDemoGenerator(i)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// demoName is the name of the demo device with the given key.
+func demoName(key string) string {
+	n, _ := strconv.Atoi(strings.TrimPrefix(key, demoPrefix))
+	if n >= 1 && n <= len(demoRooms) {
+		return demoRooms[n-1]
+	}
+	return "Room " + strconv.Itoa(n)
+}
+
+// demoGenerator produces the readings of one demo device: CO₂ and VOC
+// follow occupancy through the simulated day, temperature follows the
+// sun, everything drifts a little, and PM2.5 spikes now and then as if
+// someone were cooking. A reading depends only on the device and its
+// step, so runs are reproducible.
+type demoGenerator struct {
+	seed     uint64
+	co2Swing float64 // how far CO₂ climbs at peak occupancy, in ppm
+	tempBase float64 // °C
+	humBase  float64 // %
+
+	mu   sync.Mutex
+	step int // of the next live reading
+}
+
+func newDemoGenerator(i int) *demoGenerator {
+	seed := splitmix(demoSeed + uint64(i))
+	return &demoGenerator{
+		seed:     seed,
+		co2Swing: 300 + 800*unitHash(seed, 1, 0),
+		tempBase: 19.5 + 3*unitHash(seed, 2, 0),
+		humBase:  35 + 15*unitHash(seed, 3, 0),
+	}
+}
+
+// next returns the next live reading, stamped at.
+func (g *demoGenerator) next(at time.Time) *awair.SensorData {
+	g.mu.Lock()
+	step := g.step
+	g.step++
+	g.mu.Unlock()
+	return g.reading(step, at)
+}
+
+// averages returns the demoHistory readings before the first live one,
+// newest first and demoStep apart, as the 5-min-avg endpoint would.
+func (g *demoGenerator) averages(now time.Time) []awair.SensorData {
+	averages := make([]awair.SensorData, 0, demoHistory)
+	for i := 1; i <= demoHistory; i++ {
+		averages = append(averages, *g.reading(-i, now.Add(-time.Duration(i)*demoStep)))
+	}
+	return averages
+}
+
+// reading returns the reading at step, which may be negative for history.
+func (g *demoGenerator) reading(step int, at time.Time) *awair.SensorData {
+	hour := math.Mod((demoStart + time.Duration(step)*demoStep).Hours(), 24)
+	if hour < 0 {
+		hour += 24
+	}
+	// Occupancy peaks at 4:00 and is lowest at 16:00; the sun warms the
+	// room most at 15:00
+	occupancy := 0.5 + 0.5*math.Cos(2*math.Pi*(hour-4)/24)
+	sun := math.Sin(2 * math.Pi * (hour - 9) / 24)
+	x := float64(step) / 6 // drift changes over half an hour
+
+	d := &awair.SensorData{
+		Timestamp: at.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Temp:      round(g.tempBase+1.5*sun+0.4*g.noise(4, x), 2),
+		Humid:     round(g.humBase+5*g.noise(5, x)-2*sun, 2),
+		CO2:       math.Round(430 + g.co2Swing*occupancy + 40*g.noise(6, x)),
+		VOC:       math.Round(max(80+200*occupancy+60*g.noise(7, x), 0)),
+		PM25:      math.Round(max(3+2*g.noise(8, x), 0) + g.spike(step)),
+	}
+	d.Score = demoScore(d)
+	return d
+}
+
+// spike is the PM2.5 added at step by a cooking spike: one starts in
+// about one window of eight steps in eight, and decays over the window.
+func (g *demoGenerator) spike(step int) float64 {
+	window := int(math.Floor(float64(step) / 8))
+	if unitHash(g.seed, 9, window) >= 0.12 {
+		return 0
+	}
+	into := step - window*8
+	return (20 + 60*unitHash(g.seed, 10, window)) * math.Exp(-float64(into)/2)
+}
+
+// noise is smooth noise in [-1, 1] along x: random values at whole x,
+// eased in between. salt picks an independent sequence per sensor.
+func (g *demoGenerator) noise(salt uint64, x float64) float64 {
+	i := math.Floor(x)
+	t := x - i
+	t = t * t * (3 - 2*t)
+	a, b := unitHash(g.seed, salt, int(i)), unitHash(g.seed, salt, int(i)+1)
+	return 2*(a+(b-a)*t) - 1
+}
+
+// demoScore approximates the Awair score: 100 less a penalty for each
+// sensor that isn't good.
+func demoScore(d *awair.SensorData) int {
+	score := 100
+	for _, r := range d.Readings() {
+		if !slices.Contains(awair.CoreSensors, r.Key) {
+			continue
+		}
+		switch awair.RateSensorValue(r.Key, awair.DisplayValue(r.Key, r.Value)) {
+		case "fair":
+			score -= 6
+		case "poor":
+			score -= 15
+		}
+	}
+	return max(score, 0)
+}
+
+// demoConfig is the config a demo device reports.
+func demoConfig(key string) *awair.DeviceConfig {
+	n := strings.TrimPrefix(key, demoPrefix)
+	return &awair.DeviceConfig{
+		DeviceUUID: "awair-element_demo" + n,
+		FWVersion:  "demo",
+		Display:    "score",
+		Timezone:   "UTC",
+	}
+}
+
+// demoAirData is FetchAirData for demo devices.
+func demoAirData(key string) (*awair.SensorData, error) {
+	g, ok := demoGenerators[key]
+	if !ok {
+		return nil, &awair.FetchError{URL: deviceURL(key, awair.PathAirData), Path: awair.PathAirData, Err: errors.New("no such demo device")}
+	}
+	return g.next(time.Now()), nil
+}
+
+// demoAverages is FetchAirDataAverages for demo devices.
+func demoAverages(key string) ([]awair.SensorData, error) {
+	g, ok := demoGenerators[key]
+	if !ok {
+		return nil, &awair.FetchError{URL: deviceURL(key, "/air-data/"+awair.Avg5Min), Path: "/air-data/" + awair.Avg5Min, Err: errors.New("no such demo device")}
+	}
+	return g.averages(time.Now()), nil
+}
+
+// splitmix is the SplitMix64 finalizer, a fast well-mixed hash.
+func splitmix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// unitHash returns a value in [0, 1) determined by seed, salt and i.
+func unitHash(seed, salt uint64, i int) float64 {
+	return float64(splitmix(seed^splitmix(salt<<32^uint64(int64(i))))>>11) / (1 << 53)
+}
+
+// round rounds v to the given number of decimals, as devices report.
+func round(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
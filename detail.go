@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// detailRow is a label/value pair in the detail view.
+type detailRow struct {
+	Label string
+	Value string
+}
+
+// renderDetailSection renders a titled block of label/value rows.
+func renderDetailSection(title string, rows []detailRow) string {
+	lines := []string{lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(title)}
+	for _, r := range rows {
+		lines = append(lines, fmt.Sprintf("  %s %s",
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(visPadRight(r.Label, 18)),
+			r.Value))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// optFloat formats an optional raw value, or "—" when the device omitted it.
+func optFloat(v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	return fmt.Sprintf("%g", *v)
+}
+
+// orDash returns s, or "—" if it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// renderDetail renders the full-screen detail view for the device being
+// inspected. It is re-rendered on every update, so it follows new polls.
+func (m model) renderDetail(height int) string {
+	dev := m.device(m.detailID)
+	if dev == nil {
+		return m.renderEmptyState(height)
+	}
+
+	header := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).
+		Render(dev.Label())
+
+	var left []string
+	if d := dev.Data; d != nil {
+		sc := scoreColor(d.Score)
+		left = append(left, fmt.Sprintf("%s    %s",
+			lipgloss.NewStyle().Bold(true).Render("Awair Score"),
+			lipgloss.NewStyle().Bold(true).Foreground(sc).Render(fmt.Sprintf("%d %s", d.Score, scoreLabel(d.Score)))))
+		if aqi, source, ok := headlineAQI(d); ok {
+			left = append(left, fmt.Sprintf("%s         %s",
+				lipgloss.NewStyle().Bold(true).Render("US AQI"),
+				lipgloss.NewStyle().Bold(true).Foreground(aqiColor(aqi)).Render(fmt.Sprintf("%d %s", aqi, awair.AQICategory(aqi)))+
+					lipgloss.NewStyle().Foreground(theme.Muted).Render(" ("+source+")")))
+		}
+		if shown := m.shownScore(dev); m.smoothScore > 1 {
+			left = append(left, lipgloss.NewStyle().Foreground(theme.Muted).Render(
+				fmt.Sprintf("Cards show %d, the %s of the last %d", shown, m.smoothMode, m.smoothScore)))
+		}
+
+		var sensors []detailRow
+		for _, s := range d.Readings() {
+			rating := awair.RateSensorValue(s.Key, awair.DisplayValue(s.Key, s.Value))
+			val := lipgloss.NewStyle().Foreground(ratingColor(rating)).
+				Render(visPadLeft(awair.FormatValue(s.Key, s.Value, m.fahrenheit), 12) + "  " + rating)
+			if s.Derived {
+				val += lipgloss.NewStyle().Foreground(theme.Muted).Render(" (calc)")
+			}
+			sensors = append(sensors, detailRow{awair.OptimalRanges[s.Key].Label, val})
+		}
+		left = append(left, "", renderDetailSection("Sensors", sensors))
+		// Gaps are anything longer than a few missed polls, as in the chart
+		for _, a := range Advisories(dev.History, m.advisories, 3*m.pollInterval) {
+			left = append(left, "  "+renderAdvisory(a))
+		}
+
+		left = append(left, "", renderDetailSection("Raw values", []detailRow{
+			{"timestamp", orDash(d.Timestamp)},
+			{"co2_est_baseline", optFloat(d.CO2EstBaseline)},
+			{"voc_baseline", optFloat(d.VOCBaseline)},
+			{"voc_h2_raw", optFloat(d.VOCH2Raw)},
+			{"voc_ethanol_raw", optFloat(d.VOCEthanolRaw)},
+		}))
+	} else {
+		left = append(left, lipgloss.NewStyle().Foreground(theme.Fair).Render("No sensor data yet"))
+	}
+
+	var right []string
+	if !m.fetchConfig {
+		right = append(right, renderDetailSection("Device info", []detailRow{{"Config", "not fetched (fetch_device_config is off)"}}))
+	} else if c := dev.Config; c != nil {
+		right = append(right, renderDetailSection("Device info", []detailRow{
+			{"UUID", orDash(c.DeviceUUID)},
+			{"Model", orDash(c.Model())},
+			{"Firmware", orDash(c.FWVersion)},
+			{"SSID", orDash(c.SSID)},
+			{"MAC", orDash(c.WifiMAC)},
+			{"IP", orDash(c.IP)},
+			{"Netmask", orDash(c.Netmask)},
+			{"Gateway", orDash(c.Gateway)},
+			{"Timezone", orDash(c.Timezone)},
+			{"Display", orDash(c.Display)},
+			{"LED", ledText(dev)},
+		}))
+	} else {
+		right = append(right, renderDetailSection("Device info", []detailRow{{"Config", "not available"}}))
+	}
+
+	if recs := m.records.Devices[recordKey(dev)]; recs != nil {
+		rec := func(key string, r *Record) string {
+			if r == nil {
+				return "—"
+			}
+			return fmt.Sprintf("%s on %s", awair.FormatValue(key, r.Value, m.fahrenheit), r.Time.Format("Jan 2 15:04"))
+		}
+		right = append(right, "", renderDetailSection("Lifetime records", []detailRow{
+			{"Highest CO₂", rec("co2", recs.MaxCO2)},
+			{"Highest PM2.5", rec("pm25", recs.MaxPM25)},
+			{"Lowest temp", rec("temp", recs.MinTemp)},
+			{"Highest temp", rec("temp", recs.MaxTemp)},
+		}))
+	}
+
+	updated := "never"
+	if !dev.LastUpdate.IsZero() {
+		updated = fmt.Sprintf("%s (%s ago)", dev.LastUpdate.Format("15:04:05"),
+			age(dev.LastUpdate).Round(time.Second))
+	}
+	sampled := "—"
+	if !dev.SampleTime.IsZero() {
+		sampled = formatSampleTime(dev.SampleTime) + " (device clock)"
+	}
+	skew := "—"
+	if dev.endpoint != awair.Latest {
+		skew = "not measured on averages"
+	} else if !dev.SampleTime.IsZero() {
+		skew = fmt.Sprintf("none (within %s)", shortDuration(m.maxClockSkew))
+		if m.clockSkewed(dev) {
+			skew = lipgloss.NewStyle().Foreground(theme.Fair).Render(glyphs.Warn + " " + describeSkew(dev.ClockSkew))
+		}
+	}
+	lastErr := "none"
+	if dev.LastError != nil {
+		lastErr = lipgloss.NewStyle().Foreground(theme.Poor).Render(errorSummary(dev.LastError))
+	}
+	latency := "—"
+	if avg, n := dev.Latency.Average(); n > 1 {
+		latency = fmt.Sprintf("%s (average %s over %d polls)",
+			formatLatency(dev.Latency.Last), formatLatency(avg), n)
+	} else if n == 1 {
+		latency = formatLatency(dev.Latency.Last)
+	}
+	status := []detailRow{
+		{"Polling", "/air-data/" + dev.endpoint},
+	}
+	if isCloud(dev.IP) {
+		status = append(status, detailRow{"Cloud quota", m.cloudQuotaText(dev)})
+	}
+	status = append(status, []detailRow{
+		{"Sample taken", sampled},
+		{"Last contact", updated},
+		{"Clock skew", skew},
+		{"Latency", latency},
+		{"Last error", lastErr},
+	}...)
+	var fe *awair.FetchError
+	if errors.As(dev.LastError, &fe) {
+		status = append(status, detailRow{"Failed request",
+			fmt.Sprintf("%s after %s", fe.Path, fe.Elapsed.Round(time.Millisecond))})
+	}
+	status = append(status, detailRow{"Samples stored",
+		fmt.Sprintf("%d (%d duplicate fetches)", len(dev.History.Samples), dev.History.Duplicates)})
+	right = append(right, "", renderDetailSection("Status", status))
+
+	leftCol := strings.Join(left, "\n")
+	rightCol := strings.Join(right, "\n")
+
+	// Side by side when there's room, stacked otherwise
+	var body string
+	colWidth := (m.width - 4) / 2
+	if colWidth >= 48 {
+		body = lipgloss.JoinHorizontal(lipgloss.Top,
+			lipgloss.NewStyle().Width(colWidth).Render(leftCol),
+			lipgloss.NewStyle().Width(colWidth).Render(rightCol))
+	} else {
+		body = leftCol + "\n\n" + rightCol
+	}
+
+	help := lipgloss.NewStyle().Foreground(theme.Muted).Render("esc back  ←/→ chart sensor  D device display  L/[ ] LEDs  y/Y copy  R reset records")
+
+	// The chart takes what's left below the columns: border (2), header,
+	// help and blank lines (4), the chart title and time axis (3)
+	content := header + "\n\n" + body
+	if rows := min(height-2-4-lipgloss.Height(body)-3-1, 10); rows >= 3 {
+		content += "\n\n" + m.renderSensorChart(dev, m.width-4, rows)
+	}
+
+	return lipgloss.NewStyle().
+		Width(m.width-2).
+		Height(height-2).
+		MaxHeight(height).
+		Border(glyphs.Border).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Render(content + "\n\n" + help)
+}
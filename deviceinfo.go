@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// configRefreshInterval is how often device configs are fetched again,
+// to pick up firmware updates and Wi-Fi changes.
+const configRefreshInterval = time.Hour
+
+// configRefreshMsg is the time to fetch every device's config again.
+type configRefreshMsg struct{}
+
+func configRefreshCmd() tea.Cmd {
+	return tea.Tick(configRefreshInterval, func(time.Time) tea.Msg { return configRefreshMsg{} })
+}
+
+// refreshConfigs returns a config and LED settings fetch for every device, or nothing if
+// fetch_device_config is off.
+func (m *model) refreshConfigs() []tea.Cmd {
+	if !m.fetchConfig {
+		return nil
+	}
+	cmds := make([]tea.Cmd, 0, len(m.deviceOrder))
+	for _, ip := range m.deviceOrder {
+		switch {
+		case isCloud(ip):
+		case isDemo(ip):
+			cmds = append(cmds, configCmd(m.devices[ip]))
+		default:
+			cmds = append(cmds, configCmd(m.devices[ip]), ledCmd(m.devices[ip]))
+		}
+	}
+	return cmds
+}
+
+// logConfigChanges logs what changed between a device's previous config
+// and the one just fetched: firmware updates and network moves.
+func (m *model) logConfigChanges(dev *Device, old, cur *awair.DeviceConfig) {
+	if old == nil {
+		return
+	}
+	changed := func(what, from, to string) {
+		if from != to {
+			m.addLog(fmt.Sprintf("%s: %s changed from %s to %s", dev.Name, what, orDash(from), orDash(to)))
+		}
+	}
+	changed("firmware", old.FWVersion, cur.FWVersion)
+	changed("Wi-Fi network", old.SSID, cur.SSID)
+	changed("gateway", old.Gateway, cur.Gateway)
+}
+
+// firmwareText is the " · fw 1.4.0" after a card's update time, with
+// --show-firmware.
+func (m model) firmwareText(dev *Device) string {
+	if !m.showFirmware || dev.Config == nil || dev.Config.FWVersion == "" {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(theme.Muted).Render(" · fw " + dev.Config.FWVersion)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startTime is when the process started, for the uptime in dumps.
+var startTime = time.Now()
+
+// Sizes of the rings kept for diagnostics dumps.
+const (
+	diagMessages = 64
+	diagLogLines = 50
+)
+
+// Watchdog timing: it checks every watchdogCheck and reports a stall once
+// no message has been processed for watchdogIntervals poll intervals, or
+// watchdogMinStall if that is longer. The tick alone delivers a message
+// every interval, so a healthy program never gets close.
+const (
+	watchdogCheck     = 5 * time.Second
+	watchdogIntervals = 5
+	watchdogMinStall  = 30 * time.Second
+)
+
+// diagMessage is one message handled by Update.
+type diagMessage struct {
+	Type  string
+	Start time.Time
+	Took  time.Duration
+}
+
+// diagDevice is the state of one device as of the last message.
+type diagDevice struct {
+	Name       string
+	IP         string
+	HasData    bool
+	LastUpdate time.Time
+	LastError  error
+}
+
+// diagRecorder keeps what a diagnostics dump reports. Update feeds it on
+// every message and commands register while they run; a dump reads it
+// from another goroutine, so it works while Update is stuck.
+type diagRecorder struct {
+	mu sync.Mutex
+
+	messages []diagMessage // newest last
+	current  *diagMessage  // being handled, nil between messages
+	lastDone time.Time     // when the last message finished
+
+	// Model state after the last message
+	devices  []diagDevice
+	view     string
+	paused   bool
+	interval time.Duration
+	tickGen  int
+	logs     []logEntry
+
+	nextCmd int
+	running map[int]diagMessage // commands in flight; Took is unused
+}
+
+// diag is the process-wide recorder.
+var diag = &diagRecorder{
+	lastDone: startTime,
+	running:  make(map[int]diagMessage),
+}
+
+// begin records that Update started handling msg.
+func (r *diagRecorder) begin(msg tea.Msg) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = &diagMessage{Type: fmt.Sprintf("%T", msg), Start: time.Now()}
+}
+
+// end records that Update finished the current message, leaving m.
+func (r *diagRecorder) end(m model) {
+	devices := make([]diagDevice, 0, len(m.deviceOrder))
+	for _, dev := range m.orderedDevices() {
+		devices = append(devices, diagDevice{
+			Name:       dev.Name,
+			IP:         dev.IP,
+			HasData:    dev.Data != nil,
+			LastUpdate: dev.LastUpdate,
+			LastError:  dev.LastError,
+		})
+	}
+	logs := m.logs
+	if len(logs) > diagLogLines {
+		logs = logs[len(logs)-diagLogLines:]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.current != nil {
+		r.current.Took = now.Sub(r.current.Start)
+		r.messages = append(r.messages, *r.current)
+		if len(r.messages) > diagMessages {
+			r.messages = r.messages[len(r.messages)-diagMessages:]
+		}
+		r.current = nil
+	}
+	r.lastDone = now
+	r.devices = devices
+	r.view = m.viewName()
+	r.paused = m.paused
+	r.interval = m.pollInterval
+	r.tickGen = m.tickGen
+	r.logs = append(r.logs[:0], logs...)
+}
+
+// trackCmd wraps cmd so it shows among the running commands in a dump
+// while it runs.
+func trackCmd(name string, cmd tea.Cmd) tea.Cmd {
+	return func() tea.Msg {
+		diag.mu.Lock()
+		diag.nextCmd++
+		id := diag.nextCmd
+		diag.running[id] = diagMessage{Type: name, Start: time.Now()}
+		diag.mu.Unlock()
+		defer func() {
+			diag.mu.Lock()
+			delete(diag.running, id)
+			diag.mu.Unlock()
+		}()
+		return cmd()
+	}
+}
+
+// viewName names what the model is showing, for dumps.
+func (m model) viewName() string {
+	switch {
+	case m.detailID != 0:
+		return "detail"
+	case m.zoomID != 0:
+		return "zoom"
+	case m.mini:
+		return "mini"
+	}
+	return m.viewMode
+}
+
+// writeDiagnostics writes a diagnostics dump to a new file in the temp
+// directory and returns its path. The program keeps running.
+func writeDiagnostics() (string, error) {
+	now := time.Now()
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "awair-tui %s diagnostics, %s\n", version, now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "%s %s/%s, up %s, %d goroutines\n",
+		runtime.Version(), runtime.GOOS, runtime.GOARCH, now.Sub(startTime).Round(time.Second), runtime.NumGoroutine())
+
+	diag.mu.Lock()
+	b.WriteString("\n== State ==\n")
+	if diag.current != nil {
+		fmt.Fprintf(&b, "handling %s since %s (%s)\n",
+			diag.current.Type, diag.current.Start.Format("15:04:05.000"), now.Sub(diag.current.Start).Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(&b, "idle since %s (%s)\n", diag.lastDone.Format("15:04:05.000"), now.Sub(diag.lastDone).Round(time.Millisecond))
+	}
+	fmt.Fprintf(&b, "view %s, paused %t, polling every %s, tick loop %d, %d device(s)\n",
+		diag.view, diag.paused, diag.interval, diag.tickGen, len(diag.devices))
+	for _, d := range diag.devices {
+		fmt.Fprintf(&b, "  %s (%s): ", d.Name, d.IP)
+		if d.LastUpdate.IsZero() {
+			b.WriteString("never updated")
+		} else {
+			fmt.Fprintf(&b, "updated %s (%s ago)", d.LastUpdate.Format("15:04:05"), now.Sub(d.LastUpdate).Round(time.Second))
+		}
+		if !d.HasData {
+			b.WriteString(", no data")
+		}
+		if d.LastError != nil {
+			fmt.Fprintf(&b, ", error: %v", d.LastError)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n== Running commands ==\n")
+	running := make([]diagMessage, 0, len(diag.running))
+	for _, c := range diag.running {
+		running = append(running, c)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].Start.Before(running[j].Start) })
+	for _, c := range running {
+		fmt.Fprintf(&b, "%s  %s (%s)\n", c.Start.Format("15:04:05.000"), c.Type, now.Sub(c.Start).Round(time.Millisecond))
+	}
+
+	b.WriteString("\n== Last messages ==\n")
+	for _, msg := range diag.messages {
+		fmt.Fprintf(&b, "%s  %-28s %s\n", msg.Start.Format("15:04:05.000"), msg.Type, msg.Took)
+	}
+
+	b.WriteString("\n== Recent log ==\n")
+	for _, e := range diag.logs {
+		fmt.Fprintf(&b, "%s %-5s %s\n", e.Time.Format("15:04:05"), e.Level, e.Message)
+	}
+	diag.mu.Unlock()
+
+	b.WriteString("\n== Goroutines ==\n")
+	if err := pprof.Lookup("goroutine").WriteTo(&b, 2); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(os.TempDir(), "awair-tui-diag-"+now.Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	logf(levelInfo, "diagnostics written to %s", path)
+	return path, nil
+}
+
+// diagDumpedMsg reports a diagnostics dump, written for the key chord or
+// SIGQUIT.
+type diagDumpedMsg struct {
+	Path string
+	Err  error
+}
+
+// diagDumpCmd writes a diagnostics dump in the background.
+func diagDumpCmd() tea.Msg {
+	path, err := writeDiagnostics()
+	return diagDumpedMsg{Path: path, Err: err}
+}
+
+func (m *model) handleDiagDumped(msg diagDumpedMsg) {
+	if msg.Err != nil {
+		m.logAt(levelError, fmt.Sprintf("Writing diagnostics: %v", msg.Err))
+		return
+	}
+	m.addLog("Diagnostics written to " + msg.Path)
+}
+
+// watchdogMsg is sent by the watchdog after a stall, to restart the tick
+// loop in case it is what died.
+type watchdogMsg struct {
+	Idle time.Duration
+}
+
+func (m *model) handleWatchdog(msg watchdogMsg) tea.Cmd {
+	m.logAt(levelWarn, fmt.Sprintf("No updates for %s; restarting the poll loop", msg.Idle.Round(time.Second)))
+	m.tickGen++
+	gen := m.tickGen
+	return func() tea.Msg { return tickMsg{Gen: gen} }
+}
+
+// startDiagnostics writes a diagnostics dump on SIGQUIT instead of the
+// runtime's dump-and-exit, and runs the watchdog, which logs a stall and
+// nudges p once no message has been processed for a while.
+func startDiagnostics(p *tea.Program) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGQUIT)
+	go func() {
+		for range quit {
+			path, err := writeDiagnostics()
+			if err != nil {
+				logf(levelError, "writing diagnostics: %v", err)
+			}
+			// Send blocks while Update is stuck
+			go p.Send(diagDumpedMsg{Path: path, Err: err})
+		}
+	}()
+
+	go func() {
+		var warned time.Time // lastDone of the stall already reported
+		for range time.Tick(watchdogCheck) {
+			diag.mu.Lock()
+			lastDone, interval, current := diag.lastDone, diag.interval, diag.current
+			diag.mu.Unlock()
+
+			idle := time.Since(lastDone)
+			if idle < max(watchdogIntervals*interval, watchdogMinStall) || lastDone.Equal(warned) {
+				continue
+			}
+			warned = lastDone
+			if current != nil {
+				logf(levelError, "watchdog: stuck handling %s for %s; send SIGQUIT for a diagnostics dump",
+					current.Type, time.Since(current.Start).Round(time.Second))
+			} else {
+				logf(levelError, "watchdog: no messages for %s; nudging the poll loop", idle.Round(time.Second))
+			}
+			go p.Send(watchdogMsg{Idle: idle})
+		}
+	}()
+}
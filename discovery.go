@@ -2,83 +2,52 @@ package main
 
 import (
 	"context"
-	"io"
-	"log"
 	"strings"
-	"time"
 
-	"github.com/hashicorp/mdns"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
 )
 
-// DiscoveredDevice represents a device found via mDNS.
-type DiscoveredDevice struct {
-	Name string
-	IP   string
-	Port int
+// discoveryOptions is what discovery looks for and where, set by
+// configureDiscovery from --discovery-services, --discovery-match,
+// --discovery-interval and --interface.
+var discoveryOptions = discovery.Options{
+	Logf: func(format string, args ...any) { logf(levelDebug, format, args...) },
 }
 
-// StartDiscovery queries for Awair devices via mDNS and sends them on the
-// returned channel. It re-queries every 30 seconds until the context is
-// cancelled, to catch devices that come online later.
-func StartDiscovery(ctx context.Context) <-chan DiscoveredDevice {
-	ch := make(chan DiscoveredDevice)
-
-	go func() {
-		defer close(ch)
-
-		// Suppress hashicorp/mdns log noise (IPv6 bind errors, client close info)
-		// Save and restore the original log output to avoid clobbering it globally.
-		origLogOut := log.Writer()
-		log.SetOutput(io.Discard)
-		defer log.SetOutput(origLogOut)
-
-		for {
-			entries := make(chan *mdns.ServiceEntry, 16)
-
-			go func() {
-				for entry := range entries {
-					name := strings.ToLower(entry.Name)
-					if !strings.Contains(name, "awair") {
-						continue
-					}
-
-					if entry.AddrV4 == nil {
-						continue
-					}
-
-					// entry.Name is "AWAIR-ELEM-XXXXX._http._tcp.local."
-					// Extract the instance name (before the service type)
-					instanceName := entry.Name
-					if idx := strings.Index(instanceName, "._http._tcp"); idx > 0 {
-						instanceName = instanceName[:idx]
-					}
-
-					select {
-					case ch <- DiscoveredDevice{
-						Name: instanceName,
-						IP:   entry.AddrV4.String(),
-						Port: entry.Port,
-					}:
-					case <-ctx.Done():
-						return
-					}
-				}
-			}()
-
-			params := mdns.DefaultParams("_http._tcp")
-			params.Entries = entries
-			params.Timeout = 5 * time.Second
-			_ = mdns.Query(params)
-			close(entries)
+// configureDiscovery applies the discovery settings in s. Call it before
+// StartDiscovery. The settings have been checked by resolveSettings.
+func configureDiscovery(s Settings) {
+	discoveryOptions.Services = s.DiscoveryServices
+	discoveryOptions.Match = nil
+	if re, err := discovery.CompileMatch(s.DiscoveryMatch); err == nil {
+		discoveryOptions.Match = re
+	}
+	discoveryOptions.Interval = s.DiscoveryInterval
+	discoveryOptions.Interface = nil
+	if s.Interface != "" {
+		discoveryOptions.Interface, _ = discovery.LookupInterface(s.Interface)
+	}
+}
 
-			// Wait before re-querying, or exit if context is done
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(30 * time.Second):
-			}
-		}
-	}()
+// discoveryInterfaceName names the interface discovery uses, for logs.
+func discoveryInterfaceName() string {
+	if discoveryOptions.Interface == nil {
+		return "the default interface"
+	}
+	return discoveryOptions.Interface.Name
+}
 
+// StartDiscovery starts mDNS discovery with discoveryOptions; see
+// discovery.Start. If it can't, that is logged and the channel is closed
+// right away, as if nothing were found.
+func StartDiscovery(ctx context.Context) <-chan discovery.Device {
+	logf(levelInfo, "mdns: querying %s on %s", strings.Join(discoveryOptions.Services, ", "), discoveryInterfaceName())
+	ch, err := discovery.Start(ctx, discoveryOptions)
+	if err != nil {
+		logf(levelWarn, "mdns on %s: %v", discoveryInterfaceName(), err)
+		closed := make(chan discovery.Device)
+		close(closed)
+		return closed
+	}
 	return ch
 }
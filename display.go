@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const pathDisplay = "/settings/display"
+
+// displayModes are what a device's own display can show, in the order
+// the detail view cycles through them.
+var displayModes = []string{"score", "temp", "humid", "co2", "voc", "pm25", "clock"}
+
+// validDisplayMode reports whether mode is one of displayModes.
+func validDisplayMode(mode string) bool {
+	return slices.Contains(displayModes, mode)
+}
+
+// nextDisplayMode returns the mode after current, or the first one if
+// current isn't known.
+func nextDisplayMode(current string) string {
+	i := slices.Index(displayModes, current)
+	return displayModes[(i+1)%len(displayModes)]
+}
+
+// SetDisplay asks the device to show mode on its display.
+func SetDisplay(ctx context.Context, ip, mode string) error {
+	return deviceClient(ip).Put(ctx, pathDisplay, map[string]string{"display": mode})
+}
+
+// displayResultMsg reports a SetDisplay from the detail view.
+type displayResultMsg struct {
+	IP   string
+	Mode string
+	Err  error
+}
+
+func displayCmd(dev *Device, mode string) tea.Cmd {
+	ctx, ip := dev.ctx, dev.IP
+	return trackCmd("display "+ip, func() tea.Msg {
+		return displayResultMsg{IP: ip, Mode: mode, Err: SetDisplay(ctx, ip, mode)}
+	})
+}
+
+// cycleDisplay switches dev's display to the mode after the one it
+// shows (D in the detail view).
+func (m *model) cycleDisplay(dev *Device) tea.Cmd {
+	if isCloud(dev.IP) {
+		m.logAt(levelWarn, fmt.Sprintf("%s: the display can only be changed on the local network", dev.Name))
+		return nil
+	}
+	if isDemo(dev.IP) {
+		m.logAt(levelWarn, fmt.Sprintf("%s: demo devices have no display", dev.Name))
+		return nil
+	}
+	current := dev.displayMode
+	if current == "" && dev.Config != nil {
+		current = dev.Config.Display
+	}
+	mode := nextDisplayMode(current)
+	dev.displayMode = mode
+	m.addLog(fmt.Sprintf("%s: setting display to %s...", dev.Name, mode))
+	return displayCmd(dev, mode)
+}
+
+// handleDisplayResult logs a failed display change, or fetches the
+// device config to confirm a successful one; see confirmDisplay.
+func (m *model) handleDisplayResult(msg displayResultMsg) tea.Cmd {
+	dev, ok := m.devices[msg.IP]
+	if !ok {
+		return nil
+	}
+	if msg.Err != nil {
+		logf(levelDebug, "display %s: %v", msg.IP, msg.Err)
+		m.logAt(levelError, fmt.Sprintf("%s: can't set display to %s: %s", dev.Name, msg.Mode, settingErrorSummary(msg.Err)))
+		return nil
+	}
+	if !m.fetchConfig {
+		m.addLog(fmt.Sprintf("%s: display set to %s", dev.Name, msg.Mode))
+		return nil
+	}
+	dev.displayPending = true
+	return configCmd(dev)
+}
+
+// confirmDisplay checks a freshly fetched config against a display
+// change waiting for confirmation, and logs the outcome.
+func (m *model) confirmDisplay(dev *Device) {
+	if !dev.displayPending || dev.Config == nil {
+		return
+	}
+	dev.displayPending = false
+	mode := dev.displayMode
+	if dev.Config.Display == mode {
+		m.addLog(fmt.Sprintf("%s: display set to %s", dev.Name, mode))
+		return
+	}
+	m.logAt(levelWarn, fmt.Sprintf("%s: asked for display %s, but the device reports %s", dev.Name, mode, orDash(dev.Config.Display)))
+}
+
+// runSetDisplay is --set-display: it sets the display mode of the given
+// (or discovered) devices and returns the process exit code.
+func runSetDisplay(cfg *Config, s Settings, mode string) int {
+	targets := oneShotTargets(s)
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "No Awair devices found")
+		return 1
+	}
+
+	code := 0
+	for _, t := range targets {
+		name := t.IP
+		if n := cfg.Name("", t.IP); n != "" {
+			name = n
+		}
+		if err := SetDisplay(context.Background(), t.IP, mode); err != nil {
+			fmt.Fprintf(os.Stderr, "%s (%s): can't set display to %s: %s\n", name, t.IP, mode, settingErrorSummary(err))
+			code = 1
+			continue
+		}
+		if s.FetchDeviceConfig {
+			if devCfg, err := FetchDeviceConfig(context.Background(), t.IP); err == nil && devCfg.Display != mode {
+				fmt.Fprintf(os.Stderr, "%s (%s): asked for display %s, but the device reports %s\n", name, t.IP, mode, orDash(devCfg.Display))
+				code = 1
+				continue
+			}
+		}
+		fmt.Printf("%s (%s): display set to %s\n", name, t.IP, mode)
+	}
+	return code
+}
+
+// displayModeList lists displayModes for messages.
+func displayModeList() string {
+	return strings.Join(displayModes, ", ")
+}
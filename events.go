@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
+)
+
+// offlineAfterFailures is the number of consecutive failed polls after
+// which a device is considered offline, here and on the dashboard.
+const offlineAfterFailures = 3
+
+// streamEvent is one line of --events output.
+type streamEvent struct {
+	Type  string            `json:"type"` // reading, alert, discovered, error, offline, online
+	Time  time.Time         `json:"time"`
+	IP    string            `json:"ip"`
+	Name  string            `json:"name"`
+	Data  *awair.SensorData `json:"data,omitempty"`
+	Error string            `json:"error,omitempty"`
+
+	// LatencyMS is how long a reading took to fetch, in milliseconds.
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+
+	// Alert events only; see AlertTransition.
+	Alert    string   `json:"alert,omitempty"`    // fired, changed or cleared
+	Sensor   string   `json:"sensor,omitempty"`   // e.g. co2, or score
+	Severity string   `json:"severity,omitempty"` // the severity cleared, for cleared
+	Previous string   `json:"previous,omitempty"` // for changed
+	Value    *float64 `json:"value,omitempty"`    // display value (°F for temperatures); not for cleared
+}
+
+// eventDevice is the per-device state tracked by the event stream.
+type eventDevice struct {
+	name     string
+	failures int
+	offline  bool
+	alerts   AlertSnapshot
+}
+
+type eventPollResult struct {
+	ip       string
+	data     *awair.SensorData
+	err      error
+	latency  time.Duration
+	received time.Time
+}
+
+type eventConfigResult struct {
+	ip  string
+	cfg *awair.DeviceConfig
+}
+
+// runEvents polls devices without the TUI and writes one JSON object per
+// line to stdout for every event, until interrupted. Each event is a
+// single write, so consumers see it as soon as it happens.
+func runEvents(cfg *Config, s Settings) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	enc := json.NewEncoder(os.Stdout)
+	emit := func(ev streamEvent) {
+		if ev.Time.IsZero() {
+			ev.Time = time.Now()
+		}
+		if err := enc.Encode(ev); err != nil {
+			// stdout is gone (e.g. the consumer exited); nothing left to do
+			stop()
+		}
+	}
+
+	rules := defaultAlertRules()
+	devices := make(map[string]*eventDevice)
+	polls := make(chan eventPollResult)
+	configs := make(chan eventConfigResult)
+
+	poll := func(ip string) {
+		go func() {
+			start := time.Now()
+			data, err := FetchAirData(ctx, ip)
+			select {
+			case polls <- eventPollResult{ip: ip, data: data, err: err, latency: time.Since(start), received: time.Now()}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	add := func(ip, name string) {
+		if _, ok := devices[ip]; ok {
+			return
+		}
+		displayName := ip
+		if n := cfg.Name("", ip); n != "" {
+			displayName = n
+		} else if name != "" {
+			displayName = name
+		}
+		devices[ip] = &eventDevice{name: displayName}
+		poll(ip)
+		if !s.FetchDeviceConfig {
+			return
+		}
+		go func() {
+			devCfg, err := FetchDeviceConfig(ctx, ip)
+			if err != nil {
+				return
+			}
+			select {
+			case configs <- eventConfigResult{ip: ip, cfg: devCfg}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	for _, ip := range s.IPs {
+		add(ip, "")
+	}
+
+	var discovered <-chan discovery.Device
+	if !s.NoDiscovery {
+		discovered = StartDiscovery(ctx)
+	}
+
+	ticker := time.NewTicker(time.Duration(s.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return 0
+
+		case d, ok := <-discovered:
+			if !ok {
+				discovered = nil
+				continue
+			}
+			if _, exists := devices[d.IP]; exists {
+				continue
+			}
+			add(d.IP, d.Name)
+			emit(streamEvent{Type: "discovered", IP: d.IP, Name: devices[d.IP].name})
+
+		case c := <-configs:
+			dev, ok := devices[c.ip]
+			if !ok {
+				continue
+			}
+			if n := cfg.Name(c.cfg.DeviceUUID, c.ip); n != "" {
+				dev.name = n
+			} else if dev.name == c.ip && c.cfg.DeviceUUID != "" {
+				dev.name = c.cfg.DeviceUUID
+			}
+
+		case <-ticker.C:
+			for ip := range devices {
+				poll(ip)
+			}
+
+		case r := <-polls:
+			dev, ok := devices[r.ip]
+			if !ok {
+				continue
+			}
+			if r.err != nil {
+				dev.failures++
+				emit(streamEvent{Type: "error", IP: r.ip, Name: dev.name, Error: r.err.Error()})
+				if dev.failures >= offlineAfterFailures && !dev.offline {
+					dev.offline = true
+					emit(streamEvent{Type: "offline", IP: r.ip, Name: dev.name, Error: r.err.Error()})
+				}
+				continue
+			}
+			dev.failures = 0
+			if dev.offline {
+				dev.offline = false
+				emit(streamEvent{Type: "online", IP: r.ip, Name: dev.name})
+			}
+			emit(streamEvent{
+				Type: "reading", Time: readingTime(r.data, r.received, s.ExportTime),
+				IP: r.ip, Name: dev.name, Data: r.data, LatencyMS: latencyMS(r.latency),
+			})
+			alerts := evaluateAlerts(rules, r.data)
+			for _, t := range diffAlerts(dev.alerts, alerts) {
+				emit(streamAlertEvent(r.ip, dev.name, t))
+			}
+			dev.alerts = alerts
+		}
+	}
+}
+
+// streamAlertEvent is the --events line for an alert transition.
+func streamAlertEvent(ip, name string, t AlertTransition) streamEvent {
+	ev := streamEvent{
+		Type: "alert", IP: ip, Name: name,
+		Alert: t.Kind, Sensor: t.Alert.Key, Severity: t.Alert.Severity.String(),
+	}
+	switch t.Kind {
+	case alertChanged:
+		ev.Previous = t.Previous.String()
+	case alertCleared:
+		return ev
+	}
+	v := t.Alert.Value
+	ev.Value = &v
+	return ev
+}
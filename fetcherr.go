@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// unsupportedEndpoint reports whether err is the answer of firmware
+// that lacks the endpoint: 404, 405 or 501.
+func unsupportedEndpoint(err error) bool {
+	var status *awair.StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch status.Code {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// settingErrorSummary is errorSummary for requests that read or change
+// device settings, which older firmware may not support and which the
+// device may refuse.
+func settingErrorSummary(err error) string {
+	var status *awair.StatusError
+	switch {
+	case unsupportedEndpoint(err):
+		return "not supported by this firmware (" + errorSummary(err) + ")"
+	case errors.As(err, &status) && status.Code >= 400 && status.Code < 500:
+		return "refused by the device (" + errorSummary(err) + ")"
+	}
+	return errorSummary(err)
+}
+
+// errorSummary returns a short description of err for device cards and
+// the log panel, e.g. "timed out after 5s" or "connection refused".
+func errorSummary(err error) string {
+	var fe *awair.FetchError
+	if !errors.As(err, &fe) {
+		return err.Error()
+	}
+
+	var status *awair.StatusError
+	var netErr net.Error
+	var syntax *json.SyntaxError
+	var dnsErr *net.DNSError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &status):
+		return status.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Sprintf("timed out after %s", fe.Elapsed.Round(time.Second))
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return "host unreachable"
+	case errors.As(err, &dnsErr):
+		return "unknown host"
+	case errors.As(err, &syntax):
+		return "invalid response"
+	case errors.As(err, &urlErr):
+		// drop the repeated method and URL
+		return urlErr.Err.Error()
+	default:
+		return fe.Err.Error()
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// flashDuration is how long a device card stays highlighted after one of
+// its sensors turns poor.
+const flashDuration = 5 * time.Second
+
+// flashState highlights a device card: a poor-colored border and the
+// sensors that turned poor emphasized, until the deadline.
+type flashState struct {
+	until   time.Time
+	sensors map[string]bool
+}
+
+// flashEndMsg asks to end a device's flash if its deadline has passed.
+type flashEndMsg struct {
+	ID deviceID
+}
+
+// newlyPoor returns the sensors that turned poor in ts: critical alerts
+// that just fired or were raised from a warning. Staying poor is not a
+// transition, so it never shows up here twice in a row.
+func newlyPoor(ts []AlertTransition) []string {
+	var keys []string
+	for _, t := range ts {
+		if t.Kind != alertCleared && t.Alert.Severity == alertCritical && t.Alert.Key != scoreAlertKey {
+			keys = append(keys, t.Alert.Key)
+		}
+	}
+	return keys
+}
+
+// startFlash highlights sensors on dev's card for flashDuration, extending
+// a flash already running, and returns the command that ends it.
+func (m *model) startFlash(dev *Device, sensors []string) tea.Cmd {
+	f := m.flashes[dev.ID]
+	if f == nil {
+		f = &flashState{sensors: make(map[string]bool)}
+		m.flashes[dev.ID] = f
+	}
+	for _, k := range sensors {
+		f.sensors[k] = true
+	}
+	f.until = time.Now().Add(flashDuration)
+	id := dev.ID
+	return tea.Tick(flashDuration, func(time.Time) tea.Msg { return flashEndMsg{ID: id} })
+}
+
+// endFlash removes a device's flash once its deadline has passed; a flash
+// extended since the message was scheduled ends with a later message.
+func (m *model) endFlash(id deviceID) {
+	if f := m.flashes[id]; f != nil && !time.Now().Before(f.until) {
+		delete(m.flashes, id)
+	}
+}
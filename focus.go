@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// focusSensors are the sensors f cycles through, CO₂ first for watching a
+// house air out.
+var focusSensors = []string{"co2", "pm25", "voc", "temp", "humid"}
+
+// nextFocus returns the sensor after key in the f cycle, or "" (focus off)
+// after the last one.
+func nextFocus(key string) string {
+	if key == "" {
+		return focusSensors[0]
+	}
+	for i, k := range focusSensors {
+		if k == key && i+1 < len(focusSensors) {
+			return focusSensors[i+1]
+		}
+	}
+	return ""
+}
+
+// renderFocusLine renders the focused sensor for a device card: marked,
+// bold, and with a bar at least as wide as the others. Devices without
+// the sensor show "n/a" so every card has the line in the same place.
+func (m model) renderFocusLine(dev *Device, barWidth int) string {
+	label := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(visPadRight("▶ "+awair.OptimalRanges[m.focusSensor].Label, 14))
+	value, ok := readingValue(dev, m.focusSensor)
+	if !ok {
+		return label + " " + lipgloss.NewStyle().Bold(true).Foreground(theme.Muted).Render(visPadLeft("n/a", 12))
+	}
+
+	ratingVal := awair.DisplayValue(m.focusSensor, value)
+	rating := awair.RateSensorValue(m.focusSensor, ratingVal)
+	color := ratingColor(rating)
+	line := fmt.Sprintf("%s %s %s",
+		label,
+		lipgloss.NewStyle().Bold(true).Underline(true).Foreground(color).Render(visPadLeft(ratingMark(rating)+awair.FormatValue(m.focusSensor, value, m.fahrenheit), 12)),
+		trendArrow(m.focusSensor, dev.Prev, value))
+	if barWidth > 0 {
+		line += " " + sensorRowTail(awair.SensorReading{Key: m.focusSensor, Value: value}, ratingVal, barWidth, color)
+	}
+	return line
+}
@@ -0,0 +1,41 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// glyphSet holds the characters bars, sparklines and charts are drawn
+// with.
+type glyphSet struct {
+	Filled, Empty string // bar and gauge cells
+	Spark         []rune // sparkline levels, lowest first
+	ChartDot      rune   // replaces braille in charts, or 0 to use braille
+
+	Good, Fair, Poor string // rating marks, with themes that use them
+	Warn             string // e.g. a device clock that is off
+	Cloud            string // tags devices polled through the Awair cloud
+
+	Border, Selected lipgloss.Border // boxes, and the selected or zoomed card
+}
+
+var (
+	unicodeGlyphs = glyphSet{
+		Filled: "█", Empty: "░", Spark: []rune("▁▂▃▄▅▆▇█"),
+		Good: "✓", Fair: "!", Poor: "✗", Warn: "⚠", Cloud: "☁",
+		Border: lipgloss.RoundedBorder(), Selected: lipgloss.ThickBorder(),
+	}
+
+	// asciiGlyphs are for consoles that can't draw block elements,
+	// braille or box drawing (legacy Windows conhost, serial consoles,
+	// --ascii or "ascii": true in the config).
+	asciiGlyphs = glyphSet{
+		Filled: "#", Empty: "-", Spark: []rune("_.-=+*#@"), ChartDot: '*',
+		Good: "+", Fair: "!", Poor: "x", Warn: "!", Cloud: "(cloud)",
+		Border: lipgloss.ASCIIBorder(),
+		Selected: lipgloss.Border{
+			Top: "=", Bottom: "=", Left: "#", Right: "#",
+			TopLeft: "#", TopRight: "#", BottomLeft: "#", BottomRight: "#",
+		},
+	}
+)
+
+// glyphs is the set in use, chosen at startup.
+var glyphs = unicodeGlyphs
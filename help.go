@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpBinding is one row of the help overlay.
+type helpBinding struct {
+	keys, action string
+}
+
+// helpSections returns the keymap grouped for the help overlay, with the
+// current state of toggles filled in.
+func (m model) helpSections() []struct {
+	title    string
+	bindings []helpBinding
+} {
+	units := "°C"
+	if m.fahrenheit {
+		units = "°F"
+	}
+	polling := "running"
+	if m.paused {
+		polling = "paused"
+	}
+
+	return []struct {
+		title    string
+		bindings []helpBinding
+	}{
+		{"Navigation", []helpBinding{
+			{"← → ↑ ↓", "Select a device"},
+			{"pgup/pgdn", "Previous/next page of devices (< >)"},
+			{"enter / 1-9", "Open device details"},
+			{"z", "Zoom the selected device (z/esc back)"},
+			{"t", "Switch between grid and table"},
+			{"o", "Cycle device sort (now " + m.sortMode + ")"},
+			{"esc", "Back to the grid (in details)"},
+			{"← →", "Chart another sensor (in details)"},
+		}},
+		{"Devices", []helpBinding{
+			{"a", "Add a device by IP, host name or URL"},
+			{"n", "Rename the selected device"},
+			{"x / delete", "Remove the selected device"},
+			{"y", "Copy the selected device's address"},
+			{"Y", "Copy the reading and config as JSON (in details)"},
+			{"[ ]", "Move the selected device"},
+			{"d", "Restart mDNS discovery"},
+			{"F", "Found devices not yet added"},
+			{"S", "Scan a subnet for devices"},
+			{"R", "Reset lifetime records (in details)"},
+			{"D", "Cycle the device's own display mode (in details)"},
+			{"L / [ ]", "Cycle the LED mode / dim or brighten the LEDs (in details)"},
+		}},
+		{"Display", []helpBinding{
+			{"u", "Switch °C/°F (now " + units + ")"},
+			{"f", "Focus one sensor on every card (f next, esc off)"},
+			{"+ / -", fmt.Sprintf("Poll more/less often (now %s)", m.pollInterval)},
+			{"?", "Show or hide this help"},
+		}},
+		{"App", []helpBinding{
+			{"r", "Refresh all devices and their config now"},
+			{"ctrl+r", "Reload the config file"},
+			{"p", "Pause/resume polling (now " + polling + ")"},
+			{"l", "Expand the log (esc to collapse)"},
+			{"s", "Summary: min/avg/max, time poor, lowest score"},
+			{"q", "Quit"},
+		}},
+	}
+}
+
+// helpLines renders the help content as lines, one binding per line.
+func (m model) helpLines() []string {
+	var lines []string
+	keyStyle := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent)
+	for i, sec := range m.helpSections() {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, lipgloss.NewStyle().Bold(true).Render(sec.title))
+		for _, b := range sec.bindings {
+			lines = append(lines, "  "+keyStyle.Render(visPadRight(b.keys, 12))+" "+b.action)
+		}
+	}
+	return lines
+}
+
+func (m model) handleHelpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "?":
+		m.showHelp = false
+		m.helpScroll = 0
+	case "up", "k":
+		if m.helpScroll > 0 {
+			m.helpScroll--
+		}
+	case "down", "j":
+		if m.helpScroll < m.helpMaxScroll(m.gridHeight()) {
+			m.helpScroll++
+		}
+	case "q", "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// helpVisibleLines is how many help lines fit in the grid area: the
+// border, title and footer take four.
+func helpVisibleLines(gridHeight int) int {
+	if gridHeight-4 < 1 {
+		return 1
+	}
+	return gridHeight - 4
+}
+
+// helpMaxScroll is the largest useful scroll offset for the help panel.
+func (m model) helpMaxScroll(gridHeight int) int {
+	if n := len(m.helpLines()) - helpVisibleLines(gridHeight); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// overlayHelp renders the help panel centered in the grid area. If the
+// area is too short the content scrolls with ↑/↓.
+func (m model) overlayHelp(gridHeight int) string {
+	lines := m.helpLines()
+	visible := helpVisibleLines(gridHeight)
+	maxScroll := m.helpMaxScroll(gridHeight)
+	scroll := m.helpScroll
+	if scroll > maxScroll {
+		scroll = maxScroll
+	}
+	end := scroll + visible
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	footer := "esc close"
+	if maxScroll > 0 {
+		footer = fmt.Sprintf("↑↓ scroll (%d/%d)  esc close", scroll+1, maxScroll+1)
+	}
+
+	width := 52
+	if width > m.width-2 {
+		width = m.width - 2
+	}
+	box := lipgloss.NewStyle().
+		Width(width).
+		Border(glyphs.Border).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Render(lipgloss.NewStyle().Bold(true).Render("Keyboard shortcuts") + "\n" +
+			lipgloss.NewStyle().MaxWidth(width-2).Render(strings.Join(lines[scroll:end], "\n")) + "\n" +
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(footer))
+
+	return lipgloss.Place(m.width, gridHeight,
+		lipgloss.Center, lipgloss.Center,
+		box)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// historySize is the number of unique samples kept per device
+// (an hour at the default 10s interval).
+const historySize = 360
+
+// Sample is one unique reading from a device.
+type Sample struct {
+	DeviceTime time.Time // parsed SensorData.Timestamp; zero if missing
+	Received   time.Time // when we fetched it; carries the monotonic clock
+	Data       *awair.SensorData
+	Period     time.Duration // for an average, its span; 0 for a sample
+	Seeded     bool          // fetched when the device was added, see Seed
+}
+
+// History is a bounded buffer of unique samples, ordered by device sample
+// time. The device serves the same sample for several seconds, so fetches
+// that return an already-stored sample are counted rather than stored.
+type History struct {
+	Samples    []Sample
+	Duplicates int
+}
+
+// parseDeviceTime parses the device-reported sample timestamp.
+func parseDeviceTime(ts string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// formatSampleTime formats a device sample time in local time, with the
+// date if it isn't today.
+func formatSampleTime(t time.Time) string {
+	const day = "2006-01-02"
+	t = t.Local()
+	if t.Format(day) == time.Now().Format(day) {
+		return t.Format("15:04:05")
+	}
+	return t.Format(day + " 15:04:05")
+}
+
+// Add stores data unless a sample with the same device timestamp is
+// already stored. It reports whether the sample was new. period is the
+// span data averages over, 0 for a sample.
+//
+// Samples are placed by device time, so a device whose clock steps back
+// (e.g. an NTP correction) can't leave the history out of order. Samples
+// without a timestamp are appended. Seeded averages that overlap the
+// sample are dropped, see Seed.
+func (h *History) Add(data *awair.SensorData, received time.Time, period time.Duration) bool {
+	s := Sample{
+		DeviceTime: parseDeviceTime(data.Timestamp),
+		Received:   received,
+		Data:       data,
+		Period:     period,
+	}
+	if !h.insert(s) {
+		h.Duplicates++
+		return false
+	}
+	h.dropSeeded(s.DeviceTime)
+	return true
+}
+
+// Restore stores samples kept from an earlier run (--db) and returns
+// how many were new. Like polled ones, they replace overlapping seeded
+// averages.
+func (h *History) Restore(samples []Sample) int {
+	n := 0
+	for _, s := range samples {
+		if h.insert(s) {
+			h.dropSeeded(s.DeviceTime)
+			n++
+		}
+	}
+	return n
+}
+
+// dropSeeded removes the seeded averages whose span reaches t.
+func (h *History) dropSeeded(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	h.Samples = slices.DeleteFunc(h.Samples, func(o Sample) bool {
+		return o.Seeded && o.DeviceTime.After(t.Add(-o.Period))
+	})
+}
+
+// Seed stores averages the device kept from before it was added, each
+// covering period. Averages without a timestamp, or overlapping the
+// samples already polled, are skipped. It returns how many were stored.
+func (h *History) Seed(averages []awair.SensorData, period time.Duration, received time.Time) int {
+	var firstLive time.Time
+	for _, s := range h.Samples {
+		if !s.Seeded && !s.DeviceTime.IsZero() {
+			firstLive = s.DeviceTime
+			break
+		}
+	}
+	n := 0
+	for i := range averages {
+		t := parseDeviceTime(averages[i].Timestamp)
+		if t.IsZero() || (!firstLive.IsZero() && t.After(firstLive.Add(-period))) {
+			continue
+		}
+		if h.insert(Sample{DeviceTime: t, Received: received, Data: &averages[i], Period: period, Seeded: true}) {
+			n++
+		}
+	}
+	return n
+}
+
+// insert places s by device time, see Add. It reports false if a sample
+// with the same device time is already stored.
+//
+// Samples without a device time stay where they were appended and are
+// never compared: s goes right before the first later timed sample, or
+// at the end. Searching from the end finds the usual newest sample at
+// once.
+func (h *History) insert(s Sample) bool {
+	i := len(h.Samples)
+	if !s.DeviceTime.IsZero() {
+		for j := len(h.Samples) - 1; j >= 0; j-- {
+			t := h.Samples[j].DeviceTime
+			if t.IsZero() {
+				continue
+			}
+			if t.Equal(s.DeviceTime) {
+				return false
+			}
+			if t.Before(s.DeviceTime) {
+				break
+			}
+			i = j
+		}
+	}
+
+	h.Samples = append(h.Samples, Sample{})
+	copy(h.Samples[i+1:], h.Samples[i:])
+	h.Samples[i] = s
+	if len(h.Samples) > historySize {
+		h.Samples = h.Samples[len(h.Samples)-historySize:]
+	}
+	return true
+}
+
+// age returns how long ago t was, never negative. For times taken with
+// time.Now() in this process the monotonic clock is used, so wall-clock
+// steps don't affect it; the clamp covers times read back from disk.
+func age(t time.Time) time.Duration {
+	if d := time.Since(t); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// shortDuration formats d for compact displays such as "12m ago": whole
+// seconds under a minute, whole minutes under an hour, hours and minutes
+// beyond.
+func shortDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+}
+
+// Ways to smooth the displayed score, see smoothedScore.
+const (
+	smoothMedian = "median"
+	smoothMean   = "mean"
+)
+
+// validSmoothMode reports whether mode is a known smoothing mode.
+func validSmoothMode(mode string) bool {
+	return mode == smoothMedian || mode == smoothMean
+}
+
+// smoothedScore returns the median or mean score of the last n samples,
+// rounded to the nearest point. ok is false if there are no samples.
+func (h History) smoothedScore(n int, mode string) (score int, ok bool) {
+	samples := h.Samples
+	if len(samples) > n {
+		samples = samples[len(samples)-n:]
+	}
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	scores := make([]int, len(samples))
+	sum := 0
+	for i, s := range samples {
+		scores[i] = s.Data.Score
+		sum += s.Data.Score
+	}
+	if mode == smoothMean {
+		return int(math.Round(float64(sum) / float64(len(scores)))), true
+	}
+	sort.Ints(scores)
+	mid := len(scores) / 2
+	if len(scores)%2 == 1 {
+		return scores[mid], true
+	}
+	return int(math.Round(float64(scores[mid-1]+scores[mid]) / 2)), true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+var historyEpoch = time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+
+// historyData is a reading taken seconds after historyEpoch, or without
+// a timestamp if seconds is negative. Its score tells samples apart.
+func historyData(seconds, score int) *awair.SensorData {
+	d := &awair.SensorData{Score: score}
+	if seconds >= 0 {
+		d.Timestamp = historyEpoch.Add(time.Duration(seconds) * time.Second).Format(time.RFC3339)
+	}
+	return d
+}
+
+func historyScores(h History) []int {
+	var scores []int
+	for _, s := range h.Samples {
+		scores = append(scores, s.Data.Score)
+	}
+	return scores
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHistoryAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		seconds []int // -1: no timestamp
+		want    []int // scores in stored order
+		dups    int
+	}{
+		{"in order", []int{0, 10, 20}, []int{1, 2, 3}, 0},
+		{"duplicate", []int{0, 10, 10, 0}, []int{1, 2}, 2},
+		{"clock steps back", []int{0, 10, 20, 5, 15}, []int{1, 4, 2, 5, 3}, 0},
+		{"before everything", []int{10, 20, 0}, []int{3, 1, 2}, 0},
+		{"untimed appended", []int{0, -1, 10, -1}, []int{1, 2, 3, 4}, 0},
+		// The untimed sample keeps its place after 20 while 5 goes before
+		// every later timed sample
+		{"step back past an untimed sample", []int{20, -1, 5}, []int{3, 1, 2}, 0},
+		{"step back between untimed samples", []int{0, -1, 20, -1, 10}, []int{1, 2, 5, 3, 4}, 0},
+		{"duplicate behind an untimed sample", []int{0, 10, -1, 10}, []int{1, 2, 3}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h History
+			for i, sec := range tt.seconds {
+				h.Add(historyData(sec, i+1), historyEpoch, 0)
+			}
+			if got := historyScores(h); !sameInts(got, tt.want) {
+				t.Errorf("stored %v, want %v", got, tt.want)
+			}
+			if h.Duplicates != tt.dups {
+				t.Errorf("%d duplicates, want %d", h.Duplicates, tt.dups)
+			}
+		})
+	}
+}
+
+func TestHistoryBounded(t *testing.T) {
+	var h History
+	for i := range historySize + 10 {
+		h.Add(historyData(i, i), historyEpoch, 0)
+	}
+	if len(h.Samples) != historySize || h.Samples[0].Data.Score != 10 {
+		t.Errorf("%d samples from score %d", len(h.Samples), h.Samples[0].Data.Score)
+	}
+}
+
+// TestClockStepBack replays a device and host whose clocks both step
+// back a minute, as after an NTP correction.
+func TestClockStepBack(t *testing.T) {
+	var h History
+	for i, sec := range []int{0, 10, 20, 30, 40, 50, 60, 70} {
+		h.Add(historyData(sec, i+1), historyEpoch, 0)
+	}
+	for i, sec := range []int{20, 30, 40, 80} {
+		h.Add(historyData(sec, 100+i), historyEpoch, 0)
+	}
+	// Readings from the re-run minute are the ones already stored
+	if h.Duplicates != 3 {
+		t.Errorf("%d duplicates, want 3", h.Duplicates)
+	}
+	for i := 1; i < len(h.Samples); i++ {
+		if !h.Samples[i].DeviceTime.After(h.Samples[i-1].DeviceTime) {
+			t.Fatalf("out of order at %d: %v after %v", i, h.Samples[i].DeviceTime, h.Samples[i-1].DeviceTime)
+		}
+	}
+
+	// A time saved before the step is now in the future. Times without
+	// a monotonic reading, such as those read back from disk, show it.
+	future := time.Now().Add(time.Minute).Round(0)
+	if got := age(future); got != 0 {
+		t.Errorf("age of a future time = %v, want 0", got)
+	}
+	if got := age(time.Now().Add(-time.Minute).Round(0)); got < time.Minute {
+		t.Errorf("age of a minute ago = %v", got)
+	}
+}
+
+func TestSmoothedScore(t *testing.T) {
+	var h History
+	if _, ok := h.smoothedScore(5, smoothMedian); ok {
+		t.Error("a score without samples")
+	}
+	for i, score := range []int{90, 20, 85, 88, 80} {
+		h.Add(historyData(i, score), historyEpoch, 0)
+	}
+	if got, _ := h.smoothedScore(5, smoothMedian); got != 85 {
+		t.Errorf("median = %d, want 85", got)
+	}
+	if got, _ := h.smoothedScore(5, smoothMean); got != 73 {
+		t.Errorf("mean = %d, want 73", got)
+	}
+	if got, _ := h.smoothedScore(2, smoothMedian); got != 84 {
+		t.Errorf("median of 2 = %d, want 84", got)
+	}
+}
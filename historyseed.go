@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// historyOff is the --history-endpoint that seeds nothing; the others
+// are awair.Avg5Min and awair.Avg15Min.
+const (
+	historyOff             = "off"
+	defaultHistoryEndpoint = awair.Avg5Min
+)
+
+// validHistoryEndpoint reports whether e is a history endpoint or
+// historyOff.
+func validHistoryEndpoint(e string) bool {
+	return e == awair.Avg5Min || e == awair.Avg15Min || e == historyOff
+}
+
+// FetchAirDataAverages retrieves the averages the device keeps at the
+// given history endpoint, newest or oldest first depending on firmware.
+func FetchAirDataAverages(ctx context.Context, ip, endpoint string) ([]awair.SensorData, error) {
+	if isDemo(ip) {
+		return demoAverages(ip)
+	}
+	return deviceClient(ip).Averages(ctx, endpoint)
+}
+
+// historySeedMsg carries the averages fetched to seed a device's history.
+type historySeedMsg struct {
+	IP       string
+	Averages []awair.SensorData
+	Period   time.Duration
+	Err      error
+}
+
+func historySeedCmd(dev *Device, endpoint string) tea.Cmd {
+	ctx, ip := dev.ctx, dev.IP
+	return trackCmd("history "+ip, func() tea.Msg {
+		averages, err := FetchAirDataAverages(ctx, ip, endpoint)
+		return historySeedMsg{IP: ip, Averages: averages, Period: averagePeriods[endpoint], Err: err}
+	})
+}
+
+// seedHistory stores fetched averages in the device's history, under
+// any samples already polled. Firmware without the endpoint just starts
+// with an empty history, as before.
+func (m *model) seedHistory(msg historySeedMsg) {
+	dev, ok := m.devices[msg.IP]
+	if !ok {
+		return
+	}
+	if msg.Err != nil {
+		logf(levelDebug, "history %s: %s", msg.IP, settingErrorSummary(msg.Err))
+		return
+	}
+	if n := dev.History.Seed(msg.Averages, msg.Period, time.Now()); n > 0 {
+		m.addLog(fmt.Sprintf("%s: loaded %d %d-minute averages of recent history", dev.Name, n, int(msg.Period.Minutes())))
+	}
+}
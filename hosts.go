@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// handleHostResolved takes the lookup of a host name, either one typed at
+// the add prompt or a device's. A device's addresses let discovery and
+// IP-keyed devices be matched against it.
+func (m *model) handleHostResolved(msg hostResolvedMsg) {
+	if m.promptStep == "resolving" && m.pendingIP == msg.Host {
+		if msg.Err != nil {
+			m.logAt(levelWarn, fmt.Sprintf("Can't resolve %s: %v", msg.Host, msg.Err))
+			m.closePrompt()
+			return
+		}
+		m.promptNameStep()
+		return
+	}
+
+	dev, ok := m.devices[msg.Host]
+	if !ok {
+		return
+	}
+	if msg.Err != nil {
+		m.logAt(levelWarn, fmt.Sprintf("Can't resolve %s: %v", msg.Host, msg.Err))
+		return
+	}
+	dev.Addrs = msg.Addrs
+	logf(levelDebug, "%s resolved to %v", msg.Host, msg.Addrs)
+	for _, addr := range msg.Addrs {
+		if dup, ok := m.devices[addr]; ok {
+			m.dropDuplicate(dev, dup, "same address")
+		}
+	}
+}
+
+// deviceAt returns the device keyed by addr or whose host name resolved
+// to it, or nil if there is none.
+func (m *model) deviceAt(addr string) *Device {
+	if dev, ok := m.devices[addr]; ok {
+		return dev
+	}
+	for _, dev := range m.devices {
+		if slices.Contains(dev.Addrs, addr) {
+			return dev
+		}
+	}
+	return nil
+}
+
+// sameUUID returns another device reporting dev's device UUID, or nil.
+func (m *model) sameUUID(dev *Device) *Device {
+	if dev.UUID == "" {
+		return nil
+	}
+	for _, other := range m.devices {
+		if other != dev && other.UUID == dev.UUID {
+			return other
+		}
+	}
+	return nil
+}
+
+// preferDevice picks which of two entries for the same device to keep: the
+// one keyed by host name, as the user asked for it by name, otherwise the
+// one added first.
+func preferDevice(a, b *Device) (keep, dup *Device) {
+	if isHostname(a.IP) != isHostname(b.IP) {
+		if isHostname(a.IP) {
+			return a, b
+		}
+		return b, a
+	}
+	if a.ID < b.ID {
+		return a, b
+	}
+	return b, a
+}
+
+// dropDuplicate removes dup, found to be the same device as keep.
+func (m *model) dropDuplicate(keep, dup *Device, why string) {
+	m.removeDevice(dup.IP)
+	m.addLog(fmt.Sprintf("Removed %s: %s as %s (%s)", dup.IP, why, keep.Name, keep.IP))
+}
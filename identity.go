@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Devices are identified by their device UUID once it is known, so one
+// that gets a new IP address, e.g. from a DHCP lease change, stays the
+// same device instead of showing up twice.
+
+// deviceByUUID returns the device with the given UUID, or nil.
+func (m *model) deviceByUUID(uuid string) *Device {
+	if uuid == "" {
+		return nil
+	}
+	for _, dev := range m.devices {
+		if dev.UUID == uuid {
+			return dev
+		}
+	}
+	return nil
+}
+
+// identify records dev's UUID in the config, moving its saved entry to
+// its current address, and names it by that entry.
+func (m *model) identify(dev *Device) {
+	if dev.UUID != "" && m.config.Identify(dev.UUID, dev.IP) {
+		m.saveConfig()
+	}
+	if name := m.config.Name(dev.UUID, dev.IP); name != "" {
+		dev.Name = name
+	} else {
+		dev.Name = fallbackName(dev)
+	}
+}
+
+// moveInto moves dev to the address of dup, a device added since that
+// turned out to be dev at a new address, and drops dup. dev keeps its
+// name, history and place on the dashboard.
+func (m *model) moveInto(dev, dup *Device) tea.Cmd {
+	addr := dup.IP
+	dup.cancel()
+	selected := m.selectedDevice()
+	m.keepSelection(func() {
+		delete(m.devices, addr)
+		m.deviceOrder = slices.DeleteFunc(m.deviceOrder, func(o string) bool { return o == addr })
+	})
+	if selected == dup {
+		m.moveSelection(0)
+	}
+	if m.zoomID == dup.ID {
+		m.zoomID = dev.ID
+	}
+	if m.detailID == dup.ID {
+		m.detailID = dev.ID
+	}
+
+	old := dev.IP
+	dev.Config = dup.Config
+	if dev.DiscoveredName == "" {
+		dev.DiscoveredName = dup.DiscoveredName
+	}
+	m.records.Rekey(old, recordKey(dev))
+	m.relocateDevice(dev, addr)
+	m.identify(dev)
+	return pollCmd(dev)
+}
+
+// relocateDevice changes dev's address to addr, in the dashboard order and
+// the saved order too. Requests to the old address are abandoned.
+func (m *model) relocateDevice(dev *Device, addr string) {
+	old := dev.IP
+	dev.cancel()
+	delete(m.devices, old)
+	dev.ctx, dev.cancel = context.WithCancel(m.ctx)
+	dev.IP, dev.Addrs = addr, nil
+	dev.LastError, dev.Failures, dev.Backoff, dev.SkipTicks = nil, 0, 0, 0
+	m.devices[addr] = dev
+	for i, o := range m.deviceOrder {
+		if o == old {
+			m.deviceOrder[i] = addr
+		}
+	}
+
+	if slices.Contains(m.config.Order, old) {
+		order := m.config.Order[:0]
+		for _, o := range m.config.Order {
+			switch o {
+			case addr:
+				continue
+			case old:
+				o = addr
+			}
+			order = append(order, o)
+		}
+		m.config.Order = order
+		m.saveConfig()
+	}
+	m.addLog(fmt.Sprintf("%s moved to %s", dev.Name, addr))
+}
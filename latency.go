@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// latencyWindow is how many recent polls Latency.Average covers.
+const latencyWindow = 10
+
+// defaultSlowLatency is the response time above which a device's latency
+// is shown as slow.
+const defaultSlowLatency = time.Second
+
+// Latency tracks how long a device takes to answer its polls. Only
+// successful polls count: a failed one mostly measures the timeout.
+type Latency struct {
+	Last   time.Duration
+	recent []time.Duration // up to latencyWindow, oldest first
+}
+
+// Add records the duration of a successful poll.
+func (l *Latency) Add(d time.Duration) {
+	l.Last = d
+	if len(l.recent) == latencyWindow {
+		l.recent = l.recent[1:]
+	}
+	l.recent = append(l.recent, d)
+}
+
+// Average returns the mean of the recent polls and how many it covers.
+func (l Latency) Average() (time.Duration, int) {
+	if len(l.recent) == 0 {
+		return 0, 0
+	}
+	var sum time.Duration
+	for _, d := range l.recent {
+		sum += d
+	}
+	return sum / time.Duration(len(l.recent)), len(l.recent)
+}
+
+// formatLatency formats a poll duration compactly: "38ms", "1.2s".
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return "<1ms"
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	default:
+		return fmt.Sprintf("%.1fs", d.Seconds())
+	}
+}
+
+// latencyText is the " · 38ms" after a card's update time, in the fair
+// color when the last poll took longer than slowLatency.
+func (m model) latencyText(dev *Device) string {
+	if dev.Latency.Last == 0 {
+		return ""
+	}
+	style := lipgloss.NewStyle().Foreground(theme.Muted)
+	if dev.Latency.Last > m.slowLatency {
+		style = style.Foreground(theme.Fair)
+	}
+	return style.Render(" · " + formatLatency(dev.Latency.Last))
+}
+
+// latencyMS converts a poll duration to milliseconds for JSON output,
+// to a tenth of a millisecond.
+func latencyMS(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Millisecond)*10) / 10
+}
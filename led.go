@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const pathLED = "/settings/led"
+
+// ledModes are the LED modes in the order the detail view cycles through
+// them. "sleep" is Knocturne, which keeps the LEDs off at night.
+var ledModes = []string{"auto", "manual", "sleep"}
+
+// ledBrightnessStep is how much [ and ] change the LED brightness, in
+// percent.
+const ledBrightnessStep = 10
+
+// LEDSettings is the device's LED configuration from /settings/led.
+// Brightness, in percent, only applies in manual mode.
+type LEDSettings struct {
+	Mode       string `json:"mode"`
+	Brightness int    `json:"brightness"`
+}
+
+func (l LEDSettings) String() string {
+	if l.Mode == "manual" {
+		return fmt.Sprintf("manual, %d%%", l.Brightness)
+	}
+	return orDash(l.Mode)
+}
+
+// FetchLEDSettings retrieves the LED settings of the device at ip.
+func FetchLEDSettings(ctx context.Context, ip string) (*LEDSettings, error) {
+	var led LEDSettings
+	if err := deviceClient(ip).Get(ctx, pathLED, &led); err != nil {
+		return nil, err
+	}
+	return &led, nil
+}
+
+// SetLEDSettings asks the device to use led.
+func SetLEDSettings(ctx context.Context, ip string, led LEDSettings) error {
+	return deviceClient(ip).Put(ctx, pathLED, led)
+}
+
+// ledResultMsg carries a device's LED settings. Want is set when they
+// were read back after a change from the detail view.
+type ledResultMsg struct {
+	IP   string
+	LED  *LEDSettings
+	Want *LEDSettings
+	Err  error
+}
+
+func ledCmd(dev *Device) tea.Cmd {
+	ctx, ip := dev.ctx, dev.IP
+	return trackCmd("led settings "+ip, func() tea.Msg {
+		led, err := FetchLEDSettings(ctx, ip)
+		return ledResultMsg{IP: ip, LED: led, Err: err}
+	})
+}
+
+// setLEDCmd changes the LED settings, then reads them back so the result
+// can be checked.
+func setLEDCmd(dev *Device, want LEDSettings) tea.Cmd {
+	ctx, ip := dev.ctx, dev.IP
+	return trackCmd("set led "+ip, func() tea.Msg {
+		if err := SetLEDSettings(ctx, ip, want); err != nil {
+			return ledResultMsg{IP: ip, Want: &want, Err: err}
+		}
+		led, err := FetchLEDSettings(ctx, ip)
+		return ledResultMsg{IP: ip, LED: led, Want: &want, Err: err}
+	})
+}
+
+// changeLED applies change to dev's LED settings and sends the result
+// (L and [ ] in the detail view).
+func (m *model) changeLED(dev *Device, change func(*LEDSettings)) tea.Cmd {
+	if isCloud(dev.IP) {
+		m.logAt(levelWarn, fmt.Sprintf("%s: the LEDs can only be changed on the local network", dev.Name))
+		return nil
+	}
+	if isDemo(dev.IP) {
+		m.logAt(levelWarn, fmt.Sprintf("%s: demo devices have no LEDs", dev.Name))
+		return nil
+	}
+	if dev.ledUnsupported {
+		m.logAt(levelWarn, fmt.Sprintf("%s: LED control is not supported by this firmware", dev.Name))
+		return nil
+	}
+	if dev.LED == nil {
+		m.logAt(levelWarn, fmt.Sprintf("%s: LED settings not fetched yet", dev.Name))
+		return nil
+	}
+	want := *dev.LED
+	change(&want)
+	if want == *dev.LED {
+		return nil
+	}
+	m.addLog(fmt.Sprintf("%s: setting LED to %s...", dev.Name, want))
+	return setLEDCmd(dev, want)
+}
+
+// cycleLEDMode switches dev's LEDs to the mode after the current one.
+func (m *model) cycleLEDMode(dev *Device) tea.Cmd {
+	return m.changeLED(dev, func(l *LEDSettings) {
+		i := slices.Index(ledModes, l.Mode)
+		l.Mode = ledModes[(i+1)%len(ledModes)]
+	})
+}
+
+// stepLEDBrightness changes dev's LED brightness by delta percent, which
+// switches it to manual mode.
+func (m *model) stepLEDBrightness(dev *Device, delta int) tea.Cmd {
+	return m.changeLED(dev, func(l *LEDSettings) {
+		l.Mode = "manual"
+		l.Brightness = min(max(l.Brightness+delta, 0), 100)
+	})
+}
+
+// handleLEDResult stores fetched LED settings and, after a change, logs
+// whether the device took it.
+func (m *model) handleLEDResult(msg ledResultMsg) {
+	dev, ok := m.devices[msg.IP]
+	if !ok {
+		return
+	}
+	if msg.Err != nil {
+		logf(levelDebug, "led %s: %v", msg.IP, msg.Err)
+		if unsupportedEndpoint(msg.Err) {
+			dev.ledUnsupported = true
+			dev.LED = nil
+		}
+		if msg.Want != nil {
+			m.logAt(levelError, fmt.Sprintf("%s: can't set LED to %s: %s", dev.Name, msg.Want, settingErrorSummary(msg.Err)))
+		}
+		return
+	}
+	dev.ledUnsupported = false
+	dev.LED = msg.LED
+	if msg.Want == nil {
+		return
+	}
+	if *msg.LED == *msg.Want {
+		m.addLog(fmt.Sprintf("%s: LED set to %s", dev.Name, msg.LED))
+		return
+	}
+	m.logAt(levelWarn, fmt.Sprintf("%s: asked for LED %s, but the device reports %s", dev.Name, msg.Want, msg.LED))
+}
+
+// ledText describes dev's LED settings for the detail view.
+func ledText(dev *Device) string {
+	switch {
+	case dev.ledUnsupported:
+		return "not supported by this firmware"
+	case dev.LED == nil:
+		return "—"
+	}
+	return dev.LED.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxLogEntries is the number of log entries kept in memory. With
+// --log-file every entry is also written to the file as it is added.
+const maxLogEntries = 100
+
+// openLogView expands the log panel into the full-height viewer, pinned
+// to the newest entry.
+func (m *model) openLogView() {
+	m.showLogs = true
+	m.logFollow = true
+	m.logView = viewport.New(0, 0)
+	m.syncLogView()
+}
+
+// syncLogView refreshes the viewer's size and content. While following,
+// it stays scrolled to the bottom.
+func (m *model) syncLogView() {
+	// Header (2) + status bar (1) + border (2) + title (1)
+	m.logView.Width = m.width - 4
+	m.logView.Height = m.height - 6
+	if m.logView.Height < 1 {
+		m.logView.Height = 1
+	}
+
+	lines := make([]string, 0, len(m.logs))
+	for _, entry := range m.logs {
+		lines = append(lines, formatLogLine(entry))
+	}
+	m.logView.SetContent(strings.Join(lines, "\n"))
+	if m.logFollow {
+		m.logView.GotoBottom()
+	}
+}
+
+func (m model) handleLogKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "l":
+		m.showLogs = false
+		return m, nil
+	case "q", "ctrl+c":
+		return m, m.quit()
+	case "end", "G":
+		m.logView.GotoBottom()
+	case "home", "g":
+		m.logView.GotoTop()
+	default:
+		var cmd tea.Cmd
+		m.logView, cmd = m.logView.Update(msg)
+		m.logFollow = m.logView.AtBottom()
+		return m, cmd
+	}
+	m.logFollow = m.logView.AtBottom()
+	return m, nil
+}
+
+// renderLogView renders the expanded, scrollable log.
+func (m model) renderLogView() string {
+	state := "following"
+	if !m.logFollow {
+		state = fmt.Sprintf("%3.0f%%  End to follow", m.logView.ScrollPercent()*100)
+	}
+	title := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("Log (%d entries)", len(m.logs))) +
+		"  " + lipgloss.NewStyle().Foreground(theme.Muted).Render(state)
+
+	return lipgloss.NewStyle().
+		Width(m.width-2).
+		Border(glyphs.Border).
+		BorderForeground(theme.Muted).
+		Padding(0, 1).
+		Render(title + "\n" + m.logView.View())
+}
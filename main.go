@@ -4,25 +4,79 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
 )
 
 func main() {
-	noDiscovery := flag.Bool("no-discovery", false, "Disable mDNS auto-discovery")
-	interval := flag.Int("interval", 10, "Polling interval in seconds")
-	fahrenheit := flag.Bool("fahrenheit", false, "Display temperatures in Fahrenheit")
+	var fl cliFlags
+	flag.BoolVar(&fl.NoDiscovery, "no-discovery", false, "Disable mDNS auto-discovery")
+	flag.IntVar(&fl.Interval, "interval", defaultInterval, "Polling interval in seconds")
+	flag.BoolVar(&fl.Fahrenheit, "fahrenheit", false, "Display temperatures in Fahrenheit")
+	flag.BoolVar(&fl.RememberDiscovered, "remember-discovered", false, "Save discovered devices to the config and add them at startup")
+	flag.IntVar(&fl.MaxDiscovered, "max-discovered", defaultMaxDiscovered, "Maximum number of discovered devices to add automatically")
+	flag.BoolVar(&fl.NoConfigFetch, "no-device-config", false, "Don't fetch /settings/config/data from devices")
+	flag.BoolVar(&fl.ShowFirmware, "show-firmware", false, "Show each device's firmware version on its card")
+	flag.DurationVar(&fl.HTTPTimeout, "http-timeout", defaultHTTPTimeout, "Timeout for each request to a device")
+	flag.DurationVar(&fl.SlowLatency, "slow-latency", defaultSlowLatency, "Show a device's poll latency as slow above this")
+	flag.DurationVar(&fl.MaxClockSkew, "max-clock-skew", defaultMaxClockSkew, "Warn when a device's clock is further off than this")
+	flag.StringVar(&fl.ExportTime, "export-time", exportTimeReceived, "Time --once --json and --events give readings: received (by us) or device (its timestamp)")
+	flag.StringVar(&fl.DB, "db", "", "Save every reading to this SQLite database and load recent history from it at startup")
+	flag.StringVar(&fl.Serve, "serve", "", "Serve the latest readings as JSON over HTTP at this address, e.g. :8080 or 127.0.0.1:8080")
+	flag.StringVar(&fl.DBRetain, "db-retain", "30d", "Delete readings older than this from --db, e.g. 30d or 72h; 0 keeps them")
+	flag.StringVar(&fl.DBRaw, "db-raw", "7d", "Keep readings in --db as they came for this long, then compact them into 5-minute min/mean/max; 0 never compacts")
+	flag.StringVar(&fl.PollEndpoint, "poll-endpoint", awair.Latest, "Air data endpoint to poll: "+pollEndpointList())
+	flag.StringVar(&fl.HistoryEndpoint, "history-endpoint", defaultHistoryEndpoint, "Averages that seed a new device's history: "+awair.Avg5Min+", "+awair.Avg15Min+" or "+historyOff)
+	flag.StringVar(&fl.CloudToken, "cloud-token", "", "Awair developer API token: also poll the account's devices through the cloud")
+	flag.IntVar(&fl.HTTPRetries, "http-retries", 0, "Retry device requests that time out, are refused or get a 5xx this many times")
+	flag.StringVar(&fl.DiscoveryServices, "discovery-services", discovery.DefaultService, "Comma-separated mDNS service types to query, e.g. _http._tcp,_awair._tcp")
+	flag.StringVar(&fl.DiscoveryMatch, "discovery-match", discovery.DefaultMatch, "Regular expression (case-insensitive) a discovered instance name or TXT record must match")
+	flag.DurationVar(&fl.DiscoveryInterval, "discovery-interval", discovery.DefaultInterval, "How often to query mDNS again")
+	flag.StringVar(&fl.Interface, "interface", "", "Network interface for mDNS discovery, e.g. eth0 (default: the system's choice)")
+	flag.StringVar(&fl.LogFile, "log-file", "", "Append timestamped application log entries to this file")
+	flag.StringVar(&fl.LogLevel, "log-level", "info", "Minimum level written to --log-file: debug, info, warn or error")
+	flag.BoolVar(&fl.Mini, "mini", false, "Use the compact one-line-per-device view")
+	flag.BoolVar(&fl.ASCII, "ascii", false, "Draw bars, charts and borders with ASCII characters only")
+	flag.BoolVar(&fl.NoColor, "no-color", false, "Don't use colors; ratings are marked instead (also set by NO_COLOR)")
+	flag.BoolVar(&fl.Notify, "notify", false, "Show a desktop notification when a sensor turns poor")
+	flag.BoolVar(&fl.NotifyRecovery, "notify-recovery", false, "With --notify, also notify when a sensor is no longer poor")
+	flag.DurationVar(&fl.NotifyCooldown, "notify-cooldown", defaultNotifyCooldown, "With --notify, minimum time before a sensor that hasn't recovered notifies again")
+	flag.BoolVar(&fl.NoBell, "no-bell", false, "Don't ring the terminal bell when a sensor turns poor")
+	flag.BoolVar(&fl.NoFlash, "no-flash", false, "Don't highlight a device card when one of its sensors turns poor")
+	flag.BoolVar(&fl.NoMouse, "no-mouse", false, "Don't capture the mouse, so the terminal's own text selection works")
+	flag.IntVar(&fl.Demo, "demo", 0, fmt.Sprintf("Show this many simulated devices (up to %d) instead of the saved ones, without discovery or saving anything", demoMaxDevices))
+	flag.StringVar(&fl.AlertWebhook, "alert-webhook", "", "POST a JSON event to this URL when a sensor turns poor or is no longer poor")
+	flag.StringVar(&fl.AlertExec, "alert-exec", "", "Run this shell command when a sensor turns poor or is no longer poor (details in AWAIR_* variables)")
+	flag.IntVar(&fl.SmoothScore, "smooth-score", 0, "Show the median of the last N scores on cards instead of the latest (0 = off)")
+	flag.StringVar(&fl.SmoothMode, "smooth-mode", smoothMedian, "With --smooth-score, how to combine the scores: median or mean")
+	flag.StringVar(&fl.Theme, "theme", defaultTheme, "Color theme: "+themeNames())
+	once := flag.Bool("once", false, "Poll devices once, print the readings and exit")
+	asJSON := flag.Bool("json", false, "With --once, print readings as a JSON array on stdout")
+	jsonFahrenheit := flag.Bool("json-fahrenheit", false, "With --json, convert temperatures to Fahrenheit (default: Celsius)")
+	check := flag.Bool("check", false, "Poll devices once and exit 0/1/2 for good/fair/poor (for monitoring scripts)")
+	setDisplay := flag.String("set-display", "", "Set the display mode of the given (or discovered) devices and exit: "+displayModeList())
+	events := flag.Bool("events", false, "Print one JSON event per line on stdout instead of running the TUI")
+	testAlert := flag.Bool("test-alert", false, "Send a made-up alert through --notify, --alert-webhook and --alert-exec and exit")
+	compactNow := flag.Bool("compact-now", false, "Compact and purge --db now, as the dashboard does hourly, and exit")
+	scan := flag.String("scan", "", "Probe every address in this IPv4 range (e.g. 192.168.1.0/24, at most a /22) for devices, for networks without mDNS")
+	flag.StringVar(&configFile, "config", "", "Read and save the config in this file instead of the user config directory")
+	printConfig := flag.Bool("print-config", false, "Print the effective configuration as JSON and exit")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
+	registerCheckFlags(flag.CommandLine)
 
 	// Short flags
-	flag.IntVar(interval, "i", 10, "Polling interval in seconds (shorthand)")
-	flag.BoolVar(fahrenheit, "f", false, "Display temperatures in Fahrenheit (shorthand)")
+	flag.IntVar(&fl.Interval, "i", defaultInterval, "Polling interval in seconds (shorthand)")
+	flag.BoolVar(&fl.Fahrenheit, "f", false, "Display temperatures in Fahrenheit (shorthand)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Awair TUI — Real-time air quality monitoring
 
 Usage:
-  awair-tui [options] [ip ...]
+  awair-tui [options] [ip|host[:port]|url ...]
 
 Options:
 `)
@@ -33,31 +87,234 @@ Examples:
   awair-tui 192.168.1.100              Connect to specific device
   awair-tui -i 5 192.168.1.100        Poll every 5s
   awair-tui --fahrenheit               Show temps in °F
+  awair-tui --once --json | jq '.[].co2'
+                                       One-shot JSON output for scripts
+  awair-tui --check --check-co2-max 1000 192.168.1.100
+                                       Health check for Nagios & co.
+  awair-tui --events | jq 'select(.type=="alert")'
+                                       Stream readings, alerts and state changes as JSON lines
+  awair-tui --print-config             Show effective settings and their sources
+  awair-tui --set-display clock 192.168.1.100
+                                       Switch a device's display, e.g. for night mode
 `)
 	}
 
 	flag.Parse()
-	ips := flag.Args()
+	if *showVersion {
+		fmt.Println("awair-tui", version)
+		return
+	}
+	fl.IPs = flag.Args()
+	fl.set = visitedFlags(flag.CommandLine)
 
-	cfg := LoadConfig()
+	level, err := parseLogLevel(fl.LogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.AlertWebhook != "" {
+		if err := validateWebhookURL(fl.AlertWebhook); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	for _, svc := range splitList(fl.DiscoveryServices) {
+		if _, ok := discovery.ServiceName(svc); !ok {
+			fmt.Fprintf(os.Stderr, "Error: --discovery-services: %q is not a service type like _http._tcp\n", svc)
+			os.Exit(2)
+		}
+	}
+	if _, err := discovery.CompileMatch(fl.DiscoveryMatch); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --discovery-match: %v\n", err)
+		os.Exit(2)
+	}
+	if *setDisplay != "" && !validDisplayMode(*setDisplay) {
+		fmt.Fprintf(os.Stderr, "Error: --set-display: unknown mode %q; choose one of %s\n", *setDisplay, displayModeList())
+		os.Exit(2)
+	}
+	if _, err := parseRetention(fl.DBRetain); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --db-retain: %v\n", err)
+		os.Exit(2)
+	}
+	if _, err := parseRetention(fl.DBRaw); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --db-raw: %v\n", err)
+		os.Exit(2)
+	}
+	if fl.Demo < 0 || fl.Demo > demoMaxDevices {
+		fmt.Fprintf(os.Stderr, "Error: --demo: %d is not a number of devices from 0 to %d\n", fl.Demo, demoMaxDevices)
+		os.Exit(2)
+	}
+	if fl.Demo > 0 && (*once || *check || *events || *testAlert || *setDisplay != "" || *compactNow) {
+		fmt.Fprintln(os.Stderr, "Error: --demo: only the dashboard can show demo devices")
+		os.Exit(2)
+	}
+	if fl.Serve != "" {
+		if err := validServeAddr(fl.Serve); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --serve: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if !validPollEndpoint(fl.PollEndpoint) {
+		fmt.Fprintf(os.Stderr, "Error: --poll-endpoint: unknown endpoint %q; choose one of %s\n", fl.PollEndpoint, pollEndpointList())
+		os.Exit(2)
+	}
+	if !validHistoryEndpoint(fl.HistoryEndpoint) {
+		fmt.Fprintf(os.Stderr, "Error: --history-endpoint: %q is not %s, %s or %s\n", fl.HistoryEndpoint, awair.Avg5Min, awair.Avg15Min, historyOff)
+		os.Exit(2)
+	}
+	if !validExportTime(fl.ExportTime) {
+		fmt.Fprintf(os.Stderr, "Error: --export-time: %q is not %s or %s\n", fl.ExportTime, exportTimeReceived, exportTimeDevice)
+		os.Exit(2)
+	}
+	if _, ok := themes[fl.Theme]; !ok {
+		fmt.Fprintf(os.Stderr, "Error: --theme: unknown theme %q; choose one of %s\n", fl.Theme, themeNames())
+		os.Exit(2)
+	}
+	if fl.Interface != "" {
+		if _, err := discovery.LookupInterface(fl.Interface); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --interface: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	var scanRange netip.Prefix
+	if *scan != "" {
+		scanRange, err = parseScanRange(*scan)
+		if err == nil && scanHostCount(scanRange) > scanConfirmHosts {
+			err = fmt.Errorf("%s has %d addresses; scan a /22 or smaller here, or press S in the dashboard", scanRange, scanHostCount(scanRange))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --scan: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	for _, addr := range fl.IPs {
+		if _, ok := normalizeAddress(addr); !ok {
+			fmt.Fprintf(os.Stderr, "Error: %q is not an IP address, host name or http(s) URL\n", redactAddress(addr))
+			os.Exit(2)
+		}
+	}
+	if fl.LogFile != "" {
+		f, err := os.OpenFile(fl.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		appLog = newFileLogger(f, level)
+	}
+	// exit flushes the log file, which os.Exit would skip.
+	exit := func(code int) {
+		appLog.Close()
+		os.Exit(code)
+	}
+
+	moveLegacyFiles()
+	cfg, cfgErr := LoadConfig()
+	if cfgErr != nil {
+		logf(levelError, "loading config: %v", cfgErr)
+		fmt.Fprintf(os.Stderr, "Warning: can't load config: %v\nStarting without it; nothing will be saved until the file is fixed.\n", cfgErr)
+	}
+	if *check {
+		applyCheckOverrides()
+	} else if len(checkOverrides) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: --check-<sensor>-* flags only apply with --check; ignoring them")
+	}
+	settings := resolveSettings(cfg, fl)
+	if _, err := parseAlertTemplates(settings); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: config: %v\n", err)
+		exit(2)
+	}
+	if settings.ASCII {
+		glyphs = asciiGlyphs
+	}
+	configureHTTP(settings)
+	configureDiscovery(settings)
+	configureTheme(settings)
+
+	if *printConfig {
+		if err := printSettings(os.Stdout, cfg, settings); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+	if *check {
+		exit(runCheck(cfg, settings))
+	}
+	if *once {
+		exit(runOnce(cfg, settings, *asJSON, *jsonFahrenheit))
+	}
+	if *events {
+		exit(runEvents(cfg, settings))
+	}
+	if *testAlert {
+		exit(runTestAlert(settings))
+	}
+	if *setDisplay != "" {
+		exit(runSetDisplay(cfg, settings, *setDisplay))
+	}
+	if *compactNow {
+		exit(runCompactNow(settings))
+	}
 
 	// Set up discovery context before model creation so the cancel func
 	// is captured in the model's value copy passed to Bubbletea.
 	var cancel context.CancelFunc
 	var ctx context.Context
-	if !*noDiscovery {
+	if !settings.NoDiscovery {
 		ctx, cancel = context.WithCancel(context.Background())
 	}
 
-	m := initialModel(cfg, ips, *interval, *noDiscovery, *fahrenheit)
+	records, recordsErr := LoadRecords()
+	if settings.Demo > 0 {
+		// Demo devices start from a blank slate and leave nothing behind
+		cfg = &Config{Version: configVersion, Devices: DeviceList{}, memory: true}
+		records = &RecordStore{Devices: make(map[string]*DeviceRecords), memory: true}
+		settings.DB = ""
+	}
+
+	var store *ReadingStore
+	if settings.DB != "" {
+		if store, err = OpenStore(settings.DB, settings.DBRaw, settings.DBRetain); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: can't open database %s: %v\n", settings.DB, err)
+			exit(1)
+		}
+	}
+
+	var api *apiServer
+	if settings.Serve != "" {
+		if api, err = startAPIServer(settings.Serve); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --serve: %v\n", err)
+			exit(1)
+		}
+	}
+
+	m := initialModel(cfg, records, settings)
+	m.store = store
+	if api != nil {
+		m.api = api
+		m.addLog("Serving the API at " + api.URL())
+	}
+	if cfgErr != nil && settings.Demo == 0 {
+		m.logAt(levelError, fmt.Sprintf("Can't load config: %v; nothing will be saved until it is fixed", cfgErr))
+	}
+	if recordsErr != nil && settings.Demo == 0 {
+		m.logAt(levelError, fmt.Sprintf("Can't load lifetime records: %v; they won't be saved until it is fixed", recordsErr))
+	}
+	m.scanOnStart = scanRange
 	if cancel != nil {
 		m.discoveryCtx = cancel
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	opts := []tea.ProgramOption{tea.WithAltScreen()}
+	if settings.Mouse {
+		opts = append(opts, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(m, opts...)
+	startDiagnostics(p)
 
 	// Start mDNS discovery in a goroutine
-	if !*noDiscovery {
+	if !settings.NoDiscovery {
 		go func() {
 			ch := StartDiscovery(ctx)
 			for dev := range ch {
@@ -66,8 +323,18 @@ Examples:
 		}()
 	}
 
-	if _, err := p.Run(); err != nil {
+	_, err = p.Run()
+	if api != nil {
+		api.Close()
+	}
+	if store != nil {
+		if err := store.Close(); err != nil {
+			logf(levelError, "closing database: %v", err)
+		}
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
-		os.Exit(1)
+		exit(1)
 	}
+	exit(0)
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// configVersion is the config schema version written by this build.
+// Files without a version field are version 1.
+const configVersion = 2
+
+// configMigrations[i] upgrades a config from version i+1 to i+2. Each
+// step works on the raw top-level fields so it doesn't depend on the
+// current Config struct.
+var configMigrations = []func(fields map[string]json.RawMessage) error{
+	migrateDeviceMap,
+}
+
+// migrateConfig upgrades the raw config data to configVersion, one step
+// at a time, and reports whether anything changed. Before the first step
+// the original file is copied to a backup next to it. Files from a newer
+// version are returned unchanged.
+func migrateConfig(data []byte) ([]byte, bool, error) {
+	out, version, err := upgradeConfig(data)
+	if err != nil || version >= configVersion {
+		return out, false, err
+	}
+	logf(levelInfo, "upgrading config from version %d to %d", version, configVersion)
+	backup := fmt.Sprintf("%s.v%d.bak", configPath(), version)
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return nil, false, fmt.Errorf("backing up config: %w", err)
+	}
+	return out, true, nil
+}
+
+// upgradeConfig is migrateConfig without the backup: it returns data
+// upgraded to configVersion and the version it started from.
+func upgradeConfig(data []byte) ([]byte, int, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, 0, err
+	}
+
+	version := 1
+	if raw, ok := fields["version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, 0, fmt.Errorf("config version: %w", err)
+		}
+	}
+	if version < 1 {
+		return nil, 0, fmt.Errorf("config version %d is not valid", version)
+	}
+	if version >= configVersion {
+		return data, version, nil
+	}
+
+	for v := version; v < configVersion; v++ {
+		if err := configMigrations[v-1](fields); err != nil {
+			return nil, 0, fmt.Errorf("migrating config to version %d: %w", v+1, err)
+		}
+	}
+	fields["version"], _ = json.Marshal(configVersion)
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, version, nil
+}
+
+// migrateDeviceMap (1 → 2) converts the original {"ip": "name"} device
+// map into the device list. Its entries only carry a name.
+func migrateDeviceMap(fields map[string]json.RawMessage) error {
+	raw, ok := fields["devices"]
+	if !ok {
+		return nil
+	}
+	var names map[string]string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		// already a list
+		return nil
+	}
+
+	ips := make([]string, 0, len(names))
+	for ip := range names {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	list := make(DeviceList, 0, len(ips))
+	for _, ip := range ips {
+		list = append(list, DeviceEntry{IP: ip, Name: names[ip], Source: entryNameOnly})
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	fields["devices"] = data
+	return nil
+}
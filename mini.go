@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// miniHeightThreshold is the terminal height below which the mini list
+// view is used automatically.
+const miniHeightThreshold = 14
+
+// useMini reports whether to render the borderless mini list view.
+func (m model) useMini() bool {
+	return m.mini || m.height < miniHeightThreshold
+}
+
+// miniSeverity orders devices worst first for the mini view: unreachable
+// devices, then by ascending score, then devices still connecting.
+func miniSeverity(dev *Device) int {
+	switch {
+	case dev.LastError != nil:
+		return -1
+	case dev.Data == nil:
+		return 1000
+	default:
+		return dev.Data.Score
+	}
+}
+
+// renderMini renders one line per device plus a status line, without
+// borders or bars. When there are more devices than rows, the worst ones
+// are shown.
+func (m model) renderMini() string {
+	devs := m.orderedDevices()
+	rows := m.height - 1
+	if rows < 0 {
+		rows = 0
+	}
+	if len(devs) > rows {
+		sort.SliceStable(devs, func(i, j int) bool {
+			return miniSeverity(devs[i]) < miniSeverity(devs[j])
+		})
+		devs = devs[:rows]
+	}
+
+	nameWidth := 0
+	for _, d := range devs {
+		if w := lipgloss.Width(d.Title()); w > nameWidth {
+			nameWidth = w
+		}
+	}
+	if nameWidth > 16 {
+		nameWidth = 16
+	}
+
+	var lines []string
+	for _, dev := range devs {
+		lines = append(lines, m.renderMiniLine(dev, nameWidth))
+	}
+	if len(lines) == 0 && rows > 0 {
+		lines = append(lines, lipgloss.NewStyle().Foreground(theme.Muted).Render("No Awair devices found"))
+	}
+	for len(lines) < rows {
+		lines = append(lines, "")
+	}
+
+	return strings.Join(append(lines, m.renderMiniStatus(len(devs))), "\n")
+}
+
+func (m model) renderMiniLine(dev *Device, nameWidth int) string {
+	name := truncateWidth(dev.Title(), nameWidth)
+	name = lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(visPadRight(name, nameWidth))
+
+	var parts []string
+	switch {
+	case dev.LastError != nil && dev.Data == nil:
+		parts = append(parts, lipgloss.NewStyle().Foreground(theme.Poor).Render("error: "+errorSummary(dev.LastError)))
+	case dev.Data == nil:
+		parts = append(parts, lipgloss.NewStyle().Foreground(theme.Fair).Render("connecting..."))
+	default:
+		d := dev.Data
+		shown := m.shownScore(dev)
+		parts = append(parts, lipgloss.NewStyle().Bold(true).Foreground(scoreColor(shown)).
+			Render(fmt.Sprintf("%d %s", shown, scoreLabel(shown))))
+
+		sensor := func(key, label string, value float64) {
+			if !d.Reported(key) {
+				return
+			}
+			rating := awair.RateSensorValue(key, awair.DisplayValue(key, value))
+			val := awair.FormatValue(key, value, m.fahrenheit)
+			if r := awair.OptimalRanges[key]; key != "temp" && key != "humid" {
+				val = strings.TrimSuffix(val, " "+r.Unit)
+			}
+			if label != "" {
+				val = label + " " + val
+			}
+			parts = append(parts, lipgloss.NewStyle().Foreground(ratingColor(rating)).Render(ratingMark(rating)+val))
+		}
+		sensor("co2", "CO₂", d.CO2)
+		sensor("pm25", "PM2.5", d.PM25)
+		sensor("temp", "", d.Temp)
+		sensor("humid", "", d.Humid)
+	}
+
+	line := name + "  " + strings.Join(parts, "  ")
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(line)
+}
+
+// renderMiniStatus renders the single combined status line of the mini view.
+func (m model) renderMiniStatus(shown int) string {
+	status := fmt.Sprintf(" %d device(s)", len(m.devices))
+	if shown < len(m.devices) {
+		status += fmt.Sprintf(", %d worst shown", shown)
+	}
+	if m.paused {
+		status += "  PAUSED"
+	}
+	if n := len(m.logs); n > 0 {
+		status += "  · " + m.logs[n-1].Message
+	}
+	return lipgloss.NewStyle().
+		Width(m.width).
+		MaxWidth(m.width).
+		Background(theme.BarBG).
+		Foreground(theme.BarFG).
+		Render(status)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// doubleClickTime is the most time between two clicks on a device that
+// opens its details.
+const doubleClickTime = 400 * time.Millisecond
+
+// headerHeight is the height of renderHeader: the title line and the
+// blank line under it.
+const headerHeight = 2
+
+// lastClick is the device last clicked, for detecting double clicks.
+type lastClick struct {
+	index int // in orderedDevices
+	at    time.Time
+}
+
+// keyPress is the key message for a key as handleKey names it.
+func keyPress(key string) tea.KeyMsg {
+	switch key {
+	case "enter":
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		return tea.KeyMsg{Type: tea.KeyEscape}
+	case " ":
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(key)}
+	case "pgdown":
+		return tea.KeyMsg{Type: tea.KeyPgDown}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// handleMouse handles clicks and the wheel (unless --no-mouse): a click
+// on a device selects it and a double click opens it, a click on a
+// status bar hint presses its key, and the wheel scrolls the log panel
+// and the scrollable views.
+func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if msg.Action != tea.MouseActionPress {
+		return m, nil
+	}
+	wheel := 0
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		wheel = -1
+	case tea.MouseButtonWheelDown:
+		wheel = 1
+	case tea.MouseButtonLeft:
+	default:
+		return m, nil
+	}
+
+	switch {
+	case m.showLogs:
+		var cmd tea.Cmd
+		m.logView, cmd = m.logView.Update(msg)
+		m.logFollow = m.logView.AtBottom()
+		return m, cmd
+	case m.showHelp || m.showSummary:
+		if wheel < 0 {
+			return m.handleKey(keyPress("up"))
+		} else if wheel > 0 {
+			return m.handleKey(keyPress("down"))
+		}
+		return m, nil
+	case m.confirm != nil || m.showPrompt || m.showPicker || m.whatsNew != nil:
+		// Dialogs are answered with the keyboard
+		return m, nil
+	case m.useMini() && m.detailID == 0 && m.zoomID == 0,
+		m.width < minLayoutWidth || m.height < minLayoutHeight:
+		return m, nil
+	}
+
+	// The grid can come out shorter than gridHeight, which moves the log
+	// panel and status bar up; measure where they ended up
+	statusY := lipgloss.Height(m.View()) - 1
+	logTop := statusY - logPanelHeight
+	switch {
+	case msg.Y == statusY:
+		if key := m.statusHintAt(msg.X); wheel == 0 && key != "" {
+			return m.handleKey(keyPress(key))
+		}
+	case msg.Y >= logTop:
+		m.scrollLogPanel(-wheel)
+	case msg.Y >= headerHeight && wheel == 0 && m.detailID == 0 && m.zoomID == 0:
+		if m.clickDevice(msg.X, msg.Y-headerHeight) {
+			return m.handleKey(keyPress("enter"))
+		}
+	}
+	return m, nil
+}
+
+// clickDevice selects the device at x, y within the grid or table, and
+// reports whether this was the second click on it within
+// doubleClickTime.
+func (m *model) clickDevice(x, y int) bool {
+	idx := -1
+	if m.viewMode == viewTable {
+		// Below the table's column titles
+		perPage, _ := m.gridPaging()
+		first := (m.selected / perPage) * perPage
+		if row := y - 1; row >= 0 && row < perPage && first+row < len(m.deviceOrder) {
+			idx = first + row
+		}
+	} else {
+		idx = m.gridLayout(m.gridHeight()).cardAt(x, y)
+	}
+	if idx < 0 {
+		m.click = lastClick{}
+		return false
+	}
+	m.selected = idx
+	double := !m.click.at.IsZero() && m.click.index == idx && time.Since(m.click.at) < doubleClickTime
+	if double {
+		// A third click starts over
+		m.click = lastClick{}
+	} else {
+		m.click = lastClick{index: idx, at: time.Now()}
+	}
+	return double
+}
+
+// statusHintAt returns the key of the status bar hint at column x, or ""
+// if there is none there.
+func (m model) statusHintAt(x int) string {
+	bar := ansi.Strip(m.renderStatusBar())
+	i := strings.Index(bar, m.discoverySegment())
+	if i < 0 {
+		return ""
+	}
+	col := ansi.StringWidth(bar[:i]) + ansi.StringWidth(m.discoverySegment())
+	for _, h := range m.statusHints() {
+		w := ansi.StringWidth(h.text)
+		if x >= col && x < col+w {
+			return h.key
+		}
+		col += w + 2
+	}
+	return ""
+}
+
+// scrollLogPanel scrolls the log panel by delta entries, back in time
+// for positive delta. Scrolled to the newest entry it follows new ones.
+func (m *model) scrollLogPanel(delta int) {
+	m.logPanelScroll = min(max(m.logPanelScroll+delta, 0), max(len(m.logs)-logPanelLines, 0))
+}
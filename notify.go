@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// defaultNotifyCooldown is how long a sensor that went poor stays quiet
+// before it can notify again without first returning to good.
+const defaultNotifyCooldown = 15 * time.Minute
+
+// notifyKey identifies one sensor on one device.
+type notifyKey struct {
+	id     deviceID
+	sensor string
+}
+
+// notifyState is what was notified for one sensor: when it last turned
+// poor, and whether it still is as far as notifications go.
+type notifyState struct {
+	fired time.Time
+	poor  bool
+}
+
+// notifier turns alert snapshots into desktop notifications (--notify),
+// webhook posts (--alert-webhook) and commands (--alert-exec). A sensor
+// notifies when it turns poor and once more when it is no longer poor.
+// It only notifies as poor again after returning to good, or once the
+// cooldown has passed, so a value hovering around the poor/fair boundary
+// doesn't notify on every crossing.
+type notifier struct {
+	desktop  bool           // show desktop notifications
+	recovery bool           // also notify the desktop when a sensor is no longer poor
+	webhook  string         // URL to post turning poor and recovering to, or ""
+	exec     string         // shell command to run for the same events, or ""
+	tmpl     alertTemplates // what the webhook posts and the command gets
+	cooldown time.Duration  // between repeats while not back to good
+	sent     map[notifyKey]notifyState
+}
+
+// newNotifier returns the notifier for s, or nil if no kind of
+// notification is enabled.
+func newNotifier(s Settings) *notifier {
+	if !s.Notify && s.AlertWebhook == "" && s.AlertExec == "" {
+		return nil
+	}
+	// The templates were checked at startup
+	tmpl, _ := parseAlertTemplates(s)
+	return &notifier{
+		desktop:  s.Notify,
+		recovery: s.NotifyRecovery,
+		webhook:  s.AlertWebhook,
+		exec:     s.AlertExec,
+		tmpl:     tmpl,
+		cooldown: s.NotifyCooldown,
+		sent:     make(map[notifyKey]notifyState),
+	}
+}
+
+// dispatch returns the webhook and command deliveries for ev.
+func (n *notifier) dispatch(ev alertEvent) []tea.Cmd {
+	var cmds []tea.Cmd
+	if n.webhook != "" {
+		cmds = append(cmds, webhookCmd(n.webhook, n.tmpl, ev, 1))
+	}
+	if n.exec != "" {
+		cmds = append(cmds, alertExecCmd(n.exec, n.tmpl, ev))
+	}
+	return cmds
+}
+
+// update compares dev's new alerts with what was notified before and
+// returns the notifications to send. prev is the snapshot alerts replace.
+// A sensor that turns poor within the cooldown of its last notification
+// without having been good in between stays quiet, and so does its
+// recovery. The score is not a sensor and never notifies.
+func (n *notifier) update(dev *Device, prev, alerts AlertSnapshot, now time.Time) []tea.Cmd {
+	var cmds []tea.Cmd
+	for _, a := range alerts.Sorted() {
+		if a.Key == scoreAlertKey || a.Severity != alertCritical {
+			continue
+		}
+		k := notifyKey{dev.ID, a.Key}
+		if st, ok := n.sent[k]; ok && now.Sub(st.fired) < n.cooldown {
+			continue
+		}
+		n.sent[k] = notifyState{fired: now, poor: true}
+		if n.desktop {
+			cmds = append(cmds, notifyCmd(
+				fmt.Sprintf("%s: %s is poor", dev.Name, a.Label()),
+				fmt.Sprintf("%s %s %s", dev.Name, a.Label(), readingText(dev, a.Key))))
+		}
+		cmds = append(cmds, n.dispatch(newAlertEvent(dev, a.Key, prev, alerts, now))...)
+	}
+
+	for k, st := range n.sent {
+		if k.id != dev.ID || alerts[k.sensor].Severity == alertCritical {
+			continue
+		}
+		if alerts.Rating(k.sensor) == "good" || now.Sub(st.fired) >= n.cooldown {
+			delete(n.sent, k)
+		} else {
+			n.sent[k] = notifyState{fired: st.fired}
+		}
+		if !st.poor {
+			continue
+		}
+		if n.desktop && n.recovery {
+			label := awair.OptimalRanges[k.sensor].Label
+			cmds = append(cmds, notifyCmd(
+				fmt.Sprintf("%s: %s back to %s", dev.Name, label, alerts.Rating(k.sensor)),
+				fmt.Sprintf("%s %s %s", dev.Name, label, readingText(dev, k.sensor))))
+		}
+		cmds = append(cmds, n.dispatch(newAlertEvent(dev, k.sensor, prev, alerts, now))...)
+	}
+	return cmds
+}
+
+// forget drops what was notified for the device with id, once it is
+// removed.
+func (n *notifier) forget(id deviceID) {
+	for k := range n.sent {
+		if k.id == id {
+			delete(n.sent, k)
+		}
+	}
+}
+
+// readingValue returns dev's current value for sensor key, as reported by
+// the device or derived from it. ok is false if the latest reading doesn't have it.
+func readingValue(dev *Device, key string) (value float64, ok bool) {
+	if dev.Data == nil {
+		return 0, false
+	}
+	for _, s := range dev.Data.Readings() {
+		if s.Key == key {
+			return s.Value, true
+		}
+	}
+	return 0, false
+}
+
+// readingText formats dev's current value for sensor key, or "" if the
+// latest reading doesn't have it.
+func readingText(dev *Device, key string) string {
+	v, ok := readingValue(dev, key)
+	if !ok {
+		return ""
+	}
+	return awair.FormatValue(key, v, false)
+}
+
+// alertEvent is a sensor turning poor or no longer poor, as posted to
+// --alert-webhook and passed to --alert-exec, and what alert templates
+// are executed with. Value is as the device reports it, so temperatures
+// are in °C, and so is Formatted.
+type alertEvent struct {
+	Event          string   `json:"event"` // fired when turning poor, cleared when no longer
+	IP             string   `json:"ip"`
+	Name           string   `json:"name"`
+	UUID           string   `json:"uuid,omitempty"`
+	Sensor         string   `json:"sensor"`
+	Label          string   `json:"label"`
+	Value          *float64 `json:"value"`
+	Formatted      string   `json:"formatted,omitempty"` // value with its unit, e.g. "1240 ppm"
+	Rating         string   `json:"rating"`
+	PreviousRating string   `json:"previous_rating"`
+	Severity       string   `json:"severity"` // critical, warning or none
+	Time           string   `json:"timestamp"`
+}
+
+func newAlertEvent(dev *Device, key string, prev, alerts AlertSnapshot, now time.Time) alertEvent {
+	ev := alertEvent{
+		Event:          alertCleared,
+		IP:             dev.IP,
+		Name:           dev.Name,
+		UUID:           dev.UUID,
+		Sensor:         key,
+		Label:          awair.OptimalRanges[key].Label,
+		Formatted:      readingText(dev, key),
+		Rating:         alerts.Rating(key),
+		PreviousRating: prev.Rating(key),
+		Severity:       alerts[key].Severity.String(),
+		Time:           now.UTC().Format(time.RFC3339),
+	}
+	if alerts[key].Severity == alertCritical {
+		ev.Event = alertFired
+	}
+	if v, ok := readingValue(dev, key); ok {
+		ev.Value = &v
+	}
+	return ev
+}
+
+// errNoNotifier means no desktop notification tool is available.
+var errNoNotifier = errors.New("no desktop notifier available")
+
+// windowsToastScript shows $env:AWAIR_TOAST_TITLE and _BODY as a toast
+// through WinRT. Toasts need a registered app ID, so PowerShell's is used.
+const windowsToastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:AWAIR_TOAST_TITLE)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:AWAIR_TOAST_BODY)) | Out-Null
+$app = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($app).Show([Windows.UI.Notifications.ToastNotification]::new($xml))
+`
+
+// sendNotification shows a desktop notification; see notificationCommand.
+func sendNotification(title, body string) error {
+	cmd, err := notificationCommand(title, body)
+	if err != nil {
+		return err
+	}
+	return cmd.Run()
+}
+
+// notificationCommand returns the command that shows a desktop
+// notification: notify-send on Linux and the BSDs, osascript on macOS, or
+// a PowerShell toast on Windows.
+func notificationCommand(title, body string) (*exec.Cmd, error) {
+	switch goos {
+	case "darwin":
+		quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		script := fmt.Sprintf(`display notification "%s" with title "%s"`, quote.Replace(body), quote.Replace(title))
+		return exec.Command("osascript", "-e", script), nil
+	case "windows":
+		path, err := exec.LookPath("powershell")
+		if err != nil {
+			return nil, errNoNotifier
+		}
+		cmd := exec.Command(path, "-NoProfile", "-NonInteractive", "-Command", windowsToastScript)
+		// Passed through the environment to avoid quoting them in the script
+		cmd.Env = append(os.Environ(), "AWAIR_TOAST_TITLE="+title, "AWAIR_TOAST_BODY="+body)
+		return cmd, nil
+	default:
+		path, err := exec.LookPath("notify-send")
+		if err != nil {
+			return nil, errNoNotifier
+		}
+		return exec.Command(path, "--app-name=awair-tui", title, body), nil
+	}
+}
+
+// notifyCmd sends a notification in the background, ringing the terminal
+// bell instead if that fails.
+func notifyCmd(title, body string) tea.Cmd {
+	return func() tea.Msg {
+		logf(levelInfo, "notify: %s", body)
+		if err := sendNotification(title, body); err != nil {
+			logf(levelDebug, "notify: %v; ringing the bell instead", err)
+			ringBell()
+		}
+		return nil
+	}
+}
+
+// ringBell rings the terminal bell.
+func ringBell() {
+	os.Stdout.WriteString("\a")
+}
+
+// bellCmd rings the terminal bell in the background.
+func bellCmd() tea.Msg {
+	ringBell()
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// testNotifier posts to a webhook that accepts everything, and notifies
+// nothing else.
+func testNotifier(t *testing.T) *notifier {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return &notifier{webhook: srv.URL, cooldown: time.Hour, sent: make(map[notifyKey]notifyState)}
+}
+
+// delivered runs the deliveries in cmds and returns the events posted.
+func delivered(t *testing.T, cmds []tea.Cmd) []alertEvent {
+	t.Helper()
+	var events []alertEvent
+	for _, cmd := range cmds {
+		msg, ok := cmd().(webhookResultMsg)
+		if !ok {
+			t.Fatalf("not a webhook delivery: %#v", msg)
+		}
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		events = append(events, msg.Event)
+	}
+	return events
+}
+
+// notifyStep feeds dev a reading with the given CO₂ and returns what
+// the notifier posted.
+func notifyStep(t *testing.T, n *notifier, dev *Device, co2 float64, now time.Time) []alertEvent {
+	t.Helper()
+	prev := dev.Alerts
+	dev.Data = &awair.SensorData{Score: 80, Temp: 21, Humid: 45, CO2: co2, PM25: 3}
+	dev.Alerts = evaluateAlerts(defaultAlertRules(), dev.Data)
+	return delivered(t, n.update(dev, prev, dev.Alerts, now))
+}
+
+func TestNotifierRecovery(t *testing.T) {
+	n := testNotifier(t)
+	dev := &Device{ID: 3, IP: "192.0.2.5", Name: "Office", UUID: "awair-element_9"}
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		co2    float64
+		after  time.Duration
+		event  string // "" for nothing posted
+		rating string
+	}{
+		{500, 0, "", ""},
+		{1500, time.Minute, alertFired, "poor"},
+		{1600, time.Minute, "", ""}, // still poor, within the cooldown
+		{900, time.Minute, alertCleared, "fair"},
+		{1000, time.Minute, "", ""}, // fair isn't poor
+		{1500, time.Minute, "", ""}, // poor again without being good, within the cooldown
+		{900, time.Minute, "", ""},  // and its recovery
+		{500, time.Minute, "", ""},  // good again
+		{1500, time.Minute, alertFired, "poor"},
+		{500, time.Minute, alertCleared, "good"},
+	}
+	for i, st := range steps {
+		now = now.Add(st.after)
+		events := notifyStep(t, n, dev, st.co2, now)
+		if st.event == "" {
+			if len(events) != 0 {
+				t.Errorf("step %d (%v ppm): posted %+v", i, st.co2, events)
+			}
+			continue
+		}
+		if len(events) != 1 {
+			t.Fatalf("step %d (%v ppm): posted %d events, want 1", i, st.co2, len(events))
+		}
+		ev := events[0]
+		if ev.Event != st.event || ev.Rating != st.rating || ev.Sensor != "co2" {
+			t.Errorf("step %d (%v ppm): %s %s %s, want %s %s", i, st.co2, ev.Event, ev.Sensor, ev.Rating, st.event, st.rating)
+		}
+		if ev.UUID != "awair-element_9" {
+			t.Errorf("step %d: uuid %q", i, ev.UUID)
+		}
+	}
+}
+
+func TestNotifierCooldown(t *testing.T) {
+	n := testNotifier(t)
+	n.cooldown = 10 * time.Minute
+	dev := &Device{ID: 1, IP: "192.0.2.6", Name: "Lab"}
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got := notifyStep(t, n, dev, 1500, now); len(got) != 1 {
+		t.Fatalf("posted %d events", len(got))
+	}
+	if got := notifyStep(t, n, dev, 1500, now.Add(9*time.Minute)); len(got) != 0 {
+		t.Errorf("posted %d events within the cooldown", len(got))
+	}
+	if got := notifyStep(t, n, dev, 1500, now.Add(10*time.Minute)); len(got) != 1 || got[0].Event != alertFired {
+		t.Errorf("posted %+v after the cooldown", got)
+	}
+}
+
+func TestNotifierHoverPastCooldown(t *testing.T) {
+	n := testNotifier(t)
+	n.cooldown = 10 * time.Minute
+	dev := &Device{ID: 1, IP: "192.0.2.6", Name: "Lab"}
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	// Hovering between fair and poor notifies once per cooldown
+	for i, want := range []string{alertFired, alertCleared, "", "", "", "", alertFired} {
+		co2 := 1500.0
+		if i%2 == 1 {
+			co2 = 1100
+		}
+		got := notifyStep(t, n, dev, co2, now.Add(time.Duration(i)*2*time.Minute))
+		if want == "" && len(got) != 0 || want != "" && (len(got) != 1 || got[0].Event != want) {
+			t.Errorf("minute %d (%v ppm): posted %+v, want %q", i*2, co2, got, want)
+		}
+	}
+}
+
+func TestNotifierForget(t *testing.T) {
+	n := testNotifier(t)
+	now := time.Now()
+	kept := &Device{ID: 1, IP: "192.0.2.1", Name: "Kept"}
+	removed := &Device{ID: 2, IP: "192.0.2.2", Name: "Removed"}
+	notifyStep(t, n, kept, 1500, now)
+	notifyStep(t, n, removed, 1500, now)
+
+	n.forget(removed.ID)
+	for k := range n.sent {
+		if k.id == removed.ID {
+			t.Errorf("%v still sent", k)
+		}
+	}
+	if _, ok := n.sent[notifyKey{kept.ID, "co2"}]; !ok {
+		t.Error("forgot the other device")
+	}
+}
+
+func TestRemoveDeviceForgetsNotifications(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	m.notifier = testNotifier(t)
+	dev := m.devices["192.0.2.2"]
+	notifyStep(t, m.notifier, dev, 1500, time.Now())
+	if len(m.notifier.sent) == 0 {
+		t.Fatal("nothing sent")
+	}
+	m.removeDevice(dev.IP)
+	if len(m.notifier.sent) != 0 {
+		t.Errorf("sent %v after removing the device", m.notifier.sent)
+	}
+}
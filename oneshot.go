@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
+)
+
+// oneShotResult is the result of polling a single device once.
+// Its JSON shape is the stable output of --once --json: the sensor
+// readings sit at the top level next to ip, name and the rest, so that
+// jq '.[].co2' works.
+type oneShotResult struct {
+	IP       string              `json:"ip"`
+	Name     string              `json:"name"`
+	TempUnit string              `json:"temp_unit"`
+	Data     *awair.SensorData   `json:"-"`
+	Config   *awair.DeviceConfig `json:"config"`
+	Error    string              `json:"error"`
+
+	// Time is when the reading was fetched, or with --export-time device
+	// the device's timestamp for it.
+	Time time.Time `json:"time"`
+
+	// LatencyMS is how long the reading took to fetch, in milliseconds;
+	// absent if it failed.
+	LatencyMS float64 `json:"latency_ms,omitempty"`
+}
+
+// MarshalJSON merges the sensor data's fields into the result's. Embedding
+// the data instead would promote its MarshalJSON over the whole result.
+func (r oneShotResult) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(oneShotResultAlias(r))
+	if err != nil || r.Data == nil {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	readings, err := json.Marshal(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	var sensors map[string]json.RawMessage
+	if err := json.Unmarshal(readings, &sensors); err != nil {
+		return nil, err
+	}
+	for key, v := range sensors {
+		if _, ok := fields[key]; !ok {
+			fields[key] = v
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// oneShotResultAlias has oneShotResult's fields without its JSON methods.
+type oneShotResultAlias oneShotResult
+
+// oneShotDiscoveryTimeout bounds how long --once waits for mDNS results
+// when no device IPs are given.
+const oneShotDiscoveryTimeout = 5 * time.Second
+
+// discoverOnce runs a single bounded mDNS discovery pass and returns the
+// devices found. Progress is written to stderr.
+func discoverOnce(timeout time.Duration) []discovery.Device {
+	fmt.Fprintf(os.Stderr, "Discovering Awair devices (%s)...\n", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	var found []discovery.Device
+	for dev := range StartDiscovery(ctx) {
+		if seen[dev.IP] {
+			continue
+		}
+		seen[dev.IP] = true
+		fmt.Fprintf(os.Stderr, "Discovered: %s at %s\n", dev.Name, dev.IP)
+		found = append(found, dev)
+	}
+	return found
+}
+
+// pollOnce fetches sensor data and, if fetchConfig is set, device config
+// from every target concurrently. Results are returned in the same order
+// as targets.
+func pollOnce(cfg *Config, targets []discovery.Device, fetchConfig bool) []oneShotResult {
+	results := make([]oneShotResult, len(targets))
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func(i int, t discovery.Device) {
+			defer wg.Done()
+
+			r := oneShotResult{IP: t.IP, TempUnit: "C"}
+			start := time.Now()
+			data, err := FetchAirData(context.Background(), t.IP)
+			if err != nil {
+				r.Error = err.Error()
+			} else {
+				r.Data = data
+				r.LatencyMS = latencyMS(time.Since(start))
+			}
+			r.Time = time.Now()
+			if fetchConfig {
+				if devCfg, err := FetchDeviceConfig(context.Background(), t.IP); err == nil {
+					r.Config = devCfg
+				}
+			}
+
+			// Same naming priority as the TUI: config > mDNS > UUID > IP
+			var uuid string
+			if r.Config != nil {
+				uuid = r.Config.DeviceUUID
+			}
+			switch {
+			case cfg.Name(uuid, t.IP) != "":
+				r.Name = cfg.Name(uuid, t.IP)
+			case t.Name != "":
+				r.Name = t.Name
+			case r.Config != nil && r.Config.DeviceUUID != "":
+				r.Name = r.Config.DeviceUUID
+			default:
+				r.Name = t.IP
+			}
+			results[i] = r
+		}(i, t)
+	}
+	wg.Wait()
+	return results
+}
+
+// oneShotTargets returns the devices to poll in one-shot modes: the IPs
+// from the command line, or the results of a single discovery pass.
+func oneShotTargets(s Settings) []discovery.Device {
+	var targets []discovery.Device
+	for _, ip := range s.IPs {
+		targets = append(targets, discovery.Device{IP: ip})
+	}
+	if len(targets) == 0 && !s.NoDiscovery {
+		targets = discoverOnce(oneShotDiscoveryTimeout)
+	}
+	return targets
+}
+
+// runOnce polls the given devices once, writes the results to stdout and
+// returns the process exit code. When no IPs are given, a single discovery
+// pass is run first (unless discovery is disabled).
+func runOnce(cfg *Config, s Settings, asJSON, jsonFahrenheit bool) int {
+	targets := oneShotTargets(s)
+	if len(targets) == 0 {
+		fmt.Fprintln(os.Stderr, "No Awair devices found")
+		if asJSON {
+			fmt.Fprintln(os.Stdout, "[]")
+		}
+		return 1
+	}
+
+	results := pollOnce(cfg, targets, s.FetchDeviceConfig)
+
+	if asJSON {
+		for i := range results {
+			if jsonFahrenheit {
+				results[i].convertToFahrenheit()
+			}
+			if results[i].Data != nil {
+				results[i].Time = readingTime(results[i].Data, results[i].Time, s.ExportTime)
+			}
+		}
+		if err := writeOneShotJSON(os.Stdout, results); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	} else {
+		writeOneShotText(os.Stdout, results, s.Fahrenheit)
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// convertToFahrenheit converts the temperature fields of the result's
+// sensor data in place (used by --json-fahrenheit).
+func (r *oneShotResult) convertToFahrenheit() {
+	r.TempUnit = "F"
+	if r.Data == nil {
+		return
+	}
+	d := *r.Data
+	d.Temp = awair.CToF(d.Temp)
+	if d.DewPoint != nil {
+		dp := awair.CToF(*d.DewPoint)
+		d.DewPoint = &dp
+	}
+	r.Data = &d
+}
+
+func writeOneShotJSON(w io.Writer, results []oneShotResult) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+func writeOneShotText(w io.Writer, results []oneShotResult, fahrenheit bool) {
+	for _, r := range results {
+		if r.Data == nil {
+			fmt.Fprintf(w, "%s (%s): error: %s\n", r.Name, r.IP, r.Error)
+			continue
+		}
+		d := r.Data
+		parts := []string{fmt.Sprintf("score %d %s", d.Score, scoreLabel(d.Score))}
+		for _, s := range d.Readings() {
+			switch s.Key {
+			case "temp", "humid", "co2", "voc", "pm25", "lux", "spl_a":
+				parts = append(parts, s.Key+" "+awair.FormatValue(s.Key, s.Value, fahrenheit))
+			}
+		}
+		fmt.Fprintf(w, "%s (%s): %s\n", r.Name, r.IP, strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+func TestOneShotJSON(t *testing.T) {
+	// A Mint: no CO₂ sensor
+	var mint awair.SensorData
+	if err := json.Unmarshal([]byte(`{"timestamp": "2026-06-01T09:00:00.000Z", "score": 80, "temp": 22, "humid": 50, "voc": 100, "pm25": 3, "lux": 120}`), &mint); err != nil {
+		t.Fatal(err)
+	}
+	results := []oneShotResult{
+		{IP: "192.0.2.1", Name: "Office", TempUnit: "C", Data: summarySample(600, 3, 90), LatencyMS: 12.5},
+		{IP: "192.0.2.2", Name: "Bedroom", TempUnit: "C", Data: &mint},
+		{IP: "192.0.2.3", Name: "Hall", TempUnit: "C", Error: "i/o timeout"},
+	}
+	var buf bytes.Buffer
+	if err := writeOneShotJSON(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	var got []map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%s: %v", buf.Bytes(), err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("%d results", len(got))
+	}
+
+	// The readings are at the top level, next to the device's fields
+	if string(got[0]["co2"]) != "600" || string(got[0]["ip"]) != `"192.0.2.1"` || string(got[0]["latency_ms"]) != "12.5" {
+		t.Errorf("office: %s", buf.Bytes())
+	}
+	if _, ok := got[0]["data"]; ok {
+		t.Errorf("readings nested under data: %s", buf.Bytes())
+	}
+	if string(got[1]["co2"]) != "null" || string(got[1]["lux"]) != "120" || string(got[1]["timestamp"]) != `"2026-06-01T09:00:00.000Z"` {
+		t.Errorf("bedroom: %s", buf.Bytes())
+	}
+	// A failed device has no readings at all
+	if _, ok := got[2]["score"]; ok || string(got[2]["error"]) != `"i/o timeout"` {
+		t.Errorf("hall: %s", buf.Bytes())
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
+)
+
+// devicePicker is a checklist of discovered devices that were not added
+// automatically, from which the user can pick the ones to add.
+type devicePicker struct {
+	items    []discovery.Device
+	selected map[string]bool           // IP → checked
+	probes   map[string]probeResultMsg // IP → HTTP check result
+	cursor   int
+}
+
+// probeResultMsg is the result of checking that a listed device answers
+// the local API.
+type probeResultMsg struct {
+	IP    string
+	Score int
+	Err   error
+}
+
+// probeCmd fetches air data from ip once to check that it responds.
+func probeCmd(ctx context.Context, ip string) tea.Cmd {
+	return func() tea.Msg {
+		data, err := FetchAirData(ctx, ip)
+		if err != nil {
+			return probeResultMsg{IP: ip, Err: err}
+		}
+		return probeResultMsg{IP: ip, Score: data.Score}
+	}
+}
+
+// setProbe records the HTTP check result for a listed device.
+func (p *devicePicker) setProbe(r probeResultMsg) {
+	if p.probes == nil {
+		p.probes = make(map[string]probeResultMsg)
+	}
+	p.probes[r.IP] = r
+}
+
+// add appends d unless a device with the same IP is already listed.
+// It reports whether d was new.
+func (p *devicePicker) add(d discovery.Device) bool {
+	for _, it := range p.items {
+		if it.IP == d.IP {
+			return false
+		}
+	}
+	p.items = append(p.items, d)
+	return true
+}
+
+func (p *devicePicker) move(delta int) {
+	if len(p.items) == 0 {
+		p.cursor = 0
+		return
+	}
+	p.cursor += delta
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+	if p.cursor >= len(p.items) {
+		p.cursor = len(p.items) - 1
+	}
+}
+
+func (p *devicePicker) toggle() {
+	if p.cursor >= len(p.items) {
+		return
+	}
+	if p.selected == nil {
+		p.selected = make(map[string]bool)
+	}
+	ip := p.items[p.cursor].IP
+	p.selected[ip] = !p.selected[ip]
+}
+
+// take removes the checked devices from the picker and returns them. If
+// nothing is checked, the device under the cursor is taken instead.
+func (p *devicePicker) take() []discovery.Device {
+	var taken, kept []discovery.Device
+	for i, it := range p.items {
+		if p.selected[it.IP] || (len(p.selected) == 0 && i == p.cursor) {
+			taken = append(taken, it)
+		} else {
+			kept = append(kept, it)
+		}
+	}
+	p.items = kept
+	p.selected = nil
+	for _, it := range taken {
+		delete(p.probes, it.IP)
+	}
+	p.move(0)
+	return taken
+}
+
+// takeAll empties the picker and returns every listed device.
+func (p *devicePicker) takeAll() []discovery.Device {
+	taken := p.items
+	p.items = nil
+	p.selected = nil
+	p.probes = nil
+	p.cursor = 0
+	return taken
+}
+
+// render draws the picker list, scrolled so the cursor stays within
+// maxLines rows.
+func (p *devicePicker) render(width, maxLines int) string {
+	if len(p.items) == 0 {
+		return lipgloss.NewStyle().Foreground(theme.Muted).Render("(nothing to add)")
+	}
+	if maxLines < 1 {
+		maxLines = 1
+	}
+
+	start := 0
+	if p.cursor >= maxLines {
+		start = p.cursor - maxLines + 1
+	}
+	end := start + maxLines
+	if end > len(p.items) {
+		end = len(p.items)
+	}
+
+	var lines []string
+	for i := start; i < end; i++ {
+		it := p.items[i]
+		check := "[ ]"
+		if p.selected[it.IP] {
+			check = "[x]"
+		}
+		line := fmt.Sprintf("%s %s  %s", check, it.Name, it.IP)
+		status, color := "checking…", theme.Muted
+		if r, ok := p.probes[it.IP]; ok {
+			if r.Err != nil {
+				status, color = errorSummary(r.Err), theme.Poor
+			} else {
+				status, color = fmt.Sprintf("responds, score %d", r.Score), theme.Good
+			}
+		}
+		if lipgloss.Width(line) > width {
+			line = truncateWidth(line, width)
+			status = ""
+		} else if lipgloss.Width(line)+2+lipgloss.Width(status) > width {
+			status = ""
+		}
+		style := lipgloss.NewStyle()
+		if i == p.cursor {
+			style = style.Bold(true).Foreground(theme.Accent)
+		}
+		if status != "" {
+			line = style.Render(line) + "  " + lipgloss.NewStyle().Foreground(color).Render(status)
+		} else {
+			line = style.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,280 @@
+// Package awair reads Awair air quality monitors (Element, Omni, Mint
+// and 2nd Edition) over their local API, which is turned on per device
+// in the Awair app, and rates their readings.
+//
+//	c := awair.NewClient("http://192.168.1.5")
+//	data, err := c.AirData(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(awair.FormatValue("co2", data.CO2, false),
+//		awair.RateSensorValue("co2", awair.DisplayValue("co2", data.CO2)))
+//
+// Devices on the network can be found with package discovery.
+package awair
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Device API endpoints.
+const (
+	PathAirData = "/air-data/latest"
+	PathConfig  = "/settings/config/data"
+)
+
+// Air data endpoints under /air-data/: the latest sample, and averages
+// the firmware keeps.
+const (
+	Latest   = "latest"
+	Avg10Sec = "10-sec-avg"
+	Avg5Min  = "5-min-avg"
+	Avg15Min = "15-min-avg"
+)
+
+// DefaultTimeout is how long one request to a device may take when
+// Client.Timeout is zero.
+const DefaultTimeout = 5 * time.Second
+
+// maxResponseSize bounds the response bodies read.
+const maxResponseSize = 1 << 20 // 1 MB
+
+// Client makes requests to one device's local API, or to anything that
+// serves it, such as a reverse proxy or the Awair cloud API. Its methods
+// are safe for concurrent use once it is set up.
+type Client struct {
+	// BaseURL is what the API paths are appended to, such as
+	// "http://192.168.1.5" or "https://proxy.example.com/awair".
+	BaseURL string
+
+	// HTTP sends the requests; nil means http.DefaultClient. Devices cope
+	// best with their connection kept open from one request to the next.
+	HTTP *http.Client
+
+	// Timeout bounds each attempt at a request; zero means
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// Retries is how many more times a GET is tried after a transient
+	// failure (see Transient), a fraction of a second apart. Writes are
+	// never retried.
+	Retries int
+
+	// User, if set, is sent as basic auth credentials, for a device
+	// behind a proxy that asks for them.
+	User *url.Userinfo
+
+	// Token, if set, is sent as a bearer token, as the cloud API expects.
+	Token string
+}
+
+// NewClient returns a client for the API at baseURL, with the default
+// timeout and no retries.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// AirData returns the device's latest sample.
+func (c *Client) AirData(ctx context.Context) (*SensorData, error) {
+	var data SensorData
+	if err := c.Get(ctx, PathAirData, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// AirDataAt returns the device's reading at the given /air-data/
+// endpoint, such as Avg5Min. Firmware answers averages as one reading or
+// as a list; from a list the newest is returned, or ErrNoAverage if it
+// is empty.
+func (c *Client) AirDataAt(ctx context.Context, endpoint string) (*SensorData, error) {
+	if endpoint == Latest {
+		return c.AirData(ctx)
+	}
+	path := "/air-data/" + endpoint
+	var raw json.RawMessage
+	if err := c.Get(ctx, path, &raw); err != nil {
+		return nil, err
+	}
+	data, err := newestReading(raw)
+	if err != nil {
+		return nil, &FetchError{URL: c.BaseURL + path, Path: path, Err: err}
+	}
+	return data, nil
+}
+
+// newestReading decodes a reading, or the newest of a list of them.
+func newestReading(raw json.RawMessage) (*SensorData, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var data SensorData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, err
+		}
+		return &data, nil
+	}
+	var list []SensorData
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrNoAverage
+	}
+	newest := 0
+	for i := range list {
+		if list[i].Time().After(list[newest].Time()) {
+			newest = i
+		}
+	}
+	return &list[newest], nil
+}
+
+// Averages returns all the averages the device keeps at the given
+// /air-data/ endpoint, such as Avg15Min, newest or oldest first
+// depending on firmware.
+func (c *Client) Averages(ctx context.Context, endpoint string) ([]SensorData, error) {
+	var averages []SensorData
+	if err := c.Get(ctx, "/air-data/"+endpoint, &averages); err != nil {
+		return nil, err
+	}
+	return averages, nil
+}
+
+// Config returns the device's configuration.
+func (c *Client) Config(ctx context.Context) (*DeviceConfig, error) {
+	var cfg DeviceConfig
+	if err := c.Get(ctx, PathConfig, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Get GETs path and decodes the JSON body into v. Transient failures are
+// retried up to Retries times after a short jittered delay, each attempt
+// with its own Timeout. Cancelling ctx aborts the request and any
+// retries. Failures are returned as a *FetchError for the last attempt.
+func (c *Client) Get(ctx context.Context, path string, v any) error {
+	attempts := c.Retries + 1
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			// 250ms, 500ms, 750ms... plus up to 250ms, so devices that
+			// failed together don't retry together
+			delay := time.Duration(attempt-1)*250*time.Millisecond + rand.N(250*time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+		}
+		err = c.getOnce(ctx, path, v)
+		if err == nil {
+			return nil
+		}
+		var fe *FetchError
+		if errors.As(err, &fe) {
+			fe.Attempt, fe.Attempts = attempt, attempts
+		}
+		if ctx.Err() != nil || !Transient(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// getOnce is one attempt of Get.
+func (c *Client) getOnce(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	start := time.Now()
+	fail := func(err error) error {
+		return &FetchError{URL: c.BaseURL + path, Path: path, Elapsed: time.Since(start), Err: err}
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return fail(err)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fail(&StatusError{Code: resp.StatusCode, Status: resp.Status})
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Put sends v as JSON to path with a PUT, as the device's settings
+// endpoints take changes. It isn't retried: the device may have acted on
+// a request whose answer was lost.
+func (c *Client) Put(ctx context.Context, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout())
+	defer cancel()
+	start := time.Now()
+	fail := func(err error) error {
+		return &FetchError{Method: http.MethodPut, URL: c.BaseURL + path, Path: path, Elapsed: time.Since(start), Err: err}
+	}
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fail(err)
+	}
+	req, err := c.newRequest(ctx, http.MethodPut, path, bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail(&StatusError{Code: resp.StatusCode, Status: resp.Status})
+	}
+	return nil
+}
+
+// newRequest builds a request to path, with the client's credentials.
+func (c *Client) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if c.User != nil {
+		pass, _ := c.User.Password()
+		req.SetBasicAuth(c.User.Username(), pass)
+	}
+	return req, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+	return c.HTTP
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return DefaultTimeout
+	}
+	return c.Timeout
+}
@@ -0,0 +1,441 @@
+package awair
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// serve starts a test server answering with handler and returns a client
+// for it.
+func serve(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(srv.URL + "/")
+}
+
+// failFirst answers the first n requests with status and then body.
+func failFirst(n int32, status int, body string, hits *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) <= n {
+			w.WriteHeader(status)
+			return
+		}
+		io.WriteString(w, body)
+	}
+}
+
+func TestNewClientTrimsSlash(t *testing.T) {
+	if c := NewClient("http://192.168.1.5//"); c.BaseURL != "http://192.168.1.5" {
+		t.Errorf("BaseURL = %q", c.BaseURL)
+	}
+}
+
+func TestGetRetriesTransientFailures(t *testing.T) {
+	var hits atomic.Int32
+	c := serve(t, failFirst(2, http.StatusServiceUnavailable, `{"score": 90}`, &hits))
+	c.Retries = 2
+
+	data, err := c.AirData(context.Background())
+	if err != nil {
+		t.Fatalf("AirData: %v", err)
+	}
+	if data.Score != 90 {
+		t.Errorf("Score = %d, want 90", data.Score)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("%d requests, want 3", got)
+	}
+}
+
+func TestGetGivesUpAfterRetries(t *testing.T) {
+	var hits atomic.Int32
+	c := serve(t, failFirst(100, http.StatusInternalServerError, "", &hits))
+	c.Retries = 1
+
+	_, err := c.AirData(context.Background())
+	var fe *FetchError
+	if !errors.As(err, &fe) {
+		t.Fatalf("err = %v, want a *FetchError", err)
+	}
+	if fe.Attempt != 2 || fe.Attempts != 2 {
+		t.Errorf("attempt %d/%d, want 2/2", fe.Attempt, fe.Attempts)
+	}
+	if fe.Path != PathAirData {
+		t.Errorf("Path = %q, want %q", fe.Path, PathAirData)
+	}
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusInternalServerError {
+		t.Errorf("err = %v, want a 500 *StatusError", err)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("%d requests, want 2", got)
+	}
+}
+
+func TestGetDoesNotRetryPermanentFailures(t *testing.T) {
+	var hits atomic.Int32
+	c := serve(t, failFirst(100, http.StatusNotFound, "", &hits))
+	c.Retries = 3
+
+	if _, err := c.Config(context.Background()); err == nil {
+		t.Fatal("Config succeeded on a 404")
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestGetBadJSON(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"score": `)
+	})
+	_, err := c.AirData(context.Background())
+	var fe *FetchError
+	if !errors.As(err, &fe) {
+		t.Fatalf("err = %v, want a *FetchError", err)
+	}
+	if Transient(err) {
+		t.Error("a bad body counts as transient")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&StatusError{Code: 500, Status: "500 Internal Server Error"}, true},
+		{&StatusError{Code: 503, Status: "503 Service Unavailable"}, true},
+		{&StatusError{Code: 404, Status: "404 Not Found"}, false},
+		{&StatusError{Code: 401, Status: "401 Unauthorized"}, false},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{syscall.ECONNREFUSED, true},
+		{syscall.ECONNRESET, true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{&FetchError{Path: PathAirData, Err: &StatusError{Code: 502}}, true},
+		{&FetchError{Path: PathAirData, Err: &url.Error{Op: "Get", Err: context.DeadlineExceeded}}, true},
+		{&FetchError{Path: PathAirData, Err: ErrNoAverage}, false},
+		{errors.New("no such host"), false},
+	}
+	for _, tt := range tests {
+		if got := Transient(tt.err); got != tt.want {
+			t.Errorf("Transient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFetchErrorMessage(t *testing.T) {
+	err := &FetchError{Method: http.MethodPut, URL: "http://10.0.0.5/settings/config/data",
+		Err: &StatusError{Code: 400, Status: "400 Bad Request"}, Attempt: 1, Attempts: 1}
+	want := "PUT 10.0.0.5/settings/config/data after 0s: HTTP 400 Bad Request"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	err = &FetchError{URL: "http://10.0.0.5/air-data/latest", Err: ErrNoAverage, Attempt: 2, Attempts: 3}
+	want = "GET 10.0.0.5/air-data/latest after 0s: attempt 2/3: no averages yet"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestAirDataAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  string
+		body      string
+		wantPath  string
+		wantScore int
+		wantErr   error
+	}{
+		{"latest", Latest, `{"score": 80}`, "/air-data/latest", 80, nil},
+		{"single average", Avg5Min, `{"timestamp": "2024-01-12T10:00:00Z", "score": 81}`, "/air-data/5-min-avg", 81, nil},
+		{"newest of a list", Avg15Min, `[
+			{"timestamp": "2024-01-12T09:45:00Z", "score": 70},
+			{"timestamp": "2024-01-12T10:15:00Z", "score": 72},
+			{"timestamp": "2024-01-12T10:00:00Z", "score": 71}
+		]`, "/air-data/15-min-avg", 72, nil},
+		{"empty list", Avg10Sec, ` [] `, "/air-data/10-sec-avg", 0, ErrNoAverage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				io.WriteString(w, tt.body)
+			})
+			data, err := c.AirDataAt(context.Background(), tt.endpoint)
+			if path != tt.wantPath {
+				t.Errorf("requested %q, want %q", path, tt.wantPath)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				var fe *FetchError
+				if !errors.As(err, &fe) || fe.Path != tt.wantPath {
+					t.Errorf("err = %#v, want a *FetchError for %s", err, tt.wantPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AirDataAt: %v", err)
+			}
+			if data.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d", data.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestAverages(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `[{"score": 1}, {"score": 2}]`)
+	})
+	averages, err := c.Averages(context.Background(), Avg15Min)
+	if err != nil {
+		t.Fatalf("Averages: %v", err)
+	}
+	if len(averages) != 2 || averages[0].Score != 1 || averages[1].Score != 2 {
+		t.Errorf("Averages = %+v", averages)
+	}
+}
+
+func TestPut(t *testing.T) {
+	var method, contentType string
+	var body map[string]any
+	c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		method, contentType = r.Method, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+	if err := c.Put(context.Background(), "/settings/config/data", map[string]string{"display": "clock"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", method)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if body["display"] != "clock" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestPutIsNotRetried(t *testing.T) {
+	var hits atomic.Int32
+	c := serve(t, failFirst(100, http.StatusServiceUnavailable, "", &hits))
+	c.Retries = 3
+
+	err := c.Put(context.Background(), "/settings/led", map[string]any{"mode": "sleep"})
+	var fe *FetchError
+	if !errors.As(err, &fe) || fe.Method != http.MethodPut {
+		t.Fatalf("err = %v, want a PUT *FetchError", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	var auth string
+	c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		io.WriteString(w, `{}`)
+	})
+
+	c.User = url.UserPassword("me", "secret")
+	c.AirData(context.Background())
+	if auth != "Basic bWU6c2VjcmV0" {
+		t.Errorf("Authorization = %q, want basic auth", auth)
+	}
+
+	// A token wins over the user
+	c.Token = "t0k"
+	c.AirData(context.Background())
+	if auth != "Bearer t0k" {
+		t.Errorf("Authorization = %q, want the bearer token", auth)
+	}
+}
+
+func TestAirDataDecodes(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"timestamp": "2024-01-12T10:00:00.000Z", "score": 86, "dew_point": 10.6,
+			"temp": 23.6, "humid": 55.5, "co2": 988, "voc": 276, "pm25": 2, "pm10_est": 3, "lux": 120.5}`)
+	})
+	data, err := c.AirData(context.Background())
+	if err != nil {
+		t.Fatalf("AirData: %v", err)
+	}
+	if data.Score != 86 || data.Temp != 23.6 || data.CO2 != 988 || data.PM25 != 2 {
+		t.Errorf("data = %+v", data)
+	}
+	if data.DewPoint == nil || *data.DewPoint != 10.6 {
+		t.Errorf("DewPoint = %v, want 10.6", data.DewPoint)
+	}
+	if data.AbsHumid != nil || data.SPLA != nil {
+		t.Errorf("fields the device didn't send aren't nil: %v %v", data.AbsHumid, data.SPLA)
+	}
+	if got := data.Time().Format("15:04"); got != "10:00" {
+		t.Errorf("Time = %s", got)
+	}
+	var keys []string
+	for _, r := range data.Readings() {
+		keys = append(keys, r.Key)
+	}
+	if want := "[temp humid co2 voc pm25 dew_point abs_humid pm10_est lux]"; fmt.Sprint(keys) != want {
+		t.Errorf("Readings keys = %v, want %s", keys, want)
+	}
+}
+
+func TestMissingCoreSensor(t *testing.T) {
+	// A Mint has no CO₂ sensor
+	var data SensorData
+	if err := json.Unmarshal([]byte(`{"score": 90, "temp": 21, "humid": 45, "co2": null, "voc": 100, "pm25": 2}`), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Reported("co2") {
+		t.Error("co2 is reported")
+	}
+	if !data.Reported("temp") || !data.Reported("lux") {
+		t.Error("temp or lux isn't reported")
+	}
+	for _, r := range data.Readings() {
+		if r.Key == "co2" {
+			t.Error("Readings includes co2")
+		}
+	}
+	out, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	json.Unmarshal(out, &fields)
+	if v, ok := fields["co2"]; !ok || v != nil {
+		t.Errorf("co2 encodes as %v, want null", v)
+	}
+}
+
+func TestModel(t *testing.T) {
+	tests := map[string]string{
+		"awair-element_12345": "Element",
+		"awair-omni_7":        "Omni",
+		"awair-mint_1":        "Mint",
+		"awair-r2_9":          "Awair 2nd Edition",
+		"something-else_1":    "",
+		"":                    "",
+	}
+	for uuid, want := range tests {
+		if got := (&DeviceConfig{DeviceUUID: uuid}).Model(); got != want {
+			t.Errorf("Model(%q) = %q, want %q", uuid, got, want)
+		}
+	}
+}
+
+// hang starts a test server that answers nothing until the request is
+// abandoned, returning a client for it that doesn't time out on its own.
+// stop closes the server and the client's connections.
+func hang(t *testing.T, hits *atomic.Int32) (c *Client, stop func()) {
+	t.Helper()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	tr := &http.Transport{}
+	c = NewClient(srv.URL)
+	c.HTTP = &http.Client{Transport: tr}
+	c.Timeout = time.Minute
+	return c, func() {
+		close(release)
+		tr.CloseIdleConnections()
+		srv.Close()
+	}
+}
+
+func TestCancelAbortsRequest(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	var hits atomic.Int32
+	c, stop := hang(t, &hits)
+	defer stop()
+	c.Retries = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := c.AirData(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned %v after the cancel", elapsed-50*time.Millisecond)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if Transient(err) {
+		t.Error("a cancelled request counts as transient")
+	}
+	// Cancelled requests aren't retried
+	if got := hits.Load(); got != 1 {
+		t.Errorf("%d requests, want 1", got)
+	}
+}
+
+func TestCancelAbortsRetryDelay(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	var hits atomic.Int32
+	srv := httptest.NewServer(failFirst(100, http.StatusServiceUnavailable, "", &hits))
+	defer srv.Close()
+	c := NewClient(srv.URL)
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	c.HTTP = &http.Client{Transport: tr}
+	c.Retries = 100
+
+	// Cancelled while waiting to retry: the last failure is returned
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.AirData(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+	var status *StatusError
+	if !errors.As(err, &status) || status.Code != http.StatusServiceUnavailable {
+		t.Errorf("err = %v, want the 503", err)
+	}
+	if got := hits.Load(); got < 1 || got > 2 {
+		t.Errorf("%d requests, want 1 or 2", got)
+	}
+}
+
+func TestTimeoutAbortsRequest(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	var hits atomic.Int32
+	c, stop := hang(t, &hits)
+	defer stop()
+	c.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := c.Config(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
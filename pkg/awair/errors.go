@@ -0,0 +1,72 @@
+package awair
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrNoAverage is an averaging endpoint that has nothing yet, e.g.
+// right after the device started.
+var ErrNoAverage = errors.New("no averages yet")
+
+// FetchError is a failed request to a device. The underlying cause stays
+// reachable with errors.Is and errors.As; a non-2xx answer is a
+// *StatusError.
+type FetchError struct {
+	Method  string // "" for GET
+	URL     string // without credentials
+	Path    string // endpoint, e.g. /air-data/latest
+	Elapsed time.Duration
+	Err     error
+
+	// Which attempt failed, when the request was retried (Client.Retries)
+	Attempt  int
+	Attempts int
+}
+
+func (e *FetchError) Error() string {
+	attempt := ""
+	if e.Attempts > 1 {
+		attempt = fmt.Sprintf("attempt %d/%d: ", e.Attempt, e.Attempts)
+	}
+	method := e.Method
+	if method == "" {
+		method = "GET"
+	}
+	target := strings.TrimPrefix(e.URL, "http://")
+	return fmt.Sprintf("%s %s after %s: %s%v", method, target, e.Elapsed.Round(time.Millisecond), attempt, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is a non-2xx response from a device.
+type StatusError struct {
+	Code   int
+	Status string // e.g. "404 Not Found"
+}
+
+func (e *StatusError) Error() string {
+	return "HTTP " + e.Status
+}
+
+// Transient reports whether a failed request is worth retrying: the
+// device refused the connection, reset it, timed out or answered 5xx.
+func Transient(err error) bool {
+	var status *StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		return status.Code >= 500
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	default:
+		return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+	}
+}
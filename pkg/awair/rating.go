@@ -0,0 +1,236 @@
+package awair
+
+import (
+	"fmt"
+	"math"
+)
+
+// SensorRange defines the optimal range for a sensor reading and how far
+// outside it a value may stray before it is rated poor.
+//
+// Boundaries are inclusive throughout: a value is good when
+// Min <= value <= Max (or just value <= Max for LowerIsBetter sensors),
+// fair when its distance outside the good range is <= FairMargin, and
+// poor beyond that. Anything that rates readings must go through Rate so
+// they all agree.
+type SensorRange struct {
+	Min           float64
+	Max           float64
+	FairMargin    float64
+	LowerIsBetter bool
+	Unit          string
+	Label         string
+}
+
+// OptimalRanges defines per-sensor optimal ranges (temps in °F for
+// rating), keyed by the sensor's JSON field name. Entries may be
+// replaced to use other thresholds.
+var OptimalRanges = map[string]SensorRange{
+	"temp":      {Min: 68, Max: 77, FairMargin: 5, Unit: "°F", Label: "Temperature"},
+	"dew_point": {Min: 50, Max: 65, FairMargin: 5, Unit: "°F", Label: "Dew Point"},
+	"humid":     {Min: 40, Max: 50, FairMargin: 10, Unit: "%", Label: "Humidity"},
+	"abs_humid": {Min: 4, Max: 12, FairMargin: math.Inf(1), Unit: "g/m³", Label: "Abs Humidity"},
+	"co2":       {Min: 0, Max: 600, FairMargin: 600, LowerIsBetter: true, Unit: "ppm", Label: "CO₂"},
+	"co2_est":   {Min: 0, Max: 600, FairMargin: 600, LowerIsBetter: true, Unit: "ppm", Label: "CO₂ (est)"},
+	"voc":       {Min: 0, Max: 300, FairMargin: 300, LowerIsBetter: true, Unit: "ppb", Label: "VOC"},
+	"pm25":      {Min: 0, Max: 12, FairMargin: 12, LowerIsBetter: true, Unit: "µg/m³", Label: "PM2.5"},
+	"pm10_est":  {Min: 0, Max: 50, FairMargin: 50, LowerIsBetter: true, Unit: "µg/m³", Label: "PM10 (est)"},
+	// Light is neither good nor bad, so it always rates good
+	"lux":   {Min: 0, Max: math.Inf(1), FairMargin: math.Inf(1), LowerIsBetter: true, Unit: "lux", Label: "Light"},
+	"spl_a": {Min: 0, Max: 50, FairMargin: 20, LowerIsBetter: true, Unit: "dBA", Label: "Sound"},
+}
+
+// plausibleRanges bounds what each sensor can physically report. Values
+// outside are glitches (e.g. 65535 from a failed sensor read).
+var plausibleRanges = map[string][2]float64{
+	"temp":      {-40, 85},
+	"dew_point": {-60, 60},
+	"humid":     {0, 100},
+	"abs_humid": {0, 100},
+	"co2":       {0, 10000},
+	"co2_est":   {0, 10000},
+	"voc":       {0, 60000},
+	"pm25":      {0, 1000},
+	"pm10_est":  {0, 1000},
+	"lux":       {0, 100000},
+	"spl_a":     {0, 140},
+}
+
+// Plausible reports whether value is a believable reading for the sensor.
+// Temperatures are in °C, as reported by the API. Unknown keys are
+// always plausible.
+func Plausible(key string, value float64) bool {
+	r, ok := plausibleRanges[key]
+	if !ok {
+		return true
+	}
+	return value >= r[0] && value <= r[1]
+}
+
+// Rate returns "good", "fair", or "poor" for value using the inclusive
+// boundary rules documented on SensorRange.
+func (r SensorRange) Rate(value float64) string {
+	var dist float64
+	switch {
+	case value > r.Max:
+		dist = value - r.Max
+	case !r.LowerIsBetter && value < r.Min:
+		dist = r.Min - value
+	default:
+		return "good"
+	}
+	if dist <= r.FairMargin {
+		return "fair"
+	}
+	return "poor"
+}
+
+// Excess returns how far value lies outside the good range, in multiples
+// of FairMargin: 0 when good, up to 1 while fair, above 1 when poor.
+func (r SensorRange) Excess(value float64) float64 {
+	var dist float64
+	switch {
+	case value > r.Max:
+		dist = value - r.Max
+	case !r.LowerIsBetter && value < r.Min:
+		dist = r.Min - value
+	}
+	return dist / r.FairMargin
+}
+
+// Worsens reports whether moving from prev to cur heads towards poor:
+// upwards for LowerIsBetter sensors, away from the middle of the good
+// range for the others.
+func (r SensorRange) Worsens(prev, cur float64) bool {
+	if r.LowerIsBetter {
+		return cur > prev
+	}
+	mid := (r.Min + r.Max) / 2
+	return math.Abs(cur-mid) > math.Abs(prev-mid)
+}
+
+// RateSensorValue returns "good", "fair", or "poor" for a sensor value.
+// For temp/dew_point, value should be in °F; see DisplayValue. Unknown
+// keys rate as "fair".
+func RateSensorValue(key string, value float64) string {
+	r, ok := OptimalRanges[key]
+	if !ok {
+		return "fair"
+	}
+	return r.Rate(value)
+}
+
+// FormatValue formats a sensor value as reported (temps in °C) for
+// display, with its unit.
+func FormatValue(key string, value float64, fahrenheit bool) string {
+	r := OptimalRanges[key]
+
+	switch key {
+	case "temp", "dew_point":
+		if fahrenheit {
+			return fmt.Sprintf("%.1f°F", CToF(value))
+		}
+		return fmt.Sprintf("%.1f°C", value)
+	case "humid":
+		return fmt.Sprintf("%.1f%s", value, r.Unit)
+	case "abs_humid":
+		return fmt.Sprintf("%.1f %s", value, r.Unit)
+	default:
+		return fmt.Sprintf("%.0f %s", math.Round(value), r.Unit)
+	}
+}
+
+// DisplayValue returns the value used for rating and bar display.
+// For temp/dew_point this is always °F (since ratings are defined in °F).
+func DisplayValue(key string, rawCelsius float64) float64 {
+	if key == "temp" || key == "dew_point" {
+		return CToF(rawCelsius)
+	}
+	return rawCelsius
+}
+
+// CToF converts Celsius to Fahrenheit.
+func CToF(c float64) float64 {
+	return c*9.0/5.0 + 32.0
+}
+
+// aqiBreakpoint maps a range of truncated concentrations to a range of
+// AQI values, both inclusive.
+type aqiBreakpoint struct {
+	CLo, CHi float64
+	ILo, IHi int
+}
+
+// US EPA AQI breakpoints (40 CFR Part 58, Appendix G, 2012 table), in
+// µg/m³.
+var (
+	pm25Breakpoints = []aqiBreakpoint{
+		{0.0, 12.0, 0, 50},
+		{12.1, 35.4, 51, 100},
+		{35.5, 55.4, 101, 150},
+		{55.5, 150.4, 151, 200},
+		{150.5, 250.4, 201, 300},
+		{250.5, 350.4, 301, 400},
+		{350.5, 500.4, 401, 500},
+	}
+	pm10Breakpoints = []aqiBreakpoint{
+		{0, 54, 0, 50},
+		{55, 154, 51, 100},
+		{155, 254, 101, 150},
+		{255, 354, 151, 200},
+		{355, 424, 201, 300},
+		{425, 504, 301, 400},
+		{505, 604, 401, 500},
+	}
+)
+
+// PM25AQI returns the US EPA AQI for a PM2.5 concentration in µg/m³. The
+// concentration is truncated to 0.1 µg/m³ first, as the EPA specifies.
+func PM25AQI(conc float64) int {
+	// The epsilon keeps e.g. 12.1 from truncating to 12.0 through
+	// floating-point error
+	return aqiFromBreakpoints(math.Floor(conc*10+1e-9)/10, pm25Breakpoints)
+}
+
+// PM10AQI returns the US EPA AQI for a PM10 concentration in µg/m³,
+// truncated to a whole µg/m³ first.
+func PM10AQI(conc float64) int {
+	return aqiFromBreakpoints(math.Floor(conc+1e-9), pm10Breakpoints)
+}
+
+// aqiFromBreakpoints interpolates the AQI for a truncated concentration
+// within its breakpoint range, rounding to the nearest integer.
+// Concentrations beyond the table keep the slope of the last range, so
+// they report above 500 rather than being capped.
+func aqiFromBreakpoints(c float64, bps []aqiBreakpoint) int {
+	if c <= 0 {
+		return 0
+	}
+	bp := bps[len(bps)-1]
+	for _, b := range bps {
+		if c <= b.CHi {
+			bp = b
+			break
+		}
+	}
+	aqi := float64(bp.IHi-bp.ILo)/(bp.CHi-bp.CLo)*(c-bp.CLo) + float64(bp.ILo)
+	return int(math.Round(aqi))
+}
+
+// AQICategory returns the EPA category name for an AQI value.
+func AQICategory(aqi int) string {
+	switch {
+	case aqi <= 50:
+		return "Good"
+	case aqi <= 100:
+		return "Moderate"
+	case aqi <= 150:
+		return "Unhealthy for Sensitive Groups"
+	case aqi <= 200:
+		return "Unhealthy"
+	case aqi <= 300:
+		return "Very Unhealthy"
+	default:
+		return "Hazardous"
+	}
+}
@@ -0,0 +1,258 @@
+package awair
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRateSensorValue(t *testing.T) {
+	tests := []struct {
+		key   string
+		value float64
+		want  string
+	}{
+		{"co2", 450, "good"},
+		{"co2", 900, "fair"},
+		{"co2", 1500, "poor"},
+		{"pm25", 5, "good"},
+		{"pm25", 30, "poor"},
+		{"temp", 72, "good"},
+		{"temp", 64, "fair"},
+		{"temp", 85, "poor"},
+		{"humid", 45, "good"},
+		{"humid", 25, "poor"},
+		{"abs_humid", 100, "fair"},
+		{"lux", 50000, "good"},
+		{"unknown", 1, "fair"},
+	}
+	for _, tt := range tests {
+		if got := RateSensorValue(tt.key, tt.value); got != tt.want {
+			t.Errorf("RateSensorValue(%q, %v) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestRateBoundaries rates every sensor exactly at and just outside each
+// boundary of its good and fair ranges. Boundaries are inclusive.
+func TestRateBoundaries(t *testing.T) {
+	above := func(v float64) float64 { return math.Nextafter(v, math.Inf(1)) }
+	below := func(v float64) float64 { return math.Nextafter(v, math.Inf(-1)) }
+	type check struct {
+		value float64
+		want  string
+	}
+	tests := map[string][]check{
+		"temp": {
+			{77, "good"}, {above(77), "fair"}, {82, "fair"}, {above(82), "poor"},
+			{68, "good"}, {below(68), "fair"}, {63, "fair"}, {below(63), "poor"},
+		},
+		"dew_point": {
+			{65, "good"}, {above(65), "fair"}, {70, "fair"}, {above(70), "poor"},
+			{50, "good"}, {below(50), "fair"}, {45, "fair"}, {below(45), "poor"},
+		},
+		"humid": {
+			{50, "good"}, {above(50), "fair"}, {60, "fair"}, {above(60), "poor"},
+			{40, "good"}, {below(40), "fair"}, {30, "fair"}, {below(30), "poor"},
+		},
+		// An infinite margin never reaches poor
+		"abs_humid": {
+			{12, "good"}, {above(12), "fair"}, {1e9, "fair"},
+			{4, "good"}, {below(4), "fair"}, {-1e9, "fair"},
+		},
+		// Lower is better: nothing below Min is worse
+		"co2": {
+			{600, "good"}, {above(600), "fair"}, {1200, "fair"}, {above(1200), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+		"co2_est": {
+			{600, "good"}, {above(600), "fair"}, {1200, "fair"}, {above(1200), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+		"voc": {
+			{300, "good"}, {above(300), "fair"}, {600, "fair"}, {above(600), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+		"pm25": {
+			{12, "good"}, {above(12), "fair"}, {24, "fair"}, {above(24), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+		"pm10_est": {
+			{50, "good"}, {above(50), "fair"}, {100, "fair"}, {above(100), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+		"lux": {
+			{0, "good"}, {below(0), "good"}, {math.MaxFloat64, "good"}, {math.Inf(1), "good"},
+		},
+		"spl_a": {
+			{50, "good"}, {above(50), "fair"}, {70, "fair"}, {above(70), "poor"},
+			{0, "good"}, {below(0), "good"},
+		},
+	}
+	for key := range OptimalRanges {
+		if _, ok := tests[key]; !ok {
+			t.Errorf("no boundary checks for %q", key)
+		}
+	}
+	for key, checks := range tests {
+		r, ok := OptimalRanges[key]
+		if !ok {
+			t.Errorf("%q is not in OptimalRanges", key)
+			continue
+		}
+		for _, c := range checks {
+			if got := r.Rate(c.value); got != c.want {
+				t.Errorf("%s Rate(%v) = %q, want %q", key, c.value, got, c.want)
+			}
+		}
+	}
+}
+
+func TestExcess(t *testing.T) {
+	r := OptimalRanges["co2"]
+	tests := []struct{ value, want float64 }{
+		{400, 0}, {600, 0}, {900, 0.5}, {1200, 1}, {1800, 2},
+	}
+	for _, tt := range tests {
+		if got := r.Excess(tt.value); got != tt.want {
+			t.Errorf("co2 Excess(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if got := OptimalRanges["humid"].Excess(30); got != 1 {
+		t.Errorf("humid Excess(30) = %v, want 1", got)
+	}
+}
+
+func TestWorsens(t *testing.T) {
+	if !OptimalRanges["co2"].Worsens(800, 900) || OptimalRanges["co2"].Worsens(900, 800) {
+		t.Error("co2 should worsen going up only")
+	}
+	humid := OptimalRanges["humid"]
+	if !humid.Worsens(45, 35) || !humid.Worsens(45, 55) || humid.Worsens(30, 40) {
+		t.Error("humid should worsen moving away from 45")
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		key        string
+		value      float64
+		fahrenheit bool
+		want       string
+	}{
+		{"temp", 21.04, false, "21.0°C"},
+		{"temp", 21, true, "69.8°F"},
+		{"dew_point", 10, true, "50.0°F"},
+		{"humid", 45.26, false, "45.3%"},
+		{"abs_humid", 8.44, false, "8.4 g/m³"},
+		{"co2", 612.5, false, "613 ppm"},
+		{"pm25", 2.4, false, "2 µg/m³"},
+	}
+	for _, tt := range tests {
+		if got := FormatValue(tt.key, tt.value, tt.fahrenheit); got != tt.want {
+			t.Errorf("FormatValue(%q, %v, %v) = %q, want %q", tt.key, tt.value, tt.fahrenheit, got, tt.want)
+		}
+	}
+}
+
+func TestDisplayValue(t *testing.T) {
+	if got := DisplayValue("temp", 25); got != 77 {
+		t.Errorf("DisplayValue(temp, 25) = %v, want 77", got)
+	}
+	if got := DisplayValue("dew_point", -40); got != -40 {
+		t.Errorf("DisplayValue(dew_point, -40) = %v, want -40", got)
+	}
+	if got := DisplayValue("co2", 800); got != 800 {
+		t.Errorf("DisplayValue(co2, 800) = %v, want 800", got)
+	}
+}
+
+func TestPlausible(t *testing.T) {
+	tests := []struct {
+		key   string
+		value float64
+		want  bool
+	}{
+		{"co2", 65535, false},
+		{"co2", 10000, true},
+		{"temp", -41, false},
+		{"temp", 22, true},
+		{"humid", 101, false},
+		{"unknown", math.MaxFloat64, true},
+	}
+	for _, tt := range tests {
+		if got := Plausible(tt.key, tt.value); got != tt.want {
+			t.Errorf("Plausible(%q, %v) = %v, want %v", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestPM25AQI(t *testing.T) {
+	tests := []struct {
+		conc float64
+		want int
+	}{
+		{-1, 0}, {0, 0}, {6, 25}, {20, 68},
+		// The EPA's worked example
+		{35.9, 102},
+		// Each breakpoint and the first value past it
+		{12.0, 50}, {12.1, 51},
+		{35.4, 100}, {35.5, 101},
+		{55.4, 150}, {55.5, 151},
+		{150.4, 200}, {150.5, 201},
+		{250.4, 300}, {250.5, 301},
+		{350.4, 400}, {350.5, 401},
+		{500.4, 500},
+		// Truncated to 0.1 µg/m³, not rounded
+		{12.09, 50}, {12.19, 51}, {35.49, 100},
+		// Past the table the last range's slope carries on
+		{600, 566}, {1000, 830},
+	}
+	for _, tt := range tests {
+		if got := PM25AQI(tt.conc); got != tt.want {
+			t.Errorf("PM25AQI(%v) = %d, want %d", tt.conc, got, tt.want)
+		}
+	}
+}
+
+func TestPM10AQI(t *testing.T) {
+	tests := []struct {
+		conc float64
+		want int
+	}{
+		{0, 0}, {20, 19},
+		{54, 50}, {55, 51},
+		{154, 100}, {155, 101},
+		{254, 150}, {255, 151},
+		{354, 200}, {355, 201},
+		{424, 300}, {425, 301},
+		{504, 400}, {505, 401},
+		{604, 500},
+		// Truncated to a whole µg/m³
+		{54.9, 50},
+		{605, 501}, {700, 596},
+	}
+	for _, tt := range tests {
+		if got := PM10AQI(tt.conc); got != tt.want {
+			t.Errorf("PM10AQI(%v) = %d, want %d", tt.conc, got, tt.want)
+		}
+	}
+}
+
+func TestAQICategory(t *testing.T) {
+	tests := []struct {
+		aqi  int
+		want string
+	}{
+		{0, "Good"}, {50, "Good"},
+		{51, "Moderate"}, {100, "Moderate"},
+		{101, "Unhealthy for Sensitive Groups"}, {150, "Unhealthy for Sensitive Groups"},
+		{151, "Unhealthy"}, {200, "Unhealthy"},
+		{201, "Very Unhealthy"}, {300, "Very Unhealthy"},
+		{301, "Hazardous"}, {500, "Hazardous"}, {830, "Hazardous"},
+	}
+	for _, tt := range tests {
+		if got := AQICategory(tt.aqi); got != tt.want {
+			t.Errorf("AQICategory(%d) = %q, want %q", tt.aqi, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package awair
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SensorData represents the JSON response from /air-data/latest.
+type SensorData struct {
+	Timestamp      string   `json:"timestamp"`
+	Score          int      `json:"score"`
+	DewPoint       *float64 `json:"dew_point"`
+	Temp           float64  `json:"temp"`
+	Humid          float64  `json:"humid"`
+	AbsHumid       *float64 `json:"abs_humid"`
+	CO2            float64  `json:"co2"`
+	CO2Est         *float64 `json:"co2_est"`
+	CO2EstBaseline *float64 `json:"co2_est_baseline"`
+	VOC            float64  `json:"voc"`
+	VOCBaseline    *float64 `json:"voc_baseline"`
+	VOCH2Raw       *float64 `json:"voc_h2_raw"`
+	VOCEthanolRaw  *float64 `json:"voc_ethanol_raw"`
+	PM25           float64  `json:"pm25"`
+	PM10Est        *float64 `json:"pm10_est"`
+	Lux            *float64 `json:"lux"`   // Omni, Mint
+	SPLA           *float64 `json:"spl_a"` // Omni: sound level in dBA
+
+	// missing holds the core readings the device didn't send, e.g. co2
+	// on a Mint; see Reported.
+	missing map[string]bool
+}
+
+// optionalSensorFields are the SensorData fields a device may omit.
+var optionalSensorFields = map[string]bool{
+	"dew_point": true, "abs_humid": true, "co2_est": true, "co2_est_baseline": true,
+	"voc_baseline": true, "voc_h2_raw": true, "voc_ethanol_raw": true, "pm10_est": true,
+	"lux": true, "spl_a": true,
+}
+
+// CoreSensors are the readings every Element reports. Other models leave
+// some out: a Mint has no CO₂ sensor.
+var CoreSensors = []string{"temp", "humid", "co2", "voc", "pm25"}
+
+// Logf, if set, is told about what decoding a reading had to drop, such
+// as an optional field that isn't a number. The data still loads.
+var Logf func(format string, args ...any)
+
+// Reported reports whether the device sent the core reading key, so a
+// missing sensor isn't mistaken for a reading of zero. Other keys are
+// always reported; their fields are nil when missing.
+func (d *SensorData) Reported(key string) bool {
+	return !d.missing[key]
+}
+
+// Time parses the reading's timestamp. It is zero if the device sent
+// none or one that doesn't parse.
+func (d *SensorData) Time() time.Time {
+	t, err := time.Parse(time.RFC3339Nano, d.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// UnmarshalJSON accepts numbers sent as strings ("temp": "22.40"), as
+// some older firmware does. A string that isn't a finite number is an
+// error for the core readings and the score; optional fields holding one
+// are dropped so the rest of the reading still loads. Keys SensorData
+// doesn't know, such as newer firmware's, are skipped whatever they hold.
+func (d *SensorData) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key, raw := range fields {
+		core := key == "score" || slices.Contains(CoreSensors, key)
+		if !core && !optionalSensorFields[key] || len(raw) == 0 || raw[0] != '"' {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		s = strings.TrimSpace(s)
+		// ParseFloat also takes "NaN", "+5", ".5" and hex, so the number
+		// is written back out as JSON rather than passed through
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			if core {
+				return fmt.Errorf("%s: %q is not a number", key, s)
+			}
+			if Logf != nil {
+				Logf("ignoring %s: %q is not a number", key, s)
+			}
+			delete(fields, key)
+			continue
+		}
+		fields[key] = json.RawMessage(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, (*sensorDataAlias)(d)); err != nil {
+		return err
+	}
+	d.missing = nil
+	for _, key := range CoreSensors {
+		if raw, ok := fields[key]; !ok || string(raw) == "null" {
+			if d.missing == nil {
+				d.missing = make(map[string]bool)
+			}
+			d.missing[key] = true
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes the core readings the device didn't send as null
+// rather than 0.
+func (d SensorData) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(sensorDataAlias(d))
+	if err != nil || len(d.missing) == 0 {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key := range d.missing {
+		fields[key] = json.RawMessage("null")
+	}
+	return json.Marshal(fields)
+}
+
+// sensorDataAlias has SensorData's fields without its JSON methods.
+type sensorDataAlias SensorData
+
+// SensorReading is a single sensor value keyed by its OptimalRanges key.
+type SensorReading struct {
+	Key     string
+	Value   float64
+	Derived bool // calculated because the device didn't report it
+}
+
+// Readings returns the sensor values present in d in display order: the
+// core sensors the device reported first, then the optional fields it
+// reported. Dew point and absolute humidity are calculated from
+// temperature and humidity when the device omits them, as older firmware
+// does.
+func (d *SensorData) Readings() []SensorReading {
+	var readings []SensorReading
+	for _, r := range []SensorReading{
+		{Key: "temp", Value: d.Temp},
+		{Key: "humid", Value: d.Humid},
+		{Key: "co2", Value: d.CO2},
+		{Key: "voc", Value: d.VOC},
+		{Key: "pm25", Value: d.PM25},
+	} {
+		if d.Reported(r.Key) {
+			readings = append(readings, r)
+		}
+	}
+	canDerive := d.Humid > 0 && d.Reported("temp") && d.Reported("humid")
+	switch {
+	case d.DewPoint != nil:
+		readings = append(readings, SensorReading{Key: "dew_point", Value: *d.DewPoint})
+	case canDerive:
+		readings = append(readings, SensorReading{Key: "dew_point", Value: DewPoint(d.Temp, d.Humid), Derived: true})
+	}
+	switch {
+	case d.AbsHumid != nil:
+		readings = append(readings, SensorReading{Key: "abs_humid", Value: *d.AbsHumid})
+	case canDerive:
+		readings = append(readings, SensorReading{Key: "abs_humid", Value: AbsoluteHumidity(d.Temp, d.Humid), Derived: true})
+	}
+	optional := []struct {
+		key   string
+		value *float64
+	}{
+		{"co2_est", d.CO2Est},
+		{"pm10_est", d.PM10Est},
+		{"lux", d.Lux},
+		{"spl_a", d.SPLA},
+	}
+	for _, o := range optional {
+		if o.value != nil {
+			readings = append(readings, SensorReading{Key: o.key, Value: *o.value})
+		}
+	}
+	return readings
+}
+
+// DeviceConfig represents the JSON response from /settings/config/data.
+type DeviceConfig struct {
+	DeviceUUID string `json:"device_uuid"`
+	WifiMAC    string `json:"wifi_mac"`
+	SSID       string `json:"ssid"`
+	IP         string `json:"ip"`
+	Netmask    string `json:"netmask"`
+	Gateway    string `json:"gateway"`
+	FWVersion  string `json:"fw_version"`
+	Timezone   string `json:"timezone"`
+	Display    string `json:"display"`
+}
+
+// deviceModels maps the prefix of a device UUID (before the "_") to the
+// model's name.
+var deviceModels = map[string]string{
+	"awair-element": "Element",
+	"awair-omni":    "Omni",
+	"awair-mint":    "Mint",
+	"awair-r2":      "Awair 2nd Edition",
+}
+
+// Model returns the device model named by its UUID, or "" if unknown.
+func (c *DeviceConfig) Model() string {
+	prefix, _, _ := strings.Cut(c.DeviceUUID, "_")
+	return deviceModels[prefix]
+}
+
+// Magnus formula coefficients over water (Sonntag 1990), for °C.
+const (
+	magnusA = 17.62
+	magnusB = 243.12
+)
+
+// DewPoint returns the dew point in °C for a temperature in °C and a
+// relative humidity in percent, using the Magnus formula. Humidity must
+// be above zero.
+func DewPoint(tempC, humid float64) float64 {
+	gamma := math.Log(humid/100) + magnusA*tempC/(magnusB+tempC)
+	return magnusB * gamma / (magnusA - gamma)
+}
+
+// AbsoluteHumidity returns the water vapour density in g/m³ for a
+// temperature in °C and a relative humidity in percent: the saturation
+// vapour pressure from the Magnus formula scaled by humidity, through the
+// ideal gas law for water vapour.
+func AbsoluteHumidity(tempC, humid float64) float64 {
+	// hPa
+	saturation := 6.112 * math.Exp(magnusA*tempC/(magnusB+tempC))
+	// 100 Pa/hPa ÷ 461.5 J/(kg·K) for water vapour × 1000 g/kg = 216.7
+	return saturation * humid / 100 * 216.7 / (tempC + 273.15)
+}
@@ -0,0 +1,216 @@
+package awair
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadFixture decodes testdata/name into a SensorData.
+func loadFixture(t *testing.T, name string) (*SensorData, error) {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data SensorData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func TestQuotedNumbers(t *testing.T) {
+	for _, name := range []string{"quoted.json", "mixed.json"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := loadFixture(t, name)
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if data.Score != 82 || data.Temp != 22.4 || data.Humid != 48.1 || data.CO2 != 712 || data.VOC != 245 || data.PM25 != 3 {
+				t.Errorf("data = %+v", data)
+			}
+			if data.DewPoint == nil || *data.DewPoint != 11.2 || data.PM10Est == nil || *data.PM10Est != 4 {
+				t.Errorf("optional fields = %v, %v", data.DewPoint, data.PM10Est)
+			}
+			for _, key := range CoreSensors {
+				if !data.Reported(key) {
+					t.Errorf("%s isn't reported", key)
+				}
+			}
+		})
+	}
+}
+
+func TestOddNumberStrings(t *testing.T) {
+	// Strings ParseFloat takes that aren't JSON numbers must still decode,
+	// and NaN or Inf in optional fields is dropped
+	data, err := loadFixture(t, "odd-numbers.json")
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if data.Score != 82 || data.Temp != 22.4 || data.Humid != 0.5 || data.CO2 != 712 || data.VOC != 245 || data.PM25 != 3 {
+		t.Errorf("data = %+v", data)
+	}
+	if data.Lux != nil || data.SPLA != nil {
+		t.Errorf("NaN and Inf weren't dropped: %v, %v", data.Lux, data.SPLA)
+	}
+	if _, err := json.Marshal(data); err != nil {
+		t.Errorf("re-encoding: %v", err)
+	}
+}
+
+func TestNonNumericStrings(t *testing.T) {
+	tests := []struct {
+		fixture string
+		wantErr string // "" if it decodes
+	}{
+		{"bad-core.json", `co2: "n/a" is not a number`},
+		{"nan-core.json", `temp: "NaN" is not a number`},
+		{"bad-optional.json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var logged []string
+			Logf = func(format string, args ...any) { logged = append(logged, format) }
+			defer func() { Logf = nil }()
+
+			data, err := loadFixture(t, tt.fixture)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+			if data.CO2 != 712 || data.PM10Est != nil || data.CO2Est != nil {
+				t.Errorf("data = %+v", data)
+			}
+			if len(logged) != 2 {
+				t.Errorf("logged %d drops, want 2", len(logged))
+			}
+		})
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	// Newer firmware's extra fields don't fail the reading, whatever they
+	// hold, and aren't worth a log line
+	var logged []string
+	Logf = func(format string, args ...any) { logged = append(logged, format) }
+	defer func() { Logf = nil }()
+
+	data, err := loadFixture(t, "unknown-fields.json")
+	if err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if data.Score != 82 || data.Temp != 22.4 || data.CO2 != 712 || data.PM25 != 3 {
+		t.Errorf("data = %+v", data)
+	}
+	if len(logged) != 0 {
+		t.Errorf("logged %q", logged)
+	}
+
+	// The score is checked like the core readings
+	if err := json.Unmarshal([]byte(`{"score": "high", "temp": 22.4}`), new(SensorData)); err == nil || !strings.Contains(err.Error(), "score") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestDewPoint(t *testing.T) {
+	// Reference values from psychrometric tables, to 0.1 °C
+	tests := []struct{ temp, humid, want float64 }{
+		{20, 50, 9.3},
+		{25, 60, 16.7},
+		{30, 80, 26.2},
+		{10, 30, -6.8},
+		{-10, 80, -12.8},
+		{0, 100, 0},
+	}
+	for _, tt := range tests {
+		if got := DewPoint(tt.temp, tt.humid); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("DewPoint(%v, %v) = %.2f, want %v", tt.temp, tt.humid, got, tt.want)
+		}
+	}
+	// Saturated air is at its dew point
+	for _, temp := range []float64{-20, 5, 22.5, 35} {
+		if got := DewPoint(temp, 100); math.Abs(got-temp) > 1e-9 {
+			t.Errorf("DewPoint(%v, 100) = %v", temp, got)
+		}
+	}
+}
+
+func TestAbsoluteHumidity(t *testing.T) {
+	// Reference values from psychrometric tables, to 0.1 g/m³
+	tests := []struct{ temp, humid, want float64 }{
+		{20, 50, 8.6},
+		{25, 60, 13.8},
+		{30, 80, 24.2},
+		{0, 100, 4.85},
+		{10, 30, 2.8},
+	}
+	for _, tt := range tests {
+		if got := AbsoluteHumidity(tt.temp, tt.humid); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("AbsoluteHumidity(%v, %v) = %.2f, want %v", tt.temp, tt.humid, got, tt.want)
+		}
+	}
+	if got := AbsoluteHumidity(20, 0); got != 0 {
+		t.Errorf("AbsoluteHumidity(20, 0) = %v", got)
+	}
+}
+
+func TestDerivedReadings(t *testing.T) {
+	find := func(d *SensorData, key string) (SensorReading, bool) {
+		for _, r := range d.Readings() {
+			if r.Key == key {
+				return r, true
+			}
+		}
+		return SensorReading{}, false
+	}
+	decode := func(raw string) *SensorData {
+		var d SensorData
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+
+	// The device's own values win over calculated ones
+	reported := decode(`{"score": 80, "temp": 20, "humid": 50, "co2": 600, "voc": 100, "pm25": 2, "dew_point": 12.5, "abs_humid": 11}`)
+	if r, ok := find(reported, "dew_point"); !ok || r.Value != 12.5 || r.Derived {
+		t.Errorf("reported dew point = %+v", r)
+	}
+	if r, ok := find(reported, "abs_humid"); !ok || r.Value != 11 || r.Derived {
+		t.Errorf("reported absolute humidity = %+v", r)
+	}
+
+	// Older firmware leaves them out
+	derived := decode(`{"score": 80, "temp": 20, "humid": 50, "co2": 600, "voc": 100, "pm25": 2}`)
+	if r, ok := find(derived, "dew_point"); !ok || !r.Derived || r.Value != DewPoint(20, 50) {
+		t.Errorf("derived dew point = %+v", r)
+	}
+	if r, ok := find(derived, "abs_humid"); !ok || !r.Derived || r.Value != AbsoluteHumidity(20, 50) {
+		t.Errorf("derived absolute humidity = %+v", r)
+	}
+
+	// Nothing to derive from
+	for _, raw := range []string{
+		`{"score": 80, "temp": 20, "humid": 0, "co2": 600, "voc": 100, "pm25": 2}`,
+		`{"score": 80, "humid": 50, "co2": 600, "voc": 100, "pm25": 2}`,
+		`{"score": 80, "temp": 20, "co2": 600, "voc": 100, "pm25": 2}`,
+	} {
+		d := decode(raw)
+		if r, ok := find(d, "dew_point"); ok {
+			t.Errorf("%s: dew point %+v", raw, r)
+		}
+		if r, ok := find(d, "abs_humid"); ok {
+			t.Errorf("%s: absolute humidity %+v", raw, r)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+// Package discovery finds Awair devices on the local network with mDNS.
+//
+//	found, err := discovery.Start(ctx, discovery.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	for dev := range found {
+//		c := awair.NewClient("http://" + net.JoinHostPort(dev.IP, strconv.Itoa(dev.Port)))
+//		...
+//	}
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/ipv4"
+)
+
+// Device is a device found via mDNS.
+type Device struct {
+	Name string // instance name, e.g. "AWAIR-ELEM-1A2B3C"
+	IP   string
+	Port int
+}
+
+// mdnsGroup is where mDNS queries go and announcements arrive.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Awair devices advertise their local API as _http._tcp with "awair" in
+// the instance name. These are what zero Options use.
+const (
+	DefaultService  = "_http._tcp"
+	DefaultMatch    = "awair"
+	DefaultInterval = 30 * time.Second
+)
+
+// Options says what Start looks for. The zero value finds Awair devices
+// on the default interface.
+type Options struct {
+	// Services are the service types queried, such as "_http._tcp"; see
+	// ServiceName. Nil means DefaultService.
+	Services []string
+
+	// Match picks the instances reported: it must match the instance's
+	// full name or one of its TXT strings. Nil means DefaultMatch; see
+	// CompileMatch.
+	Match *regexp.Regexp
+
+	// Interval is how often the services are queried again; zero means
+	// DefaultInterval. Devices that announce themselves in between are
+	// picked up as they do.
+	Interval time.Duration
+
+	// Interface is where queries go out and announcements are heard; nil
+	// leaves it to the system. See LookupInterface.
+	Interface *net.Interface
+
+	// Logf, if set, receives debug detail: queries that failed, what was
+	// found where.
+	Logf func(format string, args ...any)
+}
+
+// Start listens for devices via mDNS and sends each on the returned
+// channel once, and again only if its address changes. It queries each
+// of the services right away and every Interval. Once ctx is cancelled
+// the channel is closed, after everything discovery started has
+// stopped. An error means it couldn't open a socket to query from.
+func Start(ctx context.Context, opts Options) (<-chan Device, error) {
+	l, err := newListener(opts)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan Device)
+	go func() {
+		defer close(ch)
+		l.run(ctx, ch)
+	}()
+	return ch, nil
+}
+
+// LookupInterface finds an interface to discover on by name. It has to
+// have an IPv4 address, as mDNS is only done over IPv4. Errors list the
+// usable interfaces there are.
+func LookupInterface(name string) (*net.Interface, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err == nil && interfaceIPv4(ifi) != nil {
+		return ifi, nil
+	}
+	var usable []string
+	ifaces, _ := net.Interfaces()
+	for _, other := range ifaces {
+		if ip := interfaceIPv4(&other); ip != nil {
+			usable = append(usable, fmt.Sprintf("%s (%s)", other.Name, ip))
+		}
+	}
+	available := "none"
+	if len(usable) > 0 {
+		available = strings.Join(usable, ", ")
+	}
+	if err == nil {
+		return nil, fmt.Errorf("interface %q has no IPv4 address; available: %s", name, available)
+	}
+	return nil, fmt.Errorf("no interface %q; available: %s", name, available)
+}
+
+// interfaceIPv4 returns the first IPv4 address of ifi, or nil if it has
+// none or is down.
+func interfaceIPv4(ifi *net.Interface) net.IP {
+	if ifi.Flags&net.FlagUp == 0 {
+		return nil
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4()
+		}
+	}
+	return nil
+}
+
+// ServiceName turns a service type such as "_awair._tcp" into the name
+// queried for, "_awair._tcp.local.". The ".local" suffix is optional. ok
+// is false if s isn't a service type.
+func ServiceName(s string) (name string, ok bool) {
+	s = strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "."), ".local")
+	svc, proto, ok := strings.Cut(s, ".")
+	if !ok || (proto != "_tcp" && proto != "_udp") || len(svc) < 2 || len(svc) > 64 || svc[0] != '_' {
+		return "", false
+	}
+	for _, c := range svc[1:] {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return "", false
+		}
+	}
+	return s + ".local.", true
+}
+
+// CompileMatch compiles a pattern for Options.Match, case-insensitively.
+func CompileMatch(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%q is not a valid regular expression", pattern)
+	}
+	return re, nil
+}
+
+// mdnsInstance is a service instance as far as its records have arrived.
+type mdnsInstance struct {
+	name string // instance name as advertised, e.g. "AWAIR-ELEM-1A2B3C"
+	host string // SRV target, lowercase; "" until the SRV record arrives
+	port int
+	ip   net.IP   // from an A record sent along with the SRV record, if any
+	txt  []string // TXT record strings, if any arrived
+}
+
+// listener collects mDNS records for one discovery run. Records can
+// arrive in any order and over several packets, so they are kept until
+// an instance's address is known. Only run's goroutine touches the maps.
+type listener struct {
+	services []string // names queried for, see ServiceName
+	match    *regexp.Regexp
+	interval time.Duration
+	logf     func(format string, args ...any)
+
+	iface    string         // interface name, for logs
+	query    *net.UDPConn   // sends queries; unicast replies come back here
+	conns    []*net.UDPConn // query, then the multicast listener if any
+	inst     map[string]*mdnsInstance
+	hosts    map[string]net.IP // A records by lowercase host name
+	reported map[string]string // instance → IP last sent
+	asked    map[string]bool   // follow-up queries sent since the last requery
+}
+
+func newListener(opts Options) (*listener, error) {
+	l := &listener{
+		match:    opts.Match,
+		interval: opts.Interval,
+		logf:     opts.Logf,
+		iface:    "the default interface",
+		inst:     make(map[string]*mdnsInstance),
+		hosts:    make(map[string]net.IP),
+		reported: make(map[string]string),
+		asked:    make(map[string]bool),
+	}
+	for _, svc := range opts.Services {
+		if name, ok := ServiceName(svc); ok {
+			l.services = append(l.services, name)
+		}
+	}
+	if len(l.services) == 0 {
+		l.services = []string{DefaultService + ".local."}
+	}
+	if l.match == nil {
+		l.match = regexp.MustCompile("(?i)" + DefaultMatch)
+	}
+	if l.interval <= 0 {
+		l.interval = DefaultInterval
+	}
+	if l.logf == nil {
+		l.logf = func(string, ...any) {}
+	}
+	if opts.Interface != nil {
+		l.iface = opts.Interface.Name
+	}
+
+	query, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	if opts.Interface != nil {
+		if err := ipv4.NewPacketConn(query).SetMulticastInterface(opts.Interface); err != nil {
+			query.Close()
+			return nil, err
+		}
+	}
+	l.query = query
+	l.conns = []*net.UDPConn{query}
+	// Without the multicast listener only replies to our own queries
+	// arrive, which is how discovery used to work anyway.
+	if mc, err := net.ListenMulticastUDP("udp4", opts.Interface, mdnsGroup); err != nil {
+		l.logf("mdns on %s: not listening for announcements: %v", l.iface, err)
+	} else {
+		l.conns = append(l.conns, mc)
+	}
+	return l, nil
+}
+
+// run handles packets until ctx is cancelled, then closes the sockets and
+// waits for their readers to exit.
+func (l *listener) run(ctx context.Context, ch chan<- Device) {
+	msgs := make(chan *dns.Msg)
+	var wg sync.WaitGroup
+	for _, c := range l.conns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.read(ctx, c, msgs)
+		}()
+	}
+	defer func() {
+		// Unblocks the readers
+		for _, c := range l.conns {
+			c.Close()
+		}
+		wg.Wait()
+	}()
+
+	requery := time.NewTicker(l.interval)
+	defer requery.Stop()
+	l.queryServices()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-requery.C:
+			clear(l.asked)
+			l.queryServices()
+		case msg := <-msgs:
+			for _, dev := range l.add(msg) {
+				select {
+				case ch <- dev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// read passes the responses arriving on conn to msgs until conn is closed
+// or ctx is cancelled.
+func (l *listener) read(ctx context.Context, conn *net.UDPConn, msgs chan<- *dns.Msg) {
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.logf("mdns on %s: %v", l.iface, err)
+			}
+			return
+		}
+		msg := new(dns.Msg)
+		if msg.Unpack(buf[:n]) != nil || !msg.Response {
+			continue
+		}
+		select {
+		case msgs <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// queryServices asks for the instances of each service.
+func (l *listener) queryServices() {
+	for _, svc := range l.services {
+		l.send(svc, dns.TypePTR)
+	}
+}
+
+// send queries the group for name. Failures are only logged; the next
+// requery tries again.
+func (l *listener) send(name string, qtype uint16) {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	m.Id = 0
+	m.RecursionDesired = false
+	buf, err := m.Pack()
+	if err == nil {
+		_, err = l.query.WriteToUDP(buf, mdnsGroup)
+	}
+	if err != nil {
+		l.logf("mdns on %s: query %s: %v", l.iface, name, err)
+	}
+}
+
+// add records the answers in msg and returns the devices that are now
+// complete and weren't reported at that address yet. Instances count as
+// devices if they belong to one of the services and match matches their
+// full name or one of their TXT strings. Instances still missing a
+// record are asked for it, once per requery.
+func (l *listener) add(msg *dns.Msg) []Device {
+	records := append(msg.Answer, msg.Extra...)
+	// Addresses in the same packet go with its SRV records, even where
+	// several instances share a host name
+	sent := make(map[string]net.IP)
+	for _, rr := range records {
+		if a, ok := rr.(*dns.A); ok {
+			sent[strings.ToLower(a.Hdr.Name)] = a.A
+			l.hosts[strings.ToLower(a.Hdr.Name)] = a.A
+		}
+	}
+	for _, rr := range records {
+		switch rr := rr.(type) {
+		case *dns.PTR:
+			if slices.ContainsFunc(l.services, func(svc string) bool { return strings.EqualFold(rr.Hdr.Name, svc) }) {
+				l.instance(rr.Ptr)
+			}
+		case *dns.SRV:
+			in := l.instance(rr.Hdr.Name)
+			in.host = strings.ToLower(rr.Target)
+			in.port = int(rr.Port)
+			if ip, ok := sent[in.host]; ok {
+				in.ip = ip
+			}
+		case *dns.TXT:
+			if l.serviceOf(rr.Hdr.Name) != "" {
+				l.instance(rr.Hdr.Name).txt = rr.Txt
+			}
+		}
+	}
+
+	var found []Device
+	for key, in := range l.inst {
+		if !l.matches(in, key) {
+			continue
+		}
+		if in.host == "" {
+			l.ask(key, dns.TypeSRV)
+			continue
+		}
+		ip := in.ip
+		if ip == nil {
+			if ip = l.hosts[in.host]; ip == nil {
+				l.ask(in.host, dns.TypeA)
+				continue
+			}
+		}
+		if l.reported[key] == ip.String() {
+			continue
+		}
+		l.reported[key] = ip.String()
+		l.logf("mdns on %s: found %s at %s:%d", l.iface, in.name, ip, in.port)
+		found = append(found, Device{Name: in.name, IP: ip.String(), Port: in.port})
+	}
+	return found
+}
+
+// matches reports whether the instance with the given full name is one to
+// report.
+func (l *listener) matches(in *mdnsInstance, fullName string) bool {
+	if l.serviceOf(fullName) == "" {
+		return false
+	}
+	if l.match.MatchString(fullName) {
+		return true
+	}
+	for _, t := range in.txt {
+		if l.match.MatchString(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceOf returns which of the services the instance fullName belongs
+// to, or "" if none.
+func (l *listener) serviceOf(fullName string) string {
+	for _, svc := range l.services {
+		n := len(fullName) - len(svc)
+		if n > 1 && fullName[n-1] == '.' && strings.EqualFold(fullName[n:], svc) {
+			return svc
+		}
+	}
+	return ""
+}
+
+// instance returns the entry for a service instance, adding it if new.
+// fullName is e.g. "AWAIR-ELEM-1A2B3C._http._tcp.local.".
+func (l *listener) instance(fullName string) *mdnsInstance {
+	key := strings.ToLower(fullName)
+	in, ok := l.inst[key]
+	if !ok {
+		name := fullName
+		if svc := l.serviceOf(fullName); svc != "" {
+			name = name[:len(name)-len(svc)-1]
+		}
+		in = &mdnsInstance{name: name}
+		l.inst[key] = in
+	}
+	return in
+}
+
+// ask sends a follow-up query for a missing record, unless one was sent
+// since the last requery.
+func (l *listener) ask(name string, qtype uint16) {
+	if l.asked[name] {
+		return
+	}
+	l.asked[name] = true
+	l.send(name, qtype)
+}
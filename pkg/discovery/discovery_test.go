@@ -0,0 +1,332 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.uber.org/goleak"
+)
+
+// testListener is a listener that doesn't listen: follow-up queries go
+// out from a loopback socket nothing reads.
+func testListener(t *testing.T, match string, services ...string) *listener {
+	t.Helper()
+	if len(services) == 0 {
+		services = []string{DefaultService + ".local."}
+	}
+	query, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { query.Close() })
+	return &listener{
+		query:    query,
+		services: services,
+		match:    regexp.MustCompile("(?i)" + match),
+		logf:     t.Logf,
+		iface:    "test",
+		inst:     make(map[string]*mdnsInstance),
+		hosts:    make(map[string]net.IP),
+		reported: make(map[string]string),
+		asked:    make(map[string]bool),
+	}
+}
+
+func hdr(name string, rrtype uint16) dns.RR_Header {
+	return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: 120}
+}
+
+func ptr(service, instance string) *dns.PTR {
+	return &dns.PTR{Hdr: hdr(service, dns.TypePTR), Ptr: instance}
+}
+
+func srv(instance, target string, port uint16) *dns.SRV {
+	return &dns.SRV{Hdr: hdr(instance, dns.TypeSRV), Target: target, Port: port}
+}
+
+func a(host, ip string) *dns.A {
+	return &dns.A{Hdr: hdr(host, dns.TypeA), A: net.ParseIP(ip).To4()}
+}
+
+func txt(instance string, strs ...string) *dns.TXT {
+	return &dns.TXT{Hdr: hdr(instance, dns.TypeTXT), Txt: strs}
+}
+
+func response(answer []dns.RR, extra ...dns.RR) *dns.Msg {
+	m := new(dns.Msg)
+	m.Response = true
+	m.Answer = answer
+	m.Extra = extra
+	return m
+}
+
+const elem = "AWAIR-ELEM-1A2B3C._http._tcp.local."
+
+func TestAddCompleteResponse(t *testing.T) {
+	l := testListener(t, DefaultMatch)
+	found := l.add(response(
+		[]dns.RR{ptr("_http._tcp.local.", elem)},
+		srv(elem, "awair-elem-1a2b3c.local.", 80),
+		a("awair-elem-1a2b3c.local.", "192.168.1.20"),
+	))
+	want := Device{Name: "AWAIR-ELEM-1A2B3C", IP: "192.168.1.20", Port: 80}
+	if len(found) != 1 || found[0] != want {
+		t.Fatalf("found %+v, want %+v", found, want)
+	}
+
+	// The same answer again reports nothing
+	if found := l.add(response([]dns.RR{srv(elem, "awair-elem-1a2b3c.local.", 80)})); len(found) != 0 {
+		t.Errorf("reported again: %+v", found)
+	}
+
+	// A new address is reported
+	found = l.add(response([]dns.RR{srv(elem, "awair-elem-1a2b3c.local.", 80), a("awair-elem-1a2b3c.local.", "192.168.1.21")}))
+	if len(found) != 1 || found[0].IP != "192.168.1.21" {
+		t.Errorf("after moving found %+v", found)
+	}
+}
+
+func TestAddRecordsAcrossPackets(t *testing.T) {
+	l := testListener(t, DefaultMatch)
+	if found := l.add(response([]dns.RR{ptr("_http._tcp.local.", elem)})); len(found) != 0 {
+		t.Fatalf("found %+v with only a PTR", found)
+	}
+	if !l.asked[strings.ToLower(elem)] {
+		t.Error("SRV record wasn't asked for")
+	}
+	if found := l.add(response([]dns.RR{srv(elem, "Awair-Elem-1A2B3C.local.", 8080)})); len(found) != 0 {
+		t.Fatalf("found %+v without an address", found)
+	}
+	if !l.asked["awair-elem-1a2b3c.local."] {
+		t.Error("A record wasn't asked for")
+	}
+	found := l.add(response([]dns.RR{a("awair-elem-1a2b3c.local.", "10.0.0.7")}))
+	if len(found) != 1 || found[0].IP != "10.0.0.7" || found[0].Port != 8080 {
+		t.Errorf("found %+v", found)
+	}
+}
+
+func TestAddSharedHostName(t *testing.T) {
+	// Two instances behind one host name, each SRV sent with its address
+	l := testListener(t, DefaultMatch)
+	other := "AWAIR-OMNI-9._http._tcp.local."
+	l.add(response([]dns.RR{srv(elem, "awair.local.", 80), a("awair.local.", "10.0.0.1")}))
+	found := l.add(response([]dns.RR{srv(other, "awair.local.", 80), a("awair.local.", "10.0.0.2")}))
+	if len(found) != 1 || found[0].Name != "AWAIR-OMNI-9" || found[0].IP != "10.0.0.2" {
+		t.Errorf("found %+v", found)
+	}
+}
+
+func TestAddMatchesTXT(t *testing.T) {
+	l := testListener(t, DefaultMatch)
+	room := "Living Room._http._tcp.local."
+	found := l.add(response(
+		[]dns.RR{ptr("_http._tcp.local.", room)},
+		srv(room, "living.local.", 80),
+		txt(room, "vendor=Awair"),
+		a("living.local.", "10.0.0.4"),
+	))
+	if len(found) != 1 || found[0].Name != "Living Room" {
+		t.Errorf("found %+v", found)
+	}
+}
+
+func TestAddIgnoresOtherInstances(t *testing.T) {
+	l := testListener(t, DefaultMatch)
+	printer := "Office Printer._http._tcp.local."
+	found := l.add(response(
+		[]dns.RR{ptr("_http._tcp.local.", printer)},
+		srv(printer, "printer.local.", 80),
+		a("printer.local.", "10.0.0.9"),
+		// Not a service queried for
+		srv("AWAIR-ELEM-1._ipp._tcp.local.", "awair.local.", 80),
+		a("awair.local.", "10.0.0.3"),
+	))
+	if len(found) != 0 {
+		t.Errorf("found %+v", found)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	l := testListener(t, DefaultMatch, "_http._tcp.local.", "_awair._tcp.local.")
+	tests := []struct {
+		name     string
+		fullName string
+		txt      []string
+		want     bool
+	}{
+		{"name", elem, nil, true},
+		{"case-insensitive", "awair-elem-1._http._tcp.local.", nil, true},
+		{"second service", "Living Room._awair._tcp.local.", []string{"model=awair-omni"}, true},
+		{"TXT", "Living Room._http._tcp.local.", []string{"vendor=Awair", "fw=1.4"}, true},
+		{"no match", "Living Room._http._tcp.local.", []string{"vendor=acme"}, false},
+		{"other service", "AWAIR-ELEM-1._ipp._tcp.local.", nil, false},
+		{"service itself", "_http._tcp.local.", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := l.matches(&mdnsInstance{txt: tt.txt}, tt.fullName); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, want %v", tt.fullName, tt.txt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"_http._tcp", "_http._tcp.local.", true},
+		{"_awair._tcp.local", "_awair._tcp.local.", true},
+		{"_awair._tcp.local.", "_awair._tcp.local.", true},
+		{" _HTTP._TCP ", "_http._tcp.local.", true},
+		{"_hap._udp", "_hap._udp.local.", true},
+		{"_my-svc2._tcp", "_my-svc2._tcp.local.", true},
+		{"http._tcp", "", false},
+		{"_http._sctp", "", false},
+		{"_http", "", false},
+		{"_._tcp", "", false},
+		{"_ht tp._tcp", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ServiceName(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ServiceName(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestCompileMatch(t *testing.T) {
+	re, err := CompileMatch("awair|omni")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("AWAIR-ELEM") || !re.MatchString("Omni") {
+		t.Error("match isn't case-insensitive")
+	}
+	if _, err := CompileMatch("awair("); err == nil {
+		t.Error("an invalid pattern compiled")
+	}
+}
+
+// announce sends msg to the listener's query socket, as a device
+// answering a query would.
+func announce(t *testing.T, l *listener, msg *dns.Msg) {
+	t.Helper()
+	buf, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer from.Close()
+	if _, err := from.WriteToUDP(buf, l.query.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runListener runs l as Start does, returning its channel and a function
+// that cancels the run and waits for the channel to close.
+func runListener(t *testing.T, l *listener) (<-chan Device, func()) {
+	t.Helper()
+	l.conns = []*net.UDPConn{l.query}
+	l.interval = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan Device)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(ch)
+		l.run(ctx, ch)
+	}()
+	return ch, func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("run didn't return after the cancel")
+		}
+	}
+}
+
+func TestRunReportsAndStops(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	l := testListener(t, DefaultMatch)
+	ch, stop := runListener(t, l)
+	defer stop()
+
+	complete := response(
+		[]dns.RR{ptr("_http._tcp.local.", elem)},
+		srv(elem, "awair-elem-1a2b3c.local.", 80),
+		a("awair-elem-1a2b3c.local.", "192.168.1.20"),
+	)
+	announce(t, l, complete)
+	select {
+	case dev := <-ch:
+		if dev.IP != "192.168.1.20" {
+			t.Errorf("found %+v", dev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing found")
+	}
+
+	// Announced again, across requeries: not reported again
+	announce(t, l, complete)
+	select {
+	case dev := <-ch:
+		t.Errorf("reported again: %+v", dev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestRunStopsWithDeviceUnread(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	l := testListener(t, DefaultMatch)
+	_, stop := runListener(t, l)
+
+	// Found, but nobody reads the channel: run is stuck sending it, and
+	// the reader stuck passing on the next packet
+	for _, ip := range []string{"192.168.1.20", "192.168.1.21", "192.168.1.22"} {
+		announce(t, l, response(
+			[]dns.RR{srv(elem, "awair-elem-1a2b3c.local.", 80)},
+			a("awair-elem-1a2b3c.local.", ip),
+		))
+	}
+	time.Sleep(50 * time.Millisecond)
+	stop()
+}
+
+func TestStartStopsOnCancel(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	found, err := Start(ctx, Options{Interval: 20 * time.Millisecond, Logf: t.Logf})
+	if err != nil {
+		cancel()
+		t.Skipf("no socket to discover from: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	// Whatever was found is drained; the channel closes once discovery
+	// has stopped
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case _, ok := <-found:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the channel wasn't closed after the cancel")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// goos is the platform the app takes code paths for: runtime.GOOS, except
+// in tests of another platform's paths.
+var goos = runtime.GOOS
+
+// appFilePath returns where one of the app's files lives: dotName in the
+// home directory, or on Windows winName in %AppData%\awair-tui.
+func appFilePath(dotName, winName string) string {
+	if goos == "windows" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			return filepath.Join(dir, "awair-tui", winName)
+		}
+	}
+	return legacyFilePath(dotName)
+}
+
+// legacyFilePath is dotName in the home directory, where every platform
+// kept the app's files before Windows moved to %AppData%.
+func legacyFilePath(dotName string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dotName
+	}
+	return filepath.Join(home, dotName)
+}
+
+// moveLegacyFiles moves the app's files out of the home directory, unless
+// the new location is already in use. On Windows the config and records
+// move to appFilePath; elsewhere the config is copied to configPath and
+// the copy used from then on. Files that can't be moved are left where
+// they are and logged.
+func moveLegacyFiles() {
+	if goos != "windows" {
+		copyLegacyConfig()
+		return
+	}
+	for _, f := range []struct{ dotName, winName string }{
+		{".awair-tui.json", "config.json"},
+		{".awair-tui-records.json", "records.json"},
+	} {
+		from, to := legacyFilePath(f.dotName), appFilePath(f.dotName, f.winName)
+		if from == to {
+			continue
+		}
+		if _, err := os.Stat(to); !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+			logf(levelWarn, "moving %s: %v", from, err)
+			continue
+		}
+		if err := os.Rename(from, to); err != nil {
+			logf(levelWarn, "moving %s: %v", from, err)
+			continue
+		}
+		logf(levelInfo, "moved %s to %s", from, to)
+	}
+}
+
+// copyLegacyConfig copies ~/.awair-tui.json to configPath the first time
+// the app runs with the config in the user config directory. With
+// --config the file given is used as it is.
+func copyLegacyConfig() {
+	from, to := legacyFilePath(".awair-tui.json"), configPath()
+	if configFile != "" || from == to {
+		return
+	}
+	if _, err := os.Stat(to); !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return
+	}
+	if err := writeAppFile(to, data); err != nil {
+		logf(levelWarn, "copying %s: %v", from, err)
+		return
+	}
+	logf(levelInfo, "copied %s to %s, which is used from now on", from, to)
+}
+
+// writeAppFile writes one of the app's files, creating its directory
+// first since e.g. ~/.config/awair-tui may not exist yet. The data goes
+// to a temporary file that then replaces path, so a crash halfway leaves
+// the old file intact.
+func writeAppFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// useGOOS takes platform's code paths for the rest of the test.
+func useGOOS(t *testing.T, platform string) {
+	t.Helper()
+	old := goos
+	t.Cleanup(func() { goos = old })
+	goos = platform
+}
+
+// fakeTools makes PATH hold only the named commands, which do nothing,
+// and returns its directory.
+func fakeTools(t *testing.T, names ...string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return dir
+}
+
+func TestAppFilePath(t *testing.T) {
+	home, config := useDefaultConfig(t)
+
+	useGOOS(t, "linux")
+	if got, want := appFilePath(".awair-tui-records.json", "records.json"), filepath.Join(home, ".awair-tui-records.json"); got != want {
+		t.Errorf("linux: %s, want %s", got, want)
+	}
+	useGOOS(t, "darwin")
+	if got, want := appFilePath(".awair-tui-records.json", "records.json"), filepath.Join(home, ".awair-tui-records.json"); got != want {
+		t.Errorf("darwin: %s, want %s", got, want)
+	}
+	// The user config directory, as os.UserConfigDir finds it here
+	useGOOS(t, "windows")
+	if got, want := appFilePath(".awair-tui-records.json", "records.json"), filepath.Join(config, "awair-tui", "records.json"); got != want {
+		t.Errorf("windows: %s, want %s", got, want)
+	}
+}
+
+func TestNotificationCommand(t *testing.T) {
+	dir := fakeTools(t, "notify-send", "powershell")
+
+	useGOOS(t, "linux")
+	cmd, err := notificationCommand("Office", `CO₂ poor "1500 ppm"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "notify-send"), "--app-name=awair-tui", "Office", `CO₂ poor "1500 ppm"`}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("linux: %q", cmd.Args)
+	}
+
+	useGOOS(t, "darwin")
+	if cmd, err = notificationCommand(`Office \ 2`, `CO₂ poor "1500 ppm"`); err != nil {
+		t.Fatal(err)
+	}
+	want := `display notification "CO₂ poor \"1500 ppm\"" with title "Office \\ 2"`
+	if len(cmd.Args) != 3 || cmd.Args[0] != "osascript" || cmd.Args[2] != want {
+		t.Errorf("darwin: %q", cmd.Args)
+	}
+
+	// Title and body go through the environment, not the script
+	useGOOS(t, "windows")
+	if cmd, err = notificationCommand("Office", `CO₂ poor "1500 ppm"`); err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Args[0] != filepath.Join(dir, "powershell") || cmd.Args[len(cmd.Args)-1] != windowsToastScript {
+		t.Errorf("windows: %q", cmd.Args)
+	}
+	if !slices.Contains(cmd.Env, "AWAIR_TOAST_TITLE=Office") || !slices.Contains(cmd.Env, `AWAIR_TOAST_BODY=CO₂ poor "1500 ppm"`) {
+		t.Errorf("windows: environment %q", cmd.Env[len(cmd.Env)-2:])
+	}
+}
+
+func TestNotificationCommandMissing(t *testing.T) {
+	fakeTools(t)
+	for _, platform := range []string{"linux", "freebsd", "windows"} {
+		useGOOS(t, platform)
+		if _, err := notificationCommand("Office", "CO₂ poor"); !errors.Is(err, errNoNotifier) {
+			t.Errorf("%s: %v, want errNoNotifier", platform, err)
+		}
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	useGOOS(t, "linux")
+	cmd := shellCommand(t.Context(), `echo "$1"`, "a b")
+	if want := []string{"sh", "-c", `echo "$1"`, "sh", "a b"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("linux: %q", cmd.Args)
+	}
+	useGOOS(t, "windows")
+	cmd = shellCommand(t.Context(), "notify.cmd", "a b")
+	if want := []string{"cmd", "/C", "notify.cmd", "a b"}; !slices.Equal(cmd.Args, want) {
+		t.Errorf("windows: %q", cmd.Args)
+	}
+}
+
+func TestOSC52Supported(t *testing.T) {
+	tests := []struct {
+		goos, term, termProgram, wtSession string
+		want                               bool
+	}{
+		{"linux", "xterm-256color", "", "", true},
+		{"linux", "linux", "", "", false},
+		{"linux", "dumb", "", "", false},
+		{"linux", "", "", "", false},
+		{"darwin", "xterm-256color", "Apple_Terminal", "", false},
+		{"darwin", "xterm-256color", "iTerm.app", "", true},
+		// Windows Terminal, not the console host
+		{"windows", "", "", "0b6a5c3e", true},
+		{"windows", "xterm-256color", "", "", false},
+	}
+	for _, tt := range tests {
+		useGOOS(t, tt.goos)
+		t.Setenv("TERM", tt.term)
+		t.Setenv("TERM_PROGRAM", tt.termProgram)
+		t.Setenv("WT_SESSION", tt.wtSession)
+		if got := osc52Supported(); got != tt.want {
+			t.Errorf("%s, TERM=%q, TERM_PROGRAM=%q, WT_SESSION=%q: %v", tt.goos, tt.term, tt.termProgram, tt.wtSession, got)
+		}
+	}
+}
+
+func TestASCIIOnLegacyConsole(t *testing.T) {
+	cfg := &Config{Version: configVersion, Devices: DeviceList{}}
+	s := resolveSettings(cfg, cliFlags{})
+	if s.ASCII != legacyConsole() {
+		t.Errorf("ascii %v, legacy console %v", s.ASCII, legacyConsole())
+	}
+	// The config file wins over detection, either way
+	for _, on := range []bool{true, false} {
+		cfg.ASCII = &on
+		if s := resolveSettings(cfg, cliFlags{}); s.ASCII != on || s.Sources["ascii"] != sourceFile {
+			t.Errorf("ascii %v from %v, want %v from the file", s.ASCII, s.Sources["ascii"], on)
+		}
+	}
+}
+
+func TestMoveLegacyFilesWindows(t *testing.T) {
+	useGOOS(t, "windows")
+	home, config := useDefaultConfig(t)
+	for _, name := range []string{".awair-tui.json", ".awair-tui-records.json"} {
+		writeTestConfig(t, filepath.Join(home, name), `{"from": "`+name+`"}`)
+	}
+	// The records are already in their new place, so the old ones stay
+	dir := filepath.Join(config, "awair-tui")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeTestConfig(t, filepath.Join(dir, "records.json"), `{"from": "new"}`)
+
+	moveLegacyFiles()
+	if data, err := os.ReadFile(filepath.Join(dir, "config.json")); err != nil || string(data) != `{"from": ".awair-tui.json"}` {
+		t.Errorf("config.json: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".awair-tui.json")); err == nil {
+		t.Error("the old config is still there")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "records.json")); string(data) != `{"from": "new"}` {
+		t.Errorf("records.json overwritten with %s", data)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".awair-tui-records.json")); err != nil {
+		t.Errorf("the old records went: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// averagePeriods is the span each averaging endpoint covers.
+var averagePeriods = map[string]time.Duration{
+	awair.Avg10Sec: 10 * time.Second,
+	awair.Avg5Min:  5 * time.Minute,
+	awair.Avg15Min: 15 * time.Minute,
+}
+
+// pollEndpoints are the endpoints devices can be polled at
+// (--poll-endpoint, or "endpoint" on a saved device).
+var pollEndpoints = []string{awair.Latest, awair.Avg10Sec, awair.Avg5Min}
+
+// validPollEndpoint reports whether e is one of pollEndpoints.
+func validPollEndpoint(e string) bool {
+	return slices.Contains(pollEndpoints, e)
+}
+
+// pollEndpointList lists pollEndpoints for messages.
+func pollEndpointList() string {
+	return strings.Join(pollEndpoints, ", ")
+}
+
+// FetchAirDataFrom is FetchAirData at the given endpoint. Firmware
+// answers averages as one reading or as a list; from a list the newest
+// is returned.
+func FetchAirDataFrom(ctx context.Context, ip, endpoint string) (*awair.SensorData, error) {
+	if isCloud(ip) || isDemo(ip) || endpoint == awair.Latest {
+		return FetchAirData(ctx, ip)
+	}
+	return deviceClient(ip).AirDataAt(ctx, endpoint)
+}
+
+// endpointFallback switches dev to /air-data/latest after its firmware
+// turned out not to serve the endpoint it was polled at, and says so.
+func (m *model) endpointFallback(dev *Device) {
+	if dev.endpoint == awair.Latest {
+		return
+	}
+	m.logAt(levelWarn, fmt.Sprintf("%s: firmware doesn't serve /air-data/%s; polling /air-data/latest instead", dev.Name, dev.endpoint))
+	dev.endpoint = awair.Latest
+}
+
+// endpointText is the card footer's note of an averaging endpoint, or
+// "" for the latest sample.
+func endpointText(dev *Device) string {
+	if dev.endpoint == awair.Latest {
+		return ""
+	}
+	return lipgloss.NewStyle().Foreground(theme.Muted).Render(" · " + strings.TrimSuffix(dev.endpoint, "-avg") + " avg")
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Record is an extreme reading and when it was seen.
+type Record struct {
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// DeviceRecords holds the lifetime extremes for one device. Temperatures
+// are stored in °C like the API reports them.
+type DeviceRecords struct {
+	MaxCO2  *Record `json:"max_co2,omitempty"`
+	MaxPM25 *Record `json:"max_pm25,omitempty"`
+	MinTemp *Record `json:"min_temp,omitempty"`
+	MaxTemp *Record `json:"max_temp,omitempty"`
+}
+
+// RecordStore holds lifetime records for all devices, keyed by device UUID
+// (or IP for devices whose config was never fetched).
+type RecordStore struct {
+	Devices map[string]*DeviceRecords `json:"devices"`
+	// Cloud counts each cloud device's calls today, by device key, so a
+	// restart doesn't start its quota over.
+	Cloud map[string]*CloudUsage `json:"cloud,omitempty"`
+
+	dirty  bool // changed since the last save
+	saving bool // a saveRecordsCmd is writing
+	memory bool // never saved, with --demo or if the file didn't load
+	gen    int  // encodings so far
+
+	// writeMu orders the writes, so a background save that finishes late
+	// can't overwrite what quit saved; written is the newest gen on disk.
+	writeMu sync.Mutex
+	written int
+}
+
+// recordsPath is ~/.awair-tui-records.json, or
+// %AppData%\awair-tui\records.json on Windows.
+func recordsPath() string {
+	return appFilePath(".awair-tui-records.json", "records.json")
+}
+
+// LoadRecords reads lifetime records from ~/.awair-tui-records.json.
+// A missing file is an empty store. If the file can't be read or parsed,
+// the error is returned with an empty store that is never saved, so the
+// file is left for the user to fix rather than overwritten.
+func LoadRecords() (*RecordStore, error) {
+	store := &RecordStore{Devices: make(map[string]*DeviceRecords)}
+
+	data, err := os.ReadFile(recordsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		store.memory = true
+		return store, err
+	}
+
+	var parsed RecordStore
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		store.memory = true
+		return store, fmt.Errorf("%s: %w", recordsPath(), err)
+	}
+	if parsed.Devices != nil {
+		store.Devices = parsed.Devices
+	}
+	store.Cloud = parsed.Cloud
+	return store, nil
+}
+
+// encode returns the store as saved and its generation for write, and
+// marks it saved. ok is false if it hasn't changed since the last save or
+// is never saved.
+func (s *RecordStore) encode() (data []byte, gen int, ok bool) {
+	if !s.dirty || s.memory {
+		return nil, 0, false
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		logf(levelError, "saving records: %v", err)
+		return nil, 0, false
+	}
+	s.dirty = false
+	s.gen++
+	return append(data, '\n'), s.gen, true
+}
+
+// write saves data encoded as generation gen, unless something newer has
+// been saved already. It is safe to call from any goroutine.
+func (s *RecordStore) write(data []byte, gen int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if gen <= s.written {
+		return nil
+	}
+	if err := writeAppFile(recordsPath(), data); err != nil {
+		return err
+	}
+	s.written = gen
+	return nil
+}
+
+// SaveRecords writes the store to ~/.awair-tui-records.json if it changed,
+// before returning, as on quit. Errors are logged and leave the store to
+// be saved again.
+func SaveRecords(store *RecordStore) {
+	data, gen, ok := store.encode()
+	if !ok {
+		return
+	}
+	if err := store.write(data, gen); err != nil {
+		logf(levelError, "saving records: %v", err)
+		store.dirty = true
+	}
+}
+
+// recordsSavedMsg reports a background save of the records.
+type recordsSavedMsg struct {
+	Err error
+}
+
+// saveRecordsCmd saves the store in the background if it changed, so the
+// update loop doesn't wait on the disk. The store is encoded right away,
+// as it is only safe to read from Update. One save runs at a time; a
+// change made meanwhile is saved by the next call.
+func saveRecordsCmd(store *RecordStore) tea.Cmd {
+	if store.saving {
+		return nil
+	}
+	data, gen, ok := store.encode()
+	if !ok {
+		return nil
+	}
+	store.saving = true
+	return trackCmd("save records", func() tea.Msg {
+		return recordsSavedMsg{Err: store.write(data, gen)}
+	})
+}
+
+// handleRecordsSaved logs a failed save and leaves the records to be
+// saved again on the next tick.
+func (m *model) handleRecordsSaved(msg recordsSavedMsg) {
+	m.records.saving = false
+	if msg.Err != nil {
+		logf(levelError, "saving records: %v", msg.Err)
+		m.records.dirty = true
+	}
+}
+
+// Update folds a sample into the records for id. Implausible readings are
+// skipped so a single glitch can't become a permanent record.
+func (s *RecordStore) Update(id string, d *awair.SensorData, t time.Time) {
+	r := s.Devices[id]
+	if r == nil {
+		r = &DeviceRecords{}
+		s.Devices[id] = r
+	}
+
+	higher := func(rec **Record, key string, v float64) {
+		if !awair.Plausible(key, v) {
+			return
+		}
+		if *rec == nil || v > (*rec).Value {
+			*rec = &Record{Value: v, Time: t}
+			s.dirty = true
+		}
+	}
+	lower := func(rec **Record, key string, v float64) {
+		if !awair.Plausible(key, v) {
+			return
+		}
+		if *rec == nil || v < (*rec).Value {
+			*rec = &Record{Value: v, Time: t}
+			s.dirty = true
+		}
+	}
+
+	if d.Reported("co2") {
+		higher(&r.MaxCO2, "co2", d.CO2)
+	}
+	if d.Reported("pm25") {
+		higher(&r.MaxPM25, "pm25", d.PM25)
+	}
+	if d.Reported("temp") {
+		lower(&r.MinTemp, "temp", d.Temp)
+		higher(&r.MaxTemp, "temp", d.Temp)
+	}
+}
+
+// Rekey moves the records stored under from to to, keeping the more
+// extreme value where both keys have a record. It is used when a device's
+// UUID becomes known after samples were recorded under its IP.
+func (s *RecordStore) Rekey(from, to string) {
+	old, ok := s.Devices[from]
+	if !ok || from == to {
+		return
+	}
+	delete(s.Devices, from)
+	s.dirty = true
+
+	cur := s.Devices[to]
+	if cur == nil {
+		s.Devices[to] = old
+		return
+	}
+	pick := func(a, b *Record, higher bool) *Record {
+		switch {
+		case a == nil:
+			return b
+		case b == nil:
+			return a
+		case higher == (a.Value > b.Value):
+			return a
+		default:
+			return b
+		}
+	}
+	cur.MaxCO2 = pick(cur.MaxCO2, old.MaxCO2, true)
+	cur.MaxPM25 = pick(cur.MaxPM25, old.MaxPM25, true)
+	cur.MinTemp = pick(cur.MinTemp, old.MinTemp, false)
+	cur.MaxTemp = pick(cur.MaxTemp, old.MaxTemp, true)
+}
+
+// Reset forgets all records for id.
+func (s *RecordStore) Reset(id string) {
+	if _, ok := s.Devices[id]; ok {
+		delete(s.Devices, id)
+		s.dirty = true
+	}
+}
+
+// recordKey returns the key a device's records are stored under.
+func recordKey(dev *Device) string {
+	if dev.UUID != "" {
+		return dev.UUID
+	}
+	if dev.Config != nil && dev.Config.DeviceUUID != "" {
+		return dev.Config.DeviceUUID
+	}
+	return dev.IP
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// loadTestRecords loads the saved records, failing the test if they
+// don't load.
+func loadTestRecords(t *testing.T) *RecordStore {
+	t.Helper()
+	store, err := LoadRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSaveRecordsCmd(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	store := &RecordStore{Devices: make(map[string]*DeviceRecords)}
+	if cmd := saveRecordsCmd(store); cmd != nil {
+		t.Fatal("saving an unchanged store")
+	}
+
+	store.Update("awair-element_1", &awair.SensorData{CO2: 900, PM25: 5, Temp: 21}, time.Now())
+	cmd := saveRecordsCmd(store)
+	if cmd == nil {
+		t.Fatal("no save after a change")
+	}
+	// Only one save at a time
+	store.Update("awair-element_1", &awair.SensorData{CO2: 1200, PM25: 5, Temp: 21}, time.Now())
+	if saveRecordsCmd(store) != nil {
+		t.Error("a second save started while the first runs")
+	}
+
+	msg, ok := cmd().(recordsSavedMsg)
+	if !ok || msg.Err != nil {
+		t.Fatalf("save: %#v", msg)
+	}
+	m := model{records: store}
+	m.handleRecordsSaved(msg)
+	if store.saving {
+		t.Error("still saving")
+	}
+	if got := loadTestRecords(t).Devices["awair-element_1"].MaxCO2.Value; got != 900 {
+		t.Errorf("saved max CO₂ %v, want 900", got)
+	}
+
+	// The change made meanwhile goes out with the next save
+	if cmd := saveRecordsCmd(store); cmd == nil {
+		t.Error("the change made during the save isn't saved")
+	} else {
+		m.handleRecordsSaved(cmd().(recordsSavedMsg))
+	}
+	if got := loadTestRecords(t).Devices["awair-element_1"].MaxCO2.Value; got != 1200 {
+		t.Errorf("saved max CO₂ %v, want 1200", got)
+	}
+}
+
+func TestRecordsLateWriteIsDropped(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	store := &RecordStore{Devices: make(map[string]*DeviceRecords)}
+	store.Update("a", &awair.SensorData{CO2: 900}, time.Now())
+	old, oldGen, _ := store.encode()
+	store.Update("a", &awair.SensorData{CO2: 1500}, time.Now())
+	SaveRecords(store) // on quit, while the older save is still running
+
+	if err := store.write(old, oldGen); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadTestRecords(t).Devices["a"].MaxCO2.Value; got != 1500 {
+		t.Errorf("saved max CO₂ %v, want 1500", got)
+	}
+}
+
+func TestLoadRecordsInvalid(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	if store, err := LoadRecords(); err != nil || len(store.Devices) != 0 {
+		t.Fatalf("no file: %v, %d devices", err, len(store.Devices))
+	}
+
+	// A file that doesn't parse is kept as it is, whatever is recorded
+	broken := `{"devices": {"awair-element_1": {"max_co2": {"value": 1500,`
+	if err := os.WriteFile(recordsPath(), []byte(broken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := LoadRecords()
+	if err == nil {
+		t.Fatal("loaded a half-written file")
+	}
+	store.Update("awair-element_1", &awair.SensorData{CO2: 900}, time.Now())
+	if saveRecordsCmd(store) != nil {
+		t.Error("saving over the file that didn't load")
+	}
+	SaveRecords(store)
+	if data, _ := os.ReadFile(recordsPath()); string(data) != broken {
+		t.Errorf("records overwritten with %s", data)
+	}
+}
+
+func TestRecordKey(t *testing.T) {
+	dev := &Device{IP: "192.0.2.1"}
+	if got := recordKey(dev); got != "192.0.2.1" {
+		t.Errorf("no UUID: %q", got)
+	}
+	// From the saved entry, before the config arrives or without it
+	dev.UUID = "awair-element_1"
+	if got := recordKey(dev); got != "awair-element_1" {
+		t.Errorf("saved UUID: %q", got)
+	}
+	dev.UUID = ""
+	dev.Config = &awair.DeviceConfig{DeviceUUID: "awair-element_2"}
+	if got := recordKey(dev); got != "awair-element_2" {
+		t.Errorf("config UUID: %q", got)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
+)
+
+// Subnet scans (--scan, S) probe every address in a range for the device
+// config endpoint, for networks where mDNS doesn't get through.
+const (
+	scanConcurrency  = 64 // probes in flight
+	scanProbeTimeout = time.Second
+
+	// Ranges with more hosts than scanConfirmHosts (a /22) are confirmed
+	// first in the dashboard and refused on the command line; anything
+	// beyond a /16 is refused outright.
+	scanConfirmHosts = 1022
+	scanMaxHosts     = 1<<16 - 2
+)
+
+// parseScanRange parses an IPv4 range in CIDR notation, such as
+// 192.168.1.0/24. Host bits are ignored.
+func parseScanRange(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("%q is not a range like 192.168.1.0/24", s)
+	}
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, errors.New("only IPv4 ranges can be scanned")
+	}
+	p = p.Masked()
+	if n := scanHostCount(p); n > scanMaxHosts {
+		return netip.Prefix{}, fmt.Errorf("%s has %d addresses; scan a /16 or smaller", p, n)
+	}
+	return p, nil
+}
+
+// scanHostCount is the number of addresses scanHosts returns for p.
+func scanHostCount(p netip.Prefix) int {
+	n := 1 << (32 - p.Bits())
+	if p.Bits() < 31 {
+		n -= 2 // network and broadcast
+	}
+	return n
+}
+
+// scanHosts returns the host addresses in p.
+func scanHosts(p netip.Prefix) []string {
+	hosts := make([]string, 0, scanHostCount(p))
+	for a := p.Addr(); p.Contains(a); a = a.Next() {
+		hosts = append(hosts, a.String())
+	}
+	if p.Bits() < 31 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts
+}
+
+// scanDuration estimates how long scanning n hosts takes when most don't
+// answer.
+func scanDuration(n int) time.Duration {
+	return time.Duration((n+scanConcurrency-1)/scanConcurrency) * scanProbeTimeout
+}
+
+// localSubnet guesses the range to scan: the /24 (or smaller network)
+// around this machine's first private IPv4 address, or "" if it has none.
+func localSubnet() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil || !ipnet.IP.IsPrivate() {
+			continue
+		}
+		ip, _ := netip.AddrFromSlice(ipnet.IP.To4())
+		ones, _ := ipnet.Mask.Size()
+		return netip.PrefixFrom(ip, max(ones, 24)).Masked().String()
+	}
+	return ""
+}
+
+// probeAwair reports whether an Awair device answers at ip: its config
+// endpoint returns JSON with a device UUID.
+func probeAwair(ctx context.Context, ip string) (*awair.DeviceConfig, bool) {
+	ctx, cancel := context.WithTimeout(ctx, scanProbeTimeout)
+	defer cancel()
+	// One attempt: a scan probes hundreds of addresses
+	c := deviceClient(ip)
+	c.Retries = 0
+	cfg, err := c.Config(ctx)
+	if err != nil || cfg.DeviceUUID == "" {
+		return nil, false
+	}
+	return cfg, true
+}
+
+// scanMsg reports a device found by a scan, or its progress. The last one
+// has Done set.
+type scanMsg struct {
+	Found   *discovery.Device
+	Scanned int
+	Total   int
+	Done    bool
+
+	next <-chan scanMsg // the rest of the scan
+}
+
+// scanRequestMsg starts a scan of Range, for --scan.
+type scanRequestMsg struct {
+	Range netip.Prefix
+}
+
+// runScan probes the hosts in p, at most scanConcurrency at a time, and
+// sends what it finds on the returned channel along with progress at
+// each quarter. The channel is closed once the scan is over or ctx is
+// cancelled and every probe has returned.
+func runScan(ctx context.Context, p netip.Prefix) <-chan scanMsg {
+	ch := make(chan scanMsg)
+	hosts := scanHosts(p)
+	send := func(msg scanMsg) {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		jobs := make(chan string)
+		var scanned atomic.Int64
+		var wg sync.WaitGroup
+		for range min(scanConcurrency, len(hosts)) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for ip := range jobs {
+					if cfg, ok := probeAwair(ctx, ip); ok {
+						send(scanMsg{Found: &discovery.Device{Name: cfg.DeviceUUID, IP: ip, Port: defaultPort}})
+					}
+					n := int(scanned.Add(1))
+					if n < len(hosts) && n*4/len(hosts) != (n-1)*4/len(hosts) {
+						send(scanMsg{Scanned: n, Total: len(hosts)})
+					}
+				}
+			}()
+		}
+	feed:
+		for _, ip := range hosts {
+			select {
+			case jobs <- ip:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		send(scanMsg{Scanned: int(scanned.Load()), Total: len(hosts), Done: true})
+	}()
+	return ch
+}
+
+// nextScanCmd waits for the next message of a scan.
+func nextScanCmd(name string, ch <-chan scanMsg) tea.Cmd {
+	return trackCmd("scan "+name, func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			// Cancelled; the model has already moved on
+			return nil
+		}
+		msg.next = ch
+		return msg
+	})
+}
+
+// startScan starts scanning p unless a scan is already running.
+func (m *model) startScan(p netip.Prefix) tea.Cmd {
+	if m.scanning != "" {
+		m.addLog("Already scanning " + m.scanning)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.scanning, m.cancelScan, m.scanFound = p.String(), cancel, 0
+	n := scanHostCount(p)
+	m.addLog(fmt.Sprintf("Scanning %s (%d addresses, about %s)", m.scanning, n, shortDuration(scanDuration(n))))
+	return nextScanCmd(m.scanning, runScan(ctx, p))
+}
+
+// confirmScan starts scanning p, asking first if it is large.
+func (m *model) confirmScan(p netip.Prefix) tea.Cmd {
+	n := scanHostCount(p)
+	if n <= scanConfirmHosts {
+		return m.startScan(p)
+	}
+	m.confirm = &confirmPrompt{
+		question: fmt.Sprintf("Scan all %d addresses in %s? It takes about %s.", n, p, shortDuration(scanDuration(n))),
+		onYes: func(m *model) tea.Cmd {
+			return m.startScan(p)
+		},
+	}
+	return nil
+}
+
+func (m *model) handleScan(msg scanMsg) tea.Cmd {
+	switch {
+	case msg.Found != nil:
+		m.scanFound++
+		cmd := m.handleDiscovered(*msg.Found)
+		return tea.Batch(cmd, nextScanCmd(m.scanning, msg.next))
+	case msg.Done:
+		m.addLog(fmt.Sprintf("Scan of %s done: %d device(s) found", m.scanning, m.scanFound))
+		m.cancelScan()
+		m.scanning, m.cancelScan = "", nil
+		return nil
+	default:
+		m.addLog(fmt.Sprintf("Scanned %d/%d", msg.Scanned, msg.Total))
+		return nextScanCmd(m.scanning, msg.next)
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// apiShutdownTimeout is how long requests in flight get to finish when
+// the app quits.
+const apiShutdownTimeout = 2 * time.Second
+
+const (
+	// sseHeartbeat is how often an idle GET /events stream gets a comment,
+	// so proxies don't close it.
+	sseHeartbeat = 15 * time.Second
+	// sseBuffer is how many events a GET /events client may fall behind
+	// before it is dropped.
+	sseBuffer = 32
+)
+
+// apiServer serves the dashboard's devices as JSON over HTTP (--serve), so
+// other programs can read them without polling the devices themselves.
+// The model is copied on every update, so Update publishes a snapshot
+// here and the handlers only ever read that.
+type apiServer struct {
+	srv *http.Server
+	ln  net.Listener
+
+	mu      sync.RWMutex
+	devices []apiDevice // in dashboard order
+
+	subsMu sync.Mutex
+	subs   map[chan []byte]struct{} // GET /events clients
+	done   chan struct{}            // closed by Close, ending the streams
+}
+
+// apiDevice is a device as the API serves it. Data and Config are the
+// device's own payloads, temperatures in °C.
+type apiDevice struct {
+	IP          string            `json:"ip"`
+	Name        string            `json:"name"`
+	UUID        string            `json:"uuid,omitempty"`
+	Model       string            `json:"model,omitempty"`
+	Status      string            `json:"status"` // ok, error, offline or pending
+	Error       string            `json:"error,omitempty"`
+	TempUnit    string            `json:"temp_unit"`
+	Data        *awair.SensorData `json:"data"`
+	SampleTime  *time.Time        `json:"sample_time,omitempty"` // by the device's clock
+	LastContact *time.Time        `json:"last_contact,omitempty"`
+	LatencyMS   float64           `json:"latency_ms,omitempty"`
+
+	// Only in GET /devices/{ip}
+	Config      *awair.DeviceConfig `json:"config,omitempty"`
+	ErrorDetail string              `json:"error_detail,omitempty"`
+	Failures    int                 `json:"failures,omitempty"` // consecutive
+	Endpoint    string              `json:"endpoint,omitempty"`
+}
+
+// apiReading is the data of a reading event on GET /events.
+type apiReading struct {
+	IP         string            `json:"ip"`
+	Name       string            `json:"name"`
+	UUID       string            `json:"uuid,omitempty"`
+	Data       *awair.SensorData `json:"data"`
+	SampleTime *time.Time        `json:"sample_time,omitempty"`
+	LatencyMS  float64           `json:"latency_ms"`
+}
+
+// validServeAddr checks an address to serve the API at, host:port with
+// the host optional.
+func validServeAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("%q is not an address like :8080 or 127.0.0.1:8080", addr)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return fmt.Errorf("%q is not a port", port)
+	}
+	return nil
+}
+
+// startAPIServer listens at addr and serves the API in the background.
+func startAPIServer(addr string) (*apiServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	a := &apiServer{ln: ln, subs: map[chan []byte]struct{}{}, done: make(chan struct{})}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /devices", a.handleDevices)
+	// Keys of cloud devices contain a slash
+	mux.HandleFunc("GET /devices/{ip...}", a.handleDevice)
+	mux.HandleFunc("GET /healthz", a.handleHealth)
+	mux.HandleFunc("GET /events", a.handleEvents)
+	a.srv = &http.Server{
+		Handler:           logRequests(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := a.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logf(levelError, "api: %v", err)
+		}
+	}()
+	return a, nil
+}
+
+// URL is where the API is served, for the log.
+func (a *apiServer) URL() string {
+	return "http://" + a.ln.Addr().String()
+}
+
+// Close stops the server, ending event streams and letting other
+// requests in flight finish.
+func (a *apiServer) Close() {
+	close(a.done)
+	ctx, cancel := context.WithTimeout(context.Background(), apiShutdownTimeout)
+	defer cancel()
+	if err := a.srv.Shutdown(ctx); err != nil {
+		logf(levelWarn, "api: shutting down: %v", err)
+	}
+}
+
+// publish replaces the devices served.
+func (a *apiServer) publish(devices []apiDevice) {
+	a.mu.Lock()
+	a.devices = devices
+	a.mu.Unlock()
+}
+
+// snapshot returns the devices served. The slice is never modified, only
+// replaced.
+func (a *apiServer) snapshot() []apiDevice {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.devices
+}
+
+// broadcast sends an event to every GET /events client. It never blocks:
+// a client whose buffer is full is dropped, and can reconnect.
+func (a *apiServer) broadcast(event string, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logf(levelError, "api: encoding %s event: %v", event, err)
+		return
+	}
+	msg := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", event, data))
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	for ch := range a.subs {
+		select {
+		case ch <- msg:
+		default:
+			delete(a.subs, ch)
+			close(ch)
+			logf(levelDebug, "api: dropped an event stream that fell %d events behind", sseBuffer)
+		}
+	}
+}
+
+// subscribe adds an event stream client; unsubscribe removes it unless
+// broadcast already dropped it.
+func (a *apiServer) subscribe() chan []byte {
+	ch := make(chan []byte, sseBuffer)
+	a.subsMu.Lock()
+	a.subs[ch] = struct{}{}
+	a.subsMu.Unlock()
+	return ch
+}
+
+func (a *apiServer) unsubscribe(ch chan []byte) {
+	a.subsMu.Lock()
+	if _, ok := a.subs[ch]; ok {
+		delete(a.subs, ch)
+		close(ch)
+	}
+	a.subsMu.Unlock()
+}
+
+// publishReading sends a device's new reading to the event streams.
+func (a *apiServer) publishReading(dev *Device) {
+	r := apiReading{IP: dev.IP, Name: dev.Name, UUID: dev.UUID, Data: dev.Data, LatencyMS: latencyMS(dev.Latency.Last)}
+	if !dev.SampleTime.IsZero() {
+		t := dev.SampleTime
+		r.SampleTime = &t
+	}
+	a.broadcast("reading", r)
+}
+
+// apiDevices is the devices as the API serves them, in dashboard order.
+func (m *model) apiDevices() []apiDevice {
+	var out []apiDevice
+	for _, dev := range m.orderedDevices() {
+		out = append(out, newAPIDevice(dev))
+	}
+	return out
+}
+
+func newAPIDevice(dev *Device) apiDevice {
+	d := apiDevice{
+		IP:       dev.IP,
+		Name:     dev.Name,
+		UUID:     dev.UUID,
+		Status:   "ok",
+		TempUnit: "C",
+		Data:     dev.Data,
+		Config:   dev.Config,
+		Failures: dev.Failures,
+		Endpoint: dev.endpoint,
+	}
+	if dev.Config != nil {
+		d.Model = dev.Config.Model()
+	}
+	switch {
+	case dev.Offline():
+		d.Status = "offline"
+	case dev.LastError != nil:
+		d.Status = "error"
+	case dev.Data == nil:
+		d.Status = "pending"
+	}
+	if dev.LastError != nil {
+		d.Error = errorSummary(dev.LastError)
+		d.ErrorDetail = dev.LastError.Error()
+	}
+	if !dev.SampleTime.IsZero() {
+		t := dev.SampleTime
+		d.SampleTime = &t
+	}
+	if !dev.LastUpdate.IsZero() {
+		t := dev.LastUpdate
+		d.LastContact = &t
+	}
+	if dev.Latency.Last > 0 {
+		d.LatencyMS = latencyMS(dev.Latency.Last)
+	}
+	return d
+}
+
+// handleDevices serves GET /devices: every device with its latest reading
+// and status.
+func (a *apiServer) handleDevices(w http.ResponseWriter, r *http.Request) {
+	devices := a.snapshot()
+	list := make([]apiDevice, 0, len(devices))
+	for _, d := range devices {
+		d.Config, d.ErrorDetail, d.Failures, d.Endpoint = nil, "", 0, ""
+		list = append(list, d)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// handleDevice serves GET /devices/{ip}: one device in full. It can be
+// named by its address or UUID.
+func (a *apiServer) handleDevice(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("ip")
+	ip := canonicalIP(key)
+	for _, d := range a.snapshot() {
+		if d.IP == ip || (d.UUID != "" && d.UUID == key) {
+			writeJSON(w, http.StatusOK, d)
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no device %q", key)})
+}
+
+// handleHealth serves GET /healthz: 503 when there are devices and every
+// one of them failed its last poll.
+func (a *apiServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	devices := a.snapshot()
+	failing := 0
+	for _, d := range devices {
+		if d.Status == "error" || d.Status == "offline" {
+			failing++
+		}
+	}
+	status, code := "ok", http.StatusOK
+	if len(devices) > 0 && failing == len(devices) {
+		status, code = "failing", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, struct {
+		Status  string `json:"status"`
+		Devices int    `json:"devices"`
+		Failing int    `json:"failing"`
+	}{status, len(devices), failing})
+}
+
+// handleEvents serves GET /events: a Server-Sent Events stream with a
+// reading event for every new sample from any device, and a comment
+// every sseHeartbeat while it is quiet.
+func (a *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming is not supported"})
+		return
+	}
+	ch := a.subscribe()
+	defer a.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var msg []byte
+		select {
+		case msg, ok = <-ch:
+			if !ok {
+				return // too slow, dropped by broadcast
+			}
+		case <-heartbeat.C:
+			msg = []byte(": ping\n\n")
+		case <-r.Context().Done():
+			return
+		case <-a.done:
+			return
+		}
+		if _, err := w.Write(msg); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		logf(levelDebug, "api: writing response: %v", err)
+	}
+}
+
+// logRequests logs each API request at debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logf(levelDebug, "api: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,832 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
+)
+
+// Built-in defaults for settings that can come from flags or the config.
+const (
+	defaultInterval = 10
+
+	// defaultMaxDiscovered is the default limit on discovered devices that
+	// are added to the dashboard without asking.
+	defaultMaxDiscovered = 12
+)
+
+// Where a setting's effective value came from, as reported by --print-config.
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+// cliFlags holds the parsed command-line values that feed into Settings.
+type cliFlags struct {
+	Interval           int
+	Fahrenheit         bool
+	NoDiscovery        bool
+	RememberDiscovered bool
+	MaxDiscovered      int
+	Mini               bool
+	ASCII              bool
+	NoColor            bool
+	NoConfigFetch      bool
+	ShowFirmware       bool
+	Notify             bool
+	NotifyRecovery     bool
+	NotifyCooldown     time.Duration
+	NoBell             bool
+	NoFlash            bool
+	NoMouse            bool
+	Demo               int
+	AlertWebhook       string
+	AlertExec          string
+	SmoothScore        int
+	SmoothMode         string
+	Theme              string
+	HTTPTimeout        time.Duration
+	HTTPRetries        int
+	SlowLatency        time.Duration
+	MaxClockSkew       time.Duration
+	ExportTime         string
+	CloudToken         string
+	HistoryEndpoint    string
+	PollEndpoint       string
+	DB                 string
+	DBRetain           string
+	DBRaw              string
+	Serve              string
+	DiscoveryServices  string // comma-separated
+	DiscoveryMatch     string
+	DiscoveryInterval  time.Duration
+	Interface          string
+	LogFile            string
+	LogLevel           string
+	IPs                []string
+
+	set map[string]bool // flag names passed explicitly
+}
+
+// isSet reports whether any of the named flags (e.g. a flag and its
+// shorthand) was passed on the command line.
+func (f cliFlags) isSet(names ...string) bool {
+	for _, n := range names {
+		if f.set[n] {
+			return true
+		}
+	}
+	return false
+}
+
+// visitedFlags returns the names of the flags that were explicitly set.
+func visitedFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Settings is the effective configuration, merged from built-in defaults,
+// the config file, the environment and command-line flags (in increasing
+// order of precedence).
+type Settings struct {
+	Interval      int
+	Fahrenheit    bool
+	NoDiscovery   bool
+	MaxDiscovered int
+	SlowTerminal  bool
+	ASCII         bool // draw bars, charts and borders with ASCII, see glyphs.go
+	NoColor       bool // no colors at all, see configureTheme
+
+	// RememberDiscovered saves discovered devices to the config and adds
+	// them at startup.
+	RememberDiscovered bool
+
+	// When a sensor turns poor, ring the bell and highlight the card.
+	Bell              bool
+	Flash             bool
+	Mouse             bool // clicks and the wheel; off keeps native text selection
+	FetchDeviceConfig bool
+	ShowFirmware      bool // firmware version in card footers
+	Mini              bool
+	IPs               []string
+
+	// Demo is how many simulated devices to show instead of the saved
+	// ones; see demo.go. Discovery is off and nothing is saved then.
+	Demo int
+
+	// Device requests: timeout per attempt, and retries of transient
+	// failures.
+	HTTPTimeout time.Duration
+	HTTPRetries int
+
+	// SlowLatency is the poll response time above which cards show the
+	// latency as slow.
+	SlowLatency time.Duration
+
+	// MaxClockSkew is how far a device's clock may be off before its card
+	// warns. ExportTime is the time exported readings carry: when they
+	// were received, or the device's timestamp.
+	MaxClockSkew time.Duration
+	ExportTime   string
+
+	// CloudToken is the Awair developer API token, or "" for local
+	// devices only.
+	CloudToken string
+
+	// DB is the reading database, or "" for none; readings older than
+	// DBRetain are purged from it (0 keeps them), and those older than
+	// DBRaw compacted (0 never).
+	DB       string
+	DBRetain time.Duration
+	DBRaw    time.Duration
+
+	// Serve is the address the HTTP API listens on, or "" for none.
+	Serve string
+
+	// PollEndpoint is the air data endpoint devices are polled at unless
+	// their saved entry says otherwise.
+	PollEndpoint string
+
+	// HistoryEndpoint is the averages endpoint that seeds a new device's
+	// history, or historyOff.
+	HistoryEndpoint string
+
+	// mDNS discovery: the service types queried, the pattern instance
+	// names or TXT records must match, and how often to query again.
+	DiscoveryServices []string
+	DiscoveryMatch    string
+	DiscoveryInterval time.Duration
+
+	// Interface is the network interface mDNS uses, or "" for the
+	// system's choice. Flags only.
+	Interface string
+
+	// Score smoothing for cards and the header: the median or mean of
+	// the last SmoothScore samples. 0 or 1 shows the raw score.
+	SmoothScore int
+	SmoothMode  string
+
+	// When device details advise on mold risk and ventilation. Config
+	// only.
+	Advisories AdvisoryRules
+
+	// Theme names one of themes; ThemeColors overrides its colors by
+	// "theme_colors" key (config only, validated).
+	Theme       string
+	ThemeColors map[string]string
+
+	// CardSensors are the sensor keys grid cards show, in order; nil
+	// shows all of them. Config only.
+	CardSensors []string
+
+	// Desktop notifications (--notify), flags only.
+	Notify         bool
+	NotifyRecovery bool
+	NotifyCooldown time.Duration
+
+	// Where else to report sensors turning poor and recovering: a URL
+	// to post to and a shell command to run. Flags only.
+	AlertWebhook string
+	AlertExec    string
+
+	// Templates for what those send: the webhook body and the command's
+	// arguments. Config only; see alertTemplates.
+	AlertWebhookTemplate string
+	AlertExecArgs        []string
+
+	// Sources maps each setting's JSON name to where its value came from.
+	Sources map[string]string
+}
+
+// redactToken stands in for a token in printed settings.
+func redactToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	return "xxxxx"
+}
+
+// resolveSettings merges cfg and the command-line flags into Settings.
+// This is the only place that decides precedence between them.
+func resolveSettings(cfg *Config, fl cliFlags) Settings {
+	s := Settings{
+		Interval:          defaultInterval,
+		MaxDiscovered:     defaultMaxDiscovered,
+		FetchDeviceConfig: true,
+		HTTPTimeout:       defaultHTTPTimeout,
+		SlowLatency:       defaultSlowLatency,
+		MaxClockSkew:      defaultMaxClockSkew,
+		ExportTime:        exportTimeReceived,
+		HistoryEndpoint:   defaultHistoryEndpoint,
+		PollEndpoint:      awair.Latest,
+		DBRetain:          defaultDBRetain,
+		DBRaw:             defaultDBRaw,
+		DiscoveryServices: []string{discovery.DefaultService},
+		DiscoveryMatch:    discovery.DefaultMatch,
+		DiscoveryInterval: discovery.DefaultInterval,
+		NotifyCooldown:    defaultNotifyCooldown,
+		SmoothMode:        smoothMedian,
+		Theme:             defaultTheme,
+		Bell:              true,
+		Flash:             true,
+		Mouse:             true,
+		Advisories: AdvisoryRules{
+			MoldAfter:      defaultMoldMinutes * time.Minute,
+			VentilateCO2:   defaultVentilateCO2,
+			VentilateAfter: defaultVentilateMinutes * time.Minute,
+		},
+		Sources: map[string]string{
+			"interval":               sourceDefault,
+			"fahrenheit":             sourceDefault,
+			"no_discovery":           sourceDefault,
+			"remember_discovered":    sourceDefault,
+			"max_discovered":         sourceDefault,
+			"slow_terminal":          sourceDefault,
+			"ascii":                  sourceDefault,
+			"no_color":               sourceDefault,
+			"bell":                   sourceDefault,
+			"flash":                  sourceDefault,
+			"mouse":                  sourceDefault,
+			"mini":                   sourceDefault,
+			"fetch_device_config":    sourceDefault,
+			"show_firmware":          sourceDefault,
+			"http_timeout":           sourceDefault,
+			"http_retries":           sourceDefault,
+			"slow_latency":           sourceDefault,
+			"max_clock_skew":         sourceDefault,
+			"export_time":            sourceDefault,
+			"cloud_token":            sourceDefault,
+			"history_endpoint":       sourceDefault,
+			"poll_endpoint":          sourceDefault,
+			"db":                     sourceDefault,
+			"db_retain":              sourceDefault,
+			"db_raw":                 sourceDefault,
+			"serve":                  sourceDefault,
+			"discovery_services":     sourceDefault,
+			"discovery_match":        sourceDefault,
+			"discovery_interval":     sourceDefault,
+			"interface":              sourceDefault,
+			"notify":                 sourceDefault,
+			"notify_recovery":        sourceDefault,
+			"notify_cooldown":        sourceDefault,
+			"alert_webhook":          sourceDefault,
+			"alert_exec":             sourceDefault,
+			"alert_webhook_template": sourceDefault,
+			"alert_exec_args":        sourceDefault,
+			"smooth_score":           sourceDefault,
+			"smooth_mode":            sourceDefault,
+			"mold_minutes":           sourceDefault,
+			"ventilate_co2":          sourceDefault,
+			"ventilate_minutes":      sourceDefault,
+			"card_sensors":           sourceDefault,
+			"theme":                  sourceDefault,
+			"theme_colors":           sourceDefault,
+			"devices":                sourceDefault,
+		},
+	}
+
+	if fl.isSet("interval", "i") {
+		s.Interval = fl.Interval
+		s.Sources["interval"] = sourceFlag
+	} else if cfg.Interval > 0 {
+		s.Interval = cfg.Interval
+		s.Sources["interval"] = sourceFile
+	}
+
+	if s.Interval <= 0 {
+		s.Interval = defaultInterval
+		s.Sources["interval"] = sourceDefault
+	}
+
+	if fl.isSet("fahrenheit", "f") {
+		s.Fahrenheit = fl.Fahrenheit
+		s.Sources["fahrenheit"] = sourceFlag
+	} else if cfg.Fahrenheit != nil {
+		s.Fahrenheit = *cfg.Fahrenheit
+		s.Sources["fahrenheit"] = sourceFile
+	}
+
+	if fl.isSet("no-discovery") {
+		s.NoDiscovery = fl.NoDiscovery
+		s.Sources["no_discovery"] = sourceFlag
+	} else if cfg.NoDiscovery != nil {
+		s.NoDiscovery = *cfg.NoDiscovery
+		s.Sources["no_discovery"] = sourceFile
+	}
+
+	if fl.isSet("remember-discovered") {
+		s.RememberDiscovered = fl.RememberDiscovered
+		s.Sources["remember_discovered"] = sourceFlag
+	} else if cfg.RememberDiscovered != nil {
+		s.RememberDiscovered = *cfg.RememberDiscovered
+		s.Sources["remember_discovered"] = sourceFile
+	}
+
+	if fl.isSet("max-discovered") {
+		s.MaxDiscovered = fl.MaxDiscovered
+		s.Sources["max_discovered"] = sourceFlag
+	} else if cfg.MaxDiscovered > 0 {
+		s.MaxDiscovered = cfg.MaxDiscovered
+		s.Sources["max_discovered"] = sourceFile
+	}
+
+	if cfg.SlowTerminal != nil {
+		s.SlowTerminal = *cfg.SlowTerminal
+		s.Sources["slow_terminal"] = sourceFile
+	} else if os.Getenv("SSH_CONNECTION") != "" {
+		s.SlowTerminal = true
+		s.Sources["slow_terminal"] = sourceEnv
+	}
+
+	if fl.isSet("ascii") {
+		s.ASCII = fl.ASCII
+		s.Sources["ascii"] = sourceFlag
+	} else if cfg.ASCII != nil {
+		s.ASCII = *cfg.ASCII
+		s.Sources["ascii"] = sourceFile
+	} else if legacyConsole() {
+		s.ASCII = true
+		s.Sources["ascii"] = sourceEnv
+	}
+
+	// https://no-color.org: any non-empty value
+	if fl.isSet("no-color") {
+		s.NoColor = fl.NoColor
+		s.Sources["no_color"] = sourceFlag
+	} else if os.Getenv("NO_COLOR") != "" {
+		s.NoColor = true
+		s.Sources["no_color"] = sourceEnv
+	}
+
+	if fl.isSet("no-bell") {
+		s.Bell = !fl.NoBell
+		s.Sources["bell"] = sourceFlag
+	} else if cfg.Bell != nil {
+		s.Bell = *cfg.Bell
+		s.Sources["bell"] = sourceFile
+	}
+	if fl.isSet("no-flash") {
+		s.Flash = !fl.NoFlash
+		s.Sources["flash"] = sourceFlag
+	} else if cfg.Flash != nil {
+		s.Flash = *cfg.Flash
+		s.Sources["flash"] = sourceFile
+	}
+	if fl.isSet("no-mouse") {
+		s.Mouse = !fl.NoMouse
+		s.Sources["mouse"] = sourceFlag
+	} else if cfg.Mouse != nil {
+		s.Mouse = *cfg.Mouse
+		s.Sources["mouse"] = sourceFile
+	}
+
+	if fl.isSet("no-device-config") {
+		s.FetchDeviceConfig = !fl.NoConfigFetch
+		s.Sources["fetch_device_config"] = sourceFlag
+	} else if cfg.FetchDeviceConfig != nil {
+		s.FetchDeviceConfig = *cfg.FetchDeviceConfig
+		s.Sources["fetch_device_config"] = sourceFile
+	}
+	if fl.isSet("show-firmware") {
+		s.ShowFirmware = fl.ShowFirmware
+		s.Sources["show_firmware"] = sourceFlag
+	} else if cfg.ShowFirmware != nil {
+		s.ShowFirmware = *cfg.ShowFirmware
+		s.Sources["show_firmware"] = sourceFile
+	}
+
+	if fl.isSet("http-timeout") && fl.HTTPTimeout > 0 {
+		s.HTTPTimeout = fl.HTTPTimeout
+		s.Sources["http_timeout"] = sourceFlag
+	} else if cfg.HTTPTimeout > 0 {
+		s.HTTPTimeout = time.Duration(cfg.HTTPTimeout * float64(time.Second))
+		s.Sources["http_timeout"] = sourceFile
+	}
+	if fl.isSet("http-retries") {
+		s.HTTPRetries = fl.HTTPRetries
+		s.Sources["http_retries"] = sourceFlag
+	} else if cfg.HTTPRetries > 0 {
+		s.HTTPRetries = cfg.HTTPRetries
+		s.Sources["http_retries"] = sourceFile
+	}
+	if s.HTTPRetries < 0 {
+		s.HTTPRetries = 0
+		s.Sources["http_retries"] = sourceDefault
+	}
+	if fl.isSet("slow-latency") && fl.SlowLatency > 0 {
+		s.SlowLatency = fl.SlowLatency
+		s.Sources["slow_latency"] = sourceFlag
+	} else if cfg.SlowLatency > 0 {
+		s.SlowLatency = time.Duration(cfg.SlowLatency * float64(time.Second))
+		s.Sources["slow_latency"] = sourceFile
+	}
+	if fl.isSet("max-clock-skew") && fl.MaxClockSkew > 0 {
+		s.MaxClockSkew = fl.MaxClockSkew
+		s.Sources["max_clock_skew"] = sourceFlag
+	} else if cfg.MaxClockSkew > 0 {
+		s.MaxClockSkew = time.Duration(cfg.MaxClockSkew * float64(time.Second))
+		s.Sources["max_clock_skew"] = sourceFile
+	}
+
+	if fl.isSet("export-time") {
+		s.ExportTime = fl.ExportTime
+		s.Sources["export_time"] = sourceFlag
+	} else if cfg.ExportTime != "" {
+		s.ExportTime = cfg.ExportTime
+		s.Sources["export_time"] = sourceFile
+	}
+	if !validExportTime(s.ExportTime) {
+		logf(levelWarn, "unknown export time %q; using %s", s.ExportTime, exportTimeReceived)
+		s.ExportTime = exportTimeReceived
+		s.Sources["export_time"] = sourceDefault
+	}
+
+	if fl.isSet("cloud-token") {
+		s.CloudToken = fl.CloudToken
+		s.Sources["cloud_token"] = sourceFlag
+	} else if cfg.CloudToken != "" {
+		s.CloudToken = cfg.CloudToken
+		s.Sources["cloud_token"] = sourceFile
+	}
+
+	if fl.isSet("db") {
+		s.DB = fl.DB
+		s.Sources["db"] = sourceFlag
+	} else if cfg.DB != "" {
+		s.DB = cfg.DB
+		s.Sources["db"] = sourceFile
+	}
+	if fl.isSet("db-retain") {
+		// Checked in main
+		s.DBRetain, _ = parseRetention(fl.DBRetain)
+		s.Sources["db_retain"] = sourceFlag
+	} else if cfg.DBRetain != "" {
+		if d, err := parseRetention(cfg.DBRetain); err == nil {
+			s.DBRetain = d
+			s.Sources["db_retain"] = sourceFile
+		} else {
+			logf(levelWarn, "db_retain: %v; keeping readings %s", err, shortDuration(defaultDBRetain))
+		}
+	}
+	if fl.isSet("db-raw") {
+		// Checked in main
+		s.DBRaw, _ = parseRetention(fl.DBRaw)
+		s.Sources["db_raw"] = sourceFlag
+	} else if cfg.DBRaw != "" {
+		if d, err := parseRetention(cfg.DBRaw); err == nil {
+			s.DBRaw = d
+			s.Sources["db_raw"] = sourceFile
+		} else {
+			logf(levelWarn, "db_raw: %v; compacting readings after %s", err, shortDuration(defaultDBRaw))
+		}
+	}
+
+	if fl.isSet("serve") {
+		s.Serve = fl.Serve
+		s.Sources["serve"] = sourceFlag
+	} else if cfg.Serve != "" {
+		if err := validServeAddr(cfg.Serve); err == nil {
+			s.Serve = cfg.Serve
+			s.Sources["serve"] = sourceFile
+		} else {
+			logf(levelWarn, "serve: %v; not serving the API", err)
+		}
+	}
+
+	if fl.isSet("poll-endpoint") {
+		s.PollEndpoint = fl.PollEndpoint
+		s.Sources["poll_endpoint"] = sourceFlag
+	} else if cfg.PollEndpoint != "" {
+		s.PollEndpoint = cfg.PollEndpoint
+		s.Sources["poll_endpoint"] = sourceFile
+	}
+	if !validPollEndpoint(s.PollEndpoint) {
+		logf(levelWarn, "unknown poll endpoint %q; using %s", s.PollEndpoint, awair.Latest)
+		s.PollEndpoint = awair.Latest
+		s.Sources["poll_endpoint"] = sourceDefault
+	}
+
+	if fl.isSet("history-endpoint") {
+		s.HistoryEndpoint = fl.HistoryEndpoint
+		s.Sources["history_endpoint"] = sourceFlag
+	} else if cfg.HistoryEndpoint != "" {
+		s.HistoryEndpoint = cfg.HistoryEndpoint
+		s.Sources["history_endpoint"] = sourceFile
+	}
+	if !validHistoryEndpoint(s.HistoryEndpoint) {
+		logf(levelWarn, "unknown history endpoint %q; using %s", s.HistoryEndpoint, defaultHistoryEndpoint)
+		s.HistoryEndpoint = defaultHistoryEndpoint
+		s.Sources["history_endpoint"] = sourceDefault
+	}
+
+	if fl.isSet("discovery-services") {
+		s.DiscoveryServices = splitList(fl.DiscoveryServices)
+		s.Sources["discovery_services"] = sourceFlag
+	} else if len(cfg.DiscoveryServices) > 0 {
+		s.DiscoveryServices = cfg.DiscoveryServices
+		s.Sources["discovery_services"] = sourceFile
+	}
+	s.DiscoveryServices = slices.DeleteFunc(slices.Clone(s.DiscoveryServices), func(svc string) bool {
+		if _, ok := discovery.ServiceName(svc); !ok {
+			logf(levelWarn, "ignoring discovery service %q; expected e.g. _http._tcp", svc)
+			return true
+		}
+		return false
+	})
+	if len(s.DiscoveryServices) == 0 {
+		s.DiscoveryServices = []string{discovery.DefaultService}
+		s.Sources["discovery_services"] = sourceDefault
+	}
+	if fl.isSet("discovery-match") {
+		s.DiscoveryMatch = fl.DiscoveryMatch
+		s.Sources["discovery_match"] = sourceFlag
+	} else if cfg.DiscoveryMatch != "" {
+		s.DiscoveryMatch = cfg.DiscoveryMatch
+		s.Sources["discovery_match"] = sourceFile
+	}
+	if _, err := discovery.CompileMatch(s.DiscoveryMatch); err != nil {
+		logf(levelWarn, "discovery match: %v; using %q", err, discovery.DefaultMatch)
+		s.DiscoveryMatch = discovery.DefaultMatch
+		s.Sources["discovery_match"] = sourceDefault
+	}
+	if fl.isSet("discovery-interval") && fl.DiscoveryInterval > 0 {
+		s.DiscoveryInterval = fl.DiscoveryInterval
+		s.Sources["discovery_interval"] = sourceFlag
+	} else if cfg.DiscoveryInterval > 0 {
+		s.DiscoveryInterval = time.Duration(cfg.DiscoveryInterval) * time.Second
+		s.Sources["discovery_interval"] = sourceFile
+	}
+
+	if fl.isSet("interface") {
+		s.Interface = fl.Interface
+		s.Sources["interface"] = sourceFlag
+	}
+
+	if fl.isSet("mini") {
+		s.Mini = fl.Mini
+		s.Sources["mini"] = sourceFlag
+	}
+
+	if fl.isSet("notify") {
+		s.Notify = fl.Notify
+		s.Sources["notify"] = sourceFlag
+	}
+	if fl.isSet("notify-recovery") {
+		s.NotifyRecovery = fl.NotifyRecovery
+		s.Sources["notify_recovery"] = sourceFlag
+	}
+	if fl.isSet("notify-cooldown") && fl.NotifyCooldown > 0 {
+		s.NotifyCooldown = fl.NotifyCooldown
+		s.Sources["notify_cooldown"] = sourceFlag
+	}
+
+	if fl.isSet("alert-webhook") {
+		s.AlertWebhook = fl.AlertWebhook
+		s.Sources["alert_webhook"] = sourceFlag
+	}
+	if fl.isSet("alert-exec") {
+		s.AlertExec = fl.AlertExec
+		s.Sources["alert_exec"] = sourceFlag
+	}
+	if cfg.AlertWebhookTemplate != "" {
+		s.AlertWebhookTemplate = cfg.AlertWebhookTemplate
+		s.Sources["alert_webhook_template"] = sourceFile
+	}
+	if len(cfg.AlertExecArgs) > 0 {
+		s.AlertExecArgs = cfg.AlertExecArgs
+		s.Sources["alert_exec_args"] = sourceFile
+	}
+
+	if fl.isSet("smooth-score") {
+		s.SmoothScore = fl.SmoothScore
+		s.Sources["smooth_score"] = sourceFlag
+	} else if cfg.SmoothScore > 0 {
+		s.SmoothScore = cfg.SmoothScore
+		s.Sources["smooth_score"] = sourceFile
+	}
+	if s.SmoothScore < 0 {
+		s.SmoothScore = 0
+		s.Sources["smooth_score"] = sourceDefault
+	}
+
+	if fl.isSet("smooth-mode") {
+		s.SmoothMode = fl.SmoothMode
+		s.Sources["smooth_mode"] = sourceFlag
+	} else if cfg.SmoothMode != "" {
+		s.SmoothMode = cfg.SmoothMode
+		s.Sources["smooth_mode"] = sourceFile
+	}
+	if !validSmoothMode(s.SmoothMode) {
+		logf(levelWarn, "unknown smooth mode %q; using %s", s.SmoothMode, smoothMedian)
+		s.SmoothMode = smoothMedian
+		s.Sources["smooth_mode"] = sourceDefault
+	}
+
+	if cfg.MoldMinutes > 0 {
+		s.Advisories.MoldAfter = time.Duration(cfg.MoldMinutes) * time.Minute
+		s.Sources["mold_minutes"] = sourceFile
+	}
+	if cfg.VentilateCO2 > 0 {
+		s.Advisories.VentilateCO2 = float64(cfg.VentilateCO2)
+		s.Sources["ventilate_co2"] = sourceFile
+	}
+	if cfg.VentilateMinutes > 0 {
+		s.Advisories.VentilateAfter = time.Duration(cfg.VentilateMinutes) * time.Minute
+		s.Sources["ventilate_minutes"] = sourceFile
+	}
+
+	if fl.isSet("theme") {
+		s.Theme = fl.Theme
+		s.Sources["theme"] = sourceFlag
+	} else if cfg.Theme != "" {
+		s.Theme = cfg.Theme
+		s.Sources["theme"] = sourceFile
+	}
+	if _, ok := themes[s.Theme]; !ok {
+		logf(levelWarn, "unknown theme %q; using %s", s.Theme, defaultTheme)
+		s.Theme = defaultTheme
+		s.Sources["theme"] = sourceDefault
+	}
+	if colors := validThemeColors(cfg.ThemeColors); len(colors) > 0 {
+		s.ThemeColors = colors
+		s.Sources["theme_colors"] = sourceFile
+	}
+
+	if keys := cardSensors(cfg.CardSensors); keys != nil {
+		s.CardSensors = keys
+		s.Sources["card_sensors"] = sourceFile
+	}
+
+	if fl.Demo > 0 {
+		s.Demo = fl.Demo
+		s.NoDiscovery = true
+		s.Sources["no_discovery"] = sourceFlag
+	}
+
+	if len(fl.IPs) > 0 {
+		s.IPs = make([]string, len(fl.IPs))
+		for i, ip := range fl.IPs {
+			s.IPs[i] = canonicalIP(ip)
+		}
+		s.Sources["devices"] = sourceFlag
+	}
+
+	return s
+}
+
+// cardSensors validates the card_sensors list: unknown and repeated keys
+// are logged and skipped. It returns nil, meaning all sensors, if none
+// are left.
+func cardSensors(keys []string) []string {
+	var valid []string
+	for _, k := range keys {
+		_, known := awair.OptimalRanges[k]
+		switch {
+		case !known:
+			logf(levelWarn, "config card_sensors: unknown sensor %q ignored", k)
+		case slices.Contains(valid, k):
+			logf(levelWarn, "config card_sensors: %q listed twice", k)
+		default:
+			valid = append(valid, k)
+		}
+	}
+	return valid
+}
+
+// settingValue is a single entry in the --print-config output.
+type settingValue struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// printSettings writes the effective configuration as JSON, with the
+// source of each value.
+func printSettings(w io.Writer, cfg *Config, s Settings) error {
+	entry := func(name string, v any) settingValue {
+		return settingValue{Value: v, Source: s.Sources[name]}
+	}
+
+	savedSource := sourceDefault
+	if len(cfg.Devices) > 0 {
+		savedSource = sourceFile
+	}
+	// Without saved credentials
+	saved := make([]deviceEntryAlias, len(cfg.Devices))
+	for i, e := range cfg.Devices {
+		saved[i] = deviceEntryAlias(e)
+	}
+
+	ips := s.IPs
+	if ips == nil {
+		ips = []string{}
+	}
+	cardKeys := s.CardSensors
+	if cardKeys == nil {
+		cardKeys = []string{}
+	}
+	themeColors := s.ThemeColors
+	if themeColors == nil {
+		themeColors = map[string]string{}
+	}
+	// Thresholds, sort and order live only in the config file
+	fileEntry := func(v any, set bool) settingValue {
+		if set {
+			return settingValue{Value: v, Source: sourceFile}
+		}
+		return settingValue{Value: v, Source: sourceDefault}
+	}
+	thresholds := cfg.Thresholds
+	if thresholds == nil {
+		thresholds = map[string]SensorThreshold{}
+	}
+	order := make([]string, len(cfg.Order))
+	for i, addr := range cfg.Order {
+		order[i] = redactAddress(addr)
+	}
+
+	out := struct {
+		ConfigPath string                  `json:"config_path"`
+		Settings   map[string]settingValue `json:"settings"`
+	}{
+		ConfigPath: configPath(),
+		Settings: map[string]settingValue{
+			"interval":               entry("interval", s.Interval),
+			"fahrenheit":             entry("fahrenheit", s.Fahrenheit),
+			"no_discovery":           entry("no_discovery", s.NoDiscovery),
+			"remember_discovered":    entry("remember_discovered", s.RememberDiscovered),
+			"max_discovered":         entry("max_discovered", s.MaxDiscovered),
+			"slow_terminal":          entry("slow_terminal", s.SlowTerminal),
+			"ascii":                  entry("ascii", s.ASCII),
+			"no_color":               entry("no_color", s.NoColor),
+			"bell":                   entry("bell", s.Bell),
+			"flash":                  entry("flash", s.Flash),
+			"mouse":                  entry("mouse", s.Mouse),
+			"mini":                   entry("mini", s.Mini),
+			"fetch_device_config":    entry("fetch_device_config", s.FetchDeviceConfig),
+			"show_firmware":          entry("show_firmware", s.ShowFirmware),
+			"http_timeout":           entry("http_timeout", s.HTTPTimeout.String()),
+			"http_retries":           entry("http_retries", s.HTTPRetries),
+			"slow_latency":           entry("slow_latency", s.SlowLatency.String()),
+			"max_clock_skew":         entry("max_clock_skew", s.MaxClockSkew.String()),
+			"export_time":            entry("export_time", s.ExportTime),
+			"cloud_token":            entry("cloud_token", redactToken(s.CloudToken)),
+			"history_endpoint":       entry("history_endpoint", s.HistoryEndpoint),
+			"poll_endpoint":          entry("poll_endpoint", s.PollEndpoint),
+			"db":                     entry("db", s.DB),
+			"db_retain":              entry("db_retain", s.DBRetain.String()),
+			"db_raw":                 entry("db_raw", s.DBRaw.String()),
+			"serve":                  entry("serve", s.Serve),
+			"discovery_services":     entry("discovery_services", s.DiscoveryServices),
+			"discovery_match":        entry("discovery_match", s.DiscoveryMatch),
+			"discovery_interval":     entry("discovery_interval", s.DiscoveryInterval.String()),
+			"interface":              entry("interface", s.Interface),
+			"notify":                 entry("notify", s.Notify),
+			"notify_recovery":        entry("notify_recovery", s.NotifyRecovery),
+			"notify_cooldown":        entry("notify_cooldown", s.NotifyCooldown.String()),
+			"alert_webhook":          entry("alert_webhook", redactAddress(s.AlertWebhook)),
+			"alert_exec":             entry("alert_exec", s.AlertExec),
+			"alert_webhook_template": entry("alert_webhook_template", s.AlertWebhookTemplate),
+			"alert_exec_args":        entry("alert_exec_args", s.AlertExecArgs),
+			"smooth_score":           entry("smooth_score", s.SmoothScore),
+			"smooth_mode":            entry("smooth_mode", s.SmoothMode),
+			"mold_minutes":           entry("mold_minutes", int(s.Advisories.MoldAfter/time.Minute)),
+			"ventilate_co2":          entry("ventilate_co2", s.Advisories.VentilateCO2),
+			"ventilate_minutes":      entry("ventilate_minutes", int(s.Advisories.VentilateAfter/time.Minute)),
+			"card_sensors":           entry("card_sensors", cardKeys),
+			"theme":                  entry("theme", s.Theme),
+			"theme_colors":           entry("theme_colors", themeColors),
+			"devices":                entry("devices", ips),
+			"thresholds":             fileEntry(thresholds, len(cfg.Thresholds) > 0),
+			"sort":                   fileEntry(validSortMode(cfg.Sort), cfg.Sort != ""),
+			"order":                  fileEntry(order, len(cfg.Order) > 0),
+			"saved_devices":          {Value: saved, Source: savedSource},
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,68 @@
+package main
+
+import "sort"
+
+// Device orderings, cycled with o. sortManual is the saved dashboard
+// order; the others are applied on top of it without changing it.
+const (
+	sortManual   = "manual"
+	sortName     = "name"
+	sortScore    = "score"    // worst score first
+	sortSeverity = "severity" // worst alert first
+)
+
+var sortModes = []string{sortManual, sortName, sortScore, sortSeverity}
+
+// nextSortMode returns the mode after mode in the o cycle.
+func nextSortMode(mode string) string {
+	for i, s := range sortModes {
+		if s == mode {
+			return sortModes[(i+1)%len(sortModes)]
+		}
+	}
+	return sortManual
+}
+
+// validSortMode returns mode if it is known, else sortManual.
+func validSortMode(mode string) string {
+	for _, s := range sortModes {
+		if s == mode {
+			return mode
+		}
+	}
+	return sortManual
+}
+
+// sortDevices orders devs in place by mode. The sort is stable, so ties
+// keep the manual order, and devices without data always come last.
+func sortDevices(devs []*Device, mode string) {
+	var less func(a, b *Device) bool
+	switch mode {
+	case sortName:
+		less = func(a, b *Device) bool { return a.Name < b.Name }
+	case sortScore:
+		less = func(a, b *Device) bool { return a.Data.Score < b.Data.Score }
+	case sortSeverity:
+		less = func(a, b *Device) bool {
+			wa, _ := a.Alerts.Worst()
+			wb, _ := b.Alerts.Worst()
+			if wa.Severity != wb.Severity {
+				return wa.Severity > wb.Severity
+			}
+			return a.Data.Score < b.Data.Score
+		}
+	default:
+		return
+	}
+
+	sort.SliceStable(devs, func(i, j int) bool {
+		a, b := devs[i], devs[j]
+		if (a.Data == nil) != (b.Data == nil) {
+			return b.Data == nil
+		}
+		if a.Data == nil {
+			return mode == sortName && less(a, b)
+		}
+		return less(a, b)
+	})
+}
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	_ "modernc.org/sqlite"
+)
+
+// Defaults for the reading database (--db): how long readings are kept
+// as they came, and at all; how far back a device's history is loaded
+// when it is added; how often old readings are compacted and purged; and
+// the span compaction aggregates them over.
+const (
+	defaultDBRaw    = 7 * 24 * time.Hour
+	defaultDBRetain = 30 * 24 * time.Hour
+	storeLoadWindow = 24 * time.Hour
+	storePurgeEvery = time.Hour
+	compactBucket   = 5 * time.Minute
+)
+
+// storeColumns are the sensor columns of the readings table, named like
+// the SensorData JSON fields. A reading the device didn't send is NULL.
+var storeColumns = []string{
+	"score", "temp", "humid", "co2", "voc", "pm25",
+	"dew_point", "abs_humid", "co2_est", "co2_est_baseline", "voc_baseline",
+	"voc_h2_raw", "voc_ethanol_raw", "pm10_est", "lux", "spl_a",
+}
+
+// storeSchema creates the readings table. Times are Unix milliseconds;
+// a device is identified by its address and, once known, its UUID.
+var storeSchema = `
+CREATE TABLE IF NOT EXISTS readings (
+	uuid        TEXT NOT NULL,
+	ip          TEXT NOT NULL,
+	device_time INTEGER NOT NULL,
+	received    INTEGER NOT NULL,
+	period_ms   INTEGER NOT NULL DEFAULT 0,
+	` + strings.Join(storeColumns, " REAL,\n\t") + ` REAL,
+	UNIQUE (ip, device_time)
+);
+CREATE INDEX IF NOT EXISTS readings_uuid ON readings (uuid, device_time);
+CREATE INDEX IF NOT EXISTS readings_time ON readings (device_time);
+CREATE TABLE IF NOT EXISTS aggregates (
+	uuid     TEXT NOT NULL,
+	ip       TEXT NOT NULL,
+	bucket   INTEGER NOT NULL,
+	received INTEGER NOT NULL,
+	samples  INTEGER NOT NULL,
+	` + strings.Join(aggregateColumns(), ",\n\t") + `,
+	UNIQUE (ip, bucket)
+);
+CREATE INDEX IF NOT EXISTS aggregates_uuid ON aggregates (uuid, bucket);
+CREATE INDEX IF NOT EXISTS aggregates_time ON aggregates (bucket);
+`
+
+// aggregateColumns declares the sensor columns of the aggregates table:
+// for each of the storeColumns, its min, mean and max over the bucket and
+// how many readings had it. Times are Unix milliseconds, bucket being the
+// start of the compactBucket the row covers.
+func aggregateColumns() []string {
+	var cols []string
+	for _, col := range storeColumns {
+		cols = append(cols, col+"_min REAL", col+"_mean REAL", col+"_max REAL", col+"_n INTEGER NOT NULL DEFAULT 0")
+	}
+	return cols
+}
+
+// aggregateSelect lists the aggregates columns a storedAggregate is
+// scanned from.
+func aggregateSelect() string {
+	cols := []string{"bucket", "received", "samples"}
+	for _, col := range storeColumns {
+		cols = append(cols, col+"_min", col+"_mean", col+"_max", col+"_n")
+	}
+	return strings.Join(cols, ", ")
+}
+
+// ReadingStore keeps every accepted sample in a SQLite database, so
+// device history survives restarts. Samples are queued by Add and
+// written in one transaction per Flush. Once older than raw they are
+// compacted into aggregates per compactBucket, which the queries read in
+// their place.
+type ReadingStore struct {
+	db     *sql.DB
+	raw    time.Duration // 0 never compacts
+	retain time.Duration // 0 keeps readings forever
+
+	mu        sync.Mutex
+	pending   []storedReading
+	lastPurge time.Time
+}
+
+// storedReading is one sample waiting to be written.
+type storedReading struct {
+	UUID, IP   string
+	DeviceTime time.Time
+	Received   time.Time
+	Period     time.Duration
+	Data       *awair.SensorData
+}
+
+// OpenStore opens the database at path, creating it if needed, and
+// purges readings older than retain. Readings older than raw are
+// compacted along with the hourly purges, or by Compact.
+func OpenStore(path string, raw, retain time.Duration) (*ReadingStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// One connection: SQLite serializes writers anyway, and the pragmas
+	// below are per connection
+	db.SetMaxOpenConns(1)
+	for _, stmt := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", storeSchema} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	s := &ReadingStore{db: db, raw: raw, retain: retain}
+	if _, err := s.Purge(time.Now()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add queues a sample for the next Flush. Samples without a device
+// timestamp aren't stored, since they can't be placed or deduplicated.
+func (s *ReadingStore) Add(r storedReading) {
+	if r.DeviceTime.IsZero() {
+		return
+	}
+	s.mu.Lock()
+	s.pending = append(s.pending, r)
+	s.mu.Unlock()
+}
+
+// Flush writes the queued samples in one transaction, and compacts and
+// purges old readings once per storePurgeEvery. Samples already stored
+// are skipped. On failure the queued samples are dropped.
+func (s *ReadingStore) Flush() error {
+	_, err := s.flush()
+	return err
+}
+
+// flush is Flush, returning what compacting and purging did.
+func (s *ReadingStore) flush() (compactResult, error) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	purge := time.Since(s.lastPurge) >= storePurgeEvery
+	s.mu.Unlock()
+
+	if len(pending) > 0 {
+		if err := s.insert(pending); err != nil {
+			return compactResult{}, err
+		}
+	}
+	if !purge {
+		return compactResult{}, nil
+	}
+	now := time.Now()
+	res, err := s.Compact(now, func(ip string, readings, aggregates int64) {
+		logf(levelDebug, "db compact %s: %d readings into %d aggregates", ip, readings, aggregates)
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Purged, err = s.Purge(now)
+	return res, err
+}
+
+func (s *ReadingStore) insert(readings []storedReading) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.Repeat(", ?", len(storeColumns))
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO readings (uuid, ip, device_time, received, period_ms, ` +
+		strings.Join(storeColumns, ", ") + `) VALUES (?, ?, ?, ?, ?` + placeholders + `)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range readings {
+		values, err := sensorValues(r.Data)
+		if err != nil {
+			return err
+		}
+		args := append([]any{r.UUID, r.IP, r.DeviceTime.UnixMilli(), r.Received.UnixMilli(), r.Period.Milliseconds()}, values...)
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// sensorValues returns d's readings in storeColumns order, nil for those
+// it doesn't have.
+func sensorValues(d *awair.SensorData) ([]any, error) {
+	raw, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	values := make([]any, len(storeColumns))
+	for i, col := range storeColumns {
+		if v, ok := fields[col].(float64); ok {
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+// Purge deletes readings and aggregates taken more than the retention
+// period before now, and returns how many rows it deleted.
+func (s *ReadingStore) Purge(now time.Time) (int64, error) {
+	s.mu.Lock()
+	s.lastPurge = now
+	s.mu.Unlock()
+	if s.retain <= 0 {
+		return 0, nil
+	}
+	cutoff := now.Add(-s.retain).UnixMilli()
+	var n int64
+	for _, stmt := range []string{`DELETE FROM readings WHERE device_time < ?`, `DELETE FROM aggregates WHERE bucket < ?`} {
+		res, err := s.db.Exec(stmt, cutoff)
+		if err != nil {
+			return n, err
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return n, err
+		}
+		n += deleted
+	}
+	return n, nil
+}
+
+// compactResult is what a Compact run, and the Purge after it, did.
+type compactResult struct {
+	Readings   int64 // compacted
+	Aggregates int64 // they were compacted into
+	Purged     int64
+}
+
+// storedAggregate is the readings of one device over one compactBucket,
+// as compaction leaves them.
+type storedAggregate struct {
+	UUID            string
+	Start, Received time.Time // the bucket's start, the last reading's
+	Samples         int
+	Stats           []SensorStats // in storeColumns order
+}
+
+// Compact replaces the readings taken more than raw before now with one
+// aggregate per device and compactBucket, one device per transaction,
+// and tells progress about each device done. The cutoff is on a bucket
+// boundary, so no bucket is left part raw and part compacted, and running
+// it again finds nothing to do. A reading stored after its bucket was
+// compacted, e.g. by a device whose clock was far behind, is dropped.
+func (s *ReadingStore) Compact(now time.Time, progress func(ip string, readings, aggregates int64)) (compactResult, error) {
+	var res compactResult
+	if s.raw <= 0 {
+		return res, nil
+	}
+	cutoff := now.Add(-s.raw).Truncate(compactBucket).UnixMilli()
+	rows, err := s.db.Query(`SELECT DISTINCT ip FROM readings WHERE device_time < ?`, cutoff)
+	if err != nil {
+		return res, err
+	}
+	var ips []string
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			rows.Close()
+			return res, err
+		}
+		ips = append(ips, ip)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return res, err
+	}
+
+	for _, ip := range ips {
+		readings, aggregates, err := s.compactDevice(ip, cutoff)
+		if err != nil {
+			return res, fmt.Errorf("compacting %s: %w", ip, err)
+		}
+		res.Readings += readings
+		res.Aggregates += aggregates
+		if progress != nil {
+			progress(ip, readings, aggregates)
+		}
+	}
+	return res, nil
+}
+
+// compactDevice compacts the readings of the device at ip taken before
+// cutoff, a Unix millisecond time on a bucket boundary.
+func (s *ReadingStore) compactDevice(ip string, cutoff int64) (readings, aggregates int64, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	cols := make([]string, 0, 5+4*len(storeColumns))
+	cols = append(cols, "uuid", "ip", "bucket", "received", "samples")
+	for _, col := range storeColumns {
+		cols = append(cols, col+"_min", col+"_mean", col+"_max", col+"_n")
+	}
+	insert, err := tx.Prepare(`INSERT OR IGNORE INTO aggregates (` + strings.Join(cols, ", ") + `)
+		VALUES (?` + strings.Repeat(", ?", len(cols)-1) + `)`)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer insert.Close()
+
+	rows, err := tx.Query(`SELECT uuid, device_time, received, `+strings.Join(storeColumns, ", ")+`
+		FROM readings WHERE ip = ? AND device_time < ? ORDER BY device_time`, ip, cutoff)
+	if err != nil {
+		return 0, 0, err
+	}
+	bucketMs := compactBucket.Milliseconds()
+	var aggs []*storedAggregate
+	for rows.Next() {
+		var rowUUID string
+		var deviceTime, received int64
+		values := make([]sql.NullFloat64, len(storeColumns))
+		dest := []any{&rowUUID, &deviceTime, &received}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		start := deviceTime - deviceTime%bucketMs
+		if len(aggs) == 0 || aggs[len(aggs)-1].Start.UnixMilli() != start {
+			aggs = append(aggs, &storedAggregate{Start: time.UnixMilli(start), Stats: make([]SensorStats, len(storeColumns))})
+		}
+		current := aggs[len(aggs)-1]
+		if rowUUID != "" {
+			current.UUID = rowUUID
+		}
+		current.Received = time.UnixMilli(max(received, current.Received.UnixMilli()))
+		current.Samples++
+		for i, v := range values {
+			if v.Valid && awair.Plausible(storeColumns[i], v.Float64) {
+				current.Stats[i].Add(v.Float64)
+			}
+		}
+		readings++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for _, a := range aggs {
+		args := []any{a.UUID, ip, a.Start.UnixMilli(), a.Received.UnixMilli(), a.Samples}
+		for _, st := range a.Stats {
+			if st.N == 0 {
+				args = append(args, nil, nil, nil, 0)
+				continue
+			}
+			args = append(args, st.Min, st.Avg(), st.Max, st.N)
+		}
+		res, err := insert.Exec(args...)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, 0, err
+		}
+		aggregates += n
+	}
+	if _, err := tx.Exec(`DELETE FROM readings WHERE ip = ? AND device_time < ?`, ip, cutoff); err != nil {
+		return 0, 0, err
+	}
+	return readings, aggregates, tx.Commit()
+}
+
+// aggregates returns the aggregates of the device with the given UUID or
+// address whose bucket starts from since until before until, oldest
+// first.
+func (s *ReadingStore) aggregates(uuid, ip string, since, until time.Time) ([]storedAggregate, error) {
+	rows, err := s.db.Query(`SELECT `+aggregateSelect()+`
+		FROM aggregates
+		WHERE (ip = ? OR (uuid <> '' AND uuid = ?)) AND bucket >= ? AND bucket < ?
+		ORDER BY bucket`, ip, uuid, since.UnixMilli(), until.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aggs []storedAggregate
+	for rows.Next() {
+		var bucket, received int64
+		a := storedAggregate{Stats: make([]SensorStats, len(storeColumns))}
+		dest := []any{&bucket, &received, &a.Samples}
+		means := make([]sql.NullFloat64, len(storeColumns))
+		mins := make([]sql.NullFloat64, len(storeColumns))
+		maxes := make([]sql.NullFloat64, len(storeColumns))
+		for i := range storeColumns {
+			dest = append(dest, &mins[i], &means[i], &maxes[i], &a.Stats[i].N)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		for i := range a.Stats {
+			st := &a.Stats[i]
+			st.Min, st.Max, st.Sum = mins[i].Float64, maxes[i].Float64, means[i].Float64*float64(st.N)
+		}
+		a.Start, a.Received = time.UnixMilli(bucket).UTC(), time.UnixMilli(received)
+		aggs = append(aggs, a)
+	}
+	return aggs, rows.Err()
+}
+
+// sample is the aggregate as one reading of its means, spanning the
+// bucket like a device's own averages.
+func (a storedAggregate) sample() (Sample, error) {
+	means := make([]sql.NullFloat64, len(storeColumns))
+	for i, st := range a.Stats {
+		if st.N == 0 {
+			continue
+		}
+		v := st.Avg()
+		if storeColumns[i] == "score" {
+			// SensorData holds it as a whole number
+			v = math.Round(v)
+		}
+		means[i] = sql.NullFloat64{Float64: v, Valid: true}
+	}
+	data, err := storedSensorData(a.Start, means)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{DeviceTime: a.Start, Received: a.Received, Data: data, Period: compactBucket}, nil
+}
+
+// statsByKey returns the aggregate's stats by sensor key, for
+// Summary.AddAggregate.
+func (a storedAggregate) statsByKey() map[string]SensorStats {
+	stats := make(map[string]SensorStats, len(storeColumns))
+	for i, st := range a.Stats {
+		if st.N > 0 {
+			stats[storeColumns[i]] = st
+		}
+	}
+	return stats
+}
+
+// Recent returns the newest samples, at most limit and none taken before
+// since, of the device with the given UUID or address, oldest first.
+// Compacted readings come back as one sample per aggregate.
+func (s *ReadingStore) Recent(uuid, ip string, since time.Time, limit int) ([]Sample, error) {
+	rows, err := s.db.Query(`SELECT device_time, received, period_ms, `+strings.Join(storeColumns, ", ")+`
+		FROM readings
+		WHERE (ip = ? OR (uuid <> '' AND uuid = ?)) AND device_time >= ?
+		ORDER BY device_time DESC LIMIT ?`, ip, uuid, since.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []Sample
+	for rows.Next() {
+		sample, err := scanSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	aggs, err := s.aggregates(uuid, ip, since, time.UnixMilli(math.MaxInt64))
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aggs {
+		sample, err := a.sample()
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	slices.SortFunc(samples, func(a, b Sample) int { return a.DeviceTime.Compare(b.DeviceTime) })
+	return samples[max(len(samples)-limit, 0):], nil
+}
+
+// Summarize aggregates the samples of the device with the given UUID or
+// address taken from since until before until, for the summary view,
+// compacted ones included.
+func (s *ReadingStore) Summarize(uuid, ip string, since, until time.Time) (*Summary, error) {
+	aggs, err := s.aggregates(uuid, ip, since, until)
+	if err != nil {
+		return nil, err
+	}
+	var summary Summary
+	addAggregate := func(a storedAggregate) error {
+		sample, err := a.sample()
+		if err != nil {
+			return err
+		}
+		summary.AddAggregate(sample.Data, a.Start, a.Samples, a.statsByKey())
+		return nil
+	}
+
+	rows, err := s.db.Query(`SELECT device_time, received, period_ms, `+strings.Join(storeColumns, ", ")+`
+		FROM readings
+		WHERE (ip = ? OR (uuid <> '' AND uuid = ?)) AND device_time >= ? AND device_time < ?
+		ORDER BY device_time`, ip, uuid, since.UnixMilli(), until.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sample, err := scanSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		// In time order, so the time spent poor adds up across the
+		// boundary
+		for len(aggs) > 0 && aggs[0].Start.Before(sample.DeviceTime) {
+			if err := addAggregate(aggs[0]); err != nil {
+				return nil, err
+			}
+			aggs = aggs[1:]
+		}
+		summary.Add(sample.Data, sample.DeviceTime)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, a := range aggs {
+		if err := addAggregate(a); err != nil {
+			return nil, err
+		}
+	}
+	return &summary, nil
+}
+
+// scanSample reads a sample from a row of device_time, received,
+// period_ms and the storeColumns.
+func scanSample(rows *sql.Rows) (Sample, error) {
+	var deviceTime, received, period int64
+	values := make([]sql.NullFloat64, len(storeColumns))
+	dest := []any{&deviceTime, &received, &period}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return Sample{}, err
+	}
+	t := time.UnixMilli(deviceTime).UTC()
+	data, err := storedSensorData(t, values)
+	if err != nil {
+		return Sample{}, err
+	}
+	return Sample{
+		DeviceTime: t,
+		Received:   time.UnixMilli(received),
+		Data:       data,
+		Period:     time.Duration(period) * time.Millisecond,
+	}, nil
+}
+
+// storedSensorData rebuilds a reading from its row as the device would
+// have sent it, so NULL readings come back as missing.
+func storedSensorData(t time.Time, values []sql.NullFloat64) (*awair.SensorData, error) {
+	fields := map[string]any{"timestamp": t.Format(time.RFC3339Nano)}
+	for i, v := range values {
+		if v.Valid {
+			fields[storeColumns[i]] = v.Float64
+		}
+	}
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var data awair.SensorData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Close writes what is still queued and closes the database.
+func (s *ReadingStore) Close() error {
+	return errors.Join(s.Flush(), s.db.Close())
+}
+
+// runCompactNow compacts and purges the database at s.DB as the
+// dashboard does hourly, reporting each device, and returns the exit
+// code: 0 if it worked, 1 if not and 2 without a database.
+func runCompactNow(s Settings) int {
+	if s.DB == "" {
+		fmt.Fprintln(os.Stderr, "Error: --compact-now: no database; add --db")
+		return 2
+	}
+	store, err := OpenStore(s.DB, s.DBRaw, s.DBRetain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: can't open database %s: %v\n", s.DB, err)
+		return 1
+	}
+	defer store.Close()
+
+	if s.DBRaw <= 0 {
+		fmt.Println("Not compacting: --db-raw is 0")
+	} else {
+		fmt.Printf("Compacting readings older than %s into %s aggregates\n", retentionText(s.DBRaw), shortDuration(compactBucket))
+	}
+	now := time.Now()
+	res, err := store.Compact(now, func(ip string, readings, aggregates int64) {
+		fmt.Printf("  %s: %d readings into %d aggregates\n", redactAddress(ip), readings, aggregates)
+	})
+	if err == nil {
+		res.Purged, err = store.Purge(now)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Compacted %d readings into %d aggregates; deleted %d rows past --db-retain\n", res.Readings, res.Aggregates, res.Purged)
+	return 0
+}
+
+// parseRetention parses a --db-retain value: a Go duration, or whole
+// days such as "30d". 0 keeps readings forever.
+func parseRetention(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 30d or 72h", v)
+	}
+	return d, nil
+}
+
+// retentionText writes d as parseRetention takes it, in days if whole.
+func retentionText(d time.Duration) string {
+	const day = 24 * time.Hour
+	if d > 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
+
+// storeFlushedMsg reports a background Flush, and what compacting and
+// purging did if it was time for them.
+type storeFlushedMsg struct {
+	Compacted compactResult
+	Err       error
+}
+
+func storeFlushCmd(s *ReadingStore) tea.Cmd {
+	return trackCmd("db flush", func() tea.Msg {
+		res, err := s.flush()
+		return storeFlushedMsg{Compacted: res, Err: err}
+	})
+}
+
+// storedHistoryMsg carries the samples loaded from the database for a
+// newly added device, and the summary of the readings stored before it
+// was added.
+type storedHistoryMsg struct {
+	IP      string
+	Samples []Sample
+	Summary *Summary
+	Err     error
+}
+
+func storedHistoryCmd(s *ReadingStore, dev *Device) tea.Cmd {
+	uuid, ip := dev.UUID, dev.IP
+	// Samples polled from now on are summarized as they arrive
+	until := time.Now()
+	return trackCmd("db history "+ip, func() tea.Msg {
+		since := until.Add(-storeLoadWindow)
+		samples, err := s.Recent(uuid, ip, since, historySize)
+		if err != nil {
+			return storedHistoryMsg{IP: ip, Err: err}
+		}
+		summary, err := s.Summarize(uuid, ip, since, until)
+		return storedHistoryMsg{IP: ip, Samples: samples, Summary: summary, Err: err}
+	})
+}
+
+// restoreHistory puts the samples loaded from the database into the
+// device's history and summary.
+func (m *model) restoreHistory(msg storedHistoryMsg) {
+	dev, ok := m.devices[msg.IP]
+	if !ok {
+		return
+	}
+	if msg.Err != nil {
+		m.logAt(levelError, fmt.Sprintf("%s: can't load history from the database: %v", dev.Name, msg.Err))
+		return
+	}
+	dev.Summary.Merge(msg.Summary)
+	if n := dev.History.Restore(msg.Samples); n > 0 {
+		m.addLog(fmt.Sprintf("%s: loaded %d samples from the database", dev.Name, n))
+	}
+}
+
+// handleStoreFlushed logs what compacting did, and a failed flush once
+// until one succeeds again.
+func (m *model) handleStoreFlushed(msg storeFlushedMsg) {
+	if res := msg.Compacted; res.Readings > 0 {
+		m.addLog(fmt.Sprintf("Database: compacted %d readings into %d aggregates", res.Readings, res.Aggregates))
+	}
+	if msg.Err == nil {
+		m.storeFailing = false
+		return
+	}
+	logf(levelDebug, "db flush: %v", msg.Err)
+	if !m.storeFailing {
+		m.storeFailing = true
+		m.logAt(levelError, fmt.Sprintf("Can't save readings to the database: %v", msg.Err))
+	}
+}
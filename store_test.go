@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+var storeEpoch = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func openTestStore(t *testing.T, retain time.Duration) *ReadingStore {
+	t.Helper()
+	s, err := OpenStore(filepath.Join(t.TempDir(), "readings.db"), 0, retain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// storeReading is a reading of the given CO₂ taken minutes after
+// storeEpoch by the device at ip.
+func storeReading(uuid, ip string, minutes int, co2 float64) storedReading {
+	at := storeEpoch.Add(time.Duration(minutes) * time.Minute)
+	return storedReading{
+		UUID:       uuid,
+		IP:         ip,
+		DeviceTime: at,
+		Received:   at.Add(time.Second),
+		Data:       &awair.SensorData{Timestamp: at.Format(time.RFC3339), Score: 90, Temp: 21, Humid: 45, CO2: co2, PM25: 3},
+	}
+}
+
+func storeCount(t *testing.T, s *ReadingStore) int {
+	t.Helper()
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM readings`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestStoreAddFlush(t *testing.T) {
+	s := openTestStore(t, 0)
+	s.Add(storeReading("u1", "10.0.0.1", 0, 500))
+	s.Add(storeReading("u1", "10.0.0.1", 1, 510))
+	// No device time: nothing to place it by
+	s.Add(storedReading{IP: "10.0.0.1", Received: storeEpoch, Data: &awair.SensorData{CO2: 600}})
+	if n := storeCount(t, s); n != 0 {
+		t.Fatalf("%d rows before Flush", n)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := storeCount(t, s); n != 2 {
+		t.Fatalf("%d rows after Flush, want 2", n)
+	}
+
+	// The same sample again is skipped
+	s.Add(storeReading("u1", "10.0.0.1", 1, 999))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n := storeCount(t, s); n != 2 {
+		t.Errorf("%d rows after a duplicate, want 2", n)
+	}
+	if err := s.Flush(); err != nil {
+		t.Errorf("empty Flush: %v", err)
+	}
+}
+
+func TestStoreRecent(t *testing.T) {
+	s := openTestStore(t, 0)
+	for i := range 5 {
+		s.Add(storeReading("u1", "10.0.0.1", i, float64(500+i)))
+	}
+	s.Add(storeReading("u2", "10.0.0.2", 2, 900))
+	s.Add(storeReading("", "10.0.0.3", 2, 700))
+	// The same device at a new address, found by its UUID
+	s.Add(storeReading("u1", "10.0.0.9", 5, 505))
+	lux := 120.0
+	r := storeReading("u1", "10.0.0.1", 6, 506)
+	r.Data.Lux = &lux
+	r.Period = 5 * time.Minute
+	s.Add(r)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := s.Recent("u1", "10.0.0.1", storeEpoch, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 7 {
+		t.Fatalf("%d samples, want 7", len(samples))
+	}
+	for i, sample := range samples {
+		if want := storeEpoch.Add(time.Duration(i) * time.Minute); !sample.DeviceTime.Equal(want) {
+			t.Errorf("sample %d at %v, want %v", i, sample.DeviceTime, want)
+		}
+		if sample.Data.CO2 != float64(500+i) {
+			t.Errorf("sample %d CO₂ %v, want %v", i, sample.Data.CO2, 500+i)
+		}
+	}
+	last := samples[6]
+	if last.Data.Lux == nil || *last.Data.Lux != 120 || last.Period != 5*time.Minute {
+		t.Errorf("last sample lux %v period %v", last.Data.Lux, last.Period)
+	}
+	if samples[0].Data.Lux != nil || samples[0].Data.DewPoint != nil {
+		t.Error("readings not sent came back")
+	}
+	if !samples[0].Received.Equal(storeEpoch.Add(time.Second)) {
+		t.Errorf("received %v", samples[0].Received)
+	}
+
+	// since is inclusive; limit keeps the newest
+	samples, err = s.Recent("u1", "10.0.0.1", storeEpoch.Add(2*time.Minute), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || samples[0].Data.CO2 != 504 || samples[2].Data.CO2 != 506 {
+		t.Errorf("limited to 3: %d samples", len(samples))
+	}
+	samples, _ = s.Recent("u1", "10.0.0.1", storeEpoch.Add(6*time.Minute), 100)
+	if len(samples) != 1 {
+		t.Errorf("since the last sample: %d samples, want 1", len(samples))
+	}
+
+	// Without a UUID only the address matches, and an empty UUID doesn't
+	// match other devices that lack one
+	samples, _ = s.Recent("", "10.0.0.3", storeEpoch, 100)
+	if len(samples) != 1 || samples[0].Data.CO2 != 700 {
+		t.Errorf("by address: %d samples", len(samples))
+	}
+}
+
+func TestStorePurge(t *testing.T) {
+	s := openTestStore(t, time.Hour)
+	for _, minutes := range []int{0, 30, 60, 90} {
+		s.Add(storeReading("u1", "10.0.0.1", minutes, 500))
+	}
+	if err := s.insert(s.pending); err != nil {
+		t.Fatal(err)
+	}
+	s.pending = nil
+
+	// Exactly the retention period old is kept
+	n, err := s.Purge(storeEpoch.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || storeCount(t, s) != 3 {
+		t.Errorf("purged %d, %d left; want 1, 3", n, storeCount(t, s))
+	}
+	if n, _ := s.Purge(storeEpoch.Add(90 * time.Minute)); n != 0 {
+		t.Errorf("purged %d again", n)
+	}
+
+	forever := openTestStore(t, 0)
+	forever.Add(storeReading("u1", "10.0.0.1", 0, 500))
+	forever.Flush()
+	if n, _ := forever.Purge(storeEpoch.AddDate(10, 0, 0)); n != 0 || storeCount(t, forever) != 1 {
+		t.Error("retention 0 purged readings")
+	}
+}
+
+func TestStoreFlushPurges(t *testing.T) {
+	s := openTestStore(t, time.Hour)
+	// OpenStore just purged, so Flush doesn't
+	s.Add(storeReading("u1", "10.0.0.1", 0, 500))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if storeCount(t, s) != 1 {
+		t.Fatal("an old reading was purged right after opening")
+	}
+	s.lastPurge = time.Now().Add(-storePurgeEvery)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if storeCount(t, s) != 0 {
+		t.Error("an old reading survived the hourly purge")
+	}
+}
+
+func TestStoreSummarize(t *testing.T) {
+	s := openTestStore(t, 0)
+	// CO₂ is poor from 1200 ppm
+	for i, co2 := range []float64{600, 1300, 1400, 800, 700} {
+		r := storeReading("u1", "10.0.0.1", i*5, co2)
+		r.Data.Score = 90 - i
+		s.Add(r)
+	}
+	s.Add(storeReading("u2", "10.0.0.2", 5, 2000))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// since is inclusive, until exclusive: minutes 5, 10 and 15
+	sum, err := s.Summarize("u1", "10.0.0.1", storeEpoch.Add(5*time.Minute), storeEpoch.Add(20*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Samples != 3 {
+		t.Fatalf("%d samples, want 3", sum.Samples)
+	}
+	if !sum.Since.Equal(storeEpoch.Add(5*time.Minute)) || !sum.Last.Equal(storeEpoch.Add(15*time.Minute)) {
+		t.Errorf("span %v to %v", sum.Since, sum.Last)
+	}
+	co2 := sum.Sensors["co2"]
+	if co2.Min != 800 || co2.Max != 1400 || co2.N != 3 || math.Abs(co2.Avg()-3500.0/3) > 1e-9 {
+		t.Errorf("co2 stats %+v", *co2)
+	}
+	if sum.Poor["co2"] != 10*time.Minute {
+		t.Errorf("poor for %v, want 10m", sum.Poor["co2"])
+	}
+	if sum.LowestScore != 87 || !sum.LowestAt.Equal(storeEpoch.Add(15*time.Minute)) {
+		t.Errorf("lowest score %d at %v", sum.LowestScore, sum.LowestAt)
+	}
+
+	empty, err := s.Summarize("u1", "10.0.0.1", storeEpoch.Add(time.Hour), storeEpoch.Add(2*time.Hour))
+	if err != nil || empty.Samples != 0 {
+		t.Errorf("empty window: %+v, %v", empty, err)
+	}
+}
+
+// storeAggregates returns the aggregates table, one line per row.
+func storeAggregates(t *testing.T, s *ReadingStore) []string {
+	t.Helper()
+	rows, err := s.db.Query(`SELECT uuid, ip, ` + aggregateSelect() + ` FROM aggregates ORDER BY ip, bucket`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	var lines []string
+	for rows.Next() {
+		values := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, fmt.Sprint(values...))
+	}
+	return lines
+}
+
+// compactTestStore holds one reading a minute from u1 for the first 30
+// minutes after storeEpoch, CO₂ rising by 10 ppm a minute from 500, and
+// keeps them raw for an hour.
+func compactTestStore(t *testing.T) *ReadingStore {
+	t.Helper()
+	s := openTestStore(t, 0)
+	s.raw = time.Hour
+	for i := range 30 {
+		r := storeReading("u1", "10.0.0.1", i, float64(500+10*i))
+		r.Data.Score = 90 - i
+		s.Add(r)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStoreCompact(t *testing.T) {
+	s := compactTestStore(t)
+	s.Add(storeReading("u2", "10.0.0.2", 0, 800))
+	s.Flush()
+
+	// The cutoff is rounded down to a bucket: minutes 0 to 9 go
+	now := storeEpoch.Add(time.Hour + 12*time.Minute)
+	var done []string
+	res, err := s.Compact(now, func(ip string, readings, aggregates int64) {
+		done = append(done, fmt.Sprintf("%s %d %d", ip, readings, aggregates))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Readings != 11 || res.Aggregates != 3 || storeCount(t, s) != 20 {
+		t.Errorf("compacted %d readings into %d aggregates, %d left", res.Readings, res.Aggregates, storeCount(t, s))
+	}
+	slices.Sort(done)
+	if want := []string{"10.0.0.1 10 2", "10.0.0.2 1 1"}; !slices.Equal(done, want) {
+		t.Errorf("progress %q, want %q", done, want)
+	}
+
+	aggs, err := s.aggregates("u1", "10.0.0.1", storeEpoch, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aggs) != 2 {
+		t.Fatalf("%d aggregates, want 2", len(aggs))
+	}
+	co2 := aggs[1].Stats[slices.Index(storeColumns, "co2")]
+	if !aggs[1].Start.Equal(storeEpoch.Add(5*time.Minute)) || aggs[1].Samples != 5 || aggs[1].UUID != "" {
+		t.Errorf("second aggregate from %v, %d samples", aggs[1].Start, aggs[1].Samples)
+	}
+	if co2.Min != 550 || co2.Max != 590 || co2.N != 5 || co2.Avg() != 570 {
+		t.Errorf("co2 %+v", co2)
+	}
+	if lux := aggs[1].Stats[slices.Index(storeColumns, "lux")]; lux.N != 0 {
+		t.Errorf("lux %+v from readings without it", lux)
+	}
+	if !aggs[1].Received.Equal(storeEpoch.Add(9*time.Minute + time.Second)) {
+		t.Errorf("received %v", aggs[1].Received)
+	}
+
+	// Without raw, nothing is compacted
+	s.raw = 0
+	if res, err := s.Compact(now.AddDate(1, 0, 0), nil); err != nil || res.Readings != 0 {
+		t.Errorf("compacted %d readings with raw 0, %v", res.Readings, err)
+	}
+}
+
+func TestStoreCompactIdempotent(t *testing.T) {
+	s := compactTestStore(t)
+	now := storeEpoch.Add(time.Hour + 12*time.Minute)
+	if _, err := s.Compact(now, nil); err != nil {
+		t.Fatal(err)
+	}
+	aggs := storeAggregates(t, s)
+
+	// Again, and again a little later within the same bucket
+	for _, at := range []time.Time{now, now.Add(2 * time.Minute)} {
+		res, err := s.Compact(at, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Readings != 0 || res.Aggregates != 0 || storeCount(t, s) != 20 {
+			t.Errorf("at %v: compacted %d readings into %d aggregates", at, res.Readings, res.Aggregates)
+		}
+		if got := storeAggregates(t, s); !slices.Equal(got, aggs) {
+			t.Errorf("at %v: aggregates changed:\n%q\n%q", at, aggs, got)
+		}
+	}
+
+	// A reading that turns up for a compacted bucket is dropped rather
+	// than changing it
+	s.Add(storeReading("u1", "10.0.0.1", 3, 5000))
+	s.Flush()
+	if res, _ := s.Compact(now, nil); res.Readings != 1 || res.Aggregates != 0 || storeCount(t, s) != 20 {
+		t.Errorf("late reading: compacted %d readings into %d aggregates", res.Readings, res.Aggregates)
+	}
+	if got := storeAggregates(t, s); !slices.Equal(got, aggs) {
+		t.Errorf("a late reading changed the aggregates:\n%q\n%q", aggs, got)
+	}
+
+	// Moving on compacts the next buckets and leaves the first alone
+	if res, _ := s.Compact(now.Add(10*time.Minute), nil); res.Readings != 10 || res.Aggregates != 2 {
+		t.Errorf("10 minutes later: compacted %d readings into %d aggregates", res.Readings, res.Aggregates)
+	}
+	if got := storeAggregates(t, s); len(got) != 4 || !slices.Equal(got[:2], aggs) {
+		t.Errorf("aggregates after moving on:\n%q", got)
+	}
+}
+
+func TestStoreCompactStitching(t *testing.T) {
+	s := compactTestStore(t)
+	before, err := s.Summarize("u1", "10.0.0.1", storeEpoch, storeEpoch.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Compact(storeEpoch.Add(time.Hour+12*time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two 5-minute aggregates of their means, then the raw readings
+	samples, err := s.Recent("u1", "10.0.0.1", storeEpoch, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 22 {
+		t.Fatalf("%d samples, want 22", len(samples))
+	}
+	for i, want := range []struct {
+		minutes int
+		co2     float64
+		score   int
+		period  time.Duration
+	}{
+		{0, 520, 88, compactBucket},
+		{5, 570, 83, compactBucket},
+		{10, 600, 80, 0},
+		{11, 610, 79, 0},
+	} {
+		got := samples[i]
+		if !got.DeviceTime.Equal(storeEpoch.Add(time.Duration(want.minutes)*time.Minute)) || got.Data.CO2 != want.co2 || got.Data.Score != want.score || got.Period != want.period {
+			t.Errorf("sample %d: %v CO₂ %v score %d over %v, want %+v", i, got.DeviceTime, got.Data.CO2, got.Data.Score, got.Period, want)
+		}
+	}
+	// The limit keeps the newest across the boundary
+	samples, _ = s.Recent("u1", "10.0.0.1", storeEpoch, 21)
+	if len(samples) != 21 || !samples[0].DeviceTime.Equal(storeEpoch.Add(5*time.Minute)) {
+		t.Errorf("limited to 21: %d samples from %v", len(samples), samples[0].DeviceTime)
+	}
+	// since applies to the start of a bucket
+	samples, _ = s.Recent("u1", "10.0.0.1", storeEpoch.Add(time.Minute), 100)
+	if len(samples) != 21 {
+		t.Errorf("since minute 1: %d samples", len(samples))
+	}
+
+	// The summary is the same, but for when the lowest score was
+	after, err := s.Summarize("u1", "10.0.0.1", storeEpoch, storeEpoch.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Samples != before.Samples || !after.Since.Equal(before.Since) || !after.Last.Equal(before.Last) {
+		t.Errorf("%d samples from %v to %v, want %d from %v to %v", after.Samples, after.Since, after.Last, before.Samples, before.Since, before.Last)
+	}
+	for key, b := range before.Sensors {
+		a := after.Sensors[key]
+		if a == nil || a.Min != b.Min || a.Max != b.Max || a.N != b.N || math.Abs(a.Sum-b.Sum) > 1e-9 {
+			t.Errorf("%s stats %+v, want %+v", key, a, *b)
+		}
+	}
+	if after.LowestScore != before.LowestScore {
+		t.Errorf("lowest score %d, want %d", after.LowestScore, before.LowestScore)
+	}
+	// Only the aggregates, and only the raw readings
+	compacted, _ := s.Summarize("u1", "10.0.0.1", storeEpoch, storeEpoch.Add(10*time.Minute))
+	raw, _ := s.Summarize("u1", "10.0.0.1", storeEpoch.Add(10*time.Minute), storeEpoch.Add(time.Hour))
+	if compacted.Samples != 10 || raw.Samples != 20 || compacted.Sensors["co2"].Max != 590 || raw.Sensors["co2"].Min != 600 {
+		t.Errorf("compacted %d samples, raw %d", compacted.Samples, raw.Samples)
+	}
+}
+
+func TestStorePurgeAggregates(t *testing.T) {
+	s := compactTestStore(t)
+	s.retain = 2 * time.Hour
+	if _, err := s.Compact(storeEpoch.Add(time.Hour+12*time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Both buckets and the first two raw readings are more than two hours
+	// old
+	n, err := s.Purge(storeEpoch.Add(2*time.Hour + 12*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 || len(storeAggregates(t, s)) != 0 || storeCount(t, s) != 18 {
+		t.Errorf("purged %d, %d aggregates and %d readings left", n, len(storeAggregates(t, s)), storeCount(t, s))
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"72h", 72 * time.Hour, false},
+		{"0", 0, false},
+		{"-1d", 0, true},
+		{"xd", 0, true},
+		{"-5h", 0, true},
+		{"week", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRetention(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseRetention(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// summaryPoorSensors are the sensors whose time spent poor the summary
+// view totals.
+var summaryPoorSensors = []string{"co2", "pm25"}
+
+// summaryMaxGap is the longest gap between two samples that still counts
+// towards time spent poor; a longer one means the device was offline or
+// the app wasn't running. It covers the 15-minute averages.
+const summaryMaxGap = 20 * time.Minute
+
+// SensorStats aggregates one sensor's readings.
+type SensorStats struct {
+	Min, Max, Sum float64
+	N             int
+}
+
+// Add folds v into the stats.
+func (s *SensorStats) Add(v float64) {
+	if s.N == 0 || v < s.Min {
+		s.Min = v
+	}
+	if s.N == 0 || v > s.Max {
+		s.Max = v
+	}
+	s.Sum += v
+	s.N++
+}
+
+// Merge folds o into the stats.
+func (s *SensorStats) Merge(o SensorStats) {
+	if o.N == 0 {
+		return
+	}
+	if s.N == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+	if s.N == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+	s.Sum += o.Sum
+	s.N += o.N
+}
+
+// Avg is the mean reading, 0 without any.
+func (s SensorStats) Avg() float64 {
+	if s.N == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.N)
+}
+
+// Summary aggregates a device's samples for the summary view. It is
+// updated as samples arrive, so rendering it never walks the history.
+type Summary struct {
+	Since, Last time.Time // the first and latest sample
+	Samples     int
+	Sensors     map[string]*SensorStats  // core sensors, temperature in °C
+	Poor        map[string]time.Duration // time spent poor, summaryPoorSensors
+	LowestScore int
+	LowestAt    time.Time
+	FromDB      bool // includes readings from the database, see Merge
+
+	poorNow map[string]bool // whether the latest sample rated poor
+}
+
+// Add folds a sample taken at t into the summary. The time until the
+// next sample is counted as poor if this one rated poor.
+func (s *Summary) Add(d *awair.SensorData, t time.Time) {
+	s.advance(t, 1)
+	for _, r := range d.Readings() {
+		if !slices.Contains(awair.CoreSensors, r.Key) || !awair.Plausible(r.Key, r.Value) {
+			continue
+		}
+		s.stats(r.Key).Add(r.Value)
+	}
+	s.rate(d, d.Score, t)
+}
+
+// AddAggregate folds in samples readings from t on that the database
+// compacted into stats, each sensor's by key, and their mean. Whether
+// they were poor is judged by the mean.
+func (s *Summary) AddAggregate(mean *awair.SensorData, t time.Time, samples int, stats map[string]SensorStats) {
+	s.advance(t, samples)
+	for key, st := range stats {
+		if slices.Contains(awair.CoreSensors, key) {
+			s.stats(key).Merge(st)
+		}
+	}
+	lowest := mean.Score
+	if st := stats["score"]; st.N > 0 {
+		lowest = int(st.Min)
+	}
+	s.rate(mean, lowest, t)
+}
+
+// advance counts samples taken at t, and the time since the last ones
+// as poor for the sensors that were.
+func (s *Summary) advance(t time.Time, samples int) {
+	if s.Sensors == nil {
+		s.Sensors = make(map[string]*SensorStats)
+		s.Poor = make(map[string]time.Duration)
+		s.poorNow = make(map[string]bool)
+	}
+	if gap := t.Sub(s.Last); s.Samples > 0 && gap > 0 && gap <= summaryMaxGap {
+		for key, poor := range s.poorNow {
+			if poor {
+				s.Poor[key] += gap
+			}
+		}
+	}
+	if s.Samples == 0 || t.Before(s.Since) {
+		s.Since = t
+	}
+	if t.After(s.Last) {
+		s.Last = t
+	}
+	s.Samples += samples
+}
+
+// stats returns the sensor's stats, added if it has none yet.
+func (s *Summary) stats(key string) *SensorStats {
+	stats := s.Sensors[key]
+	if stats == nil {
+		stats = &SensorStats{}
+		s.Sensors[key] = stats
+	}
+	return stats
+}
+
+// rate notes which sensors d has poor and whether score, taken at t, is
+// the lowest yet.
+func (s *Summary) rate(d *awair.SensorData, score int, t time.Time) {
+	for _, key := range summaryPoorSensors {
+		s.poorNow[key] = d.Reported(key) && awair.RateSensorValue(key, awair.DisplayValue(key, sensorValue(d, key))) == "poor"
+	}
+	if s.LowestAt.IsZero() || score < s.LowestScore {
+		s.LowestScore, s.LowestAt = score, t
+	}
+}
+
+// Merge folds in older, a summary of the readings before this one's,
+// such as those loaded from the database. It is applied once.
+func (s *Summary) Merge(older *Summary) {
+	if s.FromDB || older == nil || older.Samples == 0 {
+		return
+	}
+	if s.Sensors == nil {
+		// A copy, so adding to s leaves older as it was
+		*s = *older
+		s.Sensors = make(map[string]*SensorStats, len(older.Sensors))
+		for key, o := range older.Sensors {
+			stats := *o
+			s.Sensors[key] = &stats
+		}
+		s.Poor = maps.Clone(older.Poor)
+		s.poorNow = maps.Clone(older.poorNow)
+		s.FromDB = true
+		return
+	}
+	for key, o := range older.Sensors {
+		stats := s.Sensors[key]
+		if stats == nil {
+			stats = &SensorStats{}
+			s.Sensors[key] = stats
+		}
+		stats.Merge(*o)
+	}
+	for key, d := range older.Poor {
+		s.Poor[key] += d
+	}
+	if older.LowestScore < s.LowestScore {
+		s.LowestScore, s.LowestAt = older.LowestScore, older.LowestAt
+	}
+	if older.Since.Before(s.Since) {
+		s.Since = older.Since
+	}
+	s.Samples += older.Samples
+	s.FromDB = true
+}
+
+// sensorValue returns d's reading for the core sensor key.
+func sensorValue(d *awair.SensorData, key string) float64 {
+	for _, r := range d.Readings() {
+		if r.Key == key {
+			return r.Value
+		}
+	}
+	return math.NaN()
+}
+
+// summaryHeaderLines is the space the summary page takes besides its
+// lines: the header (2), status bar (1), border (2), title and column
+// headings (2).
+const summaryHeaderLines = 7
+
+// summaryLines renders the summary table: one row per sensor, grouped by
+// device, with each device's poor time and lowest score under it.
+func (m model) summaryLines() []string {
+	muted := lipgloss.NewStyle().Foreground(theme.Muted)
+	var lines []string
+	for i, dev := range m.orderedDevices() {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		s := &dev.Summary
+		name := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(dev.Title())
+		if s.Samples == 0 {
+			lines = append(lines, name+"  "+muted.Render("no readings yet"))
+			continue
+		}
+		span := "this session"
+		if s.FromDB {
+			span = "last 24 hours"
+		}
+		lines = append(lines, name+"  "+muted.Render(fmt.Sprintf("%s, %d samples over %s", span, s.Samples, shortDuration(s.Last.Sub(s.Since)))))
+		for _, key := range awair.CoreSensors {
+			stats := s.Sensors[key]
+			if stats == nil || stats.N == 0 {
+				continue
+			}
+			rating := awair.RateSensorValue(key, awair.DisplayValue(key, stats.Max))
+			lines = append(lines, "  "+visPadRight(awair.OptimalRanges[key].Label, 14)+
+				visPadLeft(awair.FormatValue(key, stats.Min, m.fahrenheit), 12)+
+				visPadLeft(awair.FormatValue(key, stats.Avg(), m.fahrenheit), 12)+
+				lipgloss.NewStyle().Foreground(ratingColor(rating)).Render(visPadLeft(ratingMark(rating)+awair.FormatValue(key, stats.Max, m.fahrenheit), 12+markWidth())))
+		}
+		var poor []string
+		for _, key := range summaryPoorSensors {
+			if stats := s.Sensors[key]; stats != nil && stats.N > 0 {
+				poor = append(poor, fmt.Sprintf("%s poor %s", awair.OptimalRanges[key].Label, shortDuration(s.Poor[key])))
+			}
+		}
+		lowest := lipgloss.NewStyle().Foreground(scoreColor(s.LowestScore)).Render(fmt.Sprintf("%d", s.LowestScore))
+		lines = append(lines, "  "+muted.Render(strings.Join(append(poor, "lowest score "), " · "))+lowest+
+			muted.Render(" at "+s.LowestAt.Local().Format("Jan 2 15:04")))
+	}
+	return lines
+}
+
+// summaryMaxScroll is the largest useful scroll offset for the summary.
+func (m model) summaryMaxScroll() int {
+	return max(len(m.summaryLines())-max(m.height-summaryHeaderLines, 1), 0)
+}
+
+func (m model) handleSummaryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "s":
+		m.showSummary = false
+		m.summaryScroll = 0
+	case "up", "k":
+		m.summaryScroll = max(m.summaryScroll-1, 0)
+	case "down", "j":
+		m.summaryScroll = min(m.summaryScroll+1, m.summaryMaxScroll())
+	case "home", "g":
+		m.summaryScroll = 0
+	case "end", "G":
+		m.summaryScroll = m.summaryMaxScroll()
+	case "q", "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// renderSummary renders the summary page, which takes the grid and log
+// panel's place.
+func (m model) renderSummary() string {
+	lines := m.summaryLines()
+	visible := max(m.height-summaryHeaderLines, 1)
+	scroll := min(m.summaryScroll, m.summaryMaxScroll())
+	end := min(scroll+visible, len(lines))
+
+	title := lipgloss.NewStyle().Bold(true).Render("Summary") + "  " +
+		lipgloss.NewStyle().Foreground(theme.Muted).Render("min, average and max per sensor; max colored by rating")
+	columns := lipgloss.NewStyle().Bold(true).Foreground(theme.Muted).Render("  " + visPadRight("Sensor", 14) +
+		visPadLeft("Min", 12) + visPadLeft("Avg", 12) + visPadLeft("Max", 12+markWidth()))
+
+	return lipgloss.NewStyle().
+		Width(m.width-2).
+		Border(glyphs.Border).
+		BorderForeground(theme.Muted).
+		Padding(0, 1).
+		Render(title + "\n" + columns + "\n" +
+			lipgloss.NewStyle().MaxWidth(m.width-4).Render(strings.Join(lines[scroll:end], "\n")))
+}
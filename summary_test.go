@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// summarySample is a reading with the given CO₂, PM2.5 and score.
+func summarySample(co2, pm25 float64, score int) *awair.SensorData {
+	return &awair.SensorData{Score: score, Temp: 22, Humid: 50, CO2: co2, VOC: 100, PM25: pm25}
+}
+
+func TestSensorStats(t *testing.T) {
+	var s SensorStats
+	if s.Avg() != 0 {
+		t.Errorf("avg of nothing %v", s.Avg())
+	}
+	for _, v := range []float64{3, -5, 10, 0} {
+		s.Add(v)
+	}
+	if s.Min != -5 || s.Max != 10 || s.N != 4 || s.Avg() != 2 {
+		t.Errorf("stats %+v, avg %v", s, s.Avg())
+	}
+
+	// One reading is the min and the max, even if it isn't 0
+	var one SensorStats
+	one.Add(7)
+	if one.Min != 7 || one.Max != 7 || one.Avg() != 7 {
+		t.Errorf("one reading: %+v", one)
+	}
+
+	// Merging is the same as adding one by one
+	var a, b, all SensorStats
+	for i, v := range []float64{12, 4, 9, 30, -2, 8} {
+		if i < 3 {
+			a.Add(v)
+		} else {
+			b.Add(v)
+		}
+		all.Add(v)
+	}
+	a.Merge(b)
+	if a != all {
+		t.Errorf("merged %+v, want %+v", a, all)
+	}
+	a.Merge(SensorStats{})
+	if a != all {
+		t.Errorf("merging nothing changed %+v", a)
+	}
+	var empty SensorStats
+	empty.Merge(b)
+	if empty != b {
+		t.Errorf("merged into nothing: %+v, want %+v", empty, b)
+	}
+}
+
+func TestSummaryAdd(t *testing.T) {
+	var s Summary
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	// CO₂ is poor above 1200 ppm, PM2.5 above 24 µg/m³
+	steps := []struct {
+		minutes   int
+		co2, pm25 float64
+		score     int
+	}{
+		{0, 1500, 3, 70},
+		{5, 1500, 3, 65},   // CO₂ poor for 5m
+		{10, 500, 3, 90},   // and another 5m
+		{15, 1500, 40, 65}, // poor until the device went away
+		{45, 1500, 40, 60}, // 30 minutes later: not counted
+		{50, 600, 3, 95},   // poor for 5m more, both
+	}
+	for _, st := range steps {
+		s.Add(summarySample(st.co2, st.pm25, st.score), start.Add(time.Duration(st.minutes)*time.Minute))
+	}
+
+	if s.Samples != 6 || !s.Since.Equal(start) || !s.Last.Equal(start.Add(50*time.Minute)) {
+		t.Errorf("%d samples from %v to %v", s.Samples, s.Since, s.Last)
+	}
+	if s.Poor["co2"] != 15*time.Minute || s.Poor["pm25"] != 5*time.Minute {
+		t.Errorf("poor for %v and %v, want 15m and 5m", s.Poor["co2"], s.Poor["pm25"])
+	}
+	co2 := s.Sensors["co2"]
+	if co2.Min != 500 || co2.Max != 1500 || co2.N != 6 || math.Abs(co2.Avg()-7100.0/6) > 1e-9 {
+		t.Errorf("co2 stats %+v", *co2)
+	}
+	if s.LowestScore != 60 || !s.LowestAt.Equal(start.Add(45*time.Minute)) {
+		t.Errorf("lowest score %d at %v", s.LowestScore, s.LowestAt)
+	}
+	// Only the core sensors; dew point and the rest are derived from them
+	for key := range s.Sensors {
+		if key != "temp" && key != "humid" && key != "co2" && key != "voc" && key != "pm25" {
+			t.Errorf("stats for %s", key)
+		}
+	}
+}
+
+func TestSummaryPoorGaps(t *testing.T) {
+	var s Summary
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	s.Add(summarySample(1500, 3, 60), start)
+	// A gap of exactly summaryMaxGap still counts
+	s.Add(summarySample(1500, 3, 60), start.Add(summaryMaxGap))
+	s.Add(summarySample(1500, 3, 60), start.Add(2*summaryMaxGap+time.Second))
+	if s.Poor["co2"] != summaryMaxGap {
+		t.Errorf("poor for %v, want %v", s.Poor["co2"], summaryMaxGap)
+	}
+
+	// A sample from before the last one, e.g. after the clock stepped
+	// back, widens the span but counts no time
+	before := s.Poor["co2"]
+	s.Add(summarySample(1500, 3, 60), start.Add(-time.Minute))
+	if s.Poor["co2"] != before {
+		t.Errorf("poor for %v after a sample back in time", s.Poor["co2"])
+	}
+	if !s.Since.Equal(start.Add(-time.Minute)) || !s.Last.Equal(start.Add(2*summaryMaxGap+time.Second)) {
+		t.Errorf("span %v to %v", s.Since, s.Last)
+	}
+}
+
+func TestSummarySkipsMissingAndImplausible(t *testing.T) {
+	var s Summary
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	// A Mint has no CO₂ sensor, so its 0 isn't a reading; nor is 150 °C
+	for i, data := range []string{
+		`{"score": 80, "temp": 22, "humid": 50, "co2": null, "voc": 100, "pm25": 30}`,
+		`{"score": 80, "temp": 150, "humid": 50, "co2": null, "voc": 100, "pm25": 30}`,
+	} {
+		var mint awair.SensorData
+		if err := json.Unmarshal([]byte(data), &mint); err != nil {
+			t.Fatal(err)
+		}
+		s.Add(&mint, start.Add(time.Duration(i)*time.Minute))
+	}
+	if _, ok := s.Sensors["co2"]; ok {
+		t.Errorf("co2 stats %+v", *s.Sensors["co2"])
+	}
+	if temp := s.Sensors["temp"]; temp.N != 1 || temp.Max != 22 {
+		t.Errorf("temp stats %+v", *temp)
+	}
+	if s.Poor["pm25"] != time.Minute || s.Poor["co2"] != 0 {
+		t.Errorf("poor for %v and %v", s.Poor["pm25"], s.Poor["co2"])
+	}
+}
+
+func TestSummaryLowestScoreTie(t *testing.T) {
+	var s Summary
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	for i, score := range []int{80, 55, 70, 55} {
+		s.Add(summarySample(500, 3, score), start.Add(time.Duration(i)*time.Minute))
+	}
+	// The first time it got that low
+	if s.LowestScore != 55 || !s.LowestAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("lowest score %d at %v", s.LowestScore, s.LowestAt)
+	}
+}
+
+func TestSummaryMerge(t *testing.T) {
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	var older, session Summary
+	older.Add(summarySample(1500, 3, 50), start)
+	older.Add(summarySample(800, 3, 85), start.Add(5*time.Minute))
+	session.Add(summarySample(1300, 3, 60), start.Add(time.Hour))
+	session.Add(summarySample(400, 3, 95), start.Add(time.Hour+5*time.Minute))
+
+	session.Merge(&older)
+	if !session.FromDB || session.Samples != 4 {
+		t.Fatalf("merged %d samples, from the database %v", session.Samples, session.FromDB)
+	}
+	if !session.Since.Equal(start) || !session.Last.Equal(start.Add(time.Hour+5*time.Minute)) {
+		t.Errorf("span %v to %v", session.Since, session.Last)
+	}
+	co2 := session.Sensors["co2"]
+	if co2.Min != 400 || co2.Max != 1500 || co2.N != 4 || co2.Avg() != 1000 {
+		t.Errorf("co2 stats %+v", *co2)
+	}
+	if session.Poor["co2"] != 10*time.Minute {
+		t.Errorf("poor for %v, want 10m", session.Poor["co2"])
+	}
+	if session.LowestScore != 50 || !session.LowestAt.Equal(start) {
+		t.Errorf("lowest score %d at %v", session.LowestScore, session.LowestAt)
+	}
+
+	// Once only
+	session.Merge(&older)
+	if session.Samples != 4 || session.Sensors["co2"].N != 4 {
+		t.Errorf("merged twice: %d samples", session.Samples)
+	}
+	// Samples keep coming after the merge
+	session.Add(summarySample(1300, 3, 40), start.Add(time.Hour+10*time.Minute))
+	if session.Samples != 5 || session.LowestScore != 40 {
+		t.Errorf("after the merge: %d samples, lowest %d", session.Samples, session.LowestScore)
+	}
+}
+
+func TestSummaryMergeIntoEmpty(t *testing.T) {
+	start := time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC)
+	var older Summary
+	older.Add(summarySample(1500, 3, 50), start)
+	older.Add(summarySample(800, 3, 85), start.Add(5*time.Minute))
+
+	var s Summary
+	s.Merge(nil)
+	s.Merge(&Summary{})
+	if s.FromDB || s.Samples != 0 {
+		t.Fatalf("merged nothing: %+v", s)
+	}
+	s.Merge(&older)
+	if !s.FromDB || s.Samples != 2 || s.Poor["co2"] != 5*time.Minute {
+		t.Errorf("merged into nothing: %+v", s)
+	}
+	// The merged summary doesn't share its maps with older
+	s.Add(summarySample(1500, 3, 50), start.Add(10*time.Minute))
+	if older.Sensors["co2"].N != 2 || older.Poor["co2"] != 5*time.Minute {
+		t.Errorf("adding to the merged summary changed the older one: %+v", older)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Dashboard layouts, toggled with t.
+const (
+	viewGrid  = "grid"
+	viewTable = "table"
+)
+
+// tableColumn is one column of the device table. cell returns the text
+// for a device and its color.
+type tableColumn struct {
+	title string
+	width int
+	right bool // right-aligned
+	rated bool // cells start with a rating mark, with themes that use them
+	cell  func(m model, dev *Device) (string, lipgloss.Color)
+}
+
+// cellWidth is the column's width, with room for rating marks.
+func (c tableColumn) cellWidth() int {
+	if c.rated {
+		return c.width + markWidth()
+	}
+	return c.width
+}
+
+// sensorColumn shows one sensor reading colored by its rating.
+func sensorColumn(title, key string, width int) tableColumn {
+	return tableColumn{title: title, width: width, right: true, rated: true, cell: func(m model, dev *Device) (string, lipgloss.Color) {
+		if dev.Data == nil {
+			return "—", theme.Muted
+		}
+		for _, s := range dev.Data.Readings() {
+			if s.Key == key {
+				rating := awair.RateSensorValue(key, awair.DisplayValue(key, s.Value))
+				return ratingMark(rating) + awair.FormatValue(key, s.Value, m.fahrenheit), ratingColor(rating)
+			}
+		}
+		return "—", theme.Muted
+	}}
+}
+
+// tableColumns are in priority order: when the terminal is too narrow the
+// rightmost columns are dropped first.
+var tableColumns = []tableColumn{
+	{title: "Name", width: 20, cell: func(m model, dev *Device) (string, lipgloss.Color) {
+		return dev.Title(), theme.Accent
+	}},
+	{title: "IP", width: 15, cell: func(m model, dev *Device) (string, lipgloss.Color) {
+		return dev.IP, theme.Muted
+	}},
+	{title: "Score", width: 9, right: true, cell: func(m model, dev *Device) (string, lipgloss.Color) {
+		switch {
+		case dev.Data != nil:
+			shown := m.shownScore(dev)
+			return fmt.Sprintf("%d %s", shown, scoreLabel(shown)), scoreColor(shown)
+		case dev.LastError != nil:
+			return "error", theme.Poor
+		default:
+			return "…", theme.Fair
+		}
+	}},
+	sensorColumn("Temp", "temp", 8),
+	sensorColumn("Humid", "humid", 7),
+	sensorColumn("CO₂", "co2", 9),
+	sensorColumn("VOC", "voc", 9),
+	sensorColumn("PM2.5", "pm25", 9),
+	{title: "Updated", width: 9, right: true, cell: func(m model, dev *Device) (string, lipgloss.Color) {
+		if dev.LastUpdate.IsZero() {
+			return "never", theme.Muted
+		}
+		return age(dev.LastUpdate).Round(time.Second).String() + " ago", theme.Muted
+	}},
+}
+
+// fitTableColumns returns the leading columns that fit in width.
+func fitTableColumns(width int) []tableColumn {
+	used := 0
+	for i, c := range tableColumns {
+		// columns are separated by two spaces
+		if used+c.cellWidth() > width {
+			return tableColumns[:i]
+		}
+		used += c.cellWidth() + 2
+	}
+	return tableColumns
+}
+
+// tableRows is how many device rows fit under the table header.
+func tableRows(height int) int {
+	if height-1 < 1 {
+		return 1
+	}
+	return height - 1
+}
+
+// renderDeviceTable renders one row per device, showing the page that
+// holds the selection.
+func (m model) renderDeviceTable(height int) string {
+	devs := m.orderedDevices()
+	if len(devs) == 0 {
+		return m.renderEmptyState(height)
+	}
+	cols := fitTableColumns(m.width - 2)
+
+	cell := func(c tableColumn, text string) string {
+		w := c.cellWidth()
+		text = truncateWidth(text, w)
+		if c.right {
+			return visPadLeft(text, w)
+		}
+		return visPadRight(text, w)
+	}
+
+	var titles []string
+	for _, c := range cols {
+		titles = append(titles, cell(c, c.title))
+	}
+	lines := []string{lipgloss.NewStyle().Bold(true).Foreground(theme.Muted).Render(" " + strings.Join(titles, "  "))}
+
+	perPage, _ := m.gridPaging()
+	first := (m.selected / perPage) * perPage
+	for i, dev := range devs[first:min(first+perPage, len(devs))] {
+		selected := first+i == m.selected
+		var cells []string
+		for _, c := range cols {
+			text, color := c.cell(m, dev)
+			style := lipgloss.NewStyle().Foreground(color)
+			if selected {
+				style = style.Background(theme.Dim).Bold(true)
+			}
+			cells = append(cells, style.Render(cell(c, text)))
+		}
+		sep, marker := "  ", " "
+		if selected {
+			sep = lipgloss.NewStyle().Background(theme.Dim).Render(sep)
+			marker = lipgloss.NewStyle().Background(theme.Dim).Foreground(theme.Accent).Render("▌")
+		}
+		lines = append(lines, marker+strings.Join(cells, sep))
+	}
+
+	return lipgloss.NewStyle().Width(m.width).Height(height).MaxHeight(height).
+		Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// runTestAlert sends a made-up alert (sampleAlertEvent) through the
+// configured outputs, --notify, --alert-webhook and --alert-exec, once
+// each without retries, and reports how each went. It returns 0 if all
+// of them worked, 1 if any failed and 2 if there are none.
+func runTestAlert(s Settings) int {
+	if !s.Notify && s.AlertWebhook == "" && s.AlertExec == "" {
+		fmt.Fprintln(os.Stderr, "Error: --test-alert: no alert outputs; add --notify, --alert-webhook or --alert-exec")
+		return 2
+	}
+	tmpl, _ := parseAlertTemplates(s)
+	ev := sampleAlertEvent(time.Now())
+	fmt.Printf("Sending a test alert: %s %s %s (%s)\n", ev.Name, ev.Label, ev.Formatted, ev.Rating)
+
+	failed := false
+	report := func(output string, err error) {
+		if err != nil {
+			failed = true
+			fmt.Printf("  %s: failed: %v\n", output, err)
+			return
+		}
+		fmt.Printf("  %s: ok\n", output)
+	}
+
+	if s.Notify {
+		report("desktop notification", sendNotification(
+			fmt.Sprintf("%s: %s is poor", ev.Name, ev.Label),
+			fmt.Sprintf("%s %s %s", ev.Name, ev.Label, ev.Formatted)))
+	}
+	if s.AlertWebhook != "" {
+		body, err := tmpl.webhookBody(ev)
+		if err == nil {
+			fmt.Printf("  webhook body: %s\n", body)
+			_, err = postWebhook(s.AlertWebhook, body)
+		}
+		report("webhook "+redactAddress(s.AlertWebhook), err)
+	}
+	if s.AlertExec != "" {
+		if args, err := tmpl.execArgs(ev); err == nil && len(args) > 0 {
+			fmt.Printf("  command arguments: %q\n", args)
+		}
+		out, err := runAlertExec(s.AlertExec, tmpl, ev)
+		report("command", err)
+		for _, l := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+			if strings.TrimSpace(l) != "" {
+				fmt.Printf("    %s\n", l)
+			}
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Theme holds the colors everything is drawn with.
+type Theme struct {
+	Good, Fair, Poor lipgloss.Color // ratings
+	Accent           lipgloss.Color // names, headings, sparklines
+	Muted            lipgloss.Color // secondary text, stale readings
+	Dim              lipgloss.Color // selection background
+	BarFG, BarBG     lipgloss.Color // status bars
+
+	// Marks puts a rating mark (✓ ! ✗) before colored readings, so
+	// color is never the only signal.
+	Marks bool
+}
+
+// Built-in themes, selected with --theme or "theme" in the config.
+var themes = map[string]Theme{
+	"default": {
+		Good: "#00FF00", Fair: "#FFFF00", Poor: "#FF0000",
+		Accent: "#00FFFF", Muted: "#888888", Dim: "#333333",
+		BarFG: "#FFFFFF", BarBG: "#333333",
+	},
+	// Okabe-Ito colors, which stay apart with the common kinds of color
+	// blindness
+	"colorblind": {
+		Good: "#56B4E9", Fair: "#E69F00", Poor: "#D55E00",
+		Accent: "#CC79A7", Muted: "#888888", Dim: "#333333",
+		BarFG: "#FFFFFF", BarBG: "#333333",
+		Marks: true,
+	},
+	// For dark text on a light background
+	"light": {
+		Good: "#008700", Fair: "#AF8700", Poor: "#D70000",
+		Accent: "#005F87", Muted: "#6C6C6C", Dim: "#D0D0D0",
+		BarFG: "#000000", BarBG: "#D0D0D0",
+	},
+}
+
+const defaultTheme = "default"
+
+// theme is the theme in use, chosen at startup.
+var theme = themes[defaultTheme]
+
+// themeNames lists the built-in themes for messages.
+func themeNames() string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// themeColors maps the keys of "theme_colors" in the config to the
+// colors they override.
+func (t *Theme) themeColors() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"good":   &t.Good,
+		"fair":   &t.Fair,
+		"poor":   &t.Poor,
+		"accent": &t.Accent,
+		"muted":  &t.Muted,
+		"dim":    &t.Dim,
+		"bar_fg": &t.BarFG,
+		"bar_bg": &t.BarBG,
+	}
+}
+
+// validThemeColors returns the entries of the "theme_colors" config
+// section that name a known color and hold a hex value like "#0072B2";
+// the rest are logged and skipped.
+func validThemeColors(colors map[string]string) map[string]string {
+	known := new(Theme).themeColors()
+	keys := make([]string, 0, len(colors))
+	for k := range colors {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	valid := make(map[string]string)
+	for _, k := range keys {
+		v := colors[k]
+		switch {
+		case known[k] == nil:
+			logf(levelWarn, "config theme_colors: unknown color %q ignored", k)
+		case !hexColor.MatchString(v):
+			logf(levelWarn, "config theme_colors: %s: %q is not a hex color like #0072B2; ignored", k, v)
+		default:
+			valid[k] = v
+		}
+	}
+	return valid
+}
+
+// configureTheme selects the theme from the settings. With NoColor,
+// styles render no colors at all and ratings are marked instead.
+func configureTheme(s Settings) {
+	theme = themes[s.Theme]
+	fields := theme.themeColors()
+	for k, v := range s.ThemeColors {
+		*fields[k] = lipgloss.Color(v)
+	}
+	if s.NoColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		theme.Marks = true
+	}
+}
+
+// ratingMark returns the mark for a "good", "fair" or "poor" rating and a
+// space, or "" if the theme has no marks.
+func ratingMark(rating string) string {
+	if !theme.Marks {
+		return ""
+	}
+	switch rating {
+	case "good":
+		return glyphs.Good + " "
+	case "fair":
+		return glyphs.Fair + " "
+	default:
+		return glyphs.Poor + " "
+	}
+}
+
+// markWidth is the width ratingMark adds.
+func markWidth() int {
+	if !theme.Marks {
+		return 0
+	}
+	return 2
+}
@@ -3,44 +3,41 @@ package main
 import (
 	"context"
 	"fmt"
-	"net"
+	"math"
+	"net/netip"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-)
-
-// Color palette.
-var (
-	colorGood = lipgloss.Color("#00FF00")
-	colorFair = lipgloss.Color("#FFFF00")
-	colorPoor = lipgloss.Color("#FF0000")
-	colorCyan = lipgloss.Color("#00FFFF")
-	colorGray = lipgloss.Color("#888888")
-	colorDim  = lipgloss.Color("#333333")
+	"github.com/charmbracelet/x/ansi"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+	"github.com/xxdesmus/awair-tui/pkg/discovery"
 )
 
 func ratingColor(rating string) lipgloss.Color {
 	switch rating {
 	case "good":
-		return colorGood
+		return theme.Good
 	case "fair":
-		return colorFair
+		return theme.Fair
 	default:
-		return colorPoor
+		return theme.Poor
 	}
 }
 
 func scoreColor(score int) lipgloss.Color {
 	if score >= 80 {
-		return colorGood
+		return theme.Good
 	}
 	if score >= 60 {
-		return colorFair
+		return theme.Fair
 	}
-	return colorPoor
+	return theme.Poor
 }
 
 func scoreLabel(score int) string {
@@ -53,71 +50,251 @@ func scoreLabel(score int) string {
 	return "Poor"
 }
 
+// shownScore returns the score to display for dev, which must have data:
+// the smoothed score if smoothing is on, else the latest one. Labels and
+// colors are derived from it so they always agree with the number.
+func (m model) shownScore(dev *Device) int {
+	if m.smoothScore > 1 {
+		if score, ok := dev.History.smoothedScore(m.smoothScore, m.smoothMode); ok {
+			return score
+		}
+	}
+	return dev.Data.Score
+}
+
 // logEntry is a timestamped log message.
 type logEntry struct {
 	Time    time.Time
+	Level   logLevel
 	Message string
 }
 
 // Message types for bubbletea.
-type tickMsg time.Time
+// tickMsg carries the generation of the tick loop that produced it, so
+// that restarting the loop (e.g. after an interval change) retires the
+// previous one instead of running both.
+type tickMsg struct {
+	Gen int
+}
 
 type pollResultMsg struct {
-	IP   string
-	Data *SensorData
-	Err  error
+	IP       string
+	Data     *awair.SensorData
+	Err      error
+	Latency  time.Duration // how long FetchAirData took
+	FellBack bool          // the endpoint wasn't served; Data is from latest
 }
 
 type configResultMsg struct {
 	IP     string
-	Config *DeviceConfig
+	Config *awair.DeviceConfig
 }
 
-type discoveredMsg DiscoveredDevice
+// hostResolvedMsg reports the lookup of a device host name.
+type hostResolvedMsg struct {
+	Host  string
+	Addrs []string
+	Err   error
+}
+
+type discoveredMsg discovery.Device
+
+// deviceID is a stable handle for a device, assigned when it is added and
+// never reused. Views and deferred work hold IDs rather than *Device or
+// IPs and look them up with model.device, so once a device is removed
+// they find nothing instead of acting on a stale device.
+type deviceID int
 
 // model is the bubbletea application state.
 type model struct {
 	devices     map[string]*Device
 	deviceOrder []string // stable insertion order
 	config      *Config
+	records     *RecordStore
 	logs        []logEntry
 	width       int
 	height      int
 	fahrenheit  bool
 
+	selected int      // index into orderedDevices()
+	detailID deviceID // device shown in the detail view, 0 for the grid
+	zoomID   deviceID // device zoomed to the whole grid area, 0 for the grid
+	viewMode string   // viewGrid or viewTable
+	sortMode string   // one of sortModes; applied on top of deviceOrder
+
+	chartSensor int // index into chartSensors for the detail chart
+
+	// focusSensor is highlighted on every card, the rest dimmed; "" for
+	// no focus. Cycled with f.
+	focusSensor string
+
+	confirm *confirmPrompt  // pending yes/no question, if any
+	ignored map[string]bool // devices removed this session; not re-added by discovery
+
 	showPrompt  bool
-	promptStep  string // "ip" or "name"
+	promptStep  string // "ip", "resolving", "name", "rename" or "scan"
 	promptInput textinput.Model
 	pendingIP   string
 
+	// In slow-terminal mode the prompt cursor doesn't blink and the
+	// screen around the prompt is frozen while it is open, so typing only
+	// repaints the input line. The clock stops too, and starts again once
+	// the prompt closes.
+	slowTerminal bool
+	frozen       *frame
+	clockStopped bool
+
+	mini bool // always use the mini list view, whatever the height
+
+	// With smoothScore > 1, cards show the smoothMode of the last
+	// smoothScore scores; details and exports keep the raw score.
+	smoothScore int
+	smoothMode  string
+
+	advisories  AdvisoryRules // when details advise on mold and ventilation
+	cardSensors []string      // sensors on grid cards, in order; nil for all
+	slowLatency time.Duration // poll latency cards show as slow
+
+	historyEndpoint string // averages that seed a new device's history
+	pollEndpoint    string // air data endpoint devices are polled at by default
+
+	store        *ReadingStore // nil unless --db
+	storeFailing bool          // the last flush failed and was logged
+
+	api *apiServer // nil unless --serve; gets a snapshot after every update
+
+	maxClockSkew time.Duration // device clock offset cards warn about
+
+	showHelp   bool
+	helpScroll int
+
+	whatsNew []changelogEntry // shown once after an upgrade; nil when dismissed
+
+	// The log panel expands into a scrollable viewer with l. logFollow
+	// keeps it pinned to the newest entry.
+	showLogs  bool
+	logView   viewport.Model
+	logFollow bool
+
+	// The summary page (s) replaces the grid and log panel.
+	showSummary   bool
+	summaryScroll int
+
+	logPanelScroll int       // entries the log panel is scrolled back with the wheel
+	click          lastClick // the last click on a device, see clickDevice
+
+	discoveryBurst []deviceID // discovered since the burst window opened
+
+	// Discovered devices beyond maxDiscovered, or all of them while no
+	// device is known, wait in the picker instead of being added
+	// automatically.
+	showPicker      bool
+	picker          devicePicker
+	maxDiscovered   int
+	discoveredAdded int
+
+	lastID deviceID // last handle handed out
+
 	pollInterval time.Duration
+	lastTick     time.Time // when the tick loop last fired
+	nextTick     time.Time // when it fires next, for the status bar countdown
+	tickGen      int       // current tick loop; older ticks are dropped
+	paused       bool      // ticks don't poll while paused
 	noDiscovery  bool
+	discovering  bool      // a discovery pass (d) is running
+	remember     bool      // save discovered devices to the config
+	configStamp  fileStamp // config file as last checked, see configwatch.go
+	fetchConfig  bool      // fetch /settings/config/data for each device
+	showFirmware bool      // firmware version in card footers
+	alertRules   []AlertRule
+	notifier     *notifier // nil unless --notify, --alert-webhook or --alert-exec
+	bell         bool      // ring the bell when a sensor turns poor
+	flash        bool      // highlight the card when a sensor turns poor
+	flashes      map[deviceID]*flashState
 	discoveryCtx func() // cancel function for discovery
+
+	// Subnet scan (--scan, S); scanning is its range while one runs
+	scanOnStart netip.Prefix
+	scanning    string
+	cancelScan  context.CancelFunc
+	scanFound   int
+
+	// ctx is the parent of every device's request context; quitting
+	// cancels it so no request outlives the program.
+	ctx            context.Context
+	cancelRequests context.CancelFunc
 }
 
-func initialModel(cfg *Config, ips []string, interval int, noDiscovery, fahrenheit bool) model {
+func initialModel(cfg *Config, records *RecordStore, s Settings) model {
 	ti := textinput.New()
-	ti.CharLimit = 64
+	ti.CharLimit = 256 // base URLs with credentials can be long
 	ti.Width = 40
+	if s.SlowTerminal {
+		ti.Cursor.SetMode(cursor.CursorStatic)
+	}
 
 	m := model{
-		devices:      make(map[string]*Device),
-		deviceOrder:  []string{},
-		config:       cfg,
-		logs:         []logEntry{},
-		fahrenheit:   fahrenheit,
-		promptInput:  ti,
-		pollInterval: time.Duration(interval) * time.Second,
-		noDiscovery:  noDiscovery,
+		devices:         make(map[string]*Device),
+		ignored:         make(map[string]bool),
+		deviceOrder:     []string{},
+		config:          cfg,
+		records:         records,
+		logs:            []logEntry{},
+		fahrenheit:      s.Fahrenheit,
+		promptInput:     ti,
+		pollInterval:    time.Duration(s.Interval) * time.Second,
+		noDiscovery:     s.NoDiscovery,
+		remember:        s.RememberDiscovered,
+		fetchConfig:     s.FetchDeviceConfig,
+		showFirmware:    s.ShowFirmware,
+		alertRules:      defaultAlertRules(),
+		maxDiscovered:   s.MaxDiscovered,
+		slowTerminal:    s.SlowTerminal,
+		mini:            s.Mini,
+		bell:            s.Bell,
+		flash:           s.Flash,
+		flashes:         make(map[deviceID]*flashState),
+		smoothScore:     s.SmoothScore,
+		smoothMode:      s.SmoothMode,
+		advisories:      s.Advisories,
+		cardSensors:     s.CardSensors,
+		slowLatency:     s.SlowLatency,
+		historyEndpoint: s.HistoryEndpoint,
+		pollEndpoint:    s.PollEndpoint,
+		maxClockSkew:    s.MaxClockSkew,
+		whatsNew:        checkUpgrade(cfg),
+		viewMode:        viewGrid,
+		sortMode:        validSortMode(cfg.Sort),
+		configStamp:     statConfig(),
+		nextTick:        time.Now().Add(time.Duration(s.Interval) * time.Second),
 	}
 
-	// Load config-defined device count
+	m.notifier = newNotifier(s)
+	m.ctx, m.cancelRequests = context.WithCancel(context.Background())
+
+	// Add devices saved in the config
 	if len(cfg.Devices) > 0 {
-		m.addLog(fmt.Sprintf("Loaded %d device name(s) from config", len(cfg.Devices)))
+		m.addLog(fmt.Sprintf("Loaded %d saved device(s) from config", len(cfg.Devices)))
+	}
+	for _, e := range cfg.DevicesFrom(entryManual) {
+		m.addDevice(e.IP, e.Instance)
+	}
+	if s.RememberDiscovered {
+		for _, e := range cfg.DevicesFrom(entryDiscovered) {
+			m.addDevice(e.IP, e.Instance)
+			m.discoveredAdded++
+		}
+	}
+
+	if s.Demo > 0 {
+		for _, key := range setupDemo(s.Demo) {
+			m.addDevice(key, demoName(key))
+		}
+		m.addLog(fmt.Sprintf("Demo mode: %d simulated device(s); discovery is off and nothing is saved", s.Demo))
 	}
 
 	// Add CLI-specified devices
-	for _, ip := range ips {
+	for _, ip := range s.IPs {
 		dev := m.addDevice(ip, "")
 		m.addLog(fmt.Sprintf("Added device: %s", dev.Name))
 	}
@@ -126,15 +303,37 @@ func initialModel(cfg *Config, ips []string, interval int, noDiscovery, fahrenhe
 }
 
 func (m *model) addLog(msg string) {
-	m.logs = append(m.logs, logEntry{Time: time.Now(), Message: msg})
-	if len(m.logs) > 100 {
-		m.logs = m.logs[1:]
+	m.logAt(levelInfo, msg)
+}
+
+// logAt adds a log panel entry at the given level. When the log is full
+// the oldest entry below warn is evicted first, so problems outlive
+// routine chatter.
+func (m *model) logAt(level logLevel, msg string) {
+	m.logs = append(m.logs, logEntry{Time: time.Now(), Level: level, Message: msg})
+	appLog.Log(level, msg)
+	if len(m.logs) > maxLogEntries {
+		evict := 0
+		for i, e := range m.logs {
+			if e.Level < levelWarn {
+				evict = i
+				break
+			}
+		}
+		m.logs = append(m.logs[:evict], m.logs[evict+1:]...)
+	}
+	if m.logPanelScroll > 0 {
+		// Keep showing the same entries
+		m.scrollLogPanel(1)
+	}
+	if m.showLogs {
+		m.syncLogView()
 	}
 }
 
 func (m *model) addDevice(ip, name string) *Device {
 	// Config names take priority
-	configName := m.config.Devices[ip]
+	configName := m.config.Name("", ip)
 
 	if existing, ok := m.devices[ip]; ok {
 		if configName != "" {
@@ -152,15 +351,184 @@ func (m *model) addDevice(ip, name string) *Device {
 		displayName = name
 	}
 
+	m.lastID++
 	dev := &Device{
-		IP:   ip,
-		Name: displayName,
+		ID:             m.lastID,
+		IP:             ip,
+		Name:           displayName,
+		DiscoveredName: name,
+	}
+	if e := m.config.Entry("", ip); e != nil && m.deviceByUUID(e.UUID) == nil {
+		// Until its config says otherwise, so a device saved at an old
+		// address can be matched when it turns up at a new one
+		dev.UUID = e.UUID
+	}
+	dev.endpoint = m.pollEndpoint
+	if e := m.config.Entry("", ip); e != nil && e.Endpoint != "" {
+		if validPollEndpoint(e.Endpoint) {
+			dev.endpoint = e.Endpoint
+		} else {
+			logf(levelWarn, "%s: unknown endpoint %q; polling %s", ip, e.Endpoint, dev.endpoint)
+		}
 	}
+	dev.ctx, dev.cancel = context.WithCancel(m.ctx)
 	m.devices[ip] = dev
-	m.deviceOrder = append(m.deviceOrder, ip)
+	m.insertOrdered(ip)
 	return dev
 }
 
+// orderRank returns ip's position in the saved device order.
+func (m *model) orderRank(ip string) (int, bool) {
+	for i, o := range m.config.Order {
+		if o == ip {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// insertOrdered adds ip to deviceOrder. Devices in the saved order come
+// first, in that order; all others keep their arrival order after them.
+func (m *model) insertOrdered(ip string) {
+	rank, ok := m.orderRank(ip)
+	pos := len(m.deviceOrder)
+	if ok {
+		for i, o := range m.deviceOrder {
+			if r, ranked := m.orderRank(o); !ranked || r > rank {
+				pos = i
+				break
+			}
+		}
+	}
+	m.deviceOrder = append(m.deviceOrder, "")
+	copy(m.deviceOrder[pos+1:], m.deviceOrder[pos:])
+	m.deviceOrder[pos] = ip
+}
+
+// moveDevice moves the selected device delta places in deviceOrder and
+// saves the new order to the config.
+func (m *model) moveDevice(delta int) {
+	if m.sortMode != sortManual {
+		m.addLog("Devices can only be moved in manual order (press o)")
+		return
+	}
+	i := m.selected
+	j := i + delta
+	if i < 0 || j < 0 || j >= len(m.deviceOrder) {
+		return
+	}
+	m.deviceOrder[i], m.deviceOrder[j] = m.deviceOrder[j], m.deviceOrder[i]
+	m.selected = j
+
+	// Keep saved entries for devices that aren't currently shown
+	order := append([]string{}, m.deviceOrder...)
+	for _, o := range m.config.Order {
+		if _, shown := m.devices[o]; !shown {
+			order = append(order, o)
+		}
+	}
+	m.config.Order = order
+	m.saveConfig()
+}
+
+// fallbackName returns the name dev would have without a config name:
+// its mDNS (or add-prompt) name, then its UUID, then its IP.
+func fallbackName(dev *Device) string {
+	if dev.DiscoveredName != "" {
+		return dev.DiscoveredName
+	}
+	if dev.Config != nil && dev.Config.DeviceUUID != "" {
+		return dev.Config.DeviceUUID
+	}
+	return dev.IP
+}
+
+// device returns the device with handle id, or nil if it was removed.
+func (m *model) device(id deviceID) *Device {
+	if id == 0 {
+		return nil
+	}
+	for _, dev := range m.devices {
+		if dev.ID == id {
+			return dev
+		}
+	}
+	return nil
+}
+
+// removeDevice drops a device from the dashboard. It stays ignored by
+// discovery for the rest of the session. If it was selected, the
+// selection moves to the device that takes its place (or the one before,
+// if it was last); otherwise the selection stays on the same device. Views
+// showing it close.
+func (m *model) removeDevice(ip string) {
+	dev, ok := m.devices[ip]
+	if !ok {
+		return
+	}
+	// Abandon its requests in flight; their results are dropped anyway
+	dev.cancel()
+	selected := m.selectedDevice()
+	m.keepSelection(func() {
+		delete(m.devices, ip)
+		for i, o := range m.deviceOrder {
+			if o == ip {
+				m.deviceOrder = append(m.deviceOrder[:i], m.deviceOrder[i+1:]...)
+				break
+			}
+		}
+	})
+	if selected == dev {
+		// keepSelection couldn't find it; the index now points at the
+		// neighbor
+		m.moveSelection(0)
+	}
+	m.ignored[ip] = true
+	if m.notifier != nil {
+		m.notifier.forget(dev.ID)
+	}
+	if m.zoomID == dev.ID {
+		m.zoomID = 0
+	}
+	if m.detailID == dev.ID {
+		m.detailID = 0
+		m.addLog(fmt.Sprintf("Closed details: %s was removed", dev.Name))
+	}
+}
+
+// confirmRemove asks before removing dev, then offers to forget it in the
+// config if anything is saved for it.
+func (m *model) confirmRemove(dev *Device) {
+	ip, name, uuid := dev.IP, dev.Name, dev.UUID
+	m.confirm = &confirmPrompt{
+		question: fmt.Sprintf("Remove %s (%s)?", name, ip),
+		onYes: func(m *model) tea.Cmd {
+			m.removeDevice(ip)
+			m.addLog(fmt.Sprintf("Removed device: %s (%s)", name, ip))
+			if m.config.ForgetDiscovered(uuid, ip) {
+				m.saveConfig()
+			}
+			var cmd tea.Cmd
+			if len(m.devices) == 0 && !m.noDiscovery {
+				// Back to the empty state: look again right away
+				cmd = discoverCmd()
+			}
+			if m.config.Entry(uuid, ip) != nil {
+				m.confirm = &confirmPrompt{
+					question: fmt.Sprintf("Also forget %s in the config (name and saved device)?", ip),
+					onYes: func(m *model) tea.Cmd {
+						m.config.Forget(uuid, ip)
+						m.saveConfig()
+						m.addLog(fmt.Sprintf("Forgot saved device %s", ip))
+						return nil
+					},
+				}
+			}
+			return cmd
+		},
+	}
+}
+
 // orderedDevices returns devices in stable insertion order.
 func (m *model) orderedDevices() []*Device {
 	var devs []*Device
@@ -169,92 +537,341 @@ func (m *model) orderedDevices() []*Device {
 			devs = append(devs, d)
 		}
 	}
+	sortDevices(devs, m.sortMode)
 	return devs
 }
 
+// keepSelection runs change and then moves the selection to wherever the
+// previously selected device ended up, for changes that reorder devices.
+func (m *model) keepSelection(change func()) {
+	dev := m.selectedDevice()
+	change()
+	if dev == nil {
+		return
+	}
+	for i, d := range m.orderedDevices() {
+		if d == dev {
+			m.selected = i
+			return
+		}
+	}
+}
+
+// selectedDevice returns the currently selected device, or nil if there
+// are no devices.
+func (m *model) selectedDevice() *Device {
+	devs := m.orderedDevices()
+	if len(devs) == 0 {
+		return nil
+	}
+	if m.selected >= len(devs) {
+		m.selected = len(devs) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+	return devs[m.selected]
+}
+
+// moveSelection moves the selection by delta devices, clamped to the list.
+func (m *model) moveSelection(delta int) {
+	n := len(m.deviceOrder)
+	if n == 0 {
+		return
+	}
+	m.selected += delta
+	if m.selected < 0 {
+		m.selected = 0
+	}
+	if m.selected >= n {
+		m.selected = n - 1
+	}
+}
+
 func (m model) Init() tea.Cmd {
 	// Start the first tick and poll all existing devices immediately
-	cmds := []tea.Cmd{tickCmd(m.pollInterval)}
+	cmds := []tea.Cmd{tickCmd(m.pollInterval, m.tickGen), clockCmd(), configWatchCmd(m.configStamp)}
+	if m.fetchConfig {
+		cmds = append(cmds, configRefreshCmd())
+	}
 	for _, ip := range m.deviceOrder {
-
-		cmds = append(cmds, pollCmd(ip), configCmd(ip))
+		cmds = append(cmds, m.fetchCmds(ip)...)
+	}
+	if cloudToken != "" {
+		cmds = append(cmds, cloudDevicesCmd(m.ctx))
+	}
+	if p := m.scanOnStart; p.IsValid() {
+		cmds = append(cmds, func() tea.Msg { return scanRequestMsg{Range: p} })
 	}
 	return tea.Batch(cmds...)
 }
 
-func tickCmd(d time.Duration) tea.Cmd {
-	return tea.Tick(d, func(t time.Time) tea.Msg {
-		return tickMsg(t)
+// pollAll returns a poll command for every device, including those
+// backing off, whose backoff starts over. Cloud devices still keep to
+// their interval and quota.
+func (m *model) pollAll() []tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(m.deviceOrder))
+	for _, ip := range m.deviceOrder {
+		dev := m.devices[ip]
+		dev.Backoff, dev.SkipTicks = 0, 0
+		if m.cloudPollDue(dev) {
+			cmds = append(cmds, pollCmd(dev))
+		}
+	}
+	return cmds
+}
+
+// clockMsg redraws the view every second, for the countdown to the next
+// poll in the status bar. It never polls.
+type clockMsg struct{}
+
+func clockCmd() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return clockMsg{} })
+}
+
+func tickCmd(d time.Duration, gen int) tea.Cmd {
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return tickMsg{Gen: gen}
+	})
+}
+
+// Bounds of the polling interval when adjusted with +/-.
+const (
+	minPollInterval = 2 * time.Second
+	maxPollInterval = 10 * time.Minute
+)
+
+// stepInterval returns the next polling interval up (dir > 0) or down
+// from d: 5s steps up to a minute, 30s steps beyond, never below
+// minPollInterval.
+func stepInterval(d time.Duration, dir int) time.Duration {
+	step := 5 * time.Second
+	if d > time.Minute || (d == time.Minute && dir > 0) {
+		step = 30 * time.Second
+	}
+	var next time.Duration
+	if dir > 0 {
+		next = (d/step + 1) * step
+	} else {
+		next = ((d+step-1)/step - 1) * step
+	}
+	if next < minPollInterval {
+		next = minPollInterval
+	}
+	if next > maxPollInterval {
+		next = maxPollInterval
+	}
+	return next
+}
+
+// setPollInterval switches to interval d, saves it as the new default and
+// restarts the tick loop so the change applies right away.
+func (m *model) setPollInterval(d time.Duration) tea.Cmd {
+	if d == m.pollInterval {
+		return nil
+	}
+	m.pollInterval = d
+	m.config.Interval = int(d / time.Second)
+	m.saveConfig()
+	m.addLog(fmt.Sprintf("Polling every %s", d))
+	m.tickGen++
+	m.nextTick = time.Now().Add(d)
+	return tickCmd(m.pollInterval, m.tickGen)
+}
+
+// fetchCmds returns the commands that load a newly added device: a poll,
+// unless disabled a device config and LED settings fetch and its recent
+// history, from the database and the device, and for a host name its
+// lookup.
+func (m *model) fetchCmds(ip string) []tea.Cmd {
+	dev := m.devices[ip]
+	var cmds []tea.Cmd
+	if m.cloudPollDue(dev) {
+		cmds = append(cmds, pollCmd(dev))
+	}
+	if m.fetchConfig && !isCloud(ip) {
+		cmds = append(cmds, configCmd(dev))
+		if !isDemo(ip) {
+			cmds = append(cmds, ledCmd(dev))
+		}
+	}
+	if m.store != nil {
+		cmds = append(cmds, storedHistoryCmd(m.store, dev))
+	}
+	if m.historyEndpoint != historyOff && !isCloud(ip) {
+		cmds = append(cmds, historySeedCmd(dev, m.historyEndpoint))
+	}
+	if isHostname(ip) {
+		cmds = append(cmds, resolveCmd(dev.ctx, ip))
+	}
+	return cmds
+}
+
+// pollCmd fetches dev's air data from its endpoint, or from
+// /air-data/latest if the firmware doesn't serve that.
+func pollCmd(dev *Device) tea.Cmd {
+	ctx, ip, endpoint := dev.ctx, dev.IP, dev.endpoint
+	return trackCmd("poll "+ip, func() tea.Msg {
+		start := time.Now()
+		data, err := FetchAirDataFrom(ctx, ip, endpoint)
+		fellBack := false
+		if err != nil && endpoint != awair.Latest && unsupportedEndpoint(err) {
+			logf(levelDebug, "poll %s: %v", ip, err)
+			fellBack = true
+			start = time.Now()
+			data, err = FetchAirData(ctx, ip)
+		}
+		return pollResultMsg{IP: ip, Data: data, Err: err, Latency: time.Since(start), FellBack: fellBack}
 	})
 }
 
-func pollCmd(ip string) tea.Cmd {
-	return func() tea.Msg {
-		data, err := FetchAirData(ip)
-		return pollResultMsg{IP: ip, Data: data, Err: err}
+// pollSlotMsg is a device's turn to be polled within a tick.
+type pollSlotMsg struct {
+	IP string
+}
+
+// staggeredPollCmd polls dev after delay.
+func staggeredPollCmd(dev *Device, delay time.Duration) tea.Cmd {
+	if delay <= 0 {
+		return pollCmd(dev)
 	}
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return pollSlotMsg{IP: dev.IP}
+	})
 }
 
 // discoverCmd runs a one-shot mDNS discovery and sends results as messages.
 func discoverCmd() tea.Cmd {
-	return func() tea.Msg {
+	return trackCmd("discovery", func() tea.Msg {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
 		ch := StartDiscovery(ctx)
 		// Collect all discovered devices from this query
-		var found []DiscoveredDevice
+		var found []discovery.Device
 		for dev := range ch {
 			found = append(found, dev)
 		}
 		return discoveryBatchMsg(found)
-	}
+	})
 }
 
 // discoveryBatchMsg carries all devices found in a single discovery pass.
-type discoveryBatchMsg []DiscoveredDevice
+type discoveryBatchMsg []discovery.Device
 
-func configCmd(ip string) tea.Cmd {
-	return func() tea.Msg {
-		cfg, err := FetchDeviceConfig(ip)
+func configCmd(dev *Device) tea.Cmd {
+	ctx, ip := dev.ctx, dev.IP
+	return trackCmd("device config "+ip, func() tea.Msg {
+		cfg, err := FetchDeviceConfig(ctx, ip)
 		if err != nil {
 			return nil
 		}
 		return configResultMsg{IP: ip, Config: cfg}
-	}
+	})
+}
+
+// resolveCmd looks up host in the background. Polls don't wait for it;
+// net/http resolves the name itself.
+func resolveCmd(ctx context.Context, host string) tea.Cmd {
+	return trackCmd("resolve "+host, func() tea.Msg {
+		addrs, err := resolveHost(ctx, host)
+		return hostResolvedMsg{Host: host, Addrs: addrs, Err: err}
+	})
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	diag.begin(msg)
+	next, cmd := m.update(msg)
+	nm := next.(model)
+	diag.end(nm)
+	if nm.api != nil {
+		nm.api.publish(nm.apiDevices())
+	}
+	if nm.clockStopped && !nm.showPrompt {
+		nm.clockStopped = false
+		return nm, tea.Batch(cmd, clockCmd())
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		if m.frozen != nil {
+			f := m.renderFrame()
+			m.frozen = &f
+		}
+		if m.showLogs {
+			m.syncLogView()
+		}
 		return m, nil
 
 	case tea.KeyMsg:
 		return m.handleKey(msg)
 
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
+
 	case tickMsg:
-		// Poll all devices
+		if msg.Gen != m.tickGen {
+			return m, nil
+		}
+		// Poll the devices that are due, unless paused. The tick keeps
+		// running either way so ages and saves stay current.
+		m.lastTick = time.Now()
+		m.nextTick = m.lastTick.Add(m.pollInterval)
 		var cmds []tea.Cmd
-		for _, ip := range m.deviceOrder {
-	
-			cmds = append(cmds, pollCmd(ip))
+		if !m.paused {
+			cmds = m.pollDue()
+		}
+		cmds = append(cmds, tickCmd(m.pollInterval, m.tickGen), saveRecordsCmd(m.records))
+		if m.store != nil {
+			cmds = append(cmds, storeFlushCmd(m.store))
 		}
-		cmds = append(cmds, tickCmd(m.pollInterval))
 		return m, tea.Batch(cmds...)
 
-	case pollResultMsg:
-		if dev, ok := m.devices[msg.IP]; ok {
-			if msg.Err != nil {
-				dev.LastError = msg.Err
-			} else {
-				dev.Data = msg.Data
-				dev.LastError = nil
-				dev.LastUpdate = time.Now()
-			}
+	case pollSlotMsg:
+		// Removed, or paused, since the tick
+		dev, ok := m.devices[msg.IP]
+		if !ok || m.paused {
+			return m, nil
 		}
+		return m, pollCmd(dev)
+
+	case pollResultMsg:
+		// Sorting by score or alerts can move the device
+		var cmd tea.Cmd
+		m.keepSelection(func() { cmd = m.applyPoll(msg) })
+		return m, cmd
+
+	case cloudDevicesMsg:
+		return m, tea.Batch(m.addCloudDevices(msg)...)
+
+	case storedHistoryMsg:
+		m.restoreHistory(msg)
+		return m, nil
+
+	case clipboardMsg:
+		m.handleClipboard(msg)
+		return m, nil
+
+	case storeFlushedMsg:
+		m.handleStoreFlushed(msg)
+		return m, nil
+
+	case recordsSavedMsg:
+		m.handleRecordsSaved(msg)
+		return m, nil
+
+	case historySeedMsg:
+		m.seedHistory(msg)
+		return m, nil
+
+	case ledResultMsg:
+		m.handleLEDResult(msg)
 		return m, nil
 
 	case configResultMsg:
@@ -262,29 +879,95 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		if dev, ok := m.devices[msg.IP]; ok {
+			m.logConfigChanges(dev, dev.Config, msg.Config)
 			dev.Config = msg.Config
-			// Fall back to device_uuid if no better name exists
-			if msg.Config.DeviceUUID != "" && dev.Name == dev.IP {
-				dev.Name = msg.Config.DeviceUUID
+			m.confirmDisplay(dev)
+			if msg.Config.DeviceUUID != "" {
+				dev.UUID = msg.Config.DeviceUUID
+			}
+			m.records.Rekey(dev.IP, recordKey(dev))
+			if other := m.sameUUID(dev); other != nil {
+				if isIPAddress(dev.IP) && isIPAddress(other.IP) && other.ID < dev.ID {
+					// A new address, e.g. from a DHCP lease change
+					return m, m.moveInto(other, dev)
+				}
+				keep, dup := preferDevice(dev, other)
+				m.dropDuplicate(keep, dup, "same device UUID")
+				if dup == dev {
+					return m, nil
+				}
 			}
+			m.identify(dev)
 		}
 		return m, nil
 
+	case displayResultMsg:
+		return m, m.handleDisplayResult(msg)
+
+	case configRefreshMsg:
+		return m, tea.Batch(append(m.refreshConfigs(), configRefreshCmd())...)
+
+	case hostResolvedMsg:
+		m.handleHostResolved(msg)
+		return m, nil
+
 	case discoveredMsg:
-		if _, exists := m.devices[msg.IP]; !exists {
-			dev := m.addDevice(msg.IP, msg.Name)
-			m.addLog(fmt.Sprintf("Discovered: %s at %s", dev.Name, msg.IP))
-			return m, tea.Batch(pollCmd(msg.IP), configCmd(msg.IP))
+		return m, m.handleDiscovered(discovery.Device(msg))
+
+	case scanRequestMsg:
+		return m, m.startScan(msg.Range)
+
+	case scanMsg:
+		return m, m.handleScan(msg)
+
+	case probeResultMsg:
+		m.picker.setProbe(msg)
+		return m, nil
+
+	case webhookResultMsg:
+		return m, m.handleWebhookResult(msg)
+
+	case configCheckMsg:
+		cmd := m.handleConfigCheck(msg)
+		if msg.Manual {
+			return m, cmd
 		}
+		return m, tea.Batch(cmd, configWatchCmd(m.configStamp))
+
+	case flashEndMsg:
+		m.endFlash(msg.ID)
+		return m, nil
+
+	case alertExecResultMsg:
+		m.handleAlertExecResult(msg)
+		return m, nil
+
+	case diagDumpedMsg:
+		m.handleDiagDumped(msg)
 		return m, nil
 
+	case watchdogMsg:
+		return m, m.handleWatchdog(msg)
+
+	case discoveryBurstMsg:
+		m.flushDiscoveryBurst()
+		return m, nil
+
+	case clockMsg:
+		// Nothing to do but redraw the countdown, which would repaint the
+		// whole screen under a slow terminal's prompt
+		if m.slowTerminal && m.showPrompt {
+			m.clockStopped = true
+			return m, nil
+		}
+		return m, clockCmd()
+
 	case discoveryBatchMsg:
+		m.discovering = false
 		var cmds []tea.Cmd
 		for _, d := range msg {
-			if _, exists := m.devices[d.IP]; !exists {
-				dev := m.addDevice(d.IP, d.Name)
-				m.addLog(fmt.Sprintf("Discovered: %s at %s", dev.Name, d.IP))
-				cmds = append(cmds, pollCmd(d.IP), configCmd(d.IP))
+			if cmd := m.handleDiscovered(d); cmd != nil {
+				cmds = append(cmds, cmd)
 			}
 		}
 		if len(cmds) == 0 {
@@ -296,90 +979,663 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// applyPoll records a poll result for its device. It returns the
+// notifications to send, if any.
+func (m *model) applyPoll(msg pollResultMsg) tea.Cmd {
+	dev, ok := m.devices[msg.IP]
+	if !ok {
+		return nil
+	}
+	if msg.FellBack {
+		m.endpointFallback(dev)
+	}
+	if msg.Err != nil {
+		if dev.LastError == nil {
+			m.logAt(levelError, fmt.Sprintf("%s: %s", dev.Name, errorSummary(msg.Err)))
+		}
+		logf(levelDebug, "poll %s: %v", msg.IP, msg.Err)
+		dev.LastError = msg.Err
+		dev.Failures++
+		m.backOff(dev)
+		if dev.Failures == offlineAfterFailures {
+			m.logAt(levelWarn, fmt.Sprintf("%s: offline after %d failed polls", dev.Name, offlineAfterFailures))
+		}
+		return nil
+	}
+	if dev.Offline() {
+		m.addLog(fmt.Sprintf("%s: device recovered", dev.Name))
+	}
+	dev.Failures, dev.Backoff, dev.SkipTicks = 0, 0, 0
+
+	dev.LastError = nil
+	dev.LastUpdate = time.Now()
+	dev.Latency.Add(msg.Latency)
+
+	sampleTime := parseDeviceTime(msg.Data.Timestamp)
+	m.checkClockSkew(dev, sampleTime)
+	if dev.Data != nil && !sampleTime.IsZero() && sampleTime.Equal(dev.SampleTime) {
+		// The device hasn't taken a new sample since the last poll: it's
+		// responding, but there is nothing new to show, store or alert on
+		dev.History.Duplicates++
+		return nil
+	}
+	prev := dev.Data
+	dev.Data = msg.Data
+	dev.SampleTime = sampleTime
+	if m.api != nil {
+		m.api.publishReading(dev)
+	}
+	if dev.History.Add(msg.Data, dev.LastUpdate, averagePeriods[dev.endpoint]) {
+		m.records.Update(recordKey(dev), msg.Data, dev.LastUpdate)
+		if sampleTime.IsZero() {
+			dev.Summary.Add(msg.Data, dev.LastUpdate)
+		} else {
+			dev.Summary.Add(msg.Data, sampleTime)
+		}
+		if m.store != nil {
+			m.store.Add(storedReading{UUID: dev.UUID, IP: dev.IP, DeviceTime: sampleTime, Received: dev.LastUpdate,
+				Period: averagePeriods[dev.endpoint], Data: msg.Data})
+		}
+		dev.Prev = prev
+	}
+	alerts, prevAlerts := evaluateAlerts(m.alertRules, msg.Data), dev.Alerts
+	transitions := diffAlerts(prevAlerts, alerts)
+	for _, t := range transitions {
+		level := levelInfo
+		if t.Kind != alertCleared && t.Alert.Severity == alertCritical {
+			level = levelWarn
+		}
+		m.logAt(level, t.Describe(dev.Name))
+	}
+	dev.Alerts = alerts
+
+	var cmds []tea.Cmd
+	if poor := newlyPoor(transitions); len(poor) > 0 {
+		if m.bell {
+			cmds = append(cmds, bellCmd)
+		}
+		if m.flash {
+			cmds = append(cmds, m.startFlash(dev, poor))
+		}
+	}
+	if m.notifier != nil {
+		cmds = append(cmds, m.notifier.update(dev, prevAlerts, alerts, time.Now())...)
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleDiscovered adds a newly discovered device, or parks it in the
+// picker once maxDiscovered devices have been added automatically. It
+// returns the commands to start polling, or nil if nothing was added.
+func (m *model) handleDiscovered(d discovery.Device) tea.Cmd {
+	if m.deviceAt(d.IP) != nil || m.ignored[d.IP] {
+		return nil
+	}
+	// A scan names devices by UUID, so a known one at a new address is
+	// moved there right away
+	if dev := m.deviceByUUID(d.Name); dev != nil && isIPAddress(dev.IP) && isIPAddress(d.IP) {
+		m.relocateDevice(dev, d.IP)
+		return tea.Batch(m.fetchCmds(d.IP)...)
+	}
+
+	// With nothing on the dashboard yet, the empty state lists what is
+	// found and lets the user choose.
+	if len(m.devices) == 0 {
+		if m.picker.add(d) {
+			return probeCmd(m.ctx, d.IP)
+		}
+		return nil
+	}
+
+	if m.discoveredAdded >= m.maxDiscovered {
+		if m.picker.add(d) {
+			if len(m.picker.items) == 1 {
+				m.addLog(fmt.Sprintf("Discovery limit reached (%d devices); press F to pick more", m.maxDiscovered))
+			}
+			return probeCmd(m.ctx, d.IP)
+		}
+		return nil
+	}
+
+	m.discoveredAdded++
+	dev := m.addDevice(d.IP, d.Name)
+	m.rememberDevice(dev)
+	return tea.Batch(append(m.fetchCmds(d.IP), m.noteDiscovered(dev))...)
+}
+
+// saveConfig saves m.config, logging a failure to the panel.
+func (m *model) saveConfig() {
+	if err := SaveConfig(m.config); err != nil {
+		m.logAt(levelError, "Config not saved: "+err.Error())
+	}
+}
+
+// rememberDevice saves a discovered device to be added at the next start,
+// with --remember-discovered.
+func (m *model) rememberDevice(dev *Device) {
+	if m.remember && m.config.AddDiscovered(dev.UUID, dev.IP, dev.DiscoveredName) {
+		m.saveConfig()
+	}
+}
+
+// discoveryBurstWindow is how long discoveries are collected into one
+// log entry, so startup doesn't flood the log panel.
+const discoveryBurstWindow = 2 * time.Second
+
+// discoveryBurstMsg ends the current discovery burst.
+type discoveryBurstMsg struct{}
+
+// noteDiscovered records a discovered device for the burst summary. The
+// first device of a burst starts the window.
+func (m *model) noteDiscovered(dev *Device) tea.Cmd {
+	logf(levelDebug, "discovered %s at %s", dev.Name, dev.IP)
+	m.discoveryBurst = append(m.discoveryBurst, dev.ID)
+	if len(m.discoveryBurst) > 1 {
+		return nil
+	}
+	return tea.Tick(discoveryBurstWindow, func(time.Time) tea.Msg {
+		return discoveryBurstMsg{}
+	})
+}
+
+// flushDiscoveryBurst logs the devices discovered in the last burst as a
+// single entry, leaving out any removed since.
+func (m *model) flushDiscoveryBurst() {
+	var burst []*Device
+	for _, id := range m.discoveryBurst {
+		if dev := m.device(id); dev != nil {
+			burst = append(burst, dev)
+		}
+	}
+	switch len(burst) {
+	case 0:
+	case 1:
+		m.addLog(fmt.Sprintf("Discovered: %s at %s", burst[0].Name, burst[0].IP))
+	default:
+		names := make([]string, len(burst))
+		for i, dev := range burst {
+			names[i] = dev.Name
+		}
+		m.addLog(fmt.Sprintf("Discovered %d devices: %s", len(burst), strings.Join(names, ", ")))
+	}
+	m.discoveryBurst = nil
+}
+
+// toggleUnits switches between Celsius and Fahrenheit and saves the
+// choice as the new default.
+func (m *model) toggleUnits() {
+	m.fahrenheit = !m.fahrenheit
+	f := m.fahrenheit
+	m.config.Fahrenheit = &f
+	m.saveConfig()
+	if f {
+		m.addLog("Showing temperatures in °F")
+	} else {
+		m.addLog("Showing temperatures in °C")
+	}
+}
+
+// quit stops discovery, flushes pending state to disk and exits.
+func (m *model) quit() tea.Cmd {
+	if m.discoveryCtx != nil {
+		m.discoveryCtx()
+	}
+	m.cancelRequests()
+	SaveRecords(m.records)
+	return tea.Quit
+}
+
 func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// Undocumented, and works everywhere: the terminal's SIGQUIT key
+	if msg.String() == "ctrl+\\" {
+		return m, diagDumpCmd
+	}
 	if m.showPrompt {
 		return m.handlePromptKey(msg)
 	}
+	if m.confirm != nil {
+		return m.handleConfirmKey(msg)
+	}
+	if m.showPicker {
+		return m.handlePickerKey(msg)
+	}
+	if m.whatsNew != nil {
+		return m.handleWhatsNewKey(msg)
+	}
+	if m.showHelp {
+		return m.handleHelpKey(msg)
+	}
+	if m.showLogs {
+		return m.handleLogKey(msg)
+	}
+	if m.showSummary {
+		return m.handleSummaryKey(msg)
+	}
+	if msg.String() == "l" {
+		m.openLogView()
+		return m, nil
+	}
+	if msg.String() == "s" {
+		m.showSummary = true
+		return m, nil
+	}
+	if msg.String() == "?" {
+		m.showHelp = true
+		return m, nil
+	}
+	if m.detailID != 0 {
+		return m.handleDetailKey(msg)
+	}
+	if m.zoomID != 0 {
+		return m.handleZoomKey(msg)
+	}
+	if len(m.devices) == 0 && len(m.picker.items) > 0 {
+		if next, cmd, ok := m.handleSetupKey(msg); ok {
+			return next, cmd
+		}
+	}
+
+	if msg.String() == "esc" && m.focusSensor != "" {
+		m.focusSensor = ""
+		return m, nil
+	}
 
 	switch msg.String() {
 	case "q", "esc", "ctrl+c":
-		if m.discoveryCtx != nil {
-			m.discoveryCtx()
+		return m, m.quit()
+
+	case "f":
+		m.focusSensor = nextFocus(m.focusSensor)
+		return m, nil
+
+	case "r":
+		m.addLog("Refreshing...")
+		return m, tea.Batch(append(m.pollAll(), m.refreshConfigs()...)...)
+
+	case "ctrl+r":
+		return m, reloadConfigCmd()
+
+	case "u":
+		m.toggleUnits()
+		return m, nil
+
+	case "p":
+		m.paused = !m.paused
+		if m.paused {
+			m.addLog("Polling paused")
+			return m, nil
 		}
-		return m, tea.Quit
+		m.addLog("Polling resumed")
+		return m, tea.Batch(m.pollAll()...)
+
+	case "+", "=":
+		return m, m.setPollInterval(stepInterval(m.pollInterval, 1))
+
+	case "-":
+		return m, m.setPollInterval(stepInterval(m.pollInterval, -1))
+
+	case "a":
+		return m, m.openPrompt("ip", "192.168.1.100 or awair-bedroom.local", "")
+
+	case "d":
+		if m.noDiscovery {
+			m.addLog("Discovery disabled (--no-discovery)")
+			return m, nil
+		}
+		m.addLog("Restarting mDNS discovery on " + discoveryInterfaceName() + "...")
+		m.discovering = true
+		return m, discoverCmd()
+
+	case "S":
+		if m.scanning != "" {
+			m.addLog("Already scanning " + m.scanning)
+			return m, nil
+		}
+		return m, m.openPrompt("scan", "192.168.1.0/24", localSubnet())
+
+	case "left":
+		m.moveSelection(-1)
+		return m, nil
+
+	case "right":
+		m.moveSelection(1)
+		return m, nil
+
+	case "up":
+		if m.viewMode == viewTable {
+			m.moveSelection(-1)
+			return m, nil
+		}
+		m.moveSelection(-gridCols(len(m.deviceOrder)))
+		return m, nil
+
+	case "down":
+		if m.viewMode == viewTable {
+			m.moveSelection(1)
+			return m, nil
+		}
+		m.moveSelection(gridCols(len(m.deviceOrder)))
+		return m, nil
+
+	case "pgdown", ">":
+		perPage, _ := m.gridPaging()
+		m.moveSelection(perPage)
+		return m, nil
+
+	case "pgup", "<":
+		perPage, _ := m.gridPaging()
+		m.moveSelection(-perPage)
+		return m, nil
+
+	case "enter":
+		if dev := m.selectedDevice(); dev != nil {
+			m.detailID = dev.ID
+		}
+		return m, nil
+
+	case "o":
+		m.keepSelection(func() {
+			m.sortMode = nextSortMode(m.sortMode)
+		})
+		m.config.Sort = m.sortMode
+		m.saveConfig()
+		m.addLog("Sorting devices by " + m.sortMode + " order")
+		return m, nil
+
+	case "t":
+		if m.viewMode == viewTable {
+			m.viewMode = viewGrid
+		} else {
+			m.viewMode = viewTable
+		}
+		return m, nil
+
+	case "z":
+		if dev := m.selectedDevice(); dev != nil {
+			m.zoomID = dev.ID
+		}
+		return m, nil
+
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		idx := int(msg.String()[0] - '1')
+		if devs := m.orderedDevices(); idx < len(devs) {
+			m.selected = idx
+			m.detailID = devs[idx].ID
+		}
+		return m, nil
+
+	case "[", "shift+left":
+		m.moveDevice(-1)
+		return m, nil
+
+	case "]", "shift+right":
+		m.moveDevice(1)
+		return m, nil
+
+	case "n":
+		if dev := m.selectedDevice(); dev != nil {
+			m.pendingIP = dev.IP
+			return m, m.openPrompt("rename", "(empty to reset)", dev.Name)
+		}
+		return m, nil
+
+	case "y":
+		if dev := m.selectedDevice(); dev != nil {
+			return m, m.copyAddress(dev)
+		}
+		return m, nil
+
+	case "x", "delete":
+		if dev := m.selectedDevice(); dev != nil {
+			m.confirmRemove(dev)
+		}
+		return m, nil
+
+	case "F":
+		if len(m.picker.items) == 0 {
+			m.addLog("No discovered devices waiting to be added")
+			return m, nil
+		}
+		m.showPicker = true
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "enter":
+		m.detailID = 0
+		return m, nil
+
+	case "R":
+		if dev := m.device(m.detailID); dev != nil {
+			key, name := recordKey(dev), dev.Name
+			m.confirm = &confirmPrompt{
+				question: fmt.Sprintf("Reset lifetime records for %s?", name),
+				onYes: func(m *model) tea.Cmd {
+					m.records.Reset(key)
+					m.addLog(fmt.Sprintf("Reset lifetime records for %s", name))
+					return saveRecordsCmd(m.records)
+				},
+			}
+		}
+		return m, nil
+
+	case "D":
+		if dev := m.device(m.detailID); dev != nil {
+			return m, m.cycleDisplay(dev)
+		}
+		return m, nil
+
+	case "L":
+		if dev := m.device(m.detailID); dev != nil {
+			return m, m.cycleLEDMode(dev)
+		}
+		return m, nil
+
+	case "y":
+		if dev := m.device(m.detailID); dev != nil {
+			return m, m.copyAddress(dev)
+		}
+		return m, nil
+
+	case "Y":
+		if dev := m.device(m.detailID); dev != nil {
+			return m, m.copyDeviceJSON(dev)
+		}
+		return m, nil
+
+	case "[", "]":
+		if dev := m.device(m.detailID); dev != nil {
+			delta := ledBrightnessStep
+			if msg.String() == "[" {
+				delta = -delta
+			}
+			return m, m.stepLEDBrightness(dev, delta)
+		}
+		return m, nil
+
+	case "u":
+		m.toggleUnits()
+		return m, nil
+
+	case "left":
+		m.chartSensor = (m.chartSensor + len(chartSensors) - 1) % len(chartSensors)
+		return m, nil
+
+	case "right":
+		m.chartSensor = (m.chartSensor + 1) % len(chartSensors)
+		return m, nil
+
+	case "q", "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
 
-	case "r":
-		m.addLog("Refreshing...")
-		var cmds []tea.Cmd
-		for _, ip := range m.deviceOrder {
-	
-			cmds = append(cmds, pollCmd(ip))
+func (m model) handlePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "F":
+		m.showPicker = false
+	case "up", "k":
+		m.picker.move(-1)
+	case "down", "j":
+		m.picker.move(1)
+	case " ":
+		m.picker.toggle()
+	case "enter":
+		cmd := m.addPicked(m.picker.take())
+		if len(m.picker.items) == 0 {
+			m.showPicker = false
 		}
-		return m, tea.Batch(cmds...)
+		return m, cmd
+	}
+	return m, nil
+}
 
+// handleSetupKey handles the device checklist shown in the empty state.
+// ok is false for keys it leaves to the grid.
+func (m model) handleSetupKey(msg tea.KeyMsg) (next tea.Model, cmd tea.Cmd, ok bool) {
+	switch msg.String() {
+	case "up", "k":
+		m.picker.move(-1)
+	case "down", "j":
+		m.picker.move(1)
+	case " ":
+		m.picker.toggle()
+	case "enter":
+		cmd = m.addPicked(m.picker.take())
 	case "a":
-		m.showPrompt = true
-		m.promptStep = "ip"
-		m.promptInput.Placeholder = "192.168.1.100"
-		m.promptInput.SetValue("")
-		m.promptInput.Focus()
-		return m, textinput.Blink
+		cmd = m.addPicked(m.picker.takeAll())
+	case "i":
+		cmd = m.openPrompt("ip", "192.168.1.100 or awair-bedroom.local", "")
+	default:
+		return m, nil, false
+	}
+	return m, cmd, true
+}
 
-	case "d":
-		if m.noDiscovery {
-			m.addLog("Discovery disabled (--no-discovery)")
-			return m, nil
-		}
-		m.addLog("Restarting mDNS discovery...")
-		return m, discoverCmd()
+// addPicked adds devices chosen from the picker and starts loading them.
+// They were added explicitly, so they don't count towards the automatic
+// discovery limit.
+func (m *model) addPicked(picked []discovery.Device) tea.Cmd {
+	var cmds []tea.Cmd
+	for _, d := range picked {
+		dev := m.addDevice(d.IP, d.Name)
+		m.rememberDevice(dev)
+		m.addLog(fmt.Sprintf("Added device: %s (%s)", dev.Name, d.IP))
+		cmds = append(cmds, m.fetchCmds(d.IP)...)
 	}
+	return tea.Batch(cmds...)
+}
 
-	return m, nil
+// openPrompt shows the text prompt for the given step with an initial value.
+func (m *model) openPrompt(step, placeholder, value string) tea.Cmd {
+	m.showPrompt = true
+	m.promptStep = step
+	m.promptInput.Placeholder = placeholder
+	m.promptInput.SetValue(value)
+	m.promptInput.Focus()
+	if m.slowTerminal {
+		f := m.renderFrame()
+		m.frozen = &f
+		return nil
+	}
+	return textinput.Blink
+}
+
+// promptNameStep moves the add prompt on to the optional name.
+func (m *model) promptNameStep() {
+	m.promptStep = "name"
+	m.promptInput.Placeholder = "(optional)"
+	m.promptInput.SetValue("")
+}
+
+// closePrompt hides the text prompt and resets its state.
+func (m *model) closePrompt() {
+	m.showPrompt = false
+	m.promptStep = ""
+	m.pendingIP = ""
+	m.frozen = nil
+	m.promptInput.Blur()
 }
 
 func (m model) handlePromptKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "esc":
-		m.showPrompt = false
-		m.promptStep = ""
-		m.pendingIP = ""
-		m.promptInput.Blur()
+	if msg.String() == "esc" {
+		m.closePrompt()
+		return m, nil
+	}
+	// Waiting for a host name lookup; only esc does anything
+	if m.promptStep == "resolving" {
 		return m, nil
+	}
 
+	switch msg.String() {
 	case "enter":
 		value := strings.TrimSpace(m.promptInput.Value())
 		if m.promptStep == "ip" {
 			if value == "" {
-				m.showPrompt = false
-				m.promptInput.Blur()
+				m.closePrompt()
 				return m, nil
 			}
-			if !isValidIP(value) {
-				m.addLog(fmt.Sprintf("Invalid IP: %s", value))
-				m.showPrompt = false
-				m.promptInput.Blur()
+			ip, ok := normalizeAddress(value)
+			if !ok {
+				m.logAt(levelWarn, fmt.Sprintf("Invalid address: %s", redactAddress(value)))
+				m.closePrompt()
 				return m, nil
 			}
-			m.pendingIP = value
-			m.promptStep = "name"
-			m.promptInput.Placeholder = "(optional)"
-			m.promptInput.SetValue("")
+			m.pendingIP = ip
+			if isHostname(ip) {
+				// Check it resolves before asking for a name
+				m.promptStep = "resolving"
+				return m, resolveCmd(m.ctx, ip)
+			}
+			m.promptNameStep()
 			return m, nil
 
 		} else if m.promptStep == "name" {
 			ip := m.pendingIP
 			name := value
-			if name != "" {
-				m.config.Devices[ip] = name
-				SaveConfig(m.config)
-			}
+			m.config.Remember(ip, name, entryManual)
+			m.saveConfig()
+			delete(m.ignored, ip)
 			dev := m.addDevice(ip, name)
 			m.addLog(fmt.Sprintf("Added device: %s (%s)", dev.Name, ip))
-			m.showPrompt = false
-			m.promptStep = ""
-			m.pendingIP = ""
-			m.promptInput.Blur()
-			return m, tea.Batch(pollCmd(ip), configCmd(ip))
+			m.closePrompt()
+			return m, tea.Batch(m.fetchCmds(ip)...)
+
+		} else if m.promptStep == "scan" {
+			m.closePrompt()
+			if value == "" {
+				return m, nil
+			}
+			p, err := parseScanRange(value)
+			if err != nil {
+				m.logAt(levelWarn, fmt.Sprintf("Can't scan: %v", err))
+				return m, nil
+			}
+			return m, m.confirmScan(p)
+
+		} else if m.promptStep == "rename" {
+			ip := m.pendingIP
+			m.closePrompt()
+			dev, ok := m.devices[ip]
+			if !ok {
+				return m, nil
+			}
+			if value == "" {
+				m.config.SetName(dev.UUID, ip, "")
+				dev.Name = fallbackName(dev)
+				m.addLog(fmt.Sprintf("Cleared saved name for %s; now shown as %s", ip, dev.Name))
+			} else {
+				m.config.SetName(dev.UUID, ip, value)
+				dev.Name = value
+				m.addLog(fmt.Sprintf("Renamed %s to %s (saved)", ip, value))
+			}
+			m.saveConfig()
+			return m, nil
 		}
 		return m, nil
 	}
@@ -390,8 +1646,35 @@ func (m model) handlePromptKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func isValidIP(s string) bool {
-	return net.ParseIP(s) != nil
+// gridHeight is the height left for the device grid (and overlays) once
+// the header, log panel and status bar are drawn.
+func (m model) gridHeight() int {
+	statusHeight := 1
+	return max(m.height-headerHeight-logPanelHeight-statusHeight, 1)
+}
+
+// The smallest terminal the full layout is drawn in. Shorter ones get the
+// mini view, which needs less; anything smaller than that shows
+// renderTooSmall instead of a layout that wraps onto itself.
+const (
+	minLayoutWidth  = 60
+	minLayoutHeight = miniHeightThreshold
+	minMiniWidth    = 30
+	minMiniHeight   = 2
+)
+
+// renderTooSmall asks for a terminal of at least width x height.
+func (m model) renderTooSmall(width, height int) string {
+	msg := fmt.Sprintf("Terminal too small — need at least %dx%d, currently %dx%d", width, height, m.width, m.height)
+	msg = lipgloss.NewStyle().
+		Width(min(m.width, lipgloss.Width(msg))).
+		Align(lipgloss.Center).
+		Foreground(theme.Fair).
+		Render(msg)
+	return lipgloss.NewStyle().
+		MaxWidth(m.width).
+		MaxHeight(m.height).
+		Render(lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, msg))
 }
 
 func (m model) View() string {
@@ -399,93 +1682,397 @@ func (m model) View() string {
 		return "Initializing..."
 	}
 
-	header := m.renderHeader()
-	statusBar := m.renderStatusBar()
-	logPanel := m.renderLogPanel()
-
-	// Calculate available height for device grid
-	headerHeight := 2
-	logHeight := 6
-	statusHeight := 1
-	gridHeight := m.height - headerHeight - logHeight - statusHeight
+	// Dialogs and the detail view need the full layout.
+	if m.useMini() && m.detailID == 0 && m.zoomID == 0 && m.confirm == nil && !m.showPrompt && !m.showPicker && !m.showHelp && !m.showLogs && !m.showSummary && m.whatsNew == nil {
+		if m.width < minMiniWidth || m.height < minMiniHeight {
+			return m.renderTooSmall(minMiniWidth, minMiniHeight)
+		}
+		return m.renderMini()
+	}
+	if m.width < minLayoutWidth || m.height < minLayoutHeight {
+		return m.renderTooSmall(minLayoutWidth, minLayoutHeight)
+	}
 
-	var grid string
-	if len(m.devices) == 0 {
-		grid = m.renderEmptyState(gridHeight)
-	} else {
-		grid = m.renderDeviceGrid(gridHeight)
+	if m.showLogs {
+		return lipgloss.JoinVertical(lipgloss.Left, m.renderHeader(), m.renderLogView(), m.renderStatusBar())
+	}
+	if m.showSummary {
+		return lipgloss.JoinVertical(lipgloss.Left, m.renderHeader(), m.renderSummary(), m.renderStatusBar())
 	}
+	// A slow terminal's prompt sits on the screen as it was when it opened
+	f := m.frozen
+	if !m.showPrompt || f == nil {
+		rendered := m.renderFrame()
+		f = &rendered
+	}
+	gridHeight := m.gridHeight()
+	grid := f.grid
 
 	// Overlay prompt if active
-	if m.showPrompt {
+	if m.confirm != nil {
+		grid = m.overlayConfirm(gridHeight)
+	} else if m.showPrompt {
 		grid = m.overlayPrompt(grid, gridHeight)
+	} else if m.showPicker {
+		grid = m.overlayPicker(gridHeight)
+	} else if m.whatsNew != nil {
+		grid = m.overlayWhatsNew(gridHeight)
+	} else if m.showHelp {
+		grid = m.overlayHelp(gridHeight)
 	}
 
-	return lipgloss.JoinVertical(lipgloss.Left, header, grid, logPanel, statusBar)
+	return lipgloss.JoinVertical(lipgloss.Left, f.header, grid, f.log, f.status)
+}
+
+// frame is the full layout without its overlays.
+type frame struct {
+	header, grid, log, status string
+}
+
+func (m model) renderFrame() frame {
+	f := frame{header: m.renderHeader(), log: m.renderLogPanel(), status: m.renderStatusBar()}
+	gridHeight := m.gridHeight()
+	switch {
+	case m.detailID != 0:
+		f.grid = m.renderDetail(gridHeight)
+	case m.zoomID != 0:
+		f.grid = m.renderZoom(gridHeight)
+	case len(m.devices) == 0:
+		f.grid = m.renderEmptyState(gridHeight)
+	case m.viewMode == viewTable:
+		f.grid = m.renderDeviceTable(gridHeight)
+	default:
+		f.grid = m.renderDeviceGrid(gridHeight)
+	}
+	return f
 }
 
 func (m model) renderHeader() string {
 	title := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(colorCyan).
+		Foreground(theme.Accent).
 		Render(" ☁  Awair TUI ")
 
 	subtitle := lipgloss.NewStyle().
-		Foreground(colorGray).
+		Foreground(theme.Muted).
 		Render("Real-time air quality monitoring")
 
 	line := title + " " + subtitle
+	if avg, worst, failing := m.renderRollup(); avg != "" {
+		// Drop the subtitle, then the worst reading, to fit the width
+		sep := lipgloss.NewStyle().Foreground(theme.Muted).Render("  ·  ")
+		full := sep + avg
+		if worst != "" {
+			full += sep + worst
+		}
+		short := sep + avg
+		if failing != "" {
+			full += sep + failing
+			short += sep + failing
+		}
+		switch {
+		case lipgloss.Width(line+full) <= m.width:
+			line += full
+		case lipgloss.Width(title+full) <= m.width:
+			line = title + full
+		default:
+			line = title + short
+		}
+	}
 
 	return lipgloss.NewStyle().
 		Width(m.width).
+		MaxWidth(m.width).
 		Render(line + "\n")
 }
 
+// renderRollup summarizes all devices for the header: the average score
+// of devices with data (or "waiting for data"), the worst reading anywhere
+// and how many devices are failing. Parts with nothing to say are empty.
+func (m model) renderRollup() (avg, worstReading, failingDevs string) {
+	if len(m.devices) == 0 {
+		return "", "", ""
+	}
+
+	var total, withData, failing int
+	var worstDev *Device
+	var worst awair.SensorReading
+	worstRank, worstExcess := 0, 0.0
+	for _, dev := range m.orderedDevices() {
+		if dev.LastError != nil {
+			failing++
+		}
+		if dev.Data == nil {
+			continue
+		}
+		total += m.shownScore(dev)
+		withData++
+		for _, s := range dev.Data.Readings() {
+			val := awair.DisplayValue(s.Key, s.Value)
+			rank := ratingSeverity(awair.RateSensorValue(s.Key, val))
+			excess := awair.OptimalRanges[s.Key].Excess(val)
+			if rank > worstRank || (rank == worstRank && rank > 0 && excess > worstExcess) {
+				worstDev, worst, worstRank, worstExcess = dev, s, rank, excess
+			}
+		}
+	}
+
+	if withData == 0 {
+		avg = lipgloss.NewStyle().Foreground(theme.Muted).Render("waiting for data")
+	} else {
+		score := (total + withData/2) / withData
+		avg = "avg " + lipgloss.NewStyle().Bold(true).Foreground(scoreColor(score)).
+			Render(fmt.Sprintf("%d %s", score, scoreLabel(score)))
+		if worstDev != nil {
+			color := ratingColor(awair.RateSensorValue(worst.Key, awair.DisplayValue(worst.Key, worst.Value)))
+			worstReading = "worst: " + worstDev.Name + " " + lipgloss.NewStyle().Foreground(color).
+				Render(awair.OptimalRanges[worst.Key].Label+" "+awair.FormatValue(worst.Key, worst.Value, m.fahrenheit))
+		} else {
+			worstReading = lipgloss.NewStyle().Foreground(theme.Good).Render("all readings good")
+		}
+	}
+	if failing > 0 {
+		failingDevs = lipgloss.NewStyle().Foreground(theme.Poor).
+			Render(fmt.Sprintf("%d device(s) in error", failing))
+	}
+	return avg, worstReading, failingDevs
+}
+
+// statusHint is a key hint in the status bar; clicking it presses key.
+// Hints without a key are just state.
+type statusHint struct {
+	key, text string
+}
+
+// statusHints returns the status bar's key hints for the current view.
+func (m model) statusHints() []statusHint {
+	var hints []statusHint
+	switch {
+	case len(m.devices) == 0 && len(m.picker.items) > 0:
+		hints = []statusHint{{"?", "? Help"}, {"q", "q Quit"}, {" ", "space Select"}, {"enter", "enter Add"},
+			{"a", "a Add all"}, {"i", "i Enter address"}, {"d", "d Search again"}, {"S", "S Scan"}}
+	case m.detailID != 0:
+		hints = []statusHint{{"?", "? Help"}, {"q", "q Quit"}, {"esc", "esc Back"}, {"D", "D Device display"},
+			{"L", "L/[ ] LEDs"}, {"y", "y/Y Copy"}, {"R", "R Reset records"}}
+	case m.zoomID != 0:
+		hints = []statusHint{{"?", "? Help"}, {"q", "q Quit"}, {"z", "z/esc Back"}}
+	default:
+		if m.sortMode != sortManual {
+			hints = append(hints, statusHint{"", "sort: " + m.sortMode})
+		}
+		hints = append(hints, statusHint{"?", "? Help"}, statusHint{"q", "q Quit"}, statusHint{"r", "r Refresh"},
+			statusHint{"p", "p Pause"}, statusHint{"a", "a Add device"}, statusHint{"enter", "enter Details"},
+			statusHint{"s", "s Summary"})
+	}
+	if n := len(m.picker.items); n > 0 && len(m.devices) > 0 {
+		hints = append(hints, statusHint{"F", fmt.Sprintf("F Found (%d)", n)})
+	}
+	if m.focusSensor != "" && m.detailID == 0 && m.zoomID == 0 {
+		hints = append([]statusHint{{"", "Focus: " + awair.OptimalRanges[m.focusSensor].Label},
+			{"f", "f Next sensor"}, {"esc", "esc Off"}}, hints...)
+	}
+	if perPage, pages := m.gridPaging(); pages > 1 && m.detailID == 0 && m.zoomID == 0 {
+		hints = append(hints, statusHint{"pgdown", fmt.Sprintf("pgup/pgdn page %d/%d", m.selected/perPage+1, pages)})
+	}
+	return hints
+}
+
+// discoverySegment is the discovery state the status bar shows before
+// the key hints.
+func (m model) discoverySegment() string {
+	return "discovery " + m.discoveryStatus() + "  "
+}
+
 func (m model) renderStatusBar() string {
-	return lipgloss.NewStyle().
-		Width(m.width).
-		Background(lipgloss.Color("#333333")).
-		Foreground(lipgloss.Color("#FFFFFF")).
-		Render(" q Quit  r Refresh  a Add device  d Discovery")
+	if m.showLogs {
+		return lipgloss.NewStyle().
+			Width(m.width).
+			Background(theme.BarBG).
+			Foreground(theme.BarFG).
+			Render(truncateWidth(" ↑↓ pgup/pgdn Scroll  home/end Top/Follow  esc Back  q Quit", m.width))
+	}
+	if m.showSummary {
+		return lipgloss.NewStyle().
+			Width(m.width).
+			Background(theme.BarBG).
+			Foreground(theme.BarFG).
+			Render(truncateWidth(" ↑↓ Scroll  home/end Top/Bottom  esc Back  q Quit", m.width))
+	}
+	var hints []string
+	for _, h := range m.statusHints() {
+		hints = append(hints, h.text)
+	}
+
+	paused := ""
+	if m.paused {
+		paused = lipgloss.NewStyle().
+			Bold(true).
+			Background(theme.Poor).
+			Foreground(theme.BarFG).
+			Render(" PAUSED ")
+	}
+	// The state comes first, so a narrow terminal cuts the key hints
+	// (all listed under ?) rather than it
+	bar := lipgloss.NewStyle().Background(theme.BarBG).Foreground(theme.BarFG)
+	segments := []statusSegment{{" " + m.pollStatus() + "  ", bar}}
+	if failing := m.failingCount(); failing > 0 {
+		segments = append(segments, statusSegment{fmt.Sprintf("%d/%d err  ", failing, len(m.devices)), bar.Bold(true).Foreground(theme.Poor)})
+	}
+	segments = append(segments, statusSegment{m.discoverySegment() + strings.Join(hints, "  "), bar})
+
+	out := paused
+	width := m.width - lipgloss.Width(paused)
+	for _, seg := range segments {
+		text := truncateWidth(seg.text, width)
+		out += seg.style.Render(text)
+		width -= lipgloss.Width(text)
+	}
+	return out + bar.Width(width).Render("")
+}
+
+// statusSegment is a differently styled part of the status bar.
+type statusSegment struct {
+	text  string
+	style lipgloss.Style
+}
+
+// pollStatus says when the tick loop polls next, for the status bar.
+func (m model) pollStatus() string {
+	if m.paused {
+		return fmt.Sprintf("every %s, paused", m.pollInterval)
+	}
+	next := max(time.Until(m.nextTick), 0).Round(time.Second)
+	return fmt.Sprintf("next poll in %s (every %s)", shortDuration(next), m.pollInterval)
+}
+
+// failingCount is how many devices failed their last poll.
+func (m model) failingCount() int {
+	n := 0
+	for _, dev := range m.devices {
+		if dev.LastError != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// discoveryStatus is "disabled", "searching" while a pass started with d
+// runs or nothing has been found yet, and "idle" otherwise; the listener
+// keeps picking up announcements either way.
+func (m model) discoveryStatus() string {
+	switch {
+	case m.noDiscovery:
+		return "disabled"
+	case m.discovering || len(m.devices) == 0:
+		return "searching"
+	default:
+		return "idle"
+	}
 }
 
+// The log panel shows logPanelLines entries; with its border it is
+// logPanelHeight lines high.
+const (
+	logPanelLines  = 4
+	logPanelHeight = logPanelLines + 2
+)
+
 func (m model) renderLogPanel() string {
 	border := lipgloss.NewStyle().
-		Width(m.width - 2).
-		Height(4).
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(colorGray).
+		Width(m.width-2).
+		Height(logPanelLines).
+		Border(glyphs.Border).
+		BorderForeground(theme.Muted).
 		Padding(0, 1)
 
-	start := len(m.logs) - 4
-	if start < 0 {
-		start = 0
-	}
-	lines := make([]string, 0, 4)
-	for _, entry := range m.logs[start:] {
-		ts := lipgloss.NewStyle().Foreground(colorGray).Render(entry.Time.Format("15:04:05"))
-		lines = append(lines, ts+" "+entry.Message)
+	lines := make([]string, 0, logPanelLines)
+	for _, entry := range m.panelLogEntries(logPanelLines) {
+		// One line each: the content width less the timestamp
+		entry.Message = truncateWidth(entry.Message, m.width-4-9)
+		lines = append(lines, formatLogLine(entry))
 	}
 
 	content := strings.Join(lines, "\n")
 	return border.Render(content)
 }
 
+// errorPinDuration is how long a warning or error stays pinned in the log
+// panel after newer entries have pushed it out.
+const errorPinDuration = 2 * time.Minute
+
+// panelLogEntries picks the n entries shown in the log panel: the newest
+// ones, except that a recent warning or error pushed out by newer entries
+// keeps the top line. Scrolled back, it shows the entries scrolled to.
+func (m model) panelLogEntries(n int) []logEntry {
+	if m.logPanelScroll > 0 {
+		end := max(len(m.logs)-m.logPanelScroll, 0)
+		return m.logs[max(end-n, 0):end]
+	}
+	start := len(m.logs) - n
+	if start <= 0 {
+		return m.logs
+	}
+	for i := start - 1; i >= 0; i-- {
+		e := m.logs[i]
+		if age(e.Time) > errorPinDuration {
+			break
+		}
+		if e.Level >= levelWarn {
+			return append([]logEntry{e}, m.logs[start+1:]...)
+		}
+	}
+	return m.logs[start:]
+}
+
+// formatLogLine renders one log entry, colored by level.
+func formatLogLine(e logEntry) string {
+	ts := lipgloss.NewStyle().Foreground(theme.Muted).Render(e.Time.Format("15:04:05"))
+	switch e.Level {
+	case levelError:
+		return ts + " " + lipgloss.NewStyle().Foreground(theme.Poor).Render(e.Message)
+	case levelWarn:
+		return ts + " " + lipgloss.NewStyle().Foreground(theme.Fair).Render(e.Message)
+	}
+	return ts + " " + e.Message
+}
+
 func (m model) renderEmptyState(height int) string {
+	if len(m.picker.items) > 0 {
+		return m.renderSetupList(height)
+	}
+
 	msg := lipgloss.NewStyle().Bold(true).Render("No Awair devices found") + "\n\n" +
 		"Searching via mDNS discovery...\n\n" +
 		"Press " + lipgloss.NewStyle().Bold(true).Render("a") + " to manually add a device IP\n" +
 		"Press " + lipgloss.NewStyle().Bold(true).Render("d") + " to restart discovery\n" +
+		"Press " + lipgloss.NewStyle().Bold(true).Render("S") + " to scan a subnet if mDNS is blocked\n" +
 		"Press " + lipgloss.NewStyle().Bold(true).Render("q") + " to quit"
 
 	return lipgloss.NewStyle().
 		Width(m.width).
 		Height(height).
 		Align(lipgloss.Center, lipgloss.Center).
-		Foreground(colorGray).
+		Foreground(theme.Muted).
 		Render(msg)
 }
 
+// renderSetupList shows the devices found while the dashboard is empty as
+// a checklist, with the result of an HTTP check for each.
+func (m model) renderSetupList(height int) string {
+	width := min(72, m.width-4)
+	title := lipgloss.NewStyle().Bold(true).Render(
+		fmt.Sprintf("Found %d Awair device(s) on the network", len(m.picker.items)))
+	help := lipgloss.NewStyle().Foreground(theme.Muted).
+		Render("space select  enter add  a add all  i enter an address  d search again  S scan")
+
+	// Title, help and the blank lines around the list
+	list := m.picker.render(width, height-4)
+	return lipgloss.Place(m.width, height,
+		lipgloss.Center, lipgloss.Center,
+		lipgloss.NewStyle().Width(width).
+			Render(title+"\n\n"+list+"\n\n"+help))
+}
+
 // gridCols picks column count for the device grid.
 func gridCols(n int) int {
 	if n <= 2 {
@@ -500,20 +2087,86 @@ func gridCols(n int) int {
 	return 3
 }
 
-func (m model) renderDeviceGrid(height int) string {
+// minBoxHeight is the smallest readable device box: border, name, score,
+// gauge, a blank line and five sensor rows.
+const minBoxHeight = 11
+
+// gridPaging returns how many devices fit on one page of the grid (or
+// table) and the number of pages. The page shown is the one holding the
+// selection.
+func (m model) gridPaging() (perPage, pages int) {
+	n := len(m.deviceOrder)
+	if n == 0 {
+		return 1, 1
+	}
+	if m.viewMode == viewTable {
+		perPage = tableRows(m.gridHeight())
+	} else {
+		rows := m.gridHeight() / minBoxHeight
+		if rows < 1 {
+			rows = 1
+		}
+		perPage = gridCols(n) * rows
+	}
+	if perPage >= n {
+		return n, 1
+	}
+	return perPage, (n + perPage - 1) / perPage
+}
+
+// gridLayout is where renderDeviceGrid puts the cards of the page that
+// holds the selection: devs, the first of which is at index first of
+// orderedDevices, in rows of cols boxes. Mouse clicks are hit-tested
+// against it.
+type gridLayout struct {
+	devs                []*Device
+	first, cols, rows   int
+	boxWidth, boxHeight int // the last column takes the remaining width
+}
+
+// gridLayout lays out the grid in the given height.
+func (m model) gridLayout(height int) gridLayout {
 	devs := m.orderedDevices()
 	if len(devs) == 0 {
-		return m.renderEmptyState(height)
+		return gridLayout{}
 	}
-
 	cols := gridCols(len(devs))
-	rows := (len(devs) + cols - 1) / cols
-	boxWidth := m.width / cols
-	boxHeight := height / rows
+	perPage, pages := m.gridPaging()
+	first := (m.selected / perPage) * perPage
+	rows := (perPage + cols - 1) / cols
+	devs = devs[first:min(first+perPage, len(devs))]
+	if pages == 1 {
+		rows = (len(devs) + cols - 1) / cols
+	}
+	// A border and one line at least; fitDeviceContent drops rows to fit
+	return gridLayout{devs: devs, first: first, cols: cols, rows: rows,
+		boxWidth: m.width / cols, boxHeight: max(height/rows, 3)}
+}
+
+// cardAt returns the orderedDevices index of the card at x, y within the
+// grid, or -1 if there is none.
+func (l gridLayout) cardAt(x, y int) int {
+	if l.cols == 0 || x < 0 || y < 0 {
+		return -1
+	}
+	col, row := min(x/l.boxWidth, l.cols-1), y/l.boxHeight
+	if idx := row*l.cols + col; row < l.rows && idx < len(l.devs) {
+		return l.first + idx
+	}
+	return -1
+}
+
+func (m model) renderDeviceGrid(height int) string {
+	layout := m.gridLayout(height)
+	if len(layout.devs) == 0 {
+		return m.renderEmptyState(height)
+	}
+	devs, first, cols := layout.devs, layout.first, layout.cols
+	boxWidth, boxHeight := layout.boxWidth, layout.boxHeight
 
 	var rowStrings []string
 
-	for row := 0; row < rows; row++ {
+	for row := 0; row < layout.rows; row++ {
 		var colStrings []string
 		for col := 0; col < cols; col++ {
 			idx := row*cols + col
@@ -538,14 +2191,28 @@ func (m model) renderDeviceGrid(height int) string {
 				innerWidth = 10
 			}
 
-			content := m.renderDeviceContent(dev, innerWidth)
+			// Inner height = box height - 2 (border)
+			content := m.renderDeviceContent(dev, innerWidth, boxHeight-2)
+
+			border := glyphs.Border
+			if first+idx == m.selected {
+				border = glyphs.Selected
+			}
+			borderColor := theme.Accent
+			switch {
+			case m.flashes[dev.ID] != nil:
+				borderColor = theme.Poor
+			case dev.Offline():
+				borderColor = theme.Muted
+			}
 
 			box := lipgloss.NewStyle().
-				Width(w - 2).
+				Width(w-2).
 				MaxWidth(w).
-				Height(boxHeight - 2).
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(colorCyan).
+				Height(boxHeight-2).
+				MaxHeight(boxHeight).
+				Border(border).
+				BorderForeground(borderColor).
 				Padding(0, 1).
 				Render(content)
 
@@ -557,21 +2224,69 @@ func (m model) renderDeviceGrid(height int) string {
 	return lipgloss.JoinVertical(lipgloss.Left, rowStrings...)
 }
 
-func (m model) renderDeviceContent(dev *Device, width int) string {
+// trendArrow returns a one-column arrow for the change of sensor key from
+// the previous reading to value: red when heading towards poor, green when
+// improving, a gray → when the change is within TrendThresholds. It is
+// blank without a previous reading.
+func trendArrow(key string, prev *awair.SensorData, value float64) string {
+	if prev == nil {
+		return " "
+	}
+	for _, p := range prev.Readings() {
+		if p.Key != key {
+			continue
+		}
+		if math.Abs(value-p.Value) < TrendThresholds[key] {
+			return lipgloss.NewStyle().Foreground(theme.Muted).Render("→")
+		}
+		arrow := "↓"
+		if value > p.Value {
+			arrow = "↑"
+		}
+		color := theme.Good
+		if awair.OptimalRanges[key].Worsens(awair.DisplayValue(key, p.Value), awair.DisplayValue(key, value)) {
+			color = theme.Poor
+		}
+		return lipgloss.NewStyle().Foreground(color).Render(arrow)
+	}
+	return " "
+}
+
+// cardReadings returns the readings listed in keys, in that order, or
+// all of them if keys is nil.
+func cardReadings(readings []awair.SensorReading, keys []string) []awair.SensorReading {
+	if keys == nil {
+		return readings
+	}
+	var shown []awair.SensorReading
+	for _, k := range keys {
+		for _, r := range readings {
+			if r.Key == k {
+				shown = append(shown, r)
+			}
+		}
+	}
+	return shown
+}
+
+// renderDeviceContent renders a device card's contents in width columns.
+// With height > 0 it never uses more than height lines; see
+// fitDeviceContent.
+func (m model) renderDeviceContent(dev *Device, width, height int) string {
 	// Device name header
-	nameLabel := fmt.Sprintf("%s (%s)", dev.Name, dev.IP)
-	if lipgloss.Width(nameLabel) > width {
-		nameLabel = nameLabel[:width]
+	nameLabel := truncateWidth(dev.Label(), width)
+	header := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(nameLabel)
+	if badge := renderAlertBadge(dev.Alerts); badge != "" && lipgloss.Width(nameLabel)+1+lipgloss.Width(badge) <= width {
+		header += " " + badge
 	}
-	header := lipgloss.NewStyle().Bold(true).Foreground(colorCyan).Render(nameLabel)
 
 	if dev.LastError != nil && dev.Data == nil {
-		errStyle := lipgloss.NewStyle().Foreground(colorPoor)
-		return header + "\n\n" + errStyle.Render("Error: "+dev.LastError.Error()) + "\n\nRetrying..."
+		errStyle := lipgloss.NewStyle().Foreground(theme.Poor)
+		return clipLines(header+"\n\n"+errStyle.Render("Error: "+errorSummary(dev.LastError))+"\n\n"+m.retryText(dev), height)
 	}
 
 	if dev.Data == nil {
-		return header + "\n\n" + lipgloss.NewStyle().Foreground(colorFair).Render("Connecting...")
+		return clipLines(header+"\n\n"+lipgloss.NewStyle().Foreground(theme.Fair).Render("Connecting..."), height)
 	}
 
 	d := dev.Data
@@ -579,84 +2294,224 @@ func (m model) renderDeviceContent(dev *Device, width int) string {
 	if barWidth < 0 {
 		barWidth = 0
 	}
-
-	var lines []string
-	lines = append(lines, header)
+	// An offline device keeps its last reading on show, dimmed as stale
+	offline := dev.Offline()
 
 	// Awair Score
-	sc := scoreColor(d.Score)
-	sl := scoreLabel(d.Score)
+	shown := m.shownScore(dev)
+	sc := scoreColor(shown)
+	if offline {
+		sc = theme.Muted
+	}
+	sl := scoreLabel(shown)
 	scoreStyle := lipgloss.NewStyle().Bold(true).Foreground(sc)
-	lines = append(lines,
-		fmt.Sprintf("%s    %s",
-			lipgloss.NewStyle().Bold(true).Render("Awair Score"),
-			scoreStyle.Render(fmt.Sprintf("%d %s", d.Score, sl))))
-
+	score := fmt.Sprintf("%s    %s",
+		lipgloss.NewStyle().Bold(true).Render("Awair Score"),
+		scoreStyle.Render(fmt.Sprintf("%d %s", shown, sl)))
+	gauge := ""
 	if barWidth > 0 {
-		lines = append(lines, renderGauge(d.Score, barWidth, sc))
+		gauge = renderGauge(shown, barWidth, sc)
 	}
-	lines = append(lines, "")
 
 	// Sensor readings
-	type sensorEntry struct {
-		Key   string
-		Value float64
-	}
-
-	sensors := []sensorEntry{
-		{"temp", d.Temp},
-		{"humid", d.Humid},
-		{"co2", d.CO2},
-		{"voc", d.VOC},
-		{"pm25", d.PM25},
-	}
-	if d.DewPoint != nil {
-		sensors = append(sensors, sensorEntry{"dew_point", *d.DewPoint})
-	}
-	if d.AbsHumid != nil {
-		sensors = append(sensors, sensorEntry{"abs_humid", *d.AbsHumid})
-	}
-	if d.CO2Est != nil {
-		sensors = append(sensors, sensorEntry{"co2_est", *d.CO2Est})
-	}
-	if d.PM10Est != nil {
-		sensors = append(sensors, sensorEntry{"pm10_est", *d.PM10Est})
+	readings := cardReadings(d.Readings(), m.cardSensors)
+	if m.focusSensor != "" {
+		// Shown on its own focus line instead
+		var rest []awair.SensorReading
+		for _, s := range readings {
+			if s.Key != m.focusSensor {
+				rest = append(rest, s)
+			}
+		}
+		readings = rest
 	}
-
-	for _, s := range sensors {
-		r := OptimalRanges[s.Key]
-		ratingVal := DisplayValue(s.Key, s.Value)
-		rating := RateSensorValue(s.Key, ratingVal)
+	sensors := make([]string, len(readings))
+	for i, s := range readings {
+		r := awair.OptimalRanges[s.Key]
+		ratingVal := awair.DisplayValue(s.Key, s.Value)
+		rating := awair.RateSensorValue(s.Key, ratingVal)
 		color := ratingColor(rating)
-		valStr := FormatValue(s.Key, s.Value, m.fahrenheit)
+		valStr := ratingMark(rating) + awair.FormatValue(s.Key, s.Value, m.fahrenheit)
 		label := visPadRight(r.Label, 14)
 		valPad := visPadLeft(valStr, 12)
 
 		valStyle := lipgloss.NewStyle().Foreground(color)
 		labelStyle := lipgloss.NewStyle().Bold(true)
+		barColor := color
+		if m.focusSensor != "" || offline {
+			// Dimmed behind the focus line, or stale
+			valStyle = lipgloss.NewStyle().Foreground(theme.Muted)
+			labelStyle = lipgloss.NewStyle().Foreground(theme.Muted)
+			barColor = theme.Muted
+		}
+		if f := m.flashes[dev.ID]; f != nil && f.sensors[s.Key] {
+			valStyle = valStyle.Bold(true)
+			labelStyle = labelStyle.Foreground(theme.Poor)
+		}
+		arrow := trendArrow(s.Key, dev.Prev, s.Value)
+		if offline {
+			arrow = " "
+		}
 
 		if barWidth > 0 {
-			bar := renderSensorBar(s.Key, ratingVal, barWidth, color)
-			lines = append(lines, fmt.Sprintf("%s %s  %s",
+			bar := sensorRowTail(s, ratingVal, barWidth, barColor)
+			sensors[i] = fmt.Sprintf("%s %s %s %s",
 				labelStyle.Render(label),
 				valStyle.Render(valPad),
-				bar))
+				arrow,
+				bar)
 		} else {
-			lines = append(lines, fmt.Sprintf("%s %s",
+			sensors[i] = fmt.Sprintf("%s %s %s",
 				labelStyle.Render(label),
-				valStyle.Render(valPad)))
+				valStyle.Render(valPad),
+				arrow)
 		}
 	}
 
 	// Timestamp
-	if !dev.LastUpdate.IsZero() {
+	ts := ""
+	if offline {
+		ts = lipgloss.NewStyle().Bold(true).Foreground(theme.Poor).Render("OFFLINE") +
+			lipgloss.NewStyle().Foreground(theme.Muted).Render(" — last seen "+shortDuration(age(dev.LastUpdate))+" ago, "+
+				lowerFirst(m.retryText(dev)))
+	} else if !dev.LastUpdate.IsZero() {
+		// When the device took the sample and when it last answered, so
+		// a device with a wrong clock stands out
+		updated := "Contact " + dev.LastUpdate.Format("15:04:05")
+		if !dev.SampleTime.IsZero() {
+			updated = "Sample " + formatSampleTime(dev.SampleTime) + " · contact " + dev.LastUpdate.Format("15:04:05")
+		}
+		if m.paused {
+			updated += fmt.Sprintf(" (%s ago)", age(dev.LastUpdate).Round(time.Second))
+		}
+		tail := endpointText(dev) + m.latencyText(dev) + m.firmwareText(dev)
+		warn := ""
+		if m.clockSkewed(dev) {
+			warn = lipgloss.NewStyle().Foreground(theme.Fair).Render(glyphs.Warn + " ")
+		}
+		updated = truncateWidth(updated, width-lipgloss.Width(warn)-lipgloss.Width(tail))
+		ts = warn + lipgloss.NewStyle().Foreground(theme.Muted).Render(updated) + tail
+		if m.cloudQuotaLeft(dev, time.Now()) == 0 {
+			ts = lipgloss.NewStyle().Foreground(theme.Fair).Render(truncateWidth(cloudExhaustedText, width))
+		}
+	}
+
+	// The focused sensor (f) leads the sensor list, on every card
+	focus := ""
+	if m.focusSensor != "" {
+		focus = m.renderFocusLine(dev, barWidth)
+	}
+
+	// Everything fits: header, score, gauge, blank, focus, sensors, blank,
+	// time
+	natural := 3 + len(sensors)
+	if gauge != "" {
+		natural++
+	}
+	if focus != "" {
+		natural++
+	}
+	if ts != "" {
+		natural += 2
+	}
+	if height <= 0 || natural <= height {
+		lines := []string{header, score}
+		if gauge != "" {
+			lines = append(lines, gauge)
+		}
 		lines = append(lines, "")
-		ts := lipgloss.NewStyle().Foreground(colorGray).
-			Render("Updated: " + dev.LastUpdate.Format("15:04:05"))
+		if focus != "" {
+			lines = append(lines, focus)
+		}
+		lines = append(lines, sensors...)
+		if ts != "" {
+			lines = append(lines, "", ts)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	return strings.Join(fitDeviceContent(header, score, gauge, focus, sensors, readings, ts, height), "\n")
+}
+
+// fitDeviceContent lays out a device card that doesn't fit in height
+// lines. Sections go in by priority: header, score, the focused sensor,
+// the most severe sensors (kept in their usual order, with a "+N more"
+// line for the rest), the timestamp, then the gauge if a line is left.
+// Spacing is dropped.
+func fitDeviceContent(header, score, gauge, focus string, sensors []string, readings []awair.SensorReading, ts string, height int) []string {
+	lines := []string{header}
+	if height < 2 {
+		return lines
+	}
+	lines = append(lines, score)
+	room := height - 2
+	if focus != "" && room > 0 {
+		lines = append(lines, focus)
+		room--
+	}
+	if gauge != "" && room > len(sensors)+1 {
+		lines = append(lines, gauge)
+		room--
+	}
+
+	shown := len(sensors)
+	if room < shown {
+		// Keep one line for the "+N more" note
+		shown = max(room-1, 0)
+	}
+	keep := make([]bool, len(sensors))
+	for _, i := range sensorsBySeverity(readings)[:shown] {
+		keep[i] = true
+	}
+	for i, line := range sensors {
+		if keep[i] {
+			lines = append(lines, line)
+		}
+	}
+	if hidden := len(sensors) - shown; hidden > 0 {
+		if room > 0 {
+			lines = append(lines, lipgloss.NewStyle().Foreground(theme.Muted).Render(fmt.Sprintf("+%d more", hidden)))
+		}
+	} else if ts != "" && room > shown {
 		lines = append(lines, ts)
 	}
+	return lines
+}
+
+// sensorsBySeverity returns the indexes of readings, worst rated first
+// and then by how far outside the good range they are. Ties keep their
+// order.
+func sensorsBySeverity(readings []awair.SensorReading) []int {
+	idx := make([]int, len(readings))
+	sev := make([]int, len(readings))
+	excess := make([]float64, len(readings))
+	for i, s := range readings {
+		idx[i] = i
+		val := awair.DisplayValue(s.Key, s.Value)
+		sev[i] = ratingSeverity(awair.RateSensorValue(s.Key, val))
+		excess[i] = awair.OptimalRanges[s.Key].Excess(val)
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		i, j := idx[a], idx[b]
+		if sev[i] != sev[j] {
+			return sev[i] > sev[j]
+		}
+		return excess[i] > excess[j]
+	})
+	return idx
+}
 
-	return strings.Join(lines, "\n")
+// renderAlertBadge renders the device's worst alert, or "" if none.
+func renderAlertBadge(alerts AlertSnapshot) string {
+	a, ok := alerts.Worst()
+	if !ok {
+		return ""
+	}
+	color := theme.Fair
+	if a.Severity == alertCritical {
+		color = theme.Poor
+	}
+	return lipgloss.NewStyle().Bold(true).Foreground(color).Render("▲ " + a.Label())
 }
 
 func renderGauge(score int, width int, color lipgloss.Color) string {
@@ -670,10 +2525,10 @@ func renderGauge(score int, width int, color lipgloss.Color) string {
 	}
 
 	filledStyle := lipgloss.NewStyle().Foreground(color)
-	emptyStyle := lipgloss.NewStyle().Foreground(colorDim)
+	emptyStyle := lipgloss.NewStyle().Foreground(theme.Dim)
 
-	return filledStyle.Render(strings.Repeat("█", filled)) +
-		emptyStyle.Render(strings.Repeat("░", width-filled))
+	return filledStyle.Render(strings.Repeat(glyphs.Filled, filled)) +
+		emptyStyle.Render(strings.Repeat(glyphs.Empty, width-filled))
 }
 
 func renderSensorBar(key string, value float64, width int, color lipgloss.Color) string {
@@ -697,6 +2552,10 @@ func renderSensorBar(key string, value float64, width int, color lipgloss.Color)
 		ratio = clamp01(value / 1500)
 	case "pm10_est":
 		ratio = clamp01(value / 200)
+	case "lux":
+		ratio = clamp01(value / 1000) // a well-lit room
+	case "spl_a":
+		ratio = clamp01((value - 30) / 70) // 30-100 dBA
 	default: // pm25
 		ratio = clamp01(value / 100)
 	}
@@ -707,10 +2566,10 @@ func renderSensorBar(key string, value float64, width int, color lipgloss.Color)
 	}
 
 	filledStyle := lipgloss.NewStyle().Foreground(color)
-	emptyStyle := lipgloss.NewStyle().Foreground(colorDim)
+	emptyStyle := lipgloss.NewStyle().Foreground(theme.Dim)
 
-	return filledStyle.Render(strings.Repeat("█", filled)) +
-		emptyStyle.Render(strings.Repeat("░", width-filled))
+	return filledStyle.Render(strings.Repeat(glyphs.Filled, filled)) +
+		emptyStyle.Render(strings.Repeat(glyphs.Empty, width-filled))
 }
 
 func clamp01(v float64) float64 {
@@ -725,16 +2584,23 @@ func clamp01(v float64) float64 {
 
 func (m model) overlayPrompt(grid string, gridHeight int) string {
 	var title string
-	if m.promptStep == "ip" {
-		title = "Enter device IP address"
-	} else {
+	switch m.promptStep {
+	case "ip":
+		title = "Device IP, host name or URL (:port optional)"
+	case "resolving":
+		title = fmt.Sprintf("Resolving %s…", m.pendingIP)
+	case "rename":
+		title = fmt.Sprintf("Rename %s (empty to reset)", m.pendingIP)
+	case "scan":
+		title = "Range to scan for devices"
+	default:
 		title = "Friendly name (optional, Enter to skip)"
 	}
 
 	promptBox := lipgloss.NewStyle().
 		Width(50).
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(colorCyan).
+		Border(glyphs.Border).
+		BorderForeground(theme.Accent).
 		Padding(0, 1).
 		Render(title + "\n" + m.promptInput.View())
 
@@ -743,6 +2609,34 @@ func (m model) overlayPrompt(grid string, gridHeight int) string {
 		promptBox)
 }
 
+func (m model) overlayPicker(gridHeight int) string {
+	title := fmt.Sprintf("Found but not added (%d)", len(m.picker.items))
+	help := lipgloss.NewStyle().Foreground(theme.Muted).
+		Render("space select  enter add  esc close")
+
+	// Border (2) + title + help lines
+	listHeight := gridHeight - 4
+	box := lipgloss.NewStyle().
+		Width(60).
+		Border(glyphs.Border).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Render(title + "\n" + m.picker.render(56, listHeight) + "\n" + help)
+
+	return lipgloss.Place(m.width, gridHeight,
+		lipgloss.Center, lipgloss.Center,
+		box)
+}
+
+// clipLines keeps the first n lines of s; n <= 0 keeps everything.
+func clipLines(s string, n int) string {
+	lines := strings.Split(s, "\n")
+	if n <= 0 || len(lines) <= n {
+		return s
+	}
+	return strings.Join(lines[:n], "\n")
+}
+
 // visPadRight pads s with spaces to visual width n using lipgloss.Width.
 func visPadRight(s string, n int) string {
 	w := lipgloss.Width(s)
@@ -752,6 +2646,29 @@ func visPadRight(s string, n int) string {
 	return s + strings.Repeat(" ", n-w)
 }
 
+// truncateWidth shortens plain text s to at most width terminal columns,
+// ending it with "…" if anything was cut. It cuts between grapheme
+// clusters, measured as lipgloss.Width measures them, so wide characters,
+// emoji and combining accents are never split.
+func truncateWidth(s string, width int) string {
+	if lipgloss.Width(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	out, w := 0, 0
+	for out < len(s) {
+		cluster, cw := ansi.FirstGraphemeCluster(s[out:], ansi.GraphemeWidth)
+		if w+cw > width-1 {
+			break
+		}
+		out += len(cluster)
+		w += cw
+	}
+	return s[:out] + "…"
+}
+
 // visPadLeft pads s with leading spaces to visual width n using lipgloss.Width.
 func visPadLeft(s string, n int) string {
 	w := lipgloss.Width(s)
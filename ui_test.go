@@ -0,0 +1,406 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// newTestModel is the dashboard as started with ips on the command line
+// and an in-memory config, so nothing is saved.
+func newTestModel(t *testing.T, ips ...string) model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	cfg := &Config{Version: configVersion, Devices: DeviceList{}, memory: true}
+	s := resolveSettings(cfg, cliFlags{IPs: ips})
+	m := initialModel(cfg, &RecordStore{Devices: make(map[string]*DeviceRecords)}, s)
+	t.Cleanup(m.cancelRequests)
+	return m
+}
+
+// TestTickAfterClockStepBack checks the tick loop when the wall clock
+// stepped back since the last tick, leaving it in the future.
+func TestTickAfterClockStepBack(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	m.lastTick = time.Now().Add(time.Hour).Round(0)
+	m.nextTick = m.lastTick.Add(m.pollInterval)
+
+	next, _ := m.Update(tickMsg{Gen: m.tickGen})
+	m = next.(model)
+	if d := time.Until(m.nextTick); d <= 0 || d > m.pollInterval {
+		t.Errorf("next tick in %v, want within %v", d, m.pollInterval)
+	}
+	for _, ip := range m.deviceOrder {
+		if in := m.retryIn(m.devices[ip]); in < 0 || in > 2*m.pollInterval {
+			t.Errorf("%s retry in %v", ip, in)
+		}
+	}
+
+	// A tick from a retired loop, e.g. one restarted by an interval
+	// change, doesn't fire again
+	lastTick := m.lastTick
+	if _, cmd := m.Update(tickMsg{Gen: m.tickGen - 1}); cmd != nil {
+		t.Error("a stale tick scheduled more work")
+	}
+	if !m.lastTick.Equal(lastTick) {
+		t.Error("a stale tick counted as a tick")
+	}
+
+	// retryIn never goes negative, whichever way the clock went
+	m.lastTick = time.Now().Add(-time.Hour).Round(0)
+	if in := m.retryIn(m.devices["192.0.2.1"]); in != 0 {
+		t.Errorf("retry in %v after a long gap, want 0", in)
+	}
+}
+
+// pollWith delivers a reading with the given CO₂ for the device at ip.
+func pollWith(m model, ip string, co2 float64) model {
+	next, _ := m.Update(pollResultMsg{IP: ip, Data: &awair.SensorData{Score: 85, Temp: 22, Humid: 50, CO2: co2, PM25: 3}})
+	return next.(model)
+}
+
+// selectedIP is the address of the selected device, "" if none.
+func selectedIP(m model) string {
+	if dev := m.selectedDevice(); dev != nil {
+		return dev.IP
+	}
+	return ""
+}
+
+func lastLog(m model) string {
+	if len(m.logs) == 0 {
+		return ""
+	}
+	return m.logs[len(m.logs)-1].Message
+}
+
+func TestDeviceHandles(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	a, b, c := m.devices["192.0.2.1"], m.devices["192.0.2.2"], m.devices["192.0.2.3"]
+	if a.ID == 0 || a.ID == b.ID || b.ID == c.ID || a.ID == c.ID {
+		t.Fatalf("handles %d %d %d", a.ID, b.ID, c.ID)
+	}
+	if m.device(b.ID) != b || m.device(0) != nil {
+		t.Error("device doesn't resolve handles")
+	}
+
+	m.removeDevice(b.IP)
+	if m.device(b.ID) != nil {
+		t.Error("a removed device still resolves")
+	}
+	// Handles are never reused, even for the same address
+	again := m.addDevice(b.IP, "")
+	if again.ID == b.ID || again.ID <= c.ID {
+		t.Errorf("re-added with handle %d; earlier ones %d %d %d", again.ID, a.ID, b.ID, c.ID)
+	}
+	if m.device(b.ID) != nil {
+		t.Error("the old handle resolves to the re-added device")
+	}
+}
+
+func TestRemoveSelectedDevice(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	m.selected = 1
+	m.removeDevice("192.0.2.2")
+	// The device that takes its place is selected
+	if got := selectedIP(m); got != "192.0.2.3" {
+		t.Errorf("selected %q, want 192.0.2.3", got)
+	}
+	// The last one: the one before it
+	m.removeDevice("192.0.2.3")
+	if got := selectedIP(m); got != "192.0.2.1" {
+		t.Errorf("selected %q, want 192.0.2.1", got)
+	}
+	m.removeDevice("192.0.2.1")
+	if got := selectedIP(m); got != "" {
+		t.Errorf("selected %q with no devices", got)
+	}
+}
+
+func TestRemoveOtherDeviceKeepsSelection(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	m.selected = 2
+	m.removeDevice("192.0.2.1")
+	if got := selectedIP(m); got != "192.0.2.3" {
+		t.Errorf("selected %q, want 192.0.2.3", got)
+	}
+}
+
+func TestRemoveDetailedDevice(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	b := m.devices["192.0.2.2"]
+	m.detailID, m.zoomID = b.ID, b.ID
+
+	m.removeDevice("192.0.2.1")
+	if m.detailID != b.ID || m.zoomID != b.ID {
+		t.Error("removing another device closed the views")
+	}
+	m.removeDevice(b.IP)
+	if m.detailID != 0 || m.zoomID != 0 {
+		t.Errorf("detail %d, zoom %d after removing the device", m.detailID, m.zoomID)
+	}
+	if !strings.Contains(lastLog(m), "Closed details") {
+		t.Errorf("last log %q", lastLog(m))
+	}
+	m.width, m.height = 100, 30
+	if m.View() == "" {
+		t.Error("nothing rendered")
+	}
+}
+
+func TestRemoveDeviceWithAlerts(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	m.notifier = testNotifier(t)
+	m.sortMode = sortSeverity
+	m = pollWith(m, "192.0.2.1", 500)
+	m = pollWith(m, "192.0.2.2", 1500)
+	m = pollWith(m, "192.0.2.3", 900)
+	b := m.devices["192.0.2.2"]
+	if w, ok := b.Alerts.Worst(); !ok || w.Severity != alertCritical {
+		t.Fatalf("alerts %+v", b.Alerts)
+	}
+	// Worst first: b, c, a
+	m.selected = 0
+	if got := selectedIP(m); got != b.IP {
+		t.Fatalf("selected %q, want %s", got, b.IP)
+	}
+
+	m.removeDevice(b.IP)
+	if got := selectedIP(m); got != "192.0.2.3" {
+		t.Errorf("selected %q, want 192.0.2.3", got)
+	}
+	for k := range m.notifier.sent {
+		if k.id == b.ID {
+			t.Errorf("%v still notified", k)
+		}
+	}
+	// A poll that was in flight is dropped
+	next, cmd := m.Update(pollResultMsg{IP: b.IP, Data: &awair.SensorData{Score: 40, CO2: 2000}})
+	m = next.(model)
+	if cmd != nil || m.devices[b.IP] != nil {
+		t.Error("a late poll brought the device back")
+	}
+	m.width, m.height = 100, 30
+	if m.View() == "" {
+		t.Error("nothing rendered")
+	}
+}
+
+func TestDiscoveryBurstLeavesOutRemoved(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2", "192.0.2.3")
+	for _, ip := range m.deviceOrder {
+		m.noteDiscovered(m.devices[ip])
+	}
+	m.removeDevice("192.0.2.2")
+	m.flushDiscoveryBurst()
+	if got := lastLog(m); got != "Discovered 2 devices: 192.0.2.1, 192.0.2.3" {
+		t.Errorf("logged %q", got)
+	}
+}
+
+// cardSections names each line of a rendered device card: header, score,
+// gauge, time, "" for spacing, a "+N more" note as is, and sensor keys.
+func cardSections(t *testing.T, card string, width int) []string {
+	t.Helper()
+	labels := make(map[string]string)
+	for key, r := range awair.OptimalRanges {
+		labels[r.Label] = key
+	}
+	var got []string
+	for i, line := range strings.Split(ansi.Strip(card), "\n") {
+		if w := lipgloss.Width(line); w > width {
+			t.Errorf("line %d is %d columns wide: %q", i, w, line)
+		}
+		line = strings.TrimSpace(line)
+		label, _, _ := strings.Cut(line, "  ")
+		switch {
+		case i == 0:
+			got = append(got, "header")
+		case line == "" || strings.HasPrefix(line, "+"):
+			got = append(got, line)
+		case strings.HasPrefix(line, "Awair Score"):
+			got = append(got, "score")
+		case strings.HasPrefix(line, "Contact "):
+			got = append(got, "time")
+		case labels[label] != "":
+			got = append(got, labels[label])
+		default:
+			got = append(got, "gauge")
+		}
+	}
+	return got
+}
+
+func TestDeviceCardHeights(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	dev := m.devices["192.0.2.1"]
+	dev.Name = "Office"
+	dew, abs := 12.0, 8.0
+	// CO₂ poor; PM2.5 and humidity fair, PM2.5 further out; the rest good
+	dev.Data = &awair.SensorData{
+		Score: 55, Temp: 22, Humid: 35, CO2: 1500, VOC: 100, PM25: 20,
+		DewPoint: &dew, AbsHumid: &abs,
+	}
+	dev.LastUpdate = time.Now()
+
+	const width = 60
+	tests := []struct {
+		height int
+		want   []string
+	}{
+		// Everything, with spacing
+		{14, []string{"header", "score", "gauge", "", "temp", "humid", "co2", "voc", "pm25", "dew_point", "abs_humid", "", "time"}},
+		// No spacing or gauge; every sensor and the time
+		{10, []string{"header", "score", "temp", "humid", "co2", "voc", "pm25", "dew_point", "abs_humid", "time"}},
+		// The five worst, in their usual order, and a note for the rest
+		{8, []string{"header", "score", "temp", "humid", "co2", "voc", "pm25", "+2 more"}},
+		{6, []string{"header", "score", "humid", "co2", "pm25", "+4 more"}},
+	}
+	for _, tt := range tests {
+		got := cardSections(t, m.renderDeviceContent(dev, width, tt.height), width)
+		if len(got) > tt.height {
+			t.Errorf("height %d: %d lines", tt.height, len(got))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("height %d: %q, want %q", tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"Office", 6, "Office"},
+		{"Office", 5, "Offi…"},
+		{"Office", 1, "…"},
+		{"Office", 0, ""},
+		// Wide characters aren't split: a column is left over instead
+		{"会議室の空気", 12, "会議室の空気"},
+		{"会議室の空気", 6, "会議…"},
+		{"会議室の空気", 5, "会議…"},
+		{"会議室の空気", 2, "…"},
+		{"Ｆｕｌｌ", 6, "Ｆｕ…"},
+		// Emoji, including ZWJ sequences and flags, are one character
+		{"Office 🌿", 9, "Office 🌿"},
+		{"Office 🌿", 8, "Office …"},
+		{"👩‍👩‍👧 Kids", 4, "👩‍👩‍👧 …"},
+		{"👩‍👩‍👧 Kids", 2, "…"},
+		{"🇩🇪🇫🇷 Flags", 4, "🇩🇪…"},
+		// A combining accent stays with its letter
+		{"Cafe\u0301 Lounge", 5, "Cafe\u0301…"},
+		{"Cafe\u0301 Lounge", 4, "Caf…"},
+	}
+	for _, tt := range tests {
+		if got := truncateWidth(tt.in, tt.width); got != tt.want {
+			t.Errorf("truncateWidth(%q, %d) = %q, want %q", tt.in, tt.width, got, tt.want)
+		}
+	}
+
+	// At every width: never wider, never a broken character
+	for _, s := range []string{"会議室の空気", "Office 🌿", "👩‍👩‍👧 Kids", "Cafe\u0301 Lounge", "🇩🇪🇫🇷 Flags"} {
+		for width := range lipgloss.Width(s) + 2 {
+			got := truncateWidth(s, width)
+			if lipgloss.Width(got) > width || !utf8.ValidString(got) || strings.ContainsRune(got, utf8.RuneError) {
+				t.Errorf("truncateWidth(%q, %d) = %q, %d columns", s, width, got, lipgloss.Width(got))
+			}
+			if !strings.HasPrefix(s, strings.TrimSuffix(got, "…")) {
+				t.Errorf("truncateWidth(%q, %d) = %q, not a prefix", s, width, got)
+			}
+		}
+	}
+}
+
+func TestWideNamesFitCards(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	dev := m.devices["192.0.2.1"]
+	m = pollWith(m, dev.IP, 500)
+	for _, name := range []string{"会議室の空気モニター", "👩‍👩‍👧 Kids' room 🌿", "Cafe\u0301 Lounge, upstairs"} {
+		dev.Name = name
+		for _, width := range []int{9, 12, 20} {
+			card := ansi.Strip(m.renderDeviceContent(dev, width, 0))
+			header, _, _ := strings.Cut(card, "\n")
+			if w := lipgloss.Width(header); w > width || !strings.HasSuffix(header, "…") {
+				t.Errorf("%q in %d columns: header %q, %d wide", name, width, header, w)
+			}
+			if strings.ContainsRune(header, utf8.RuneError) {
+				t.Errorf("%q in %d columns: header %q", name, width, header)
+			}
+		}
+	}
+}
+
+// press sends the key, as typed, to m.
+func press(m model, key string) (model, tea.Cmd) {
+	msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	if key == "esc" {
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	}
+	next, cmd := m.Update(msg)
+	return next.(model), cmd
+}
+
+func TestSlowTerminalStopsClock(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1")
+	m.slowTerminal = true
+	m, _ = press(m, "a")
+	next, cmd := m.Update(clockMsg{})
+	m = next.(model)
+	if cmd != nil {
+		t.Error("the clock kept ticking under the prompt")
+	}
+	// Closing the prompt starts it again
+	if m, cmd = press(m, "esc"); cmd == nil || m.clockStopped {
+		t.Error("the clock didn't start again after the prompt")
+	}
+
+	// Without slow-terminal mode it keeps ticking
+	m.slowTerminal = false
+	m, _ = press(m, "a")
+	if _, cmd := m.Update(clockMsg{}); cmd == nil {
+		t.Error("the clock stopped under the prompt")
+	}
+}
+
+func TestSlowTerminalFreezesScreen(t *testing.T) {
+	m := newTestModel(t, "192.0.2.1", "192.0.2.2")
+	m.slowTerminal = true
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = pollWith(next.(model), "192.0.2.1", 600)
+	m, _ = press(m, "a")
+	before := strings.Split(m.View(), "\n")
+
+	// Typing, polls and ticks only change the prompt's input line
+	for i, key := range strings.Split("192.0.2.30", "") {
+		m, _ = press(m, key)
+		m = pollWith(m, "192.0.2.1", float64(900+100*i))
+		m = failPoll(m, "192.0.2.2")
+		next, _ := m.Update(tickMsg{Gen: m.tickGen})
+		next, _ = next.(model).Update(clockMsg{})
+		m = next.(model)
+	}
+	after := strings.Split(m.View(), "\n")
+	if len(after) != len(before) {
+		t.Fatalf("%d lines, then %d", len(before), len(after))
+	}
+	for i := range before {
+		if before[i] != after[i] && !strings.Contains(after[i], "192.0.2.30") {
+			t.Errorf("line %d changed under the prompt:\n%s\n%s", i, before[i], after[i])
+		}
+	}
+
+	// Closing the prompt shows the screen as it is now
+	m, _ = press(m, "esc")
+	if view := m.View(); view == strings.Join(before, "\n") || !strings.Contains(view, "1800") {
+		t.Errorf("the screen stayed frozen after the prompt:\n%s", view)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// Webhook delivery: each attempt is cut off after webhookTimeout, and
+// 5xx responses and network errors are retried up to webhookAttempts in
+// total, waiting webhookBackoff before the first retry and doubling after.
+const (
+	webhookTimeout  = 10 * time.Second
+	webhookAttempts = 4
+	webhookBackoff  = 2 * time.Second
+)
+
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// validateWebhookURL checks that raw is an absolute http or https URL.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("--alert-webhook: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--alert-webhook: %q is not an http or https URL", raw)
+	}
+	return nil
+}
+
+// postWebhook makes one delivery attempt. retry reports whether a failure
+// is worth retrying.
+func postWebhook(target string, body []byte) (retry bool, err error) {
+	resp, err := webhookClient.Post(target, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL is the same for every attempt; keep just the cause
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode >= 500, &awair.StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+	return false, nil
+}
+
+// webhookResultMsg reports one delivery attempt.
+type webhookResultMsg struct {
+	URL      string
+	Template alertTemplates
+	Event    alertEvent
+	Attempt  int // 1-based
+	Err      error
+	Retry    bool
+}
+
+// webhookCmd makes delivery attempt number attempt in the background,
+// posting ev as t's webhook template has it.
+func webhookCmd(target string, t alertTemplates, ev alertEvent, attempt int) tea.Cmd {
+	return trackCmd("webhook "+ev.Sensor+" "+ev.IP, func() tea.Msg {
+		msg := webhookResultMsg{URL: target, Template: t, Event: ev, Attempt: attempt}
+		body, err := t.webhookBody(ev)
+		if err != nil {
+			msg.Err = fmt.Errorf("alert_webhook_template: %w", err)
+			return msg
+		}
+		msg.Retry, msg.Err = postWebhook(target, body)
+		return msg
+	})
+}
+
+// handleWebhookResult logs a delivery attempt and schedules the next one
+// if it failed and may be retried.
+func (m *model) handleWebhookResult(msg webhookResultMsg) tea.Cmd {
+	what := fmt.Sprintf("%s %s %s", msg.Event.Name, msg.Event.Label, msg.Event.Rating)
+	if msg.Err == nil {
+		m.addLog(fmt.Sprintf("Webhook: sent %s (attempt %d)", what, msg.Attempt))
+		return nil
+	}
+	if !msg.Retry || msg.Attempt >= webhookAttempts {
+		m.logAt(levelError, fmt.Sprintf("Webhook: giving up on %s after attempt %d: %s", what, msg.Attempt, errorSummary(msg.Err)))
+		return nil
+	}
+	wait := webhookBackoff << (msg.Attempt - 1)
+	m.logAt(levelWarn, fmt.Sprintf("Webhook: attempt %d for %s failed: %s; retrying in %s", msg.Attempt, what, errorSummary(msg.Err), wait))
+	return tea.Tick(wait, func(time.Time) tea.Msg {
+		return webhookCmd(msg.URL, msg.Template, msg.Event, msg.Attempt+1)()
+	})
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// version is the release version, overridden at build time with
+// -ldflags "-X main.version=1.2.3".
+var version = "0.2.0"
+
+// changelogEntry lists the notable user-facing changes in one release.
+type changelogEntry struct {
+	Version string
+	Items   []string
+}
+
+// changelog is shown in the what's-new overlay after an upgrade, newest
+// release first. Add an item here for every new key or feature.
+var changelog = []changelogEntry{
+	{"0.2.0", []string{
+		"enter device details with lifetime records",
+		"History chart in device details, ← → to pick the sensor",
+		"US EPA AQI next to PM2.5, and in device details",
+		"f focus one sensor across all devices",
+		"z zoom a device with sparklines and history stats",
+		"t table view, one row per device",
+		"o sort devices by name, score or worst alert (saved)",
+		"n rename, x remove, [ ] reorder devices (saved)",
+		"p pause polling, + / - change the interval",
+		"u switch °C/°F (saved)",
+		"? keybinding help, l scrollable log",
+		"F pick from devices found beyond --max-discovered",
+		"First run lists discovered devices to pick from",
+		"Mini view for short terminals (--mini)",
+		"Alert badges on device cards",
+		"Offline devices turn gray with how long ago they were last seen",
+		"Unreachable devices are retried less and less often; r retries now",
+		"--once, --check, --events and --print-config",
+		"--notify desktop notifications when a sensor turns poor",
+		"--alert-webhook to POST sensors turning poor to a URL",
+		"--alert-exec to run a command when a sensor turns poor",
+		"Windows: config in %AppData%, toast notifications, ASCII bars in the legacy console",
+		"Bell and red card flash when a sensor turns poor (--no-bell, --no-flash)",
+		"Dew point and absolute humidity calculated when the device omits them",
+		"kill -QUIT writes a diagnostics file for hang reports",
+		"Mold risk and ventilate hints in device details",
+		"--smooth-score to show a median score on cards",
+		"--log-file with --log-level",
+		"--http-timeout and --http-retries for slow or flaky devices",
+		"Add devices by host name, such as awair-bedroom.local",
+		"Devices on a port other than 80: 192.168.1.100:8080",
+		"Proxied devices by URL, with HTTPS and basic auth",
+		"Devices that come online are found as soon as they announce themselves",
+		"--scan and S probe a subnet for devices where mDNS is blocked",
+		"--discovery-services, --discovery-match and --discovery-interval tune mDNS discovery",
+		"--interface picks the network interface mDNS discovery uses",
+		"Devices that get a new IP address are moved in place, matched by UUID",
+		"--remember-discovered adds discovered devices at startup",
+		"The config lives in ~/.config/awair-tui (or --config); the old file is copied over",
+		"A config that fails to load is reported and never overwritten",
+		"\"no_discovery\" in the config, and plain addresses in its device list",
+		"Edits to the config file are picked up while running; ctrl+r reloads it",
+		"\"card_sensors\" in the config picks and orders the sensors on grid cards",
+		"--theme: default, colorblind (with rating marks) and light, plus custom colors",
+		"--no-color (or NO_COLOR) and --ascii, which now also draws plain borders",
+		"Long names with emoji or CJK characters are shortened cleanly with …",
+		"Terminals too small for the dashboard get a message instead of a broken layout",
+		"The status bar counts down to the next poll and shows failing devices and discovery state",
+		"Cards show each device's poll latency, highlighted above --slow-latency; --once --json and --events report it as latency_ms",
+		"Cards show when the device took its sample and when it last answered; repeated samples no longer count as updates",
+		"Cards warn when a device clock is off by more than --max-clock-skew; --export-time device exports readings at device time",
+		"Omni and Mint devices show light and sound level, and sensors a device lacks are hidden instead of showing 0",
+		"Device configs refresh hourly and on r, firmware and Wi-Fi changes are logged, and --show-firmware puts the version on cards",
+		"D in the device details cycles what the device display shows; --set-display <mode> does it from scripts",
+		"L and [ ] in the device details change the LED mode and brightness, checked by reading them back",
+		"--cloud-token polls your Awair account's devices through the cloud, tagged ☁, next to the local ones",
+		"Cloud devices keep within their daily quota across restarts, slow down to make it last, and say when it is exhausted",
+		"New devices start with recent history from their 5-min-avg endpoint; --history-endpoint picks 15-min-avg or off",
+		"--poll-endpoint (or a device's \"endpoint\") polls 10-second or 5-minute averages instead of the latest sample",
+		"--db <path> saves readings to SQLite and reloads the last 24 hours at startup; --db-retain purges old ones",
+		"--db compacts readings older than --db-raw into 5-minute min/mean/max rows; --compact-now does it right away",
+		"s opens a summary of each device: min/avg/max per sensor, time CO₂ and PM2.5 spent poor and the lowest score",
+		"y copies the selected device's address and Y in the detail view its reading and config as JSON, over SSH too (OSC 52)",
+		"Mouse support: click a device to select it, double-click to open it, click status bar hints, and scroll the log with the wheel; --no-mouse turns it off",
+		"--demo N shows N simulated devices with drifting readings, for trying the app or taking screenshots without hardware",
+		"The device client and mDNS discovery are importable Go packages: pkg/awair and pkg/discovery",
+		"--serve :8080 serves the latest readings as JSON at /devices, /devices/<ip> and /healthz",
+		"--serve also streams new readings as Server-Sent Events at /events",
+		"--events reports alerts firing, changing and clearing, as the dashboard does",
+		"Webhook bodies and --alert-exec arguments can be text/templates in the config; --test-alert tries them out",
+	}},
+	{"0.1.0", []string{"Initial release"}},
+}
+
+// parseVersion splits "v1.2.3" into its numeric parts. ok is false for
+// anything else, such as development builds.
+func parseVersion(v string) (parts [3]int, ok bool) {
+	fields := strings.Split(strings.TrimPrefix(v, "v"), ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// compareVersions returns -1, 0 or 1 as a is older than, the same as or
+// newer than b. Unparseable versions compare equal to everything.
+func compareVersions(a, b string) int {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA || !okB {
+		return 0
+	}
+	for i := range pa {
+		switch {
+		case pa[i] < pb[i]:
+			return -1
+		case pa[i] > pb[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// changesSince returns the changelog entries newer than last, up to and
+// including the running version.
+func changesSince(last string) []changelogEntry {
+	var out []changelogEntry
+	for _, e := range changelog {
+		if compareVersions(e.Version, last) > 0 && compareVersions(e.Version, version) <= 0 {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// checkUpgrade records the running version in cfg and returns the
+// changes to show if it is newer than the last one seen. Nothing is shown
+// on first run or when "whats_new" is false.
+func checkUpgrade(cfg *Config) []changelogEntry {
+	last := cfg.LastSeenVersion
+	if last == version {
+		return nil
+	}
+	cfg.LastSeenVersion = version
+	if err := SaveConfig(cfg); err != nil {
+		logf(levelWarn, "saving config: %v", err)
+	}
+
+	if last == "" || (cfg.WhatsNew != nil && !*cfg.WhatsNew) || compareVersions(version, last) <= 0 {
+		return nil
+	}
+	return changesSince(last)
+}
+
+func (m model) handleWhatsNewKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "enter", " ":
+		m.whatsNew = nil
+	case "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// overlayWhatsNew renders the changes since the last run, truncated to
+// the grid area.
+func (m model) overlayWhatsNew(gridHeight int) string {
+	var lines []string
+	for i, e := range m.whatsNew {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).Render(e.Version))
+		for _, item := range e.Items {
+			lines = append(lines, "  • "+item)
+		}
+	}
+	// Border (2) + title + footer lines
+	if limit := gridHeight - 4; limit > 0 && len(lines) > limit {
+		lines = append(lines[:limit-1], "  …")
+	}
+
+	width := 60
+	if width > m.width-2 {
+		width = m.width - 2
+	}
+	return lipgloss.Place(m.width, gridHeight,
+		lipgloss.Center, lipgloss.Center,
+		lipgloss.NewStyle().
+			Width(width).
+			Border(glyphs.Border).
+			BorderForeground(theme.Accent).
+			Padding(0, 1).
+			Render(lipgloss.NewStyle().Bold(true).Render("What's new in "+version)+"\n"+
+				lipgloss.NewStyle().MaxWidth(width-2).Render(strings.Join(lines, "\n"))+"\n"+
+				lipgloss.NewStyle().Foreground(theme.Muted).Render("enter close")))
+}
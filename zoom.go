@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/xxdesmus/awair-tui/pkg/awair"
+)
+
+// sparkline renders the last width values scaled between their minimum
+// and maximum. A flat series is drawn mid-height.
+func sparkline(values []float64, width int) string {
+	if width <= 0 || len(values) == 0 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	levels := glyphs.Spark
+	var b strings.Builder
+	for _, v := range values {
+		i := len(levels) / 2
+		if hi > lo {
+			i = int((v - lo) / (hi - lo) * float64(len(levels)-1))
+		}
+		b.WriteRune(levels[i])
+	}
+	return b.String()
+}
+
+// historyValues returns the stored values of one sensor, oldest first.
+func historyValues(h History, key string) []float64 {
+	var out []float64
+	for _, s := range h.Samples {
+		for _, r := range s.Data.Readings() {
+			if r.Key == key {
+				out = append(out, r.Value)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (m model) handleZoomKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "z":
+		m.zoomID = 0
+	case "u":
+		m.toggleUnits()
+	case "q", "ctrl+c":
+		return m, m.quit()
+	}
+	return m, nil
+}
+
+// renderZoom renders the selected device across the whole grid area, with
+// wide bars, a sparkline and min/avg/max of the stored history per sensor.
+func (m model) renderZoom(height int) string {
+	dev := m.device(m.zoomID)
+	if dev == nil {
+		return m.renderEmptyState(height)
+	}
+
+	// Border (2) + padding (2)
+	inner := m.width - 4
+	header := lipgloss.NewStyle().Bold(true).Foreground(theme.Accent).
+		Render(dev.Label())
+	if badge := renderAlertBadge(dev.Alerts); badge != "" {
+		header += " " + badge
+	}
+
+	lines := []string{header, ""}
+	switch {
+	case dev.LastError != nil && dev.Data == nil:
+		lines = append(lines, lipgloss.NewStyle().Foreground(theme.Poor).Render("Error: "+errorSummary(dev.LastError)))
+	case dev.Data == nil:
+		lines = append(lines, lipgloss.NewStyle().Foreground(theme.Fair).Render("Connecting..."))
+	default:
+		d := dev.Data
+		shown := m.shownScore(dev)
+		sc := scoreColor(shown)
+		lines = append(lines,
+			fmt.Sprintf("%s    %s",
+				lipgloss.NewStyle().Bold(true).Render("Awair Score"),
+				lipgloss.NewStyle().Bold(true).Foreground(sc).Render(fmt.Sprintf("%d %s", shown, scoreLabel(shown)))),
+			renderGauge(shown, inner, sc),
+			"")
+
+		// label (14) + value (12) + gaps, then the rest split between the
+		// bar, the sparkline and the stats (~34)
+		rest := inner - 14 - 1 - 12 - 2 - 2 - 34
+		barWidth := rest / 2
+		sparkWidth := rest - barWidth
+		for _, s := range d.Readings() {
+			val := awair.DisplayValue(s.Key, s.Value)
+			rating := awair.RateSensorValue(s.Key, val)
+			color := ratingColor(rating)
+			line := lipgloss.NewStyle().Bold(true).Render(visPadRight(awair.OptimalRanges[s.Key].Label, 14)) + " " +
+				lipgloss.NewStyle().Foreground(color).Render(visPadLeft(ratingMark(rating)+awair.FormatValue(s.Key, s.Value, m.fahrenheit), 12))
+			if barWidth > 0 {
+				line += "  " + renderSensorBar(s.Key, val, barWidth, color)
+			}
+
+			hist := historyValues(dev.History, s.Key)
+			if sparkWidth > 0 {
+				line += "  " + lipgloss.NewStyle().Foreground(theme.Accent).Render(visPadRight(sparkline(hist, sparkWidth), sparkWidth))
+			}
+			if len(hist) > 1 {
+				lo, hi, sum := hist[0], hist[0], 0.0
+				for _, v := range hist {
+					lo, hi, sum = math.Min(lo, v), math.Max(hi, v), sum+v
+				}
+				line += lipgloss.NewStyle().Foreground(theme.Muted).Render(fmt.Sprintf("  %s / %s / %s",
+					awair.FormatValue(s.Key, lo, m.fahrenheit),
+					awair.FormatValue(s.Key, sum/float64(len(hist)), m.fahrenheit),
+					awair.FormatValue(s.Key, hi, m.fahrenheit)))
+			}
+			lines = append(lines, line)
+		}
+
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(theme.Muted).Render(fmt.Sprintf(
+			"Raw VOC: H₂ %s  ethanol %s  baseline %s   ·   %d samples, min / avg / max",
+			optFloat(d.VOCH2Raw), optFloat(d.VOCEthanolRaw), optFloat(d.VOCBaseline), len(dev.History.Samples))))
+	}
+
+	lines = append(lines, "", lipgloss.NewStyle().Foreground(theme.Muted).Render("z/esc back  u °C/°F"))
+
+	return lipgloss.NewStyle().
+		Width(m.width-2).
+		Height(height-2).
+		MaxHeight(height).
+		Border(glyphs.Selected).
+		BorderForeground(theme.Accent).
+		Padding(0, 1).
+		Render(lipgloss.NewStyle().MaxWidth(inner).Render(strings.Join(lines, "\n")))
+}